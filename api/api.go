@@ -0,0 +1,533 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikefero/tpl/db"
+	"github.com/mikefero/tpl/log"
+)
+
+const basePath = "/api/v1"
+const apiTokenKey = "apiToken"
+const defaultLimit = 25
+const maximumLimit = 100
+
+type errorBody struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+type pagination struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+	Total  int `json:"total"`
+}
+
+type listResponse struct {
+	Data       interface{} `json:"data"`
+	Pagination pagination  `json:"pagination"`
+}
+
+type dataResponse struct {
+	Data interface{} `json:"data"`
+}
+
+type responseKind int
+
+const (
+	responseObject responseKind = iota
+	responseList
+	responsePage
+)
+
+type queryParameter struct {
+	Name        string
+	Type        string
+	Description string
+	Required    bool
+}
+
+type route struct {
+	Method   string
+	Path     string
+	Summary  string
+	Handler  gin.HandlerFunc
+	Scope    string
+	Query    []queryParameter
+	Request  interface{}
+	Upload   string
+	Status   int
+	Model    interface{}
+	Response responseKind
+}
+
+var routes = []route{
+	{
+		Method:  http.MethodGet,
+		Path:    "/machines",
+		Summary: "List active machines",
+		Handler: handleMachines,
+		Scope:   db.ScopeReadMachines,
+		Query: []queryParameter{
+			{Name: "manufacturer_id", Type: "integer", Description: "Only machines from this manufacturer"},
+			{Name: "name", Type: "string", Description: "Only machines whose name contains this text"},
+			{Name: "decade", Type: "integer", Description: "Only machines manufactured during this decade, e.g. 1990"},
+			{Name: "feature", Type: "string", Description: "Only machines with this OPDB feature, e.g. Premium edition"},
+			{Name: "sort", Type: "string", Description: "Sort by name (default), year or manufacturer"},
+		},
+		Model:    Machine{},
+		Response: responsePage,
+	},
+	{
+		Method:  http.MethodGet,
+		Path:    "/machines/:opdb_id",
+		Summary: "Get a machine from the OPDB catalog",
+		Handler: handleMachine,
+		Scope:   db.ScopeReadMachines,
+		Model:   Machine{},
+	},
+	{
+		Method:  http.MethodGet,
+		Path:    "/machines/:opdb_id/difficulty",
+		Summary: "Get the difficulty of a machine from its league results",
+		Handler: handleDifficulty,
+		Scope:   db.ScopeReadMachines,
+		Model:   MachineDifficulty{},
+	},
+	{
+		Method:   http.MethodGet,
+		Path:     "/difficulty",
+		Summary:  "Rank the machines played in the league from swingy to skill-heavy",
+		Handler:  handleDifficulties,
+		Scope:    db.ScopeReadMachines,
+		Model:    MachineDifficulty{},
+		Response: responseList,
+	},
+	{
+		Method:  http.MethodGet,
+		Path:    "/compare/players",
+		Summary: "Compare the league record of two players against each other",
+		Handler: handleComparePlayers,
+		Scope:   db.ScopeReadLeagues,
+		Query: []queryParameter{
+			{Name: "a", Type: "integer", Description: "The player whose point of view is used", Required: true},
+			{Name: "b", Type: "integer", Description: "The opposing player", Required: true},
+		},
+		Model: Rivalry{},
+	},
+	{
+		Method:  http.MethodGet,
+		Path:    "/compare/teams",
+		Summary: "Compare the league record of two teams against each other",
+		Handler: handleCompareTeams,
+		Scope:   db.ScopeReadLeagues,
+		Query: []queryParameter{
+			{Name: "a", Type: "integer", Description: "The team whose point of view is used", Required: true},
+			{Name: "b", Type: "integer", Description: "The opposing team", Required: true},
+		},
+		Model: Rivalry{},
+	},
+	{
+		Method:  http.MethodGet,
+		Path:    "/search",
+		Summary: "Search the OPDB catalog by machine name, manufacturer and features",
+		Handler: handleSearch,
+		Scope:   db.ScopeReadMachines,
+		Query: []queryParameter{
+			{Name: "q", Type: "string", Description: "Search terms; each term matches as a prefix and tolerates typos"},
+		},
+		Model:    SearchResult{},
+		Response: responsePage,
+	},
+	{
+		Method:  http.MethodGet,
+		Path:    "/machine-matches",
+		Summary: "Match a free text machine name to the OPDB catalog",
+		Handler: handleMachineMatches,
+		Scope:   db.ScopeReadMachines,
+		Query: []queryParameter{
+			{Name: "name", Type: "string", Description: "Machine name, e.g. Godzilla (Premium) or TMNT Pro", Required: true},
+			{Name: "limit", Type: "integer", Description: "Number of candidates, 5 by default and at most 25"},
+		},
+		Model:    MachineMatch{},
+		Response: responseList,
+	},
+	{
+		Method:   http.MethodGet,
+		Path:     "/lineup",
+		Summary:  "List the machine lineup including machines removed by hand",
+		Handler:  handleLineup,
+		Scope:    db.ScopeReadMachines,
+		Model:    LineupEntry{},
+		Response: responseList,
+	},
+	{
+		Method:  http.MethodPost,
+		Path:    "/lineup",
+		Summary: "Add a machine to the lineup by hand",
+		Handler: handleAddToLineup,
+		Scope:   db.ScopeWriteLineup,
+		Request: LineupRequest{},
+		Model:   LineupEntry{},
+	},
+	{
+		Method:  http.MethodDelete,
+		Path:    "/lineup/:opdb_id",
+		Summary: "Remove a machine from the lineup by hand",
+		Handler: handleRemoveFromLineup,
+		Scope:   db.ScopeWriteLineup,
+		Model:   LineupEntry{},
+	},
+	{
+		Method:  http.MethodDelete,
+		Path:    "/lineup/:opdb_id/override",
+		Summary: "Return a machine to the lineup state listed by Pinball Map",
+		Handler: handleClearLineupOverride,
+		Scope:   db.ScopeWriteLineup,
+		Model:   LineupEntry{},
+	},
+	{
+		Method:  http.MethodGet,
+		Path:    "/leagues",
+		Summary: "List leagues",
+		Handler: handleLeagues,
+		Scope:   db.ScopeReadLeagues,
+		Query: []queryParameter{
+			{Name: "active", Type: "boolean", Description: "Only leagues with this active state"},
+		},
+		Model:    League{},
+		Response: responsePage,
+	},
+	{
+		Method:  http.MethodGet,
+		Path:    "/leagues/:id",
+		Summary: "Get a league",
+		Handler: handleLeague,
+		Scope:   db.ScopeReadLeagues,
+		Model:   League{},
+	},
+	{
+		Method:   http.MethodGet,
+		Path:     "/leagues/:id/seasons",
+		Summary:  "List the seasons of a league",
+		Handler:  handleSeasons,
+		Scope:    db.ScopeReadLeagues,
+		Model:    Season{},
+		Response: responsePage,
+	},
+	{
+		Method:   http.MethodGet,
+		Path:     "/leagues/:id/teams",
+		Summary:  "List the teams of a league",
+		Handler:  handleTeams,
+		Scope:    db.ScopeReadLeagues,
+		Model:    Team{},
+		Response: responsePage,
+	},
+	{
+		Method:   http.MethodGet,
+		Path:     "/leagues/:id/subs",
+		Summary:  "List the sub pool of a league",
+		Handler:  handleLeagueSubs,
+		Scope:    db.ScopeReadLeagues,
+		Model:    LeagueSub{},
+		Response: responseList,
+	},
+	{
+		Method:  http.MethodGet,
+		Path:    "/seasons/:id",
+		Summary: "Get a season",
+		Handler: handleSeason,
+		Scope:   db.ScopeReadLeagues,
+		Model:   Season{},
+	},
+	{
+		Method:   http.MethodGet,
+		Path:     "/seasons/:id/standings",
+		Summary:  "Get the team standings of a season",
+		Handler:  handleStandings,
+		Scope:    db.ScopeReadLeagues,
+		Model:    Standing{},
+		Response: responseList,
+	},
+	{
+		Method:   http.MethodGet,
+		Path:     "/seasons/:id/swiss-standings",
+		Summary:  "Rank the teams of a season by Swiss score with Buchholz and strength of schedule tiebreaks",
+		Handler:  handleSwissStandings,
+		Scope:    db.ScopeReadLeagues,
+		Model:    SwissStanding{},
+		Response: responseList,
+	},
+	{
+		Method:   http.MethodGet,
+		Path:     "/seasons/:id/usage",
+		Summary:  "Get how often each machine was played per week of a season",
+		Handler:  handleSeasonUsage,
+		Scope:    db.ScopeReadLeagues,
+		Model:    MachineUsage{},
+		Response: responseList,
+	},
+	{
+		Method:  http.MethodGet,
+		Path:    "/seasons/:id/schedule",
+		Summary: "List the scheduled matches of a season",
+		Handler: handleSchedule,
+		Scope:   db.ScopeReadLeagues,
+		Query: []queryParameter{
+			{Name: "team_id", Type: "integer", Description: "Only matches played by this team"},
+			{Name: "week", Type: "integer", Description: "Only matches played during this week"},
+		},
+		Model:    Match{},
+		Response: responsePage,
+	},
+	{
+		Method:  http.MethodGet,
+		Path:    "/matches/:id",
+		Summary: "Get a match",
+		Handler: handleMatch,
+		Scope:   db.ScopeReadLeagues,
+		Model:   Match{},
+	},
+	{
+		Method:   http.MethodGet,
+		Path:     "/matches/:id/selections",
+		Summary:  "List the machines selected for the games of a match",
+		Handler:  handleSelections,
+		Scope:    db.ScopeReadLeagues,
+		Model:    Selection{},
+		Response: responseList,
+	},
+	{
+		Method:  http.MethodPost,
+		Path:    "/matches/:id/selections",
+		Summary: "Pick or draw the machine for the next game of a match",
+		Handler: handleCreateSelection,
+		Scope:   db.ScopeWriteResults,
+		Request: SelectionRequest{},
+		Status:  http.StatusCreated,
+		Model:   Selection{},
+	},
+	{
+		Method:   http.MethodGet,
+		Path:     "/tournaments",
+		Summary:  "List tournaments with unfinished tournaments first",
+		Handler:  handleTournaments,
+		Scope:    db.ScopeReadLeagues,
+		Model:    Tournament{},
+		Response: responsePage,
+	},
+	{
+		Method:  http.MethodGet,
+		Path:    "/tournaments/:id",
+		Summary: "Get a tournament",
+		Handler: handleTournament,
+		Scope:   db.ScopeReadLeagues,
+		Model:   Tournament{},
+	},
+	{
+		Method:   http.MethodGet,
+		Path:     "/tournaments/:id/standings",
+		Summary:  "Get the live standings of a tournament",
+		Handler:  handleTournamentStandings,
+		Scope:    db.ScopeReadLeagues,
+		Model:    TournamentStanding{},
+		Response: responseList,
+	},
+	{
+		Method:   http.MethodGet,
+		Path:     "/tournaments/:id/games",
+		Summary:  "List the games of a tournament by round",
+		Handler:  handleTournamentGames,
+		Scope:    db.ScopeReadLeagues,
+		Model:    TournamentGame{},
+		Response: responseList,
+	},
+	{
+		Method:   http.MethodGet,
+		Path:     "/tournaments/:id/qualifying",
+		Summary:  "Get the qualifying leaderboard of a qualifying tournament",
+		Handler:  handleTournamentQualifying,
+		Scope:    db.ScopeReadLeagues,
+		Model:    Qualifier{},
+		Response: responseList,
+	},
+	{
+		Method:  http.MethodGet,
+		Path:    "/results",
+		Summary: "List machine results",
+		Handler: handleResults,
+		Scope:   db.ScopeReadLeagues,
+		Query: []queryParameter{
+			{Name: "match_id", Type: "integer", Description: "Only results from this match"},
+			{Name: "opdb_id", Type: "string", Description: "Only results played on this machine"},
+		},
+		Model:    Result{},
+		Response: responsePage,
+	},
+	{
+		Method:  http.MethodPost,
+		Path:    "/results",
+		Summary: "Record a machine result for a match",
+		Handler: handleCreateResult,
+		Scope:   db.ScopeWriteResults,
+		Request: ResultRequest{},
+		Status:  http.StatusCreated,
+		Model:   Result{},
+	},
+	{
+		Method:  http.MethodPut,
+		Path:    "/results/:id/photo",
+		Summary: "Attach a photo of the scores to a result",
+		Handler: handleUploadResultPhoto,
+		Scope:   db.ScopeWriteResults,
+		Upload:  "photo",
+		Model:   Result{},
+	},
+}
+
+func abortWithError(ctx *gin.Context, status int, message string) {
+	ctx.AbortWithStatusJSON(status, errorResponse{
+		Error: errorBody{
+			Status:  status,
+			Message: message,
+		},
+	})
+}
+
+func getPagination(ctx *gin.Context) (pagination, bool) {
+	var page pagination
+	var ok bool
+	if page.Limit, ok = getQueryInt(ctx, "limit", defaultLimit); !ok {
+		return page, false
+	}
+	if page.Limit < 1 || page.Limit > maximumLimit {
+		abortWithError(ctx, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maximumLimit))
+		return page, false
+	}
+	if page.Offset, ok = getQueryInt(ctx, "offset", 0); !ok {
+		return page, false
+	}
+	if page.Offset < 0 {
+		abortWithError(ctx, http.StatusBadRequest, "offset must not be negative")
+		return page, false
+	}
+
+	return page, true
+}
+
+func getQueryInt(ctx *gin.Context, key string, defaultValue int) (int, bool) {
+	value, exists := ctx.GetQuery(key)
+	if !exists {
+		return defaultValue, true
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, key+" must be an integer")
+		return 0, false
+	}
+
+	return number, true
+}
+
+func getParamInt(ctx *gin.Context, key string) (int, bool) {
+	number, err := strconv.Atoi(ctx.Param(key))
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, key+" must be an integer")
+		return 0, false
+	}
+
+	return number, true
+}
+
+func respondWithList(ctx *gin.Context, data interface{}, page pagination) {
+	ctx.JSON(http.StatusOK, listResponse{
+		Data:       data,
+		Pagination: page,
+	})
+}
+
+func authenticate(ctx *gin.Context) {
+	authorization := ctx.GetHeader("Authorization")
+	if len(authorization) == 0 {
+		ctx.Next()
+		return
+	}
+
+	secret := strings.TrimPrefix(authorization, "Bearer ")
+	token := db.AuthenticateApiToken(secret)
+	if secret == authorization || token == nil {
+		ctx.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		abortWithError(ctx, http.StatusUnauthorized, "invalid API token")
+		return
+	}
+	ctx.Set(apiTokenKey, token)
+	ctx.Next()
+}
+
+// isReadScope tells whether routes requiring a scope are open to requests
+// without an API token; tokens sent to them must still carry the scope.
+func isReadScope(scope string) bool {
+	return strings.HasPrefix(scope, "read:")
+}
+
+func requireScope(scope string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		value, exists := ctx.Get(apiTokenKey)
+		if !exists && isReadScope(scope) {
+			ctx.Next()
+			return
+		}
+		if !exists {
+			ctx.Header("WWW-Authenticate", `Bearer scope="`+scope+`"`)
+			abortWithError(ctx, http.StatusUnauthorized, "an API token is required")
+			return
+		}
+		if !value.(*db.ApiToken).HasScope(scope) {
+			ctx.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+scope+`"`)
+			abortWithError(ctx, http.StatusForbidden, "API token is missing the "+scope+" scope")
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// getTokenUser returns the user of the API token of a write request, aborting
+// the request when the user no longer exists.
+func getTokenUser(ctx *gin.Context) *db.User {
+	value, _ := ctx.Get(apiTokenKey)
+	user := db.GetUser(value.(*db.ApiToken).UserId)
+	if user == nil {
+		abortWithError(ctx, http.StatusForbidden, "API token user no longer exists")
+	}
+	return user
+}
+
+func handleNoRoute(ctx *gin.Context) {
+	if strings.HasPrefix(ctx.Request.URL.Path, "/api/") {
+		abortWithError(ctx, http.StatusNotFound, "resource not found")
+	}
+}
+
+func Initialize(router *gin.Engine) {
+	log.Debug("initializing API endpoints")
+	spec := newOpenAPI(routes)
+	router.GET("/api/openapi.json", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, spec)
+	})
+
+	v1 := router.Group(basePath)
+	v1.Use(authenticate)
+	for _, route := range routes {
+		v1.Handle(route.Method, route.Path, requireScope(route.Scope), route.Handler)
+	}
+	router.NoRoute(handleNoRoute)
+	log.Debug("API endpoints initialized")
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mikefero/tpl/db"
+)
+
+func TestRequireScope(t *testing.T) {
+	router := newTestRouter()
+	readToken, _ := db.CreateApiToken(2, "read", []string{db.ScopeReadMachines})
+	writeToken, _ := db.CreateApiToken(2, "write", []string{db.ScopeWriteResults})
+
+	for _, test := range []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		status int
+	}{
+		{"anonymous read", http.MethodGet, "/machines", "", http.StatusOK},
+		{"read with scope", http.MethodGet, "/machines", readToken, http.StatusOK},
+		{"read without scope", http.MethodGet, "/leagues", readToken, http.StatusForbidden},
+		{"read with invalid token", http.MethodGet, "/machines", "tpl_invalid", http.StatusUnauthorized},
+		{"anonymous write", http.MethodPost, "/results", "", http.StatusUnauthorized},
+		{"write without scope", http.MethodPost, "/lineup", writeToken, http.StatusForbidden},
+		{"write with scope", http.MethodPost, "/results", writeToken, http.StatusBadRequest},
+	} {
+		request := httptest.NewRequest(test.method, basePath+test.path, strings.NewReader("{}"))
+		if len(test.token) > 0 {
+			request.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if recorder.Code != test.status {
+			t.Errorf("%s: expected %d, got %d: %s", test.name, test.status, recorder.Code, recorder.Body.String())
+		}
+	}
+}
+
+func TestCreateResultRequiresMatchPlayer(t *testing.T) {
+	router := newTestRouter()
+	// Bob plays for Flippers while Eve is a sub on neither team of match 1;
+	// the machine was not selected, so a permitted request stops there
+	body := `{"match_id": 1, "opdb_id": "GrXzD-MjBPX", "team_1": {"a_player": {"player_id": 1, "score": 1000}}, "team_2": {"a_player": {"player_id": 3, "score": 2000}}}`
+
+	for _, test := range []struct {
+		name   string
+		userId int
+		status int
+	}{
+		{"staff", 1, http.StatusUnprocessableEntity},
+		{"player of the match", 2, http.StatusUnprocessableEntity},
+		{"player outside the match", 5, http.StatusForbidden},
+	} {
+		token, _ := db.CreateApiToken(test.userId, "results", []string{db.ScopeWriteResults})
+		request := httptest.NewRequest(http.MethodPost, basePath+"/results", strings.NewReader(body))
+		request.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if recorder.Code != test.status {
+			t.Errorf("%s: expected %d, got %d: %s", test.name, test.status, recorder.Code, recorder.Body.String())
+		}
+	}
+}
+
+func TestUploadResultPhotoRequiresStaff(t *testing.T) {
+	router := newTestRouter()
+	// Bob plays in match 1 but only staff can replace the photo of its results
+	token, _ := db.CreateApiToken(2, "photos", []string{db.ScopeWriteResults})
+	upload, contentType := newPhotoUpload(t, "photo")
+	request := httptest.NewRequest(http.MethodPut, basePath+"/results/1/photo", upload)
+	request.Header.Set("Content-Type", contentType)
+	request.Header.Set("Authorization", "Bearer "+token)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("expected %d, got %d: %s", http.StatusForbidden, recorder.Code, recorder.Body.String())
+	}
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikefero/tpl/db"
+	"github.com/mikefero/tpl/stats"
+)
+
+func getComparedIds(ctx *gin.Context) (int, int, bool) {
+	var ids [2]int
+	for i, key := range []string{"a", "b"} {
+		if _, exists := ctx.GetQuery(key); !exists {
+			abortWithError(ctx, http.StatusBadRequest, key+" is required")
+			return 0, 0, false
+		}
+		id, ok := getQueryInt(ctx, key, 0)
+		if !ok {
+			return 0, 0, false
+		}
+		ids[i] = id
+	}
+	if ids[0] == ids[1] {
+		abortWithError(ctx, http.StatusBadRequest, "a and b must be different")
+		return 0, 0, false
+	}
+
+	return ids[0], ids[1], true
+}
+
+func handleComparePlayers(ctx *gin.Context) {
+	a, b, ok := getComparedIds(ctx)
+	if !ok {
+		return
+	}
+	if db.GetUser(a) == nil || db.GetUser(b) == nil {
+		abortWithError(ctx, http.StatusNotFound, "player not found")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newRivalry(a, b, stats.GetPlayerRivalry(a, b)))
+}
+
+func handleCompareTeams(ctx *gin.Context) {
+	a, b, ok := getComparedIds(ctx)
+	if !ok {
+		return
+	}
+	if db.GetTeam(a) == nil || db.GetTeam(b) == nil {
+		abortWithError(ctx, http.StatusNotFound, "team not found")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newRivalry(a, b, stats.GetTeamRivalry(a, b)))
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikefero/tpl/db"
+	"github.com/mikefero/tpl/tournament"
+)
+
+func handleLeagues(ctx *gin.Context) {
+	page, ok := getPagination(ctx)
+	if !ok {
+		return
+	}
+
+	var active sql.NullBool
+	if value, exists := ctx.GetQuery("active"); exists {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			abortWithError(ctx, http.StatusBadRequest, "active must be a boolean")
+			return
+		}
+		active = sql.NullBool{
+			Bool:  parsed,
+			Valid: true,
+		}
+	}
+
+	page.Total = db.CountLeagues(active)
+	respondWithList(ctx, newLeagues(db.GetLeagues(active, page.Limit, page.Offset)), page)
+}
+
+func getLeague(ctx *gin.Context) *db.League {
+	id, ok := getParamInt(ctx, "id")
+	if !ok {
+		return nil
+	}
+	league := db.GetLeague(id)
+	if league == nil {
+		abortWithError(ctx, http.StatusNotFound, "league not found")
+	}
+
+	return league
+}
+
+func handleLeague(ctx *gin.Context) {
+	league := getLeague(ctx)
+	if league == nil {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newLeague(*league))
+}
+
+func handleSeasons(ctx *gin.Context) {
+	league := getLeague(ctx)
+	if league == nil {
+		return
+	}
+	page, ok := getPagination(ctx)
+	if !ok {
+		return
+	}
+
+	page.Total = db.CountSeasons(league.Id)
+	respondWithList(ctx, newSeasons(db.GetSeasons(league.Id, page.Limit, page.Offset)), page)
+}
+
+func handleTeams(ctx *gin.Context) {
+	league := getLeague(ctx)
+	if league == nil {
+		return
+	}
+	page, ok := getPagination(ctx)
+	if !ok {
+		return
+	}
+
+	page.Total = db.CountTeams(league.Id)
+	respondWithList(ctx, newTeams(db.GetTeams(league.Id, page.Limit, page.Offset)), page)
+}
+
+func handleLeagueSubs(ctx *gin.Context) {
+	league := getLeague(ctx)
+	if league == nil {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dataResponse{
+		Data: newLeagueSubs(db.GetLeagueSubs(league.Id)),
+	})
+}
+
+func getSeason(ctx *gin.Context) *db.Season {
+	id, ok := getParamInt(ctx, "id")
+	if !ok {
+		return nil
+	}
+	season := db.GetSeason(id)
+	if season == nil {
+		abortWithError(ctx, http.StatusNotFound, "season not found")
+	}
+
+	return season
+}
+
+func handleSeason(ctx *gin.Context) {
+	season := getSeason(ctx)
+	if season == nil {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newSeason(*season))
+}
+
+func handleStandings(ctx *gin.Context) {
+	season := getSeason(ctx)
+	if season == nil {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dataResponse{
+		Data: newStandings(db.GetStandings(season.Id)),
+	})
+}
+
+func handleSwissStandings(ctx *gin.Context) {
+	season := getSeason(ctx)
+	if season == nil {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dataResponse{
+		Data: newSwissStandings(tournament.GetSeasonStandings(*season)),
+	})
+}
+
+func handleSeasonUsage(ctx *gin.Context) {
+	season := getSeason(ctx)
+	if season == nil {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dataResponse{
+		Data: newMachineUsage(db.GetSeasonMachineUsage(season.Id), db.GetSeasonWeeks(season.Id)),
+	})
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikefero/tpl/db"
+)
+
+func handleLineup(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, dataResponse{
+		Data: newLineup(db.GetLineup()),
+	})
+}
+
+func respondWithLineupEntry(ctx *gin.Context, opdbId string) {
+	entry := db.GetLineupEntry(opdbId)
+	if entry == nil {
+		abortWithError(ctx, http.StatusInternalServerError, "unable to read lineup")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newLineupEntry(*entry))
+}
+
+func handleAddToLineup(ctx *gin.Context) {
+	var request LineupRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		abortWithError(ctx, http.StatusBadRequest, "invalid lineup change: "+err.Error())
+		return
+	}
+	if !db.SetLineupOverride(request.OpdbId, true) {
+		abortWithError(ctx, http.StatusUnprocessableEntity, "machine does not exist")
+		return
+	}
+
+	respondWithLineupEntry(ctx, request.OpdbId)
+}
+
+func handleRemoveFromLineup(ctx *gin.Context) {
+	opdbId := ctx.Param("opdb_id")
+	if !db.SetLineupOverride(opdbId, false) {
+		abortWithError(ctx, http.StatusNotFound, "machine not found")
+		return
+	}
+
+	respondWithLineupEntry(ctx, opdbId)
+}
+
+func handleClearLineupOverride(ctx *gin.Context) {
+	opdbId := ctx.Param("opdb_id")
+	if !db.ClearLineupOverride(opdbId) {
+		abortWithError(ctx, http.StatusNotFound, "machine has no lineup override")
+		return
+	}
+
+	respondWithLineupEntry(ctx, opdbId)
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikefero/tpl/db"
+	"github.com/mikefero/tpl/stats"
+)
+
+const defaultMachineMatches = 5
+const maximumMachineMatches = 25
+
+func handleMachines(ctx *gin.Context) {
+	page, ok := getPagination(ctx)
+	if !ok {
+		return
+	}
+	manufacturerId, ok := getQueryInt(ctx, "manufacturer_id", 0)
+	if !ok {
+		return
+	}
+	decade, ok := getQueryInt(ctx, "decade", 0)
+	if !ok {
+		return
+	}
+	sort := ctx.DefaultQuery("sort", db.SortMachinesByName)
+	if !db.IsMachineSort(sort) {
+		abortWithError(ctx, http.StatusBadRequest, "sort must be one of "+strings.Join(db.MachineSorts, ", "))
+		return
+	}
+
+	filter := db.MachineFilter{
+		ManufacturerId: manufacturerId,
+		Name:           ctx.Query("name"),
+		Decade:         decade,
+		Feature:        ctx.Query("feature"),
+		Sort:           sort,
+		Limit:          page.Limit,
+		Offset:         page.Offset,
+	}
+	page.Total = db.CountActiveMachines(filter)
+	respondWithList(ctx, newMachines(db.GetActiveMachines(filter)), page)
+}
+
+func handleMachine(ctx *gin.Context) {
+	machine := db.GetMachine(ctx.Param("opdb_id"))
+	if machine == nil {
+		abortWithError(ctx, http.StatusNotFound, "machine not found")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newMachine(*machine))
+}
+
+func handleDifficulties(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, dataResponse{
+		Data: newMachineDifficulties(stats.GetMachineDifficulties()),
+	})
+}
+
+func handleDifficulty(ctx *gin.Context) {
+	difficulty := stats.GetMachineDifficulty(ctx.Param("opdb_id"))
+	if difficulty == nil {
+		abortWithError(ctx, http.StatusNotFound, "machine has no league results")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newMachineDifficulty(*difficulty))
+}
+
+func handleSearch(ctx *gin.Context) {
+	page, ok := getPagination(ctx)
+	if !ok {
+		return
+	}
+	query := strings.TrimSpace(ctx.Query("q"))
+	if len(query) == 0 {
+		abortWithError(ctx, http.StatusBadRequest, "q must not be empty")
+		return
+	}
+
+	page.Total = db.CountSearchMachines(query)
+	respondWithList(ctx, newSearchResults(db.SearchMachines(query, page.Limit, page.Offset)), page)
+}
+
+// handleMachineMatches resolves a free text machine name to the most likely
+// machines of the OPDB catalog with their confidence.
+func handleMachineMatches(ctx *gin.Context) {
+	name := strings.TrimSpace(ctx.Query("name"))
+	if len(name) == 0 {
+		abortWithError(ctx, http.StatusBadRequest, "name must not be empty")
+		return
+	}
+	limit, ok := getQueryInt(ctx, "limit", defaultMachineMatches)
+	if !ok {
+		return
+	}
+	if limit < 1 || limit > maximumMachineMatches {
+		abortWithError(ctx, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maximumMachineMatches))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dataResponse{
+		Data: newMachineMatches(db.MatchMachineName(name, limit)),
+	})
+}
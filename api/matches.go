@@ -0,0 +1,233 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikefero/tpl/db"
+	"github.com/mikefero/tpl/storage"
+)
+
+func handleSchedule(ctx *gin.Context) {
+	season := getSeason(ctx)
+	if season == nil {
+		return
+	}
+	page, ok := getPagination(ctx)
+	if !ok {
+		return
+	}
+	teamId, ok := getQueryInt(ctx, "team_id", 0)
+	if !ok {
+		return
+	}
+	week, ok := getQueryInt(ctx, "week", 0)
+	if !ok {
+		return
+	}
+
+	filter := db.MatchFilter{
+		SeasonId: season.Id,
+		TeamId:   teamId,
+		Week:     week,
+		Limit:    page.Limit,
+		Offset:   page.Offset,
+	}
+	page.Total = db.CountMatches(filter)
+	respondWithList(ctx, newMatches(db.GetMatches(filter)), page)
+}
+
+func getMatch(ctx *gin.Context) *db.Match {
+	id, ok := getParamInt(ctx, "id")
+	if !ok {
+		return nil
+	}
+	match := db.GetMatch(id)
+	if match == nil {
+		abortWithError(ctx, http.StatusNotFound, "match not found")
+	}
+
+	return match
+}
+
+func handleMatch(ctx *gin.Context) {
+	match := getMatch(ctx)
+	if match == nil {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newMatch(*match))
+}
+
+func handleSelections(ctx *gin.Context) {
+	match := getMatch(ctx)
+	if match == nil {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dataResponse{
+		Data: newSelections(db.GetMatchSelections(match.Id)),
+	})
+}
+
+func handleCreateSelection(ctx *gin.Context) {
+	match := getMatch(ctx)
+	if match == nil {
+		return
+	}
+	var request SelectionRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		abortWithError(ctx, http.StatusBadRequest, "invalid selection: "+err.Error())
+		return
+	}
+	user := getTokenUser(ctx)
+	if user == nil {
+		return
+	}
+
+	selection, err := db.SelectMachine(*match, *user, request.OpdbId)
+	switch err {
+	case nil:
+	case db.ErrSelectionNotPlayer, db.ErrSelectionWrongSide:
+		abortWithError(ctx, http.StatusForbidden, err.Error())
+		return
+	case db.ErrSelectionPickRequired, db.ErrSelectionRandom, db.ErrSelectionUnavailable,
+		db.ErrSelectionRepeated, db.ErrSelectionUsageLimit, db.ErrSelectionNoMachines, db.ErrSelectionConflict,
+		db.ErrSelectionComplete:
+		abortWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	default:
+		abortWithError(ctx, http.StatusInternalServerError, "unable to select machine")
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, newSelection(*selection))
+}
+
+func handleResults(ctx *gin.Context) {
+	page, ok := getPagination(ctx)
+	if !ok {
+		return
+	}
+	matchId, ok := getQueryInt(ctx, "match_id", 0)
+	if !ok {
+		return
+	}
+
+	filter := db.ResultFilter{
+		MatchId: matchId,
+		OpdbId:  ctx.Query("opdb_id"),
+		Limit:   page.Limit,
+		Offset:  page.Offset,
+	}
+	page.Total = db.CountResults(filter)
+	respondWithList(ctx, newResults(db.GetResults(filter)), page)
+}
+
+func handleCreateResult(ctx *gin.Context) {
+	var request ResultRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		abortWithError(ctx, http.StatusBadRequest, "invalid result: "+err.Error())
+		return
+	}
+	user := getTokenUser(ctx)
+	if user == nil {
+		return
+	}
+	match := db.GetMatch(request.MatchId)
+	if match == nil {
+		abortWithError(ctx, http.StatusUnprocessableEntity, "match does not exist")
+		return
+	}
+	if !user.HasRole(db.RoleStaff) && !db.IsMatchPlayer(*match, user.Id) {
+		abortWithError(ctx, http.StatusForbidden, "only players of the match and staff can record its results")
+		return
+	}
+	machine := db.GetMachine(request.OpdbId)
+	if machine == nil {
+		abortWithError(ctx, http.StatusUnprocessableEntity, "machine does not exist")
+		return
+	}
+	if machine.OutOfOrder {
+		abortWithError(ctx, http.StatusUnprocessableEntity, "machine is out of order")
+		return
+	}
+	if !db.IsMachineSelected(request.MatchId, request.OpdbId) {
+		abortWithError(ctx, http.StatusUnprocessableEntity, "machine was not selected for this match")
+		return
+	}
+	result := request.toResult()
+	if err := db.FlagSubs(*match, &result); err != nil {
+		abortWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	inserted := db.InsertResult(result)
+	if inserted == nil {
+		abortWithError(ctx, http.StatusInternalServerError, "unable to record result")
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, newResult(*inserted))
+}
+
+// handleUploadResultPhoto attaches or replaces the proof photo of a result.
+// Like the photo upload of the machine page it is limited to staff, who
+// settle disputed scores.
+func handleUploadResultPhoto(ctx *gin.Context) {
+	id, ok := getParamInt(ctx, "id")
+	if !ok {
+		return
+	}
+	result := db.GetResult(id)
+	if result == nil {
+		abortWithError(ctx, http.StatusNotFound, "result not found")
+		return
+	}
+	user := getTokenUser(ctx)
+	if user == nil {
+		return
+	}
+	if !user.HasRole(db.RoleStaff) {
+		abortWithError(ctx, http.StatusForbidden, "only staff can attach photos to results")
+		return
+	}
+	header, err := ctx.FormFile("photo")
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, "photo must be uploaded as multipart form data")
+		return
+	}
+	if header.Size > storage.MaxPhotoSize {
+		abortWithError(ctx, http.StatusRequestEntityTooLarge, storage.ErrPhotoTooLarge.Error())
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, "unable to read photo")
+		return
+	}
+	defer file.Close()
+
+	photoKey, err := storage.SavePhoto(file)
+	switch err {
+	case nil:
+	case storage.ErrPhotoTooLarge:
+		abortWithError(ctx, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	case storage.ErrPhotoType:
+		abortWithError(ctx, http.StatusUnsupportedMediaType, err.Error())
+		return
+	case storage.ErrPhotoInvalid:
+		abortWithError(ctx, http.StatusBadRequest, err.Error())
+		return
+	default:
+		abortWithError(ctx, http.StatusInternalServerError, "unable to store photo")
+		return
+	}
+	if !db.SetResultPhoto(result.Id, photoKey) {
+		abortWithError(ctx, http.StatusInternalServerError, "unable to record photo")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newResult(*db.GetResult(result.Id)))
+}
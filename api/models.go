@@ -0,0 +1,833 @@
+package api
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/mikefero/tpl/db"
+	"github.com/mikefero/tpl/stats"
+	"github.com/mikefero/tpl/tournament"
+)
+
+type Manufacturer struct {
+	Id       int    `json:"id"`
+	Name     string `json:"name"`
+	FullName string `json:"full_name"`
+}
+
+type Machine struct {
+	OpdbId          string        `json:"opdb_id"`
+	IpdbId          *int64        `json:"ipdb_id"`
+	Name            string        `json:"name"`
+	Manufacturer    *Manufacturer `json:"manufacturer"`
+	Features        []string      `json:"features"`
+	ManufactureDate *string       `json:"manufacture_date"`
+	ImageURL        *string       `json:"image_url"`
+	Active          bool          `json:"active"`
+	OutOfOrder      bool          `json:"out_of_order"`
+}
+
+type MachineDifficulty struct {
+	OpdbId          string   `json:"opdb_id"`
+	Name            string   `json:"name"`
+	Scores          int      `json:"scores"`
+	MedianScore     float64  `json:"median_score"`
+	LowerQuartile   float64  `json:"lower_quartile"`
+	UpperQuartile   float64  `json:"upper_quartile"`
+	Spread          float64  `json:"spread"`
+	RatedMatchups   int      `json:"rated_matchups"`
+	FavoriteWinRate *float64 `json:"favorite_win_rate"`
+	Classification  *string  `json:"classification"`
+}
+
+type SearchResult struct {
+	Machine Machine `json:"machine"`
+	Score   float64 `json:"score"`
+}
+
+type MachineMatch struct {
+	Machine    Machine `json:"machine"`
+	Confidence float64 `json:"confidence"`
+	Alias      bool    `json:"alias"`
+}
+
+type League struct {
+	Id            int    `json:"id"`
+	Name          string `json:"name"`
+	Active        bool   `json:"active"`
+	SelectionMode string `json:"selection_mode"`
+	NoRepeat      bool   `json:"no_repeat"`
+	UsageLimit    *int64 `json:"usage_limit"`
+	SubLimit      *int64 `json:"sub_limit"`
+	Games         int    `json:"games"`
+}
+
+type LeagueSub struct {
+	PlayerId int    `json:"player_id"`
+	Name     string `json:"name"`
+	AddedAt  string `json:"added_at"`
+}
+
+type Season struct {
+	Id        int     `json:"id"`
+	LeagueId  int     `json:"league_id"`
+	Name      string  `json:"name"`
+	StartDate string  `json:"start_date"`
+	EndDate   *string `json:"end_date"`
+}
+
+type Team struct {
+	Id       int    `json:"id"`
+	LeagueId int    `json:"league_id"`
+	Name     string `json:"name"`
+	APlayer  int    `json:"a_player_id"`
+	BPlayer  int    `json:"b_player_id"`
+	Active   bool   `json:"active"`
+}
+
+type Standing struct {
+	TeamId   int    `json:"team_id"`
+	TeamName string `json:"team_name"`
+	Played   int    `json:"played"`
+	Wins     int    `json:"wins"`
+	Losses   int    `json:"losses"`
+	Ties     int    `json:"ties"`
+	Points   int    `json:"points"`
+	SubGames int    `json:"sub_games"`
+}
+
+type SwissStanding struct {
+	Position           int     `json:"position"`
+	TeamId             int     `json:"team_id"`
+	TeamName           string  `json:"team_name"`
+	Wins               int     `json:"wins"`
+	Losses             int     `json:"losses"`
+	Ties               int     `json:"ties"`
+	Byes               int     `json:"byes"`
+	Score              float64 `json:"score"`
+	Buchholz           float64 `json:"buchholz"`
+	StrengthOfSchedule float64 `json:"strength_of_schedule"`
+}
+
+type Match struct {
+	Id            int     `json:"id"`
+	LeagueId      int     `json:"league_id"`
+	SeasonId      int     `json:"season_id"`
+	Team1Id       int     `json:"team_1_id"`
+	Team2Id       *int64  `json:"team_2_id"`
+	Week          *int64  `json:"week"`
+	Date          *string `json:"date"`
+	SelectionSeed *int64  `json:"selection_seed"`
+}
+
+type Selection struct {
+	Game           int      `json:"game"`
+	OpdbId         string   `json:"opdb_id"`
+	Name           string   `json:"name"`
+	PickedByTeamId *int64   `json:"picked_by_team_id"`
+	PickedBy       int      `json:"picked_by_user_id"`
+	DrawPool       []string `json:"draw_pool"`
+	CreatedAt      string   `json:"created_at"`
+}
+
+type WeekUsage struct {
+	Week  int `json:"week"`
+	Games int `json:"games"`
+}
+
+type MachineUsage struct {
+	OpdbId string      `json:"opdb_id"`
+	Name   string      `json:"name"`
+	Games  int         `json:"games"`
+	Uses   int         `json:"uses"`
+	Weeks  []WeekUsage `json:"weeks"`
+}
+
+type Meeting struct {
+	MatchId       int     `json:"match_id"`
+	OpdbId        *string `json:"opdb_id"`
+	Name          *string `json:"name"`
+	Date          *string `json:"date"`
+	Score         int64   `json:"score"`
+	OpponentScore int64   `json:"opponent_score"`
+}
+
+type MachineRivalry struct {
+	OpdbId        string  `json:"opdb_id"`
+	Name          string  `json:"name"`
+	Wins          int     `json:"wins"`
+	Losses        int     `json:"losses"`
+	Ties          int     `json:"ties"`
+	AverageMargin float64 `json:"average_margin"`
+}
+
+type Rivalry struct {
+	Id            int              `json:"id"`
+	OpponentId    int              `json:"opponent_id"`
+	Wins          int              `json:"wins"`
+	Losses        int              `json:"losses"`
+	Ties          int              `json:"ties"`
+	AverageMargin float64          `json:"average_margin"`
+	Machines      []MachineRivalry `json:"machines"`
+	Recent        []Meeting        `json:"recent"`
+}
+
+type Tournament struct {
+	Id              int     `json:"id"`
+	Name            string  `json:"name"`
+	Format          string  `json:"format"`
+	State           string  `json:"state"`
+	GroupSize       int     `json:"group_size"`
+	StrikeLimit     int     `json:"strike_limit"`
+	Rounds          *int64  `json:"rounds"`
+	Scoring         *string `json:"scoring"`
+	Entries         *int64  `json:"entries"`
+	CountedMachines *int64  `json:"counted_machines"`
+	Qualifiers      *int64  `json:"qualifiers"`
+	BracketId       *int64  `json:"bracket_id"`
+	Seed            int64   `json:"seed"`
+	Players         int     `json:"players"`
+	CreatedAt       string  `json:"created_at"`
+}
+
+type TournamentStanding struct {
+	Position           int     `json:"position"`
+	PlayerId           int     `json:"player_id"`
+	Name               string  `json:"name"`
+	Strikes            int     `json:"strikes"`
+	Games              int     `json:"games"`
+	Wins               int     `json:"wins"`
+	Losses             int     `json:"losses"`
+	Byes               int     `json:"byes"`
+	Score              float64 `json:"score"`
+	Buchholz           float64 `json:"buchholz"`
+	StrengthOfSchedule float64 `json:"strength_of_schedule"`
+	EliminatedRound    *int    `json:"eliminated_round"`
+}
+
+type TournamentGamePlayer struct {
+	PlayerId int    `json:"player_id"`
+	Name     string `json:"name"`
+	Position int    `json:"position"`
+	Place    *int64 `json:"place"`
+}
+
+type TournamentGame struct {
+	Id          int                    `json:"id"`
+	Round       int                    `json:"round"`
+	OpdbId      string                 `json:"opdb_id"`
+	Name        string                 `json:"name"`
+	DrawPool    []string               `json:"draw_pool"`
+	PickedBy    *int64                 `json:"picked_by"`
+	CompletedAt *string                `json:"completed_at"`
+	Players     []TournamentGamePlayer `json:"players"`
+}
+
+type QualifyingMachine struct {
+	OpdbId  string `json:"opdb_id"`
+	Name    string `json:"name"`
+	Score   int64  `json:"score"`
+	Rank    int    `json:"rank"`
+	Points  int    `json:"points"`
+	Counted bool   `json:"counted"`
+}
+
+type Qualifier struct {
+	Position  int                 `json:"position"`
+	PlayerId  int                 `json:"player_id"`
+	Name      string              `json:"name"`
+	Points    int                 `json:"points"`
+	Entries   int                 `json:"entries"`
+	Qualified bool                `json:"qualified"`
+	Machines  []QualifyingMachine `json:"machines"`
+}
+
+type PlayerScore struct {
+	PlayerId *int64 `json:"player_id"`
+	Score    *int64 `json:"score"`
+}
+
+type TeamResult struct {
+	APlayer PlayerScore `json:"a_player"`
+	BPlayer PlayerScore `json:"b_player"`
+	Score   *int64      `json:"score"`
+}
+
+type Result struct {
+	Id           int        `json:"id"`
+	MatchId      int        `json:"match_id"`
+	OpdbId       string     `json:"opdb_id"`
+	Team1        TeamResult `json:"team_1"`
+	Team2        TeamResult `json:"team_2"`
+	SubPlayerIds []int64    `json:"sub_player_ids"`
+	PhotoURL     *string    `json:"photo_url"`
+	ThumbnailURL *string    `json:"thumbnail_url"`
+}
+
+type LineupEntry struct {
+	OpdbId           string  `json:"opdb_id"`
+	Name             string  `json:"name"`
+	Active           bool    `json:"active"`
+	Source           *string `json:"source"`
+	Override         bool    `json:"override"`
+	PinballMapActive bool    `json:"pinballmap_active"`
+}
+
+type LineupRequest struct {
+	OpdbId string `json:"opdb_id" binding:"required"`
+}
+
+type SelectionRequest struct {
+	OpdbId string `json:"opdb_id"`
+}
+
+type ResultRequest struct {
+	MatchId int        `json:"match_id" binding:"required"`
+	OpdbId  string     `json:"opdb_id" binding:"required"`
+	Team1   TeamResult `json:"team_1"`
+	Team2   TeamResult `json:"team_2"`
+}
+
+func nullInt(value sql.NullInt64) *int64 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Int64
+}
+
+func nullString(value sql.NullString) *string {
+	if !value.Valid {
+		return nil
+	}
+	return &value.String
+}
+
+func toNullInt(value *int64) sql.NullInt64 {
+	if value == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{
+		Int64: *value,
+		Valid: true,
+	}
+}
+
+func formatDate(timestamp int64) string {
+	return time.Unix(timestamp, 0).UTC().Format("2006-01-02")
+}
+
+func nullDate(timestamp sql.NullInt64) *string {
+	if !timestamp.Valid {
+		return nil
+	}
+	date := formatDate(timestamp.Int64)
+	return &date
+}
+
+func newMachine(machine db.Machine) Machine {
+	model := Machine{
+		OpdbId:          machine.OpdbId,
+		IpdbId:          nullInt(machine.IpdbId),
+		Name:            machine.Name,
+		Features:        []string{},
+		ManufactureDate: nullDate(machine.ManufactureDate),
+		Active:          machine.Active,
+		OutOfOrder:      machine.OutOfOrder,
+	}
+	if manufacturer := db.GetManufacturer(machine.ManufacturerId); manufacturer != nil {
+		model.Manufacturer = &Manufacturer{
+			Id:       manufacturer.Id,
+			Name:     manufacturer.Name,
+			FullName: manufacturer.FullName,
+		}
+	}
+	if machine.FeaturesId.Valid {
+		features := db.GetFeatures(int(machine.FeaturesId.Int64))
+		if len(features) > 0 {
+			model.Features = strings.Split(features, ",")
+		}
+	}
+	if machine.BackglassImageUuid.Valid {
+		imageURL := "/images/" + machine.BackglassImageUuid.String
+		model.ImageURL = &imageURL
+	}
+
+	return model
+}
+
+func newMachines(machines []db.Machine) []Machine {
+	models := []Machine{}
+	for _, machine := range machines {
+		models = append(models, newMachine(machine))
+	}
+	return models
+}
+
+func newMachineDifficulty(difficulty stats.MachineDifficulty) MachineDifficulty {
+	model := MachineDifficulty{
+		OpdbId:        difficulty.OpdbId,
+		Name:          difficulty.MachineName,
+		Scores:        difficulty.Scores,
+		MedianScore:   difficulty.MedianScore,
+		LowerQuartile: difficulty.LowerQuartile,
+		UpperQuartile: difficulty.UpperQuartile,
+		Spread:        difficulty.Spread,
+		RatedMatchups: difficulty.RatedMatchups,
+	}
+	if difficulty.RatedMatchups > 0 {
+		model.FavoriteWinRate = &difficulty.FavoriteWinRate
+	}
+	if classification := difficulty.Classification(); len(classification) > 0 {
+		model.Classification = &classification
+	}
+
+	return model
+}
+
+func newMachineDifficulties(difficulties []stats.MachineDifficulty) []MachineDifficulty {
+	models := []MachineDifficulty{}
+	for _, difficulty := range difficulties {
+		models = append(models, newMachineDifficulty(difficulty))
+	}
+	return models
+}
+
+func newSearchResults(results []db.SearchResult) []SearchResult {
+	models := []SearchResult{}
+	for _, result := range results {
+		models = append(models, SearchResult{
+			Machine: newMachine(result.Machine),
+			// bm25 scores are negative with the best match lowest
+			Score: -result.Score,
+		})
+	}
+	return models
+}
+
+func newMachineMatches(matches []db.MachineMatch) []MachineMatch {
+	models := []MachineMatch{}
+	for _, match := range matches {
+		models = append(models, MachineMatch{
+			Machine:    newMachine(match.Machine),
+			Confidence: match.Confidence,
+			Alias:      match.Alias,
+		})
+	}
+	return models
+}
+
+func newLineupEntry(entry db.LineupEntry) LineupEntry {
+	return LineupEntry{
+		OpdbId:           entry.OpdbId,
+		Name:             entry.Name,
+		Active:           entry.Active,
+		Source:           nullString(entry.Source),
+		Override:         entry.IsOverride(),
+		PinballMapActive: entry.PinballMapActive,
+	}
+}
+
+func newLineup(entries []db.LineupEntry) []LineupEntry {
+	models := []LineupEntry{}
+	for _, entry := range entries {
+		models = append(models, newLineupEntry(entry))
+	}
+	return models
+}
+
+func newLeague(league db.League) League {
+	return League{
+		Id:            league.Id,
+		Name:          league.Name,
+		Active:        league.Active,
+		SelectionMode: league.SelectionMode,
+		NoRepeat:      league.NoRepeat,
+		UsageLimit:    nullInt(league.UsageLimit),
+		SubLimit:      nullInt(league.SubLimit),
+		Games:         league.Games,
+	}
+}
+
+func newLeagues(leagues []db.League) []League {
+	models := []League{}
+	for _, league := range leagues {
+		models = append(models, newLeague(league))
+	}
+	return models
+}
+
+func newLeagueSubs(subs []db.LeagueSub) []LeagueSub {
+	models := []LeagueSub{}
+	for _, sub := range subs {
+		models = append(models, LeagueSub{
+			PlayerId: sub.UserId,
+			Name:     sub.Name,
+			AddedAt:  time.Unix(sub.AddedAt, 0).UTC().Format(time.RFC3339),
+		})
+	}
+	return models
+}
+
+func newSeason(season db.Season) Season {
+	return Season{
+		Id:        season.Id,
+		LeagueId:  season.LeagueId,
+		Name:      season.Name,
+		StartDate: formatDate(season.StartDate),
+		EndDate:   nullDate(season.EndDate),
+	}
+}
+
+func newSeasons(seasons []db.Season) []Season {
+	models := []Season{}
+	for _, season := range seasons {
+		models = append(models, newSeason(season))
+	}
+	return models
+}
+
+func newTeams(teams []db.Team) []Team {
+	models := []Team{}
+	for _, team := range teams {
+		models = append(models, Team{
+			Id:       team.Id,
+			LeagueId: team.LeagueId,
+			Name:     team.Name,
+			APlayer:  team.APlayer,
+			BPlayer:  team.BPlayer,
+			Active:   team.Active,
+		})
+	}
+	return models
+}
+
+func newStandings(standings []db.Standing) []Standing {
+	models := []Standing{}
+	for _, standing := range standings {
+		models = append(models, Standing{
+			TeamId:   standing.TeamId,
+			TeamName: standing.TeamName,
+			Played:   standing.Played,
+			Wins:     standing.Wins,
+			Losses:   standing.Losses,
+			Ties:     standing.Ties,
+			Points:   standing.Points,
+			SubGames: standing.SubGames,
+		})
+	}
+	return models
+}
+
+func newSwissStandings(standings []tournament.SeasonStanding) []SwissStanding {
+	models := []SwissStanding{}
+	for _, standing := range standings {
+		models = append(models, SwissStanding{
+			Position:           standing.Position,
+			TeamId:             standing.Id,
+			TeamName:           standing.TeamName,
+			Wins:               standing.Wins,
+			Losses:             standing.Losses,
+			Ties:               standing.Ties,
+			Byes:               standing.Byes,
+			Score:              standing.Score,
+			Buchholz:           standing.Buchholz,
+			StrengthOfSchedule: standing.StrengthOfSchedule,
+		})
+	}
+	return models
+}
+
+func newMachineUsage(usage []db.MachineUsage, weeks []int) []MachineUsage {
+	models := []MachineUsage{}
+	for _, machine := range usage {
+		model := MachineUsage{
+			OpdbId: machine.OpdbId,
+			Name:   machine.MachineName,
+			Games:  machine.Games,
+			Uses:   machine.Uses,
+			Weeks:  []WeekUsage{},
+		}
+		for _, week := range weeks {
+			model.Weeks = append(model.Weeks, WeekUsage{
+				Week:  week,
+				Games: machine.Weeks[week],
+			})
+		}
+		models = append(models, model)
+	}
+	return models
+}
+
+func newMatch(match db.Match) Match {
+	return Match{
+		Id:            match.Id,
+		LeagueId:      match.LeagueId,
+		SeasonId:      match.SeasonId,
+		Team1Id:       match.Team1Id,
+		Team2Id:       nullInt(match.Team2Id),
+		Week:          nullInt(match.Week),
+		Date:          nullDate(match.Date),
+		SelectionSeed: nullInt(match.SelectionSeed),
+	}
+}
+
+func newMatches(matches []db.Match) []Match {
+	models := []Match{}
+	for _, match := range matches {
+		models = append(models, newMatch(match))
+	}
+	return models
+}
+
+func newRivalry(id int, opponentId int, rivalry stats.Rivalry) Rivalry {
+	model := Rivalry{
+		Id:            id,
+		OpponentId:    opponentId,
+		Wins:          rivalry.Wins,
+		Losses:        rivalry.Losses,
+		Ties:          rivalry.Ties,
+		AverageMargin: rivalry.AverageMargin,
+		Machines:      []MachineRivalry{},
+		Recent:        []Meeting{},
+	}
+	for _, machine := range rivalry.Machines {
+		model.Machines = append(model.Machines, MachineRivalry{
+			OpdbId:        machine.OpdbId,
+			Name:          machine.MachineName,
+			Wins:          machine.Wins,
+			Losses:        machine.Losses,
+			Ties:          machine.Ties,
+			AverageMargin: machine.AverageMargin,
+		})
+	}
+	for _, meeting := range rivalry.Recent {
+		recent := Meeting{
+			MatchId:       meeting.MatchId,
+			Date:          nullDate(meeting.Date),
+			Score:         meeting.Score,
+			OpponentScore: meeting.OpponentScore,
+		}
+		if len(meeting.OpdbId) > 0 {
+			recent.OpdbId = &meeting.OpdbId
+			recent.Name = &meeting.MachineName
+		}
+		model.Recent = append(model.Recent, recent)
+	}
+
+	return model
+}
+
+func newTournament(t db.Tournament) Tournament {
+	return Tournament{
+		Id:              t.Id,
+		Name:            t.Name,
+		Format:          t.Format,
+		State:           t.State,
+		GroupSize:       t.GroupSize,
+		StrikeLimit:     t.StrikeLimit,
+		Rounds:          nullInt(t.Rounds),
+		Scoring:         nullString(t.Scoring),
+		Entries:         nullInt(t.Entries),
+		CountedMachines: nullInt(t.CountedMachines),
+		Qualifiers:      nullInt(t.Qualifiers),
+		BracketId:       nullInt(t.BracketId),
+		Seed:            t.Seed,
+		Players:         len(db.GetTournamentPlayers(t.Id)),
+		CreatedAt:       time.Unix(t.CreatedAt, 0).UTC().Format(time.RFC3339),
+	}
+}
+
+func newTournaments(tournaments []db.Tournament) []Tournament {
+	models := []Tournament{}
+	for _, t := range tournaments {
+		models = append(models, newTournament(t))
+	}
+	return models
+}
+
+func newTournamentStandings(standings []tournament.Standing) []TournamentStanding {
+	models := []TournamentStanding{}
+	for _, standing := range standings {
+		model := TournamentStanding{
+			Position:           standing.Position,
+			PlayerId:           standing.UserId,
+			Name:               standing.Name,
+			Strikes:            standing.Strikes,
+			Games:              standing.Games,
+			Wins:               standing.Wins,
+			Losses:             standing.Losses,
+			Byes:               standing.Byes,
+			Score:              standing.Score,
+			Buchholz:           standing.Buchholz,
+			StrengthOfSchedule: standing.StrengthOfSchedule,
+		}
+		if standing.Eliminated() {
+			round := standing.EliminatedRound
+			model.EliminatedRound = &round
+		}
+		models = append(models, model)
+	}
+	return models
+}
+
+func newTournamentGames(games []db.TournamentGame) []TournamentGame {
+	models := []TournamentGame{}
+	for _, game := range games {
+		model := TournamentGame{
+			Id:       game.Id,
+			Round:    game.Round,
+			OpdbId:   game.OpdbId,
+			Name:     game.MachineName,
+			DrawPool: []string{},
+			PickedBy: nullInt(game.PickedBy),
+			Players:  []TournamentGamePlayer{},
+		}
+		if game.DrawPool.Valid {
+			model.DrawPool = strings.Split(game.DrawPool.String, ",")
+		}
+		if game.CompletedAt.Valid {
+			completedAt := time.Unix(game.CompletedAt.Int64, 0).UTC().Format(time.RFC3339)
+			model.CompletedAt = &completedAt
+		}
+		for _, player := range game.Players {
+			model.Players = append(model.Players, TournamentGamePlayer{
+				PlayerId: player.UserId,
+				Name:     player.Name,
+				Position: player.Position,
+				Place:    nullInt(player.Place),
+			})
+		}
+		models = append(models, model)
+	}
+	return models
+}
+
+func newQualifiers(qualifiers []tournament.Qualifier) []Qualifier {
+	models := []Qualifier{}
+	for _, qualifier := range qualifiers {
+		model := Qualifier{
+			Position:  qualifier.Position,
+			PlayerId:  qualifier.UserId,
+			Name:      qualifier.Name,
+			Points:    qualifier.Points,
+			Entries:   qualifier.Entries,
+			Qualified: qualifier.Qualified,
+			Machines:  []QualifyingMachine{},
+		}
+		for _, rank := range qualifier.Machines {
+			model.Machines = append(model.Machines, QualifyingMachine{
+				OpdbId:  rank.OpdbId,
+				Name:    rank.MachineName,
+				Score:   rank.Score,
+				Rank:    rank.Rank,
+				Points:  rank.Points,
+				Counted: rank.Counted,
+			})
+		}
+		models = append(models, model)
+	}
+	return models
+}
+
+func newSelection(selection db.Selection) Selection {
+	model := Selection{
+		Game:           selection.Game,
+		OpdbId:         selection.OpdbId,
+		Name:           selection.MachineName,
+		PickedByTeamId: nullInt(selection.PickedByTeamId),
+		PickedBy:       selection.PickedByUserId,
+		DrawPool:       []string{},
+		CreatedAt:      time.Unix(selection.CreatedAt, 0).UTC().Format(time.RFC3339),
+	}
+	if selection.DrawPool.Valid {
+		model.DrawPool = strings.Split(selection.DrawPool.String, ",")
+	}
+
+	return model
+}
+
+func newSelections(selections []db.Selection) []Selection {
+	models := []Selection{}
+	for _, selection := range selections {
+		models = append(models, newSelection(selection))
+	}
+	return models
+}
+
+func newResult(result db.Result) Result {
+	model := Result{
+		Id:      result.Id,
+		MatchId: result.MatchId,
+		OpdbId:  result.OpdbId,
+		Team1: TeamResult{
+			APlayer: PlayerScore{
+				PlayerId: nullInt(result.Team1APlayerId),
+				Score:    nullInt(result.Team1APlayerScore),
+			},
+			BPlayer: PlayerScore{
+				PlayerId: nullInt(result.Team1BPlayerId),
+				Score:    nullInt(result.Team1BPlayerScore),
+			},
+			Score: nullInt(result.Team1Score),
+		},
+		Team2: TeamResult{
+			APlayer: PlayerScore{
+				PlayerId: nullInt(result.Team2APlayerId),
+				Score:    nullInt(result.Team2APlayerScore),
+			},
+			BPlayer: PlayerScore{
+				PlayerId: nullInt(result.Team2BPlayerId),
+				Score:    nullInt(result.Team2BPlayerScore),
+			},
+			Score: nullInt(result.Team2Score),
+		},
+		SubPlayerIds: []int64{},
+	}
+	for _, player := range []struct {
+		id  sql.NullInt64
+		sub bool
+	}{
+		{result.Team1APlayerId, result.Team1APlayerSub},
+		{result.Team1BPlayerId, result.Team1BPlayerSub},
+		{result.Team2APlayerId, result.Team2APlayerSub},
+		{result.Team2BPlayerId, result.Team2BPlayerSub},
+	} {
+		if player.sub && player.id.Valid {
+			model.SubPlayerIds = append(model.SubPlayerIds, player.id.Int64)
+		}
+	}
+	if result.PhotoKey.Valid {
+		photoURL := "/photos/" + result.PhotoKey.String
+		thumbnailURL := photoURL + "/thumbnail"
+		model.PhotoURL = &photoURL
+		model.ThumbnailURL = &thumbnailURL
+	}
+
+	return model
+}
+
+func newResults(results []db.Result) []Result {
+	models := []Result{}
+	for _, result := range results {
+		models = append(models, newResult(result))
+	}
+	return models
+}
+
+func (request ResultRequest) toResult() db.Result {
+	return db.Result{
+		MatchId:           request.MatchId,
+		OpdbId:            request.OpdbId,
+		Team1APlayerId:    toNullInt(request.Team1.APlayer.PlayerId),
+		Team1APlayerScore: toNullInt(request.Team1.APlayer.Score),
+		Team1BPlayerId:    toNullInt(request.Team1.BPlayer.PlayerId),
+		Team1BPlayerScore: toNullInt(request.Team1.BPlayer.Score),
+		Team1Score:        toNullInt(request.Team1.Score),
+		Team2APlayerId:    toNullInt(request.Team2.APlayer.PlayerId),
+		Team2APlayerScore: toNullInt(request.Team2.APlayer.Score),
+		Team2BPlayerId:    toNullInt(request.Team2.BPlayer.PlayerId),
+		Team2BPlayerScore: toNullInt(request.Team2.BPlayer.Score),
+		Team2Score:        toNullInt(request.Team2.Score),
+	}
+}
@@ -0,0 +1,416 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Nullable    bool               `json:"nullable,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *schema            `json:"items,omitempty"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type response struct {
+	Description string               `json:"description"`
+	Content     map[string]mediaType `json:"content"`
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type operation struct {
+	Summary     string                `json:"summary"`
+	Description string                `json:"description,omitempty"`
+	OperationId string                `json:"operationId"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Parameters  []parameter           `json:"parameters,omitempty"`
+	RequestBody *requestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*response  `json:"responses"`
+}
+
+type info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type server struct {
+	URL string `json:"url"`
+}
+
+type securityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+type components struct {
+	Schemas         map[string]*schema        `json:"schemas"`
+	SecuritySchemes map[string]securityScheme `json:"securitySchemes"`
+}
+
+type openAPI struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       info                             `json:"info"`
+	Servers    []server                         `json:"servers"`
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components components                       `json:"components"`
+}
+
+var rePathParameter = regexp.MustCompile(`:([a-z_]+)`)
+
+func newComponentRef(name string) *schema {
+	return &schema{
+		Ref: "#/components/schemas/" + name,
+	}
+}
+
+// newSchema reflects over the JSON encoding of a model; named structs are
+// registered as components and referenced so that the models in the document
+// are the models written by the handlers.
+func (spec *openAPI) newSchema(t reflect.Type) *schema {
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := spec.newSchema(t.Elem())
+		if len(s.Ref) > 0 {
+			// OpenAPI 3.0 ignores siblings of $ref so nullable references are inlined
+			component := spec.resolve(s)
+			return &schema{
+				Type:       component.Type,
+				Nullable:   true,
+				Properties: component.Properties,
+				Required:   component.Required,
+			}
+		}
+		s.Nullable = true
+		return s
+	case reflect.Slice:
+		return &schema{
+			Type:  "array",
+			Items: spec.newSchema(t.Elem()),
+		}
+	case reflect.Struct:
+		name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+		if _, exists := spec.Components.Schemas[name]; !exists {
+			s := &schema{
+				Type:       "object",
+				Properties: map[string]*schema{},
+			}
+			spec.Components.Schemas[name] = s
+			for i := 0; i < t.NumField(); i++ {
+				field := t.Field(i)
+				property := strings.Split(field.Tag.Get("json"), ",")[0]
+				if len(property) == 0 || property == "-" {
+					continue
+				}
+				s.Properties[property] = spec.newSchema(field.Type)
+				s.Required = append(s.Required, property)
+			}
+		}
+		return newComponentRef(name)
+	case reflect.Bool:
+		return &schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64:
+		return &schema{Type: "integer", Format: "int64"}
+	case reflect.Float64:
+		return &schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &schema{Type: "string"}
+	}
+	return &schema{}
+}
+
+func (spec *openAPI) newResponseSchema(route route) *schema {
+	model := spec.newSchema(reflect.TypeOf(route.Model))
+	switch route.Response {
+	case responseList:
+		return &schema{
+			Type: "object",
+			Properties: map[string]*schema{
+				"data": {
+					Type:  "array",
+					Items: model,
+				},
+			},
+			Required: []string{"data"},
+		}
+	case responsePage:
+		return &schema{
+			Type: "object",
+			Properties: map[string]*schema{
+				"data": {
+					Type:  "array",
+					Items: model,
+				},
+				"pagination": spec.newSchema(reflect.TypeOf(pagination{})),
+			},
+			Required: []string{"data", "pagination"},
+		}
+	}
+	return model
+}
+
+func newJSONResponse(description string, s *schema) *response {
+	return &response{
+		Description: description,
+		Content: map[string]mediaType{
+			"application/json": {
+				Schema: s,
+			},
+		},
+	}
+}
+
+func getOperationId(route route) string {
+	operationId := strings.ToLower(route.Method)
+	for _, segment := range strings.Split(route.Path, "/") {
+		segment = strings.TrimPrefix(segment, ":")
+		for _, word := range strings.Split(segment, "_") {
+			if len(word) > 0 {
+				operationId += strings.ToUpper(word[:1]) + word[1:]
+			}
+		}
+	}
+	return operationId
+}
+
+func newOpenAPI(routes []route) *openAPI {
+	spec := &openAPI{
+		OpenAPI: "3.0.3",
+		Info: info{
+			Title:   "The Pinball League API",
+			Version: "1.0.0",
+		},
+		Servers: []server{
+			{URL: basePath},
+		},
+		Paths: map[string]map[string]*operation{},
+		Components: components{
+			Schemas: map[string]*schema{},
+			SecuritySchemes: map[string]securityScheme{
+				"bearerAuth": {
+					Type:         "http",
+					Scheme:       "bearer",
+					BearerFormat: "personal access token",
+					Description:  "Personal access token created from the user profile page",
+				},
+			},
+		},
+	}
+	errorSchema := spec.newSchema(reflect.TypeOf(errorResponse{}))
+
+	for _, route := range routes {
+		status := route.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		op := &operation{
+			Summary:     route.Summary,
+			OperationId: getOperationId(route),
+			Responses: map[string]*response{
+				strconv.Itoa(status): newJSONResponse(http.StatusText(status), spec.newResponseSchema(route)),
+			},
+		}
+		if len(route.Scope) > 0 {
+			op.Description = "Requires an API token with the " + route.Scope + " scope."
+			op.Security = []map[string][]string{
+				{"bearerAuth": {}},
+			}
+			if isReadScope(route.Scope) {
+				op.Description = "Open without an API token; a token sent must have the " + route.Scope + " scope."
+				op.Security = append([]map[string][]string{{}}, op.Security...)
+			}
+			op.Responses[strconv.Itoa(http.StatusUnauthorized)] = newJSONResponse("Missing or invalid API token", errorSchema)
+			op.Responses[strconv.Itoa(http.StatusForbidden)] = newJSONResponse("API token is missing the required scope or its user is not allowed", errorSchema)
+		}
+		if route.Request != nil {
+			op.RequestBody = &requestBody{
+				Required: true,
+				Content: map[string]mediaType{
+					"application/json": {
+						Schema: spec.newSchema(reflect.TypeOf(route.Request)),
+					},
+				},
+			}
+			op.Responses[strconv.Itoa(http.StatusBadRequest)] = newJSONResponse("Invalid request body", errorSchema)
+			op.Responses[strconv.Itoa(http.StatusUnprocessableEntity)] = newJSONResponse("Referenced resource does not exist", errorSchema)
+		}
+		if len(route.Upload) > 0 {
+			op.RequestBody = &requestBody{
+				Required: true,
+				Content: map[string]mediaType{
+					"multipart/form-data": {
+						Schema: &schema{
+							Type: "object",
+							Properties: map[string]*schema{
+								route.Upload: {Type: "string", Format: "binary"},
+							},
+							Required: []string{route.Upload},
+						},
+					},
+				},
+			}
+			op.Responses[strconv.Itoa(http.StatusBadRequest)] = newJSONResponse("Invalid upload", errorSchema)
+			op.Responses[strconv.Itoa(http.StatusRequestEntityTooLarge)] = newJSONResponse("Upload is too large", errorSchema)
+			op.Responses[strconv.Itoa(http.StatusUnsupportedMediaType)] = newJSONResponse("Upload is not a supported type", errorSchema)
+		}
+
+		for _, match := range rePathParameter.FindAllStringSubmatch(route.Path, -1) {
+			s := &schema{Type: "string"}
+			if match[1] == "id" {
+				s = &schema{Type: "integer", Format: "int64"}
+				op.Responses[strconv.Itoa(http.StatusBadRequest)] = newJSONResponse("Invalid parameter", errorSchema)
+			}
+			op.Parameters = append(op.Parameters, parameter{
+				Name:     match[1],
+				In:       "path",
+				Required: true,
+				Schema:   s,
+			})
+			op.Responses[strconv.Itoa(http.StatusNotFound)] = newJSONResponse("Not found", errorSchema)
+		}
+		for _, query := range route.Query {
+			op.Parameters = append(op.Parameters, parameter{
+				Name:        query.Name,
+				In:          "query",
+				Description: query.Description,
+				Required:    query.Required,
+				Schema:      &schema{Type: query.Type},
+			})
+			op.Responses[strconv.Itoa(http.StatusBadRequest)] = newJSONResponse("Invalid parameter", errorSchema)
+			if query.Required && query.Type == "integer" {
+				op.Responses[strconv.Itoa(http.StatusNotFound)] = newJSONResponse("Not found", errorSchema)
+			}
+		}
+		if route.Response == responsePage {
+			op.Parameters = append(op.Parameters,
+				parameter{
+					Name:        "limit",
+					In:          "query",
+					Description: fmt.Sprintf("Maximum number of items to return (1-%d, default %d)", maximumLimit, defaultLimit),
+					Schema:      &schema{Type: "integer"},
+				},
+				parameter{
+					Name:        "offset",
+					In:          "query",
+					Description: "Number of items to skip",
+					Schema:      &schema{Type: "integer"},
+				})
+			op.Responses[strconv.Itoa(http.StatusBadRequest)] = newJSONResponse("Invalid parameter", errorSchema)
+		}
+
+		path := rePathParameter.ReplaceAllString(route.Path, "{$1}")
+		if _, exists := spec.Paths[path]; !exists {
+			spec.Paths[path] = map[string]*operation{}
+		}
+		spec.Paths[path][strings.ToLower(route.Method)] = op
+	}
+
+	return spec
+}
+
+func (spec *openAPI) getOperation(method string, fullPath string) *operation {
+	path := rePathParameter.ReplaceAllString(strings.TrimPrefix(fullPath, basePath), "{$1}")
+	if operations, exists := spec.Paths[path]; exists {
+		return operations[strings.ToLower(method)]
+	}
+	return nil
+}
+
+func (spec *openAPI) resolve(s *schema) *schema {
+	if len(s.Ref) > 0 {
+		return spec.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+// validate compares a decoded JSON value against a schema and returns a
+// description of every difference found.
+func (spec *openAPI) validate(s *schema, value interface{}, path string) []string {
+	s = spec.resolve(s)
+	if value == nil {
+		if s.Nullable {
+			return nil
+		}
+		return []string{path + ": null is not allowed"}
+	}
+
+	var drift []string
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{path + ": expected object"}
+		}
+		for _, name := range s.Required {
+			if _, exists := object[name]; !exists {
+				drift = append(drift, path+"."+name+": required property is missing")
+			}
+		}
+		var names []string
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, exists := s.Properties[name]
+			if !exists {
+				drift = append(drift, path+"."+name+": property is not described")
+				continue
+			}
+			drift = append(drift, spec.validate(property, object[name], path+"."+name)...)
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return []string{path + ": expected array"}
+		}
+		for i, item := range array {
+			drift = append(drift, spec.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case "integer":
+		number, ok := value.(float64)
+		if !ok || number != float64(int64(number)) {
+			return []string{path + ": expected integer"}
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return []string{path + ": expected number"}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return []string{path + ": expected string"}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{path + ": expected boolean"}
+		}
+	}
+
+	return drift
+}
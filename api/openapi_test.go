@@ -0,0 +1,217 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikefero/tpl/db"
+	"github.com/mikefero/tpl/storage"
+)
+
+// fixtureRequest is the request sent to a route of the API against the
+// fixture data; the path is relative to the API base path.
+type fixtureRequest struct {
+	Path string
+	Body string
+}
+
+// fixtureRequests holds a successful request for every route of the API.
+// Requests are sent in the order of the route table so that writes can rely
+// on earlier ones: the selection of match 2 is recorded before its result.
+var fixtureRequests = map[string]fixtureRequest{
+	"GET /machines":                     {Path: "/machines?sort=year"},
+	"GET /machines/:opdb_id":            {Path: "/machines/G4ODR-MDXEy"},
+	"GET /machines/:opdb_id/difficulty": {Path: "/machines/G4ODR-MDXEy/difficulty"},
+	"GET /difficulty":                   {Path: "/difficulty"},
+	"GET /compare/players":              {Path: "/compare/players?a=1&b=3"},
+	"GET /compare/teams":                {Path: "/compare/teams?a=1&b=2"},
+	"GET /search":                       {Path: "/search?q=addams"},
+	"GET /machine-matches":              {Path: "/machine-matches?name=Adams+Family"},
+	"GET /lineup":                       {Path: "/lineup"},
+	"POST /lineup":                      {Path: "/lineup", Body: `{"opdb_id": "G5BLE-MQ75Y"}`},
+	"DELETE /lineup/:opdb_id":           {Path: "/lineup/GrXzD-MjBPX"},
+	"DELETE /lineup/:opdb_id/override":  {Path: "/lineup/GrXzD-MjBPX/override"},
+	"GET /leagues":                      {Path: "/leagues?active=true"},
+	"GET /leagues/:id":                  {Path: "/leagues/1"},
+	"GET /leagues/:id/seasons":          {Path: "/leagues/1/seasons"},
+	"GET /leagues/:id/teams":            {Path: "/leagues/1/teams"},
+	"GET /leagues/:id/subs":             {Path: "/leagues/1/subs"},
+	"GET /seasons/:id":                  {Path: "/seasons/1"},
+	"GET /seasons/:id/standings":        {Path: "/seasons/1/standings"},
+	"GET /seasons/:id/swiss-standings":  {Path: "/seasons/1/swiss-standings"},
+	"GET /seasons/:id/usage":            {Path: "/seasons/1/usage"},
+	"GET /seasons/:id/schedule":         {Path: "/seasons/1/schedule?team_id=1"},
+	"GET /matches/:id":                  {Path: "/matches/1"},
+	"GET /matches/:id/selections":       {Path: "/matches/1/selections"},
+	"POST /matches/:id/selections":      {Path: "/matches/2/selections", Body: `{"opdb_id": "G4ODR-MDXEy"}`},
+	"GET /tournaments":                  {Path: "/tournaments"},
+	"GET /tournaments/:id":              {Path: "/tournaments/1"},
+	"GET /tournaments/:id/standings":    {Path: "/tournaments/1/standings"},
+	"GET /tournaments/:id/games":        {Path: "/tournaments/1/games"},
+	"GET /tournaments/:id/qualifying":   {Path: "/tournaments/2/qualifying"},
+	"GET /results":                      {Path: "/results?match_id=1"},
+	"POST /results":                     {Path: "/results", Body: `{"match_id": 2, "opdb_id": "G4ODR-MDXEy", "team_1": {"a_player": {"player_id": 3, "score": 1000}, "b_player": {"player_id": 5, "score": 2000}, "score": 3}, "team_2": {"a_player": {"player_id": 1, "score": 1500}, "b_player": {"player_id": 2, "score": 500}, "score": 1}}`},
+	"PUT /results/:id/photo":            {Path: "/results/1/photo"},
+}
+
+var apiToken string
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	dir, err := ioutil.TempDir("", "tpl-api")
+	if err != nil {
+		panic(err)
+	}
+	status := func() int {
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "tpl.db")
+		db.Initialize(db.Config{
+			Path:           path,
+			OpdbExportPath: filepath.Join("..", "db", "opdb.json"),
+		})
+		defer db.Close()
+		if err := loadFixture(path, filepath.Join("testdata", "fixture.sql")); err != nil {
+			panic(err)
+		}
+		store, err := storage.NewLocalBlobStore(filepath.Join(dir, "photos"))
+		if err != nil {
+			panic(err)
+		}
+		storage.SetPhotoStore(store)
+		apiToken, _ = db.CreateApiToken(1, "fixture", db.Scopes)
+
+		return m.Run()
+	}()
+	os.Exit(status)
+}
+
+func loadFixture(databasePath string, fixturePath string) error {
+	fixture, err := ioutil.ReadFile(fixturePath)
+	if err != nil {
+		return err
+	}
+	session, err := sql.Open("sqlite3", databasePath)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	_, err = session.Exec(string(fixture))
+	return err
+}
+
+func newTestRouter() *gin.Engine {
+	router := gin.New()
+	Initialize(router)
+	return router
+}
+
+func newPhotoUpload(t *testing.T, field string) (*bytes.Buffer, string) {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile(field, "scores.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(part, image.NewGray(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &body, writer.FormDataContentType()
+}
+
+func serveRoute(t *testing.T, router *gin.Engine, route route, path string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	var request *http.Request
+	switch {
+	case len(route.Upload) > 0:
+		upload, contentType := newPhotoUpload(t, route.Upload)
+		request = httptest.NewRequest(route.Method, basePath+path, upload)
+		request.Header.Set("Content-Type", contentType)
+	case len(body) > 0:
+		request = httptest.NewRequest(route.Method, basePath+path, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+	default:
+		request = httptest.NewRequest(route.Method, basePath+path, nil)
+	}
+	request.Header.Set("Authorization", "Bearer "+apiToken)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// validateResponse returns every difference between a response and the one
+// the OpenAPI document describes for its route and status.
+func validateResponse(spec *openAPI, route route, recorder *httptest.ResponseRecorder) []string {
+	op := spec.getOperation(route.Method, basePath+route.Path)
+	if op == nil {
+		return []string{"route is not described"}
+	}
+	documented, exists := op.Responses[strconv.Itoa(recorder.Code)]
+	if !exists {
+		return []string{"status " + strconv.Itoa(recorder.Code) + " is not described: " + recorder.Body.String()}
+	}
+	var body interface{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		return []string{"response is not JSON: " + err.Error()}
+	}
+	return spec.validate(documented.Content["application/json"].Schema, body, "$")
+}
+
+func TestResponsesMatchOpenAPI(t *testing.T) {
+	router := newTestRouter()
+	spec := newOpenAPI(routes)
+
+	for _, route := range routes {
+		name := route.Method + " " + route.Path
+		request, exists := fixtureRequests[name]
+		if !exists {
+			t.Fatalf("%s: no fixture request for route", name)
+		}
+
+		recorder := serveRoute(t, router, route, request.Path, request.Body)
+		if recorder.Code < 200 || recorder.Code >= 300 {
+			t.Fatalf("%s: expected success, got %d: %s", name, recorder.Code, recorder.Body.String())
+		}
+		if drift := validateResponse(spec, route, recorder); len(drift) > 0 {
+			t.Fatalf("%s: response drifted from the OpenAPI document:\n%s", name, strings.Join(drift, "\n"))
+		}
+	}
+}
+
+func TestNotFoundResponsesMatchOpenAPI(t *testing.T) {
+	router := newTestRouter()
+	spec := newOpenAPI(routes)
+
+	for _, route := range routes {
+		if !strings.Contains(route.Path, ":") {
+			continue
+		}
+		name := route.Method + " " + route.Path
+		path := strings.NewReplacer(":opdb_id", "XXXXX-XXXXX", ":id", "999999").Replace(route.Path)
+
+		recorder := serveRoute(t, router, route, path, fixtureRequests[name].Body)
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("%s: expected %d, got %d: %s", name, http.StatusNotFound, recorder.Code, recorder.Body.String())
+		}
+		if drift := validateResponse(spec, route, recorder); len(drift) > 0 {
+			t.Fatalf("%s: response drifted from the OpenAPI document:\n%s", name, strings.Join(drift, "\n"))
+		}
+	}
+}
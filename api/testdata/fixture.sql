@@ -0,0 +1,71 @@
+UPDATE machines
+  SET active = true, pinballmap_active = true, active_source = 'pinballmap'
+  WHERE opdb_id IN ('G4ODR-MDXEy', 'G4do5-MDlN7', 'G5pe4-MePZv', 'GrXzD-MjBPX');
+
+INSERT INTO leagues (id, name, active) VALUES
+  (1, 'Tuesday League', true);
+
+INSERT INTO seasons (id, league_id, name, start_date) VALUES
+  (1, 1, 'Fall', 1630000000);
+
+INSERT INTO users (id, league_id, email, password, name, initials, role, active) VALUES
+  (1, 1, 'ann@example.com', '', 'Ann', 'ANN', 'admin', true),
+  (2, 1, 'bob@example.com', '', 'Bob', 'BOB', 'player', true),
+  (3, 1, 'cat@example.com', '', 'Cat', 'CAT', 'player', true),
+  (4, 1, 'dan@example.com', '', 'Dan', 'DAN', 'player', true),
+  (5, 1, 'eve@example.com', '', 'Eve', 'EVE', 'player', true);
+
+INSERT INTO teams (id, league_id, name, a_player, b_player, active) VALUES
+  (1, 1, 'Flippers', 1, 2, true),
+  (2, 1, 'Tilt', 3, 4, true);
+
+INSERT INTO league_subs (league_id, user_id, added_at) VALUES
+  (1, 5, 1630000000);
+
+INSERT INTO matches (id, league_id, season_id, team_1_id, team_2_id, week, date) VALUES
+  (1, 1, 1, 1, 2, 1, 1630500000),
+  (2, 1, 1, 2, 1, 2, 1631100000);
+
+INSERT INTO match_selections (match_id, game, opdb_id, picked_by_team_id, picked_by_user_id, created_at) VALUES
+  (1, 1, 'G4ODR-MDXEy', 1, 1, 1630500000),
+  (1, 2, 'G4do5-MDlN7', 2, 3, 1630500000),
+  (1, 3, 'G5pe4-MePZv', 1, 2, 1630500000);
+
+INSERT INTO results (match_id, opdb_id,
+    team_1_a_player_id, team_1_a_player_score, team_1_b_player_id, team_1_b_player_score, team_1_score,
+    team_2_a_player_id, team_2_a_player_score, team_2_b_player_id, team_2_b_player_score, team_2_score) VALUES
+  (1, 'G4ODR-MDXEy', 1, 41000000, 2, 12000000, 3, 3, 30000000, 4, 9000000, 1),
+  (1, 'G4do5-MDlN7', 1, 800000000, 2, 300000000, 1, 3, 1200000000, 4, 500000000, 3),
+  (1, 'G5pe4-MePZv', 1, 15000000, 2, 22000000, 2, 3, 18000000, 4, 11000000, 2);
+
+INSERT INTO tournaments (id, name, format, state, group_size, strike_limit, seed, created_by, created_at) VALUES
+  (1, 'Strikes Night', 'strikes', 'running', 2, 3, 42, 1, 1630600000);
+
+INSERT INTO tournaments (id, name, format, state, group_size, strike_limit, entries, counted_machines, qualifiers, seed, created_by, created_at) VALUES
+  (2, 'Best Game', 'qualifying', 'running', 4, 3, 3, 2, 2, 7, 1, 1630600000);
+
+INSERT INTO tournament_players (tournament_id, user_id, checked_in_at) VALUES
+  (1, 1, 1630600000),
+  (1, 2, 1630600000),
+  (1, 3, 1630600000),
+  (1, 4, 1630600000);
+
+INSERT INTO tournament_games (id, tournament_id, round, opdb_id, completed_at) VALUES
+  (1, 1, 1, 'G4ODR-MDXEy', 1630601000),
+  (2, 1, 1, 'G4do5-MDlN7', NULL);
+
+INSERT INTO tournament_game_players (game_id, user_id, position, place) VALUES
+  (1, 1, 1, 1),
+  (1, 2, 2, 2),
+  (2, 3, 1, NULL),
+  (2, 4, 2, NULL);
+
+INSERT INTO tournament_tickets (id, tournament_id, user_id, opdb_id, sold_by, sold_at) VALUES
+  (1, 2, 1, 'G4ODR-MDXEy', 1, 1630600000),
+  (2, 2, 2, 'G4ODR-MDXEy', 1, 1630600000),
+  (3, 2, 1, 'G5pe4-MePZv', 1, 1630600000);
+
+INSERT INTO tournament_scores (ticket_id, score, submitted_by, submitted_at, verified_by, verified_at) VALUES
+  (1, 52000000, 1, 1630601000, 1, 1630601000),
+  (2, 31000000, 2, 1630601000, 1, 1630601000),
+  (3, 9000000, 1, 1630601000, NULL, NULL);
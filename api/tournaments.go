@@ -0,0 +1,74 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikefero/tpl/db"
+	"github.com/mikefero/tpl/tournament"
+)
+
+func handleTournaments(ctx *gin.Context) {
+	page, ok := getPagination(ctx)
+	if !ok {
+		return
+	}
+
+	page.Total = db.CountTournaments()
+	respondWithList(ctx, newTournaments(db.GetTournaments(page.Limit, page.Offset)), page)
+}
+
+func getTournament(ctx *gin.Context) *db.Tournament {
+	id, ok := getParamInt(ctx, "id")
+	if !ok {
+		return nil
+	}
+	t := db.GetTournament(id)
+	if t == nil {
+		abortWithError(ctx, http.StatusNotFound, "tournament not found")
+	}
+
+	return t
+}
+
+func handleTournament(ctx *gin.Context) {
+	t := getTournament(ctx)
+	if t == nil {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newTournament(*t))
+}
+
+func handleTournamentStandings(ctx *gin.Context) {
+	t := getTournament(ctx)
+	if t == nil {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dataResponse{
+		Data: newTournamentStandings(tournament.GetTournamentStandings(*t)),
+	})
+}
+
+func handleTournamentGames(ctx *gin.Context) {
+	t := getTournament(ctx)
+	if t == nil {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dataResponse{
+		Data: newTournamentGames(db.GetTournamentGames(t.Id)),
+	})
+}
+
+func handleTournamentQualifying(ctx *gin.Context) {
+	t := getTournament(ctx)
+	if t == nil {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dataResponse{
+		Data: newQualifiers(tournament.GetQualifyingStandings(*t)),
+	})
+}
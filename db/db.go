@@ -13,7 +13,24 @@ import (
 	json "github.com/tidwall/gjson"
 )
 
+// Config locates the TPL database and the data a new database is created
+// from.
+type Config struct {
+	Path           string
+	OpdbExportPath string
+	// LocationId is the Pinball Map location whose machines are active; zero
+	// leaves the lineup to be managed by hand.
+	LocationId int
+}
+
+var DefaultConfig = Config{
+	Path:           "db/tpl.db",
+	OpdbExportPath: "db/opdb.json",
+	LocationId:     4907, // The Pinball Lounge
+}
+
 var session *sql.DB
+var config Config
 
 func Close() {
 	closeAllPreparedStatements()
@@ -61,12 +78,44 @@ func txPrepare(tx *sql.Tx, sql string) *sql.Stmt {
 func prepareAllStatements() {
 	log.Debug("preparing statements")
 	prepareMachinesStatements()
+	prepareMachineStatsStatements()
+	prepareLeaguesStatements()
+	prepareTeamsStatements()
+	prepareMatchesStatements()
+	prepareUsersStatements()
+	prepareIfpaStatements()
+	prepareApiTokensStatements()
+	prepareLineupStatements()
+	prepareMaintenanceStatements()
+	prepareHighScoresStatements()
+	prepareSelectionStatements()
+	prepareUsageStatements()
+	prepareSearchStatements()
+	prepareMatcherStatements()
+	prepareSubsStatements()
+	prepareTournamentsStatements()
 	log.Debug("statements prepared")
 }
 
 func closeAllPreparedStatements() {
 	log.Debug("closing prepared statements")
 	closePreparedMachinesStatements()
+	closePreparedMachineStatsStatements()
+	closePreparedLeaguesStatements()
+	closePreparedTeamsStatements()
+	closePreparedMatchesStatements()
+	closePreparedUsersStatements()
+	closePreparedIfpaStatements()
+	closePreparedApiTokensStatements()
+	closePreparedLineupStatements()
+	closePreparedMaintenanceStatements()
+	closePreparedHighScoresStatements()
+	closePreparedSelectionStatements()
+	closePreparedUsageStatements()
+	closePreparedSearchStatements()
+	closePreparedMatcherStatements()
+	closePreparedSubsStatements()
+	closePreparedTournamentsStatements()
 	log.Debug("prepared statements closed")
 }
 
@@ -94,6 +143,16 @@ func getValueInt(value json.Result) sql.NullInt64 {
 	}
 }
 
+func getValueString(value json.Result) sql.NullString {
+	if value.Value() == nil || len(strings.TrimSpace(value.String())) == 0 {
+		return sql.NullString{}
+	}
+	return sql.NullString{
+		String: value.String(),
+		Valid:  true,
+	}
+}
+
 func getValueStringRegex(value json.Result, re *regexp.Regexp) sql.NullString {
 	if value.Value() == nil {
 		return sql.NullString{}
@@ -115,7 +174,8 @@ func getValueTime(value json.Result) sql.NullInt64 {
 	}
 }
 
-func maybeCreateDatabase(path string) {
+func maybeCreateDatabase() {
+	path := config.Path
 	if utils.FileExists(path) {
 		openDatabase(path)
 		return
@@ -126,7 +186,7 @@ func maybeCreateDatabase(path string) {
 	}).Debug("creating TPL database")
 	openDatabase(path)
 
-	initPinballMachineFeaturesTable(tplInitialOpdbExportPath)
+	initPinballMachineFeaturesTable(config.OpdbExportPath)
 
 	// Disable foreign key constraints and begin transaction
 	_, err := session.Exec("PRAGMA foreign_keys = off;")
@@ -142,16 +202,33 @@ func maybeCreateDatabase(path string) {
 		}).Panic("unable to begin transaction TPL table creation and machines tables initialization")
 	}
 
-	// Create the initial tables for the database
+	// Create the initial tables for the database at the current schema version
+	txExec(tx, schemaVersionTable)
+	txSetSchemaVersion(tx, len(schemaMigrations))
 	txExec(tx, leaguesTable)
+	txExec(tx, leagueSubsTable)
 	txExec(tx, matchesTable)
 	txExec(tx, resultsTable)
 	txExec(tx, seasonsTable)
 	txExec(tx, teamsTable)
 	txExec(tx, usersTable)
+	txExec(tx, ifpaRankingsTable)
+	txExec(tx, apiTokensTable)
+	txExec(tx, machineLineupHistoryTable)
+	txExec(tx, machineAliasesTable)
+	txExec(tx, maintenanceTicketsTable)
+	txExec(tx, maintenanceNotesTable)
+	txExec(tx, highScoresTable)
+	txExec(tx, matchSelectionsTable)
+	txExec(tx, tournamentsTable)
+	txExec(tx, tournamentPlayersTable)
+	txExec(tx, tournamentGamesTable)
+	txExec(tx, tournamentGamePlayersTable)
+	txExec(tx, tournamentTicketsTable)
+	txExec(tx, tournamentScoresTable)
 
 	// Initialize the machines tables with data from Open Pinball (opdb.org)
-	initPinballMachineTables(tx, tplInitialOpdbExportPath)
+	initPinballMachineTables(tx, config.OpdbExportPath)
 
 	// Commit the transaction and re-enable foreign key constraints
 	err = tx.Commit()
@@ -168,14 +245,20 @@ func maybeCreateDatabase(path string) {
 	}
 
 	// Determine the active machines at the given location
-	assignActiveMachines(tplInitialLocationId)
+	SyncActiveMachines()
 
 	log.Debug("TPL database created")
 }
 
-func init() {
+// Initialize opens the TPL database, creating it when it does not exist and
+// migrating its schema when it was created by an earlier version, and prepares
+// all statements.
+func Initialize(c Config) {
 	log.Debug("initializing TPL database")
-	maybeCreateDatabase(tplDatabasePath)
+	config = c
+	maybeCreateDatabase()
+	migrateDatabase()
+	maybeCreateMachinesSearchIndex()
 	prepareAllStatements()
 	log.Debug("TPL database initialized")
 }
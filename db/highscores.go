@@ -0,0 +1,183 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/mikefero/tpl/log"
+)
+
+// HighScoreBoardSize is the number of grand champions listed for a machine
+const HighScoreBoardSize = 10
+
+type HighScore struct {
+	Id          int
+	OpdbId      string
+	MachineName string
+	UserId      int
+	UserName    string
+	Score       int64
+	PhotoKey    sql.NullString
+	ResultId    sql.NullInt64
+	CreatedAt   int64
+	VerifiedAt  sql.NullInt64
+}
+
+var stmtInsertHighScore *sql.Stmt
+var stmtSelectHighScoreBoard *sql.Stmt
+var stmtSelectHighScoreBoards *sql.Stmt
+var stmtSelectUnverifiedHighScores *sql.Stmt
+var stmtVerifyHighScore *sql.Stmt
+var stmtDeleteUnverifiedHighScore *sql.Stmt
+var stmtImportLeagueHighScores *sql.Stmt
+
+func scanHighScore(scanner interface{ Scan(...interface{}) error }) (HighScore, error) {
+	var highScore HighScore
+	err := scanner.Scan(&highScore.Id,
+		&highScore.OpdbId,
+		&highScore.MachineName,
+		&highScore.UserId,
+		&highScore.UserName,
+		&highScore.Score,
+		&highScore.PhotoKey,
+		&highScore.ResultId,
+		&highScore.CreatedAt,
+		&highScore.VerifiedAt)
+	return highScore, err
+}
+
+func queryHighScores(stmt *sql.Stmt, statement string, args ...interface{}) []HighScore {
+	rows, err := stmt.Query(args...)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": statement,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var highScores []HighScore
+	for rows.Next() {
+		if highScore, err := scanHighScore(rows); err != nil {
+			log.WithFields(log.Fields{
+				"statement": statement,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for high score")
+		} else {
+			highScores = append(highScores, highScore)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": statement,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for high score")
+	}
+
+	return highScores
+}
+
+// SubmitHighScore records a player's high score; the score is not shown on
+// the board until it is verified by staff.
+func SubmitHighScore(opdbId string, userId int, score int64, photoKey sql.NullString) bool {
+	_, err := stmtInsertHighScore.Exec(opdbId, userId, score, photoKey, time.Now().Unix())
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlInsertHighScore,
+			"opdb_id":   opdbId,
+			"user_id":   userId,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return false
+	}
+
+	return true
+}
+
+// GetHighScoreBoard returns the grand champions of a machine.
+func GetHighScoreBoard(opdbId string) []HighScore {
+	return queryHighScores(stmtSelectHighScoreBoard, sqlSelectHighScoreBoard, opdbId, HighScoreBoardSize)
+}
+
+// GetHighScoreBoards returns the grand champions of every active machine
+// ordered by machine name.
+func GetHighScoreBoards() []HighScore {
+	return queryHighScores(stmtSelectHighScoreBoards, sqlSelectHighScoreBoards, HighScoreBoardSize)
+}
+
+func GetUnverifiedHighScores() []HighScore {
+	return queryHighScores(stmtSelectUnverifiedHighScores, sqlSelectUnverifiedHighScores)
+}
+
+func VerifyHighScore(id int, verifiedBy int) bool {
+	result, err := stmtVerifyHighScore.Exec(time.Now().Unix(), verifiedBy, id)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlVerifyHighScore,
+			"id":        id,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return false
+	}
+	verified, _ := result.RowsAffected()
+
+	return verified > 0
+}
+
+func RejectHighScore(id int) bool {
+	result, err := stmtDeleteUnverifiedHighScore.Exec(id)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlDeleteUnverifiedHighScore,
+			"id":        id,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return false
+	}
+	rejected, _ := result.RowsAffected()
+
+	return rejected > 0
+}
+
+// ImportLeagueHighScores copies the player scores of league results onto the
+// high score boards; results that were already imported are skipped.
+func ImportLeagueHighScores(importedBy int) int {
+	result, err := stmtImportLeagueHighScores.Exec(time.Now().Unix(), importedBy)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlImportLeagueHighScores,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return 0
+	}
+	imported, _ := result.RowsAffected()
+
+	return int(imported)
+}
+
+func closePreparedHighScoresStatements() {
+	log.Debug("closing prepared high scores statements")
+	stmtInsertHighScore.Close()
+	stmtSelectHighScoreBoard.Close()
+	stmtSelectHighScoreBoards.Close()
+	stmtSelectUnverifiedHighScores.Close()
+	stmtVerifyHighScore.Close()
+	stmtDeleteUnverifiedHighScore.Close()
+	stmtImportLeagueHighScores.Close()
+	log.Debug("prepared high scores statements closed")
+}
+
+func prepareHighScoresStatements() {
+	log.Debug("preparing high scores statements")
+	stmtInsertHighScore = prepare(sqlInsertHighScore)
+	stmtSelectHighScoreBoard = prepare(sqlSelectHighScoreBoard)
+	stmtSelectHighScoreBoards = prepare(sqlSelectHighScoreBoards)
+	stmtSelectUnverifiedHighScores = prepare(sqlSelectUnverifiedHighScores)
+	stmtVerifyHighScore = prepare(sqlVerifyHighScore)
+	stmtDeleteUnverifiedHighScore = prepare(sqlDeleteUnverifiedHighScore)
+	stmtImportLeagueHighScores = prepare(sqlImportLeagueHighScores)
+	log.Debug("high scores statements prepared")
+}
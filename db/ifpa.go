@@ -0,0 +1,134 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/mikefero/tpl/log"
+)
+
+// IfpaRanking is the world ranking of a player in the last imported IFPA
+// ranking snapshot.
+type IfpaRanking struct {
+	IfpaId     int64
+	Name       string
+	Rank       int
+	Wppr       float64
+	Country    sql.NullString
+	ImportedAt int64
+}
+
+// IfpaRankingsSnapshot describes the last imported IFPA ranking snapshot.
+type IfpaRankingsSnapshot struct {
+	Players    int
+	ImportedAt sql.NullInt64
+}
+
+var stmtSelectIfpaRanking *sql.Stmt
+var stmtSelectIfpaRankingsSnapshot *sql.Stmt
+
+func GetIfpaRanking(ifpaId int64) *IfpaRanking {
+	var ranking IfpaRanking
+	err := stmtSelectIfpaRanking.QueryRow(ifpaId).Scan(&ranking.IfpaId,
+		&ranking.Name,
+		&ranking.Rank,
+		&ranking.Wppr,
+		&ranking.Country,
+		&ranking.ImportedAt)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectIfpaRanking,
+				"ifpa_id":   ifpaId,
+				"error":     err,
+			}).Error("unable to execute prepared SQL statement")
+		}
+		return nil
+	}
+
+	return &ranking
+}
+
+// GetUserIfpaRanking returns the ranking of the verified IFPA number of a
+// user; unverified numbers are not trusted for seeding.
+func GetUserIfpaRanking(user User) *IfpaRanking {
+	if !user.IfpaId.Valid || !user.IfpaVerifiedAt.Valid {
+		return nil
+	}
+	return GetIfpaRanking(user.IfpaId.Int64)
+}
+
+func GetIfpaRankingsSnapshot() IfpaRankingsSnapshot {
+	var snapshot IfpaRankingsSnapshot
+	err := stmtSelectIfpaRankingsSnapshot.QueryRow().Scan(&snapshot.Players, &snapshot.ImportedAt)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectIfpaRankingsSnapshot,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+	}
+
+	return snapshot
+}
+
+// ReplaceIfpaRankings replaces the IFPA ranking snapshot with the rankings of
+// a newer one.
+func ReplaceIfpaRankings(rankings []IfpaRanking) bool {
+	tx, err := session.Begin()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("unable to begin transaction for replacing IFPA rankings")
+		return false
+	}
+
+	if _, err := tx.Exec(sqlDeleteIfpaRankings); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlDeleteIfpaRankings,
+			"error":     err,
+		}).Error("unable to transactionally delete IFPA rankings")
+		tx.Rollback()
+		return false
+	}
+	stmtInsertIfpaRanking := txPrepare(tx, sqlInsertIfpaRanking)
+	defer stmtInsertIfpaRanking.Close()
+	now := time.Now().Unix()
+	for _, ranking := range rankings {
+		if _, err := stmtInsertIfpaRanking.Exec(ranking.IfpaId,
+			ranking.Name,
+			ranking.Rank,
+			ranking.Wppr,
+			ranking.Country,
+			now); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlInsertIfpaRanking,
+				"ifpa_id":   ranking.IfpaId,
+				"error":     err,
+			}).Error("unable to transactionally insert IFPA ranking")
+			tx.Rollback()
+			return false
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("unable to commit transaction for replacing IFPA rankings")
+		return false
+	}
+	return true
+}
+
+func closePreparedIfpaStatements() {
+	log.Debug("closing prepared IFPA statements")
+	stmtSelectIfpaRanking.Close()
+	stmtSelectIfpaRankingsSnapshot.Close()
+	log.Debug("prepared IFPA statements closed")
+}
+
+func prepareIfpaStatements() {
+	log.Debug("preparing IFPA statements")
+	stmtSelectIfpaRanking = prepare(sqlSelectIfpaRanking)
+	stmtSelectIfpaRankingsSnapshot = prepare(sqlSelectIfpaRankingsSnapshot)
+	log.Debug("IFPA statements prepared")
+}
@@ -0,0 +1,284 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/mikefero/tpl/log"
+)
+
+type League struct {
+	Id            int
+	Name          string
+	Active        bool
+	SelectionMode string
+	NoRepeat      bool
+	UsageLimit    sql.NullInt64
+	SubLimit      sql.NullInt64
+	Games         int
+}
+
+type Season struct {
+	Id        int
+	LeagueId  int
+	Name      string
+	StartDate int64
+	EndDate   sql.NullInt64
+}
+
+type Standing struct {
+	TeamId   int
+	TeamName string
+	Played   int
+	Wins     int
+	Losses   int
+	Ties     int
+	Points   int
+	SubGames int
+}
+
+var stmtSelectLeagues *sql.Stmt
+var stmtCountLeagues *sql.Stmt
+var stmtSelectLeague *sql.Stmt
+var stmtUpdateLeagueSelection *sql.Stmt
+var stmtSelectSeasons *sql.Stmt
+var stmtCountSeasons *sql.Stmt
+var stmtSelectSeason *sql.Stmt
+var stmtSelectStandings *sql.Stmt
+
+func GetLeagues(active sql.NullBool, limit int, offset int) []League {
+	rows, err := stmtSelectLeagues.Query(active, limit, offset)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectLeagues,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var leagues []League
+	for rows.Next() {
+		var league League
+		if err := rows.Scan(&league.Id,
+			&league.Name,
+			&league.Active,
+			&league.SelectionMode,
+			&league.NoRepeat,
+			&league.UsageLimit,
+			&league.SubLimit,
+			&league.Games); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectLeagues,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for league")
+		} else {
+			leagues = append(leagues, league)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectLeagues,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for league")
+	}
+
+	return leagues
+}
+
+func CountLeagues(active sql.NullBool) int {
+	var count int
+	err := stmtCountLeagues.QueryRow(active).Scan(&count)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlCountLeagues,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+	}
+
+	return count
+}
+
+func GetLeague(id int) *League {
+	var league League
+	err := stmtSelectLeague.QueryRow(id).Scan(&league.Id,
+		&league.Name,
+		&league.Active,
+		&league.SelectionMode,
+		&league.NoRepeat,
+		&league.UsageLimit,
+		&league.SubLimit,
+		&league.Games)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectLeague,
+				"id":        id,
+				"error":     err,
+			}).Error("unable to execute prepared SQL statement")
+		}
+		return nil
+	}
+
+	return &league
+}
+
+// UpdateLeagueSelection changes how machines are selected for the matches of
+// a league and how many games each match has; the usage limit caps how often
+// a machine is selected each season.
+func UpdateLeagueSelection(id int, mode string, noRepeat bool, usageLimit sql.NullInt64, games int) bool {
+	result, err := stmtUpdateLeagueSelection.Exec(mode, noRepeat, usageLimit, games, id)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlUpdateLeagueSelection,
+			"id":        id,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return false
+	}
+	updated, _ := result.RowsAffected()
+
+	return updated > 0
+}
+
+func GetSeasons(leagueId int, limit int, offset int) []Season {
+	rows, err := stmtSelectSeasons.Query(leagueId, limit, offset)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectSeasons,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var seasons []Season
+	for rows.Next() {
+		var season Season
+		if err := rows.Scan(&season.Id,
+			&season.LeagueId,
+			&season.Name,
+			&season.StartDate,
+			&season.EndDate); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectSeasons,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for season")
+		} else {
+			seasons = append(seasons, season)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectSeasons,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for season")
+	}
+
+	return seasons
+}
+
+func CountSeasons(leagueId int) int {
+	var count int
+	err := stmtCountSeasons.QueryRow(leagueId).Scan(&count)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlCountSeasons,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+	}
+
+	return count
+}
+
+func GetSeason(id int) *Season {
+	var season Season
+	err := stmtSelectSeason.QueryRow(id).Scan(&season.Id,
+		&season.LeagueId,
+		&season.Name,
+		&season.StartDate,
+		&season.EndDate)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectSeason,
+				"id":        id,
+				"error":     err,
+			}).Error("unable to execute prepared SQL statement")
+		}
+		return nil
+	}
+
+	return &season
+}
+
+func GetStandings(seasonId int) []Standing {
+	rows, err := stmtSelectStandings.Query(seasonId)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectStandings,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var standings []Standing
+	for rows.Next() {
+		var standing Standing
+		if err := rows.Scan(&standing.TeamId,
+			&standing.TeamName,
+			&standing.Played,
+			&standing.Wins,
+			&standing.Losses,
+			&standing.Ties,
+			&standing.Points,
+			&standing.SubGames); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectStandings,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for standing")
+		} else {
+			standings = append(standings, standing)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectStandings,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for standing")
+	}
+
+	return standings
+}
+
+func closePreparedLeaguesStatements() {
+	log.Debug("closing prepared leagues statements")
+	stmtSelectLeagues.Close()
+	stmtCountLeagues.Close()
+	stmtSelectLeague.Close()
+	stmtUpdateLeagueSelection.Close()
+	stmtSelectSeasons.Close()
+	stmtCountSeasons.Close()
+	stmtSelectSeason.Close()
+	stmtSelectStandings.Close()
+	log.Debug("prepared leagues statements closed")
+}
+
+func prepareLeaguesStatements() {
+	log.Debug("preparing leagues statements")
+	stmtSelectLeagues = prepare(sqlSelectLeagues)
+	stmtCountLeagues = prepare(sqlCountLeagues)
+	stmtSelectLeague = prepare(sqlSelectLeague)
+	stmtUpdateLeagueSelection = prepare(sqlUpdateLeagueSelection)
+	stmtSelectSeasons = prepare(sqlSelectSeasons)
+	stmtCountSeasons = prepare(sqlCountSeasons)
+	stmtSelectSeason = prepare(sqlSelectSeason)
+	stmtSelectStandings = prepare(sqlSelectStandings)
+	log.Debug("leagues statements prepared")
+}
@@ -0,0 +1,210 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/mikefero/tpl/log"
+)
+
+const LineupSourceManual = "manual"
+const LineupSourcePinballMap = "pinballmap"
+
+type LineupPeriod struct {
+	AddedAt   int64
+	RemovedAt sql.NullInt64
+}
+
+type LineupEntry struct {
+	OpdbId           string
+	Name             string
+	Active           bool
+	Source           sql.NullString
+	PinballMapActive bool
+}
+
+func (entry LineupEntry) IsOverride() bool {
+	return entry.Source.Valid && entry.Source.String == LineupSourceManual
+}
+
+var stmtSelectMachineLineupHistory *sql.Stmt
+var stmtSelectLineup *sql.Stmt
+var stmtSelectLineupEntry *sql.Stmt
+
+func scanLineupEntry(scanner interface{ Scan(...interface{}) error }) (LineupEntry, error) {
+	var entry LineupEntry
+	err := scanner.Scan(&entry.OpdbId,
+		&entry.Name,
+		&entry.Active,
+		&entry.Source,
+		&entry.PinballMapActive)
+	return entry, err
+}
+
+// txRecordLineupHistory closes the lineup periods of machines that are no
+// longer active and opens periods for machines that became active.
+func txRecordLineupHistory(tx *sql.Tx) {
+	now := time.Now().Unix()
+	if _, err := tx.Exec(sqlCloseMachineLineupHistory, now); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlCloseMachineLineupHistory,
+			"error":     err,
+		}).Warn("unable to transactionally close machine lineup history")
+	}
+	if _, err := tx.Exec(sqlOpenMachineLineupHistory, now); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlOpenMachineLineupHistory,
+			"error":     err,
+		}).Warn("unable to transactionally open machine lineup history")
+	}
+}
+
+// updateLineup applies a manual lineup change and records the lineup history
+// in a single transaction.
+func updateLineup(statement string, args ...interface{}) bool {
+	tx, err := session.Begin()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("unable to begin transaction for updating lineup")
+		return false
+	}
+
+	result, err := tx.Exec(statement, args...)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": statement,
+			"error":     err,
+		}).Error("unable to transactionally update lineup")
+		tx.Rollback()
+		return false
+	}
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		tx.Rollback()
+		return false
+	}
+	txRecordLineupHistory(tx)
+
+	if err := tx.Commit(); err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("unable to commit transaction for updating lineup")
+		return false
+	}
+	return true
+}
+
+// SetLineupOverride adds or removes a machine from the lineup by hand; the
+// override is kept when the lineup is synchronized with Pinball Map.
+func SetLineupOverride(opdbId string, active bool) bool {
+	return updateLineup(sqlUpdateLineupOverride, active, opdbId)
+}
+
+// ClearLineupOverride returns a machine to the state listed by Pinball Map.
+func ClearLineupOverride(opdbId string) bool {
+	return updateLineup(sqlClearLineupOverride, opdbId)
+}
+
+// GetLineup returns the active machines along with machines that were
+// removed by hand.
+func GetLineup() []LineupEntry {
+	rows, err := stmtSelectLineup.Query()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectLineup,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var entries []LineupEntry
+	for rows.Next() {
+		if entry, err := scanLineupEntry(rows); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectLineup,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for lineup")
+		} else {
+			entries = append(entries, entry)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectLineup,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for lineup")
+	}
+
+	return entries
+}
+
+func GetLineupEntry(opdbId string) *LineupEntry {
+	entry, err := scanLineupEntry(stmtSelectLineupEntry.QueryRow(opdbId))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectLineupEntry,
+				"opdb_id":   opdbId,
+				"error":     err,
+			}).Error("unable to execute prepared SQL statement")
+		}
+		return nil
+	}
+
+	return &entry
+}
+
+func GetMachineLineupHistory(opdbId string) []LineupPeriod {
+	rows, err := stmtSelectMachineLineupHistory.Query(opdbId)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectMachineLineupHistory,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var periods []LineupPeriod
+	for rows.Next() {
+		var period LineupPeriod
+		if err := rows.Scan(&period.AddedAt,
+			&period.RemovedAt); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectMachineLineupHistory,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for machine lineup period")
+		} else {
+			periods = append(periods, period)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectMachineLineupHistory,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for machine lineup period")
+	}
+
+	return periods
+}
+
+func closePreparedLineupStatements() {
+	log.Debug("closing prepared lineup statements")
+	stmtSelectMachineLineupHistory.Close()
+	stmtSelectLineup.Close()
+	stmtSelectLineupEntry.Close()
+	log.Debug("prepared lineup statements closed")
+}
+
+func prepareLineupStatements() {
+	log.Debug("preparing lineup statements")
+	stmtSelectMachineLineupHistory = prepare(sqlSelectMachineLineupHistory)
+	stmtSelectLineup = prepare(sqlSelectLineup)
+	stmtSelectLineupEntry = prepare(sqlSelectLineupEntry)
+	log.Debug("lineup statements prepared")
+}
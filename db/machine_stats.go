@@ -0,0 +1,255 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/mikefero/tpl/log"
+)
+
+type MachineMetadata struct {
+	Type        sql.NullString
+	Display     sql.NullString
+	PlayerCount sql.NullInt64
+	Description sql.NullString
+}
+
+type MachineGame struct {
+	ResultId   int
+	MatchId    int
+	Week       sql.NullInt64
+	Date       sql.NullInt64
+	Team1Name  string
+	Team2Name  sql.NullString
+	Team1Score sql.NullInt64
+	Team2Score sql.NullInt64
+	PhotoKey   sql.NullString
+}
+
+type MachineScore struct {
+	UserId   int
+	UserName string
+	Score    int64
+	Date     sql.NullInt64
+}
+
+// PlayedGame is a league result along with when and on which machine it was
+// played.
+type PlayedGame struct {
+	Result      Result
+	MachineName string
+	SeasonId    int
+	Team1Id     int
+	Team2Id     sql.NullInt64
+	Date        sql.NullInt64
+}
+
+var stmtSelectMachineMetadata *sql.Stmt
+var stmtSelectMachineGames *sql.Stmt
+var stmtCountMachineGames *sql.Stmt
+var stmtSelectMachineTopScores *sql.Stmt
+var stmtSelectMachineAverageWinningScore *sql.Stmt
+var stmtSelectPlayedGames *sql.Stmt
+
+func GetMachineMetadata(opdbId string) MachineMetadata {
+	var metadata MachineMetadata
+	err := stmtSelectMachineMetadata.QueryRow(opdbId).Scan(&metadata.Type,
+		&metadata.Display,
+		&metadata.PlayerCount,
+		&metadata.Description)
+	if err != nil && err != sql.ErrNoRows {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectMachineMetadata,
+			"opdb_id":   opdbId,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+	}
+
+	return metadata
+}
+
+func GetMachineGames(opdbId string, limit int) []MachineGame {
+	rows, err := stmtSelectMachineGames.Query(opdbId, limit)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectMachineGames,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var games []MachineGame
+	for rows.Next() {
+		var game MachineGame
+		if err := rows.Scan(&game.ResultId,
+			&game.MatchId,
+			&game.Week,
+			&game.Date,
+			&game.Team1Name,
+			&game.Team2Name,
+			&game.Team1Score,
+			&game.Team2Score,
+			&game.PhotoKey); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectMachineGames,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for machine game")
+		} else {
+			games = append(games, game)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectMachineGames,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for machine game")
+	}
+
+	return games
+}
+
+func CountMachineGames(opdbId string) int {
+	var count int
+	err := stmtCountMachineGames.QueryRow(opdbId).Scan(&count)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlCountMachineGames,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+	}
+
+	return count
+}
+
+func GetMachineTopScores(opdbId string, limit int) []MachineScore {
+	rows, err := stmtSelectMachineTopScores.Query(opdbId, limit)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectMachineTopScores,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var scores []MachineScore
+	for rows.Next() {
+		var score MachineScore
+		if err := rows.Scan(&score.UserId,
+			&score.UserName,
+			&score.Score,
+			&score.Date); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectMachineTopScores,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for machine score")
+		} else {
+			scores = append(scores, score)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectMachineTopScores,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for machine score")
+	}
+
+	return scores
+}
+
+func GetMachineAverageWinningScore(opdbId string) sql.NullFloat64 {
+	var average sql.NullFloat64
+	err := stmtSelectMachineAverageWinningScore.QueryRow(opdbId).Scan(&average)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectMachineAverageWinningScore,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+	}
+
+	return average
+}
+
+// GetPlayedGames returns every league result in the order it was played.
+func GetPlayedGames() []PlayedGame {
+	rows, err := stmtSelectPlayedGames.Query()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectPlayedGames,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var games []PlayedGame
+	for rows.Next() {
+		var game PlayedGame
+		if err := rows.Scan(&game.Result.Id,
+			&game.Result.MatchId,
+			&game.Result.OpdbId,
+			&game.Result.Team1APlayerId,
+			&game.Result.Team1APlayerScore,
+			&game.Result.Team1BPlayerId,
+			&game.Result.Team1BPlayerScore,
+			&game.Result.Team1Score,
+			&game.Result.Team2APlayerId,
+			&game.Result.Team2APlayerScore,
+			&game.Result.Team2BPlayerId,
+			&game.Result.Team2BPlayerScore,
+			&game.Result.Team2Score,
+			&game.Result.PhotoKey,
+			&game.Result.Team1APlayerSub,
+			&game.Result.Team1BPlayerSub,
+			&game.Result.Team2APlayerSub,
+			&game.Result.Team2BPlayerSub,
+			&game.MachineName,
+			&game.SeasonId,
+			&game.Team1Id,
+			&game.Team2Id,
+			&game.Date); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectPlayedGames,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for played game")
+		} else {
+			games = append(games, game)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectPlayedGames,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for played game")
+	}
+
+	return games
+}
+
+func closePreparedMachineStatsStatements() {
+	log.Debug("closing prepared machine stats statements")
+	stmtSelectMachineMetadata.Close()
+	stmtSelectMachineGames.Close()
+	stmtCountMachineGames.Close()
+	stmtSelectMachineTopScores.Close()
+	stmtSelectMachineAverageWinningScore.Close()
+	stmtSelectPlayedGames.Close()
+	log.Debug("prepared machine stats statements closed")
+}
+
+func prepareMachineStatsStatements() {
+	log.Debug("preparing machine stats statements")
+	stmtSelectMachineMetadata = prepare(sqlSelectMachineMetadata)
+	stmtSelectMachineGames = prepare(sqlSelectMachineGames)
+	stmtCountMachineGames = prepare(sqlCountMachineGames)
+	stmtSelectMachineTopScores = prepare(sqlSelectMachineTopScores)
+	stmtSelectMachineAverageWinningScore = prepare(sqlSelectMachineAverageWinningScore)
+	stmtSelectPlayedGames = prepare(sqlSelectPlayedGames)
+	log.Debug("machine stats statements prepared")
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -14,9 +15,7 @@ import (
 	json "github.com/tidwall/gjson"
 )
 
-const tplDatabasePath = "db/tpl.db"
-const tplInitialOpdbExportPath = "db/opdb.json"
-const tplInitialLocationId = 4907 // The Pinball Lounge
+var ErrInvalidOpdbExport = errors.New("OPDB export must be a JSON array of machines")
 
 type Machine struct {
 	OpdbId             string
@@ -28,17 +27,70 @@ type Machine struct {
 	BackglassImageUuid sql.NullString
 	UpdatedAt          int
 	Active             bool
+	OutOfOrder         bool
+}
+
+type Manufacturer struct {
+	Id        int
+	Name      string
+	FullName  string
+	UpdatedAt int
+}
+
+type MachineFilter struct {
+	ManufacturerId int
+	Name           string
+	Decade         int
+	Feature        string
+	Sort           string
+	Limit          int
+	Offset         int
+}
+
+type Edition struct {
+	Key     string
+	Name    string
+	Feature string
+}
+
+const SortMachinesByName = "name"
+const SortMachinesByYear = "year"
+const SortMachinesByManufacturer = "manufacturer"
+
+var MachineSorts = []string{
+	SortMachinesByName,
+	SortMachinesByYear,
+	SortMachinesByManufacturer,
+}
+
+// Editions are the OPDB features that distinguish the models of a title
+var Editions = []Edition{
+	{Key: "pro", Name: "Pro", Feature: "Pro edition"},
+	{Key: "premium", Name: "Premium", Feature: "Premium edition"},
+	{Key: "le", Name: "LE", Feature: "Limited edition"},
+	{Key: "vault", Name: "Vault", Feature: "Vault edition"},
 }
 
 var stmtSelectIdFromFeatures *sql.Stmt
-var stmtSelectAllActiveMachines *sql.Stmt
 var stmtSelectFeatures *sql.Stmt
-
-func GetAllActiveMachines() []Machine {
-	rows, err := stmtSelectAllActiveMachines.Query()
+var stmtSelectMachine *sql.Stmt
+var stmtSelectActiveMachines *sql.Stmt
+var stmtCountActiveMachines *sql.Stmt
+var stmtSelectManufacturer *sql.Stmt
+var stmtSelectActiveManufacturers *sql.Stmt
+var stmtSelectActiveDecades *sql.Stmt
+
+func GetActiveMachines(filter MachineFilter) []Machine {
+	rows, err := stmtSelectActiveMachines.Query(filter.ManufacturerId,
+		filter.Name,
+		filter.Decade,
+		filter.Feature,
+		filter.Sort,
+		filter.Limit,
+		filter.Offset)
 	if err != nil {
 		log.WithFields(log.Fields{
-			"statement": sqlSelectAllActiveMachines,
+			"statement": sqlSelectActiveMachines,
 			"error":     err,
 		}).Error("unable to execute prepared SQL statement")
 		return nil
@@ -56,9 +108,10 @@ func GetAllActiveMachines() []Machine {
 			&activeMachine.Name,
 			&activeMachine.ManufactureDate,
 			&activeMachine.BackglassImageUuid,
-			&activeMachine.UpdatedAt); err != nil {
+			&activeMachine.UpdatedAt,
+			&activeMachine.OutOfOrder); err != nil {
 			log.WithFields(log.Fields{
-				"statement": sqlSelectAllActiveMachines,
+				"statement": sqlSelectActiveMachines,
 				"result":    rows,
 				"error":     err,
 			}).Warn("unable to scan result for active machine")
@@ -68,7 +121,7 @@ func GetAllActiveMachines() []Machine {
 	}
 	if err := rows.Err(); err != nil {
 		log.WithFields(log.Fields{
-			"statement": sqlSelectAllActiveMachines,
+			"statement": sqlSelectActiveMachines,
 			"result":    rows,
 			"error":     err,
 		}).Error("unable to scan result for active machine")
@@ -77,6 +130,159 @@ func GetAllActiveMachines() []Machine {
 	return activeMachines
 }
 
+func CountActiveMachines(filter MachineFilter) int {
+	var count int
+	err := stmtCountActiveMachines.QueryRow(filter.ManufacturerId,
+		filter.Name,
+		filter.Decade,
+		filter.Feature).Scan(&count)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlCountActiveMachines,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+	}
+
+	return count
+}
+
+func GetMachine(opdbId string) *Machine {
+	var machine Machine
+	err := stmtSelectMachine.QueryRow(opdbId).Scan(&machine.OpdbId,
+		&machine.ManufacturerId,
+		&machine.IpdbId,
+		&machine.FeaturesId,
+		&machine.Name,
+		&machine.ManufactureDate,
+		&machine.BackglassImageUuid,
+		&machine.UpdatedAt,
+		&machine.Active,
+		&machine.OutOfOrder)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectMachine,
+				"opdb_id":   opdbId,
+				"error":     err,
+			}).Error("unable to execute prepared SQL statement")
+		}
+		return nil
+	}
+
+	return &machine
+}
+
+func GetManufacturer(id int) *Manufacturer {
+	var manufacturer Manufacturer
+	err := stmtSelectManufacturer.QueryRow(id).Scan(&manufacturer.Id,
+		&manufacturer.Name,
+		&manufacturer.FullName,
+		&manufacturer.UpdatedAt)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectManufacturer,
+				"id":        id,
+				"error":     err,
+			}).Error("unable to execute prepared SQL statement")
+		}
+		return nil
+	}
+
+	return &manufacturer
+}
+
+func GetActiveManufacturers() []Manufacturer {
+	rows, err := stmtSelectActiveManufacturers.Query()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectActiveManufacturers,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var manufacturers []Manufacturer
+	for rows.Next() {
+		var manufacturer Manufacturer
+		if err := rows.Scan(&manufacturer.Id,
+			&manufacturer.Name,
+			&manufacturer.FullName,
+			&manufacturer.UpdatedAt); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectActiveManufacturers,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for manufacturer")
+		} else {
+			manufacturers = append(manufacturers, manufacturer)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectActiveManufacturers,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for manufacturer")
+	}
+
+	return manufacturers
+}
+
+func GetActiveDecades() []int {
+	rows, err := stmtSelectActiveDecades.Query()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectActiveDecades,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var decades []int
+	for rows.Next() {
+		var decade int
+		if err := rows.Scan(&decade); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectActiveDecades,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for decade")
+		} else {
+			decades = append(decades, decade)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectActiveDecades,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for decade")
+	}
+
+	return decades
+}
+
+func GetEdition(key string) *Edition {
+	for _, edition := range Editions {
+		if edition.Key == key {
+			return &edition
+		}
+	}
+	return nil
+}
+
+func IsMachineSort(sort string) bool {
+	for _, s := range MachineSorts {
+		if s == sort {
+			return true
+		}
+	}
+	return false
+}
+
 func GetFeatures(id int) string {
 	var features string
 	err := stmtSelectFeatures.QueryRow(id).Scan(&features)
@@ -94,7 +300,12 @@ func closePreparedMachinesStatements() {
 	log.Debug("closing prepared machines statements")
 	stmtSelectIdFromFeatures.Close()
 	stmtSelectFeatures.Close()
-	stmtSelectAllActiveMachines.Close()
+	stmtSelectMachine.Close()
+	stmtSelectActiveMachines.Close()
+	stmtCountActiveMachines.Close()
+	stmtSelectManufacturer.Close()
+	stmtSelectActiveManufacturers.Close()
+	stmtSelectActiveDecades.Close()
 	log.Debug("prepared machines statements closed")
 }
 
@@ -102,15 +313,17 @@ func prepareMachinesStatements() {
 	log.Debug("preparing machines statements")
 	stmtSelectIdFromFeatures = prepare(sqlSelectIdFromFeatures)
 	stmtSelectFeatures = prepare(sqlSelectFeatures)
-	stmtSelectAllActiveMachines = prepare(sqlSelectAllActiveMachines)
+	stmtSelectMachine = prepare(sqlSelectMachine)
+	stmtSelectActiveMachines = prepare(sqlSelectActiveMachines)
+	stmtCountActiveMachines = prepare(sqlCountActiveMachines)
+	stmtSelectManufacturer = prepare(sqlSelectManufacturer)
+	stmtSelectActiveManufacturers = prepare(sqlSelectActiveManufacturers)
+	stmtSelectActiveDecades = prepare(sqlSelectActiveDecades)
 	log.Debug("machines statements prepared")
 }
 
-func initPinballMachineFeaturesTable(path string) {
-	log.WithFields(log.Fields{
-		"path": path,
-	}).Debug("initializing features tables")
-	file, err := os.Open("db/opdb.json")
+func readOpdbExport(path string) []byte {
+	file, err := os.Open(path)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"path":  path,
@@ -127,6 +340,15 @@ func initPinballMachineFeaturesTable(path string) {
 		}).Panic("failed reading Open Pinball Database JSON file")
 	}
 
+	return data
+}
+
+func initPinballMachineFeaturesTable(path string) {
+	log.WithFields(log.Fields{
+		"path": path,
+	}).Debug("initializing features tables")
+	data := readOpdbExport(path)
+
 	tx, err := session.Begin()
 	if err != nil {
 		log.WithFields(log.Fields{
@@ -135,6 +357,19 @@ func initPinballMachineFeaturesTable(path string) {
 	}
 
 	txExec(tx, featuresTables)
+	txImportFeatures(tx, data)
+
+	err = tx.Commit()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Panic("unable to commit transaction for TPL features table creation and initialization")
+	}
+}
+
+// txImportFeatures adds the feature combinations of an OPDB export that are
+// not known yet.
+func txImportFeatures(tx *sql.Tx, data []byte) {
 	stmtInsertFeatures := txPrepare(tx, sqlInsertFeatures)
 	defer stmtInsertFeatures.Close()
 
@@ -161,45 +396,69 @@ func initPinballMachineFeaturesTable(path string) {
 		}
 		return true
 	})
-
-	err = tx.Commit()
-	if err != nil {
-		log.WithFields(log.Fields{
-			"error": err,
-		}).Panic("unable to commit transaction for TPL features table creation and initialization")
-	}
 }
 
 func initPinballMachineTables(tx *sql.Tx, path string) {
 	log.WithFields(log.Fields{
 		"path": path,
 	}).Debug("initializing machine and machine manufacturers tables")
-	file, err := os.Open("db/opdb.json")
+	data := readOpdbExport(path)
+
+	txExec(tx, machineManufacturersTable)
+	txExec(tx, machinesTable)
+	txImportMachines(tx, data)
+
+	log.Debug("machine and machine manufacturers tables initialized")
+}
+
+// ImportOpdb updates the machines catalog from an OPDB export and returns the
+// number of machines imported. Machines keep their lineup state, the search
+// index is rebuilt in the same transaction and names are matched against the
+// new catalog.
+func ImportOpdb(path string) (int, error) {
+	file, err := os.Open(path)
 	if err != nil {
-		log.WithFields(log.Fields{
-			"path":  path,
-			"error": err,
-		}).Panic("failed reading Open Pinball Database JSON file")
+		return 0, err
 	}
 	defer file.Close()
-
 	data, err := ioutil.ReadAll(file)
 	if err != nil {
-		log.WithFields(log.Fields{
-			"path":  path,
-			"error": err,
-		}).Panic("failed reading Open Pinball Database JSON file")
+		return 0, err
+	}
+	if !json.ValidBytes(data) || !json.ParseBytes(data).IsArray() {
+		return 0, ErrInvalidOpdbExport
 	}
 
-	txExec(tx, machineManufacturersTable)
-	txExec(tx, machinesTable)
+	tx, err := session.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	txImportFeatures(tx, data)
+	count := txImportMachines(tx, data)
+	if fullTextSearch {
+		txRebuildMachinesSearch(tx)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	resetSearchVocabulary()
+	resetMachineCatalog()
 
+	return count, nil
+}
+
+// txImportMachines inserts or updates the machines and manufacturers of an
+// OPDB export without changing the lineup state of known machines, and
+// returns the number of machines in the export.
+func txImportMachines(tx *sql.Tx, data []byte) int {
 	stmtInsertMachines := txPrepare(tx, sqlInsertMachines)
 	stmtInsertMachineManufacturers := txPrepare(tx, sqlInsertMachineManufacturers)
-	stmtSelectIdFromFeatures := prepare(sqlSelectIdFromFeatures)
+	stmtSelectIdFromFeatures := txPrepare(tx, sqlSelectIdFromFeatures)
 	defer stmtInsertMachines.Close()
 	defer stmtInsertMachineManufacturers.Close()
 	defer stmtSelectIdFromFeatures.Close()
+	count := 0
 	re := regexp.MustCompile(`[0-9a-fA-F]{8}\-[0-9a-fA-F]{4}\-[0-9a-fA-F]{4}\-[0-9a-fA-F]{4}\-[0-9a-fA-F]{12}`)
 
 	json.ParseBytes(data).ForEach(func(key, value json.Result) bool {
@@ -207,6 +466,10 @@ func initPinballMachineTables(tx *sql.Tx, path string) {
 		ipdbId := value.Get("ipdb_id")
 		name := value.Get("name")
 		mfrDate := value.Get("manufacture_date")
+		machineType := value.Get("type")
+		display := value.Get("display")
+		playerCount := value.Get("player_count")
+		description := value.Get("description")
 		backglassImageUrl := value.Get("images.#(type=backglass).urls.large")
 		updatedAtValue, _ := time.Parse("2006-01-02", value.Get("updated_at").String())
 		mfrId := value.Get("manufacturer.manufacturer_id")
@@ -217,8 +480,8 @@ func initPinballMachineTables(tx *sql.Tx, path string) {
 		// Determine the features id
 		featuresArray := value.Get("features").Array()
 		var featuresId sql.NullInt64
+		var features []string
 		if len(featuresArray) > 0 {
-			var features []string
 			for _, feature := range featuresArray {
 				features = append(features, feature.String())
 			}
@@ -241,6 +504,10 @@ func initPinballMachineTables(tx *sql.Tx, path string) {
 			name.String(),
 			getValueTime(mfrDate),
 			getValueStringRegex(backglassImageUrl, re),
+			getValueString(machineType),
+			getValueString(display),
+			getValueInt(playerCount),
+			getValueString(description),
 			updatedAtValue.Unix(),
 			false)
 		log.WithFields(log.Fields{
@@ -251,6 +518,9 @@ func initPinballMachineTables(tx *sql.Tx, path string) {
 			"name":              name.String(),
 			"manufacture_date":  getValueTime(mfrDate),
 			"backglassImageUrl": backglassImageUrl.String(),
+			"type":              machineType.String(),
+			"display":           display.String(),
+			"player_count":      playerCount.Int(),
 			"updated_at":        updatedAtValue.Unix(),
 			"active":            false,
 		}).Trace("transactionally execute machines SQL insert statement")
@@ -263,6 +533,9 @@ func initPinballMachineTables(tx *sql.Tx, path string) {
 				"name":                 name.String(),
 				"manufacture_date":     mfrDate.String(),
 				"backglass_image_uuid": getValueStringRegex(backglassImageUrl, re),
+				"type":                 machineType.String(),
+				"display":              display.String(),
+				"player_count":         playerCount.Int(),
 				"updated_at":           updatedAtValue.Unix(),
 				"active":               false,
 				"error":                err,
@@ -292,10 +565,19 @@ func initPinballMachineTables(tx *sql.Tx, path string) {
 			}).Panic("unable to transactionally execute machine_manufacturers SQL insert statement")
 		}
 
+		count++
 		return true
 	})
 
-	log.Debug("machine and machine manufacturers tables initialized")
+	return count
+}
+
+// SyncActiveMachines refreshes the lineup from Pinball Map; machines that were
+// added or removed by hand keep their state.
+func SyncActiveMachines() {
+	if config.LocationId != 0 {
+		assignActiveMachines(config.LocationId)
+	}
 }
 
 func assignActiveMachines(locationId int) {
@@ -365,6 +647,8 @@ func assignActiveMachines(locationId int) {
 
 		return true
 	})
+	txExec(tx, sqlApplyPinballMapActiveMachines)
+	txRecordLineupHistory(tx)
 
 	err = tx.Commit()
 	if err != nil {
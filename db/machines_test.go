@@ -0,0 +1,99 @@
+package db
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// testOpdbExport is an OPDB export of two machines; the name of the first one
+// changes between imports.
+const testOpdbExport = `[
+  {
+    "opdb_id": "G4ODR-MDXEy",
+    "name": %q,
+    "manufacturer": {"manufacturer_id": 4, "name": "Bally", "full_name": "Bally Manufacturing Co.", "updated_at": "2018-03-11"},
+    "type": "ss",
+    "features": ["Dot-matrix display"],
+    "updated_at": "2021-01-01"
+  },
+  {
+    "opdb_id": "G5pe4-MePZv",
+    "name": "Medieval Madness",
+    "manufacturer": {"manufacturer_id": 1, "name": "Williams", "full_name": "Williams Electronic Games", "updated_at": "2018-03-11"},
+    "type": "ss",
+    "updated_at": "2021-01-01"
+  }
+]`
+
+func writeTestOpdbExport(t *testing.T, name string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "opdb.json")
+	if err := ioutil.WriteFile(path, []byte(fmt.Sprintf(testOpdbExport, name)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// openTestCatalog replaces the TPL database with one holding only the machines
+// catalog and its search index when FTS5 is available.
+func openTestCatalog(t *testing.T) {
+	t.Helper()
+	openTestDatabase(t, featuresTables, machineManufacturersTable, machinesTable)
+	previous := fullTextSearch
+	t.Cleanup(func() {
+		fullTextSearch = previous
+		resetSearchVocabulary()
+		resetMachineCatalog()
+	})
+	maybeCreateMachinesSearchIndex()
+	fullTextSearch = hasTable(t, "machines_search")
+}
+
+func TestImportOpdb(t *testing.T) {
+	openTestCatalog(t)
+	if count, err := ImportOpdb(writeTestOpdbExport(t, "The Addams Family")); err != nil || count != 2 {
+		t.Fatalf("expected 2 machines imported, got %d: %v", count, err)
+	}
+	if _, err := session.Exec(`UPDATE machines SET active = true, active_source = 'manual', out_of_order = true WHERE opdb_id = 'G4ODR-MDXEy'`); err != nil {
+		t.Fatal(err)
+	}
+	searchVocabularyLoaded = true
+	loadedMachineCatalog = newMachineCatalog(nil)
+
+	if count, err := ImportOpdb(writeTestOpdbExport(t, "The Addams Family Special Collectors Edition")); err != nil || count != 2 {
+		t.Fatalf("expected 2 machines imported, got %d: %v", count, err)
+	}
+	var name, source string
+	var active, outOfOrder bool
+	if err := session.QueryRow(`SELECT name, active, active_source, out_of_order FROM machines WHERE opdb_id = 'G4ODR-MDXEy'`).Scan(&name, &active, &source, &outOfOrder); err != nil {
+		t.Fatal(err)
+	}
+	if name != "The Addams Family Special Collectors Edition" {
+		t.Errorf("expected the machine name to be updated, got %q", name)
+	}
+	if !active || source != "manual" || !outOfOrder {
+		t.Errorf("expected the lineup state to be kept, got active %v, source %q, out of order %v", active, source, outOfOrder)
+	}
+	if searchVocabularyLoaded {
+		t.Error("expected the search vocabulary to be reset")
+	}
+	if loadedMachineCatalog != nil {
+		t.Error("expected the machine catalog to be reset")
+	}
+}
+
+func TestImportOpdbInvalidExport(t *testing.T) {
+	openTestCatalog(t)
+	path := filepath.Join(t.TempDir(), "opdb.json")
+	if err := ioutil.WriteFile(path, []byte(`{"opdb_id": "G4ODR-MDXEy"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ImportOpdb(path); err != ErrInvalidOpdbExport {
+		t.Errorf("expected %v, got %v", ErrInvalidOpdbExport, err)
+	}
+	if _, err := ImportOpdb(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected an error for a missing export")
+	}
+}
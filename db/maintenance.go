@@ -0,0 +1,277 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/mikefero/tpl/log"
+)
+
+const TicketStateOpen = "open"
+const TicketStateInProgress = "in_progress"
+const TicketStateFixed = "fixed"
+
+var TicketStates = []string{
+	TicketStateOpen,
+	TicketStateInProgress,
+	TicketStateFixed,
+}
+
+type Ticket struct {
+	Id           int
+	OpdbId       string
+	MachineName  string
+	ReporterId   int
+	ReporterName string
+	Description  string
+	State        string
+	CreatedAt    int64
+	UpdatedAt    int64
+}
+
+type TicketNote struct {
+	Id         int
+	TicketId   int
+	AuthorId   int
+	AuthorName string
+	Note       string
+	CreatedAt  int64
+}
+
+var stmtUpdateMachineOutOfOrder *sql.Stmt
+var stmtInsertMaintenanceTicket *sql.Stmt
+var stmtSelectMaintenanceTickets *sql.Stmt
+var stmtSelectMaintenanceTicket *sql.Stmt
+var stmtUpdateMaintenanceTicketState *sql.Stmt
+var stmtSelectMaintenanceNotes *sql.Stmt
+
+func IsTicketState(state string) bool {
+	for _, s := range TicketStates {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// SetMachineOutOfOrder flags a machine that cannot be played; out-of-order
+// machines remain in the lineup but are excluded from league play.
+func SetMachineOutOfOrder(opdbId string, outOfOrder bool) bool {
+	result, err := stmtUpdateMachineOutOfOrder.Exec(outOfOrder, opdbId)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlUpdateMachineOutOfOrder,
+			"opdb_id":   opdbId,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return false
+	}
+	updated, _ := result.RowsAffected()
+
+	return updated > 0
+}
+
+func CreateTicket(opdbId string, reporterId int, description string) *Ticket {
+	now := time.Now().Unix()
+	result, err := stmtInsertMaintenanceTicket.Exec(opdbId, reporterId, description, now, now)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement":   sqlInsertMaintenanceTicket,
+			"opdb_id":     opdbId,
+			"reporter_id": reporterId,
+			"error":       err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	id, _ := result.LastInsertId()
+
+	return GetTicket(int(id))
+}
+
+func scanTicket(scanner interface{ Scan(...interface{}) error }) (Ticket, error) {
+	var ticket Ticket
+	err := scanner.Scan(&ticket.Id,
+		&ticket.OpdbId,
+		&ticket.MachineName,
+		&ticket.ReporterId,
+		&ticket.ReporterName,
+		&ticket.Description,
+		&ticket.State,
+		&ticket.CreatedAt,
+		&ticket.UpdatedAt)
+	return ticket, err
+}
+
+// GetTickets returns the maintenance tickets with unresolved tickets first; an
+// empty state or OPDB ID matches every ticket.
+func GetTickets(state string, opdbId string) []Ticket {
+	rows, err := stmtSelectMaintenanceTickets.Query(state, opdbId)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectMaintenanceTickets,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var tickets []Ticket
+	for rows.Next() {
+		if ticket, err := scanTicket(rows); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectMaintenanceTickets,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for maintenance ticket")
+		} else {
+			tickets = append(tickets, ticket)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectMaintenanceTickets,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for maintenance ticket")
+	}
+
+	return tickets
+}
+
+func GetTicket(id int) *Ticket {
+	ticket, err := scanTicket(stmtSelectMaintenanceTicket.QueryRow(id))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectMaintenanceTicket,
+				"id":        id,
+				"error":     err,
+			}).Error("unable to execute prepared SQL statement")
+		}
+		return nil
+	}
+
+	return &ticket
+}
+
+func UpdateTicketState(id int, state string) bool {
+	result, err := stmtUpdateMaintenanceTicketState.Exec(state, time.Now().Unix(), id)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlUpdateMaintenanceTicketState,
+			"id":        id,
+			"state":     state,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return false
+	}
+	updated, _ := result.RowsAffected()
+
+	return updated > 0
+}
+
+// AddTicketNote records a note from a tech and marks the ticket as updated.
+func AddTicketNote(ticketId int, authorId int, note string) bool {
+	tx, err := session.Begin()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("unable to begin transaction for adding maintenance note")
+		return false
+	}
+
+	now := time.Now().Unix()
+	result, err := tx.Exec(sqlTouchMaintenanceTicket, now, ticketId)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlTouchMaintenanceTicket,
+			"ticket_id": ticketId,
+			"error":     err,
+		}).Error("unable to transactionally update maintenance ticket")
+		tx.Rollback()
+		return false
+	}
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		tx.Rollback()
+		return false
+	}
+	if _, err := tx.Exec(sqlInsertMaintenanceNote, ticketId, authorId, note, now); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlInsertMaintenanceNote,
+			"ticket_id": ticketId,
+			"error":     err,
+		}).Error("unable to transactionally insert maintenance note")
+		tx.Rollback()
+		return false
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("unable to commit transaction for adding maintenance note")
+		return false
+	}
+	return true
+}
+
+func GetTicketNotes(ticketId int) []TicketNote {
+	rows, err := stmtSelectMaintenanceNotes.Query(ticketId)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectMaintenanceNotes,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var notes []TicketNote
+	for rows.Next() {
+		var note TicketNote
+		if err := rows.Scan(&note.Id,
+			&note.TicketId,
+			&note.AuthorId,
+			&note.AuthorName,
+			&note.Note,
+			&note.CreatedAt); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectMaintenanceNotes,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for maintenance note")
+		} else {
+			notes = append(notes, note)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectMaintenanceNotes,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for maintenance note")
+	}
+
+	return notes
+}
+
+func closePreparedMaintenanceStatements() {
+	log.Debug("closing prepared maintenance statements")
+	stmtUpdateMachineOutOfOrder.Close()
+	stmtInsertMaintenanceTicket.Close()
+	stmtSelectMaintenanceTickets.Close()
+	stmtSelectMaintenanceTicket.Close()
+	stmtUpdateMaintenanceTicketState.Close()
+	stmtSelectMaintenanceNotes.Close()
+	log.Debug("prepared maintenance statements closed")
+}
+
+func prepareMaintenanceStatements() {
+	log.Debug("preparing maintenance statements")
+	stmtUpdateMachineOutOfOrder = prepare(sqlUpdateMachineOutOfOrder)
+	stmtInsertMaintenanceTicket = prepare(sqlInsertMaintenanceTicket)
+	stmtSelectMaintenanceTickets = prepare(sqlSelectMaintenanceTickets)
+	stmtSelectMaintenanceTicket = prepare(sqlSelectMaintenanceTicket)
+	stmtUpdateMaintenanceTicketState = prepare(sqlUpdateMaintenanceTicketState)
+	stmtSelectMaintenanceNotes = prepare(sqlSelectMaintenanceNotes)
+	log.Debug("maintenance statements prepared")
+}
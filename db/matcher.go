@@ -0,0 +1,458 @@
+package db
+
+import (
+	"database/sql"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikefero/tpl/log"
+	"github.com/mikefero/tpl/utils"
+)
+
+// MinMachineMatchConfidence is the confidence from which a machine match is
+// trusted without a person confirming it
+const MinMachineMatchConfidence = 0.75
+
+// ReMachineName matches the edition of an OPDB machine name, e.g. (Premium)
+var ReMachineName = regexp.MustCompile(`(?i)\(.*\)`)
+
+// ReMachineFeatures matches the words of OPDB features that do not tell
+// editions apart, e.g. the edition of Premium edition
+var ReMachineFeatures = regexp.MustCompile(`(?i) play| edition| table | model| game`)
+
+// machineNameStopWords are left out of names since integrations often drop
+// them, e.g. Addams Family for The Addams Family
+var machineNameStopWords = map[string]bool{
+	"the": true,
+	"a":   true,
+	"an":  true,
+	"of":  true,
+	"and": true,
+}
+
+// MachineMatch is a machine of the OPDB catalog a free text name may refer
+// to; the confidence is between 0 and 1 and matches by alias are certain.
+type MachineMatch struct {
+	Machine
+	Confidence float64
+	Alias      bool
+}
+
+type MachineAlias struct {
+	Alias       string
+	Name        string
+	OpdbId      string
+	MachineName string
+	CreatedBy   int
+	CreatedAt   int64
+}
+
+// catalogMachine is a machine of the OPDB catalog with the tokens of its name
+// and of its edition and features.
+type catalogMachine struct {
+	opdbId   string
+	name     []string
+	acronym  string
+	features map[string]bool
+}
+
+var stmtSelectMachineCatalog *sql.Stmt
+var stmtSelectMachineAliases *sql.Stmt
+var stmtSelectMachineAlias *sql.Stmt
+var stmtInsertMachineAlias *sql.Stmt
+var stmtDeleteMachineAlias *sql.Stmt
+
+// machineCatalog holds the tokenized OPDB catalog and every edition or
+// feature token found in it.
+type machineCatalog struct {
+	machines []catalogMachine
+	editions map[string]bool
+}
+
+var loadedMachineCatalog *machineCatalog
+var machineCatalogMutex sync.Mutex
+
+// getMachineNameTokens returns the lower case words of a name without stop
+// words.
+func getMachineNameTokens(name string) []string {
+	var tokens []string
+	for _, token := range reSearchTerm.FindAllString(strings.ToLower(name), -1) {
+		if !machineNameStopWords[token] {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// NormalizeMachineName reduces a free text machine name to its lower case
+// words, the key of machine aliases.
+func NormalizeMachineName(name string) string {
+	return strings.Join(getMachineNameTokens(name), " ")
+}
+
+// newCatalogMachine tokenizes an OPDB machine: the name without its edition,
+// as shown by getMachineName, and the edition together with the features
+// without the words matched by ReMachineFeatures.
+func newCatalogMachine(opdbId string, name string, features string) catalogMachine {
+	machine := catalogMachine{
+		opdbId:   opdbId,
+		name:     getMachineNameTokens(ReMachineName.ReplaceAllString(name, "")),
+		features: map[string]bool{},
+	}
+	for _, token := range machine.name {
+		machine.acronym += string([]rune(token)[0])
+	}
+	editions := strings.Join(ReMachineName.FindAllString(name, -1), " ")
+	for _, token := range getMachineNameTokens(editions + " " + ReMachineFeatures.ReplaceAllString(features, "")) {
+		machine.features[token] = true
+	}
+	return machine
+}
+
+func newMachineCatalog(machines []catalogMachine) *machineCatalog {
+	catalog := &machineCatalog{
+		machines: machines,
+		editions: map[string]bool{},
+	}
+	for _, machine := range machines {
+		for token := range machine.features {
+			catalog.editions[token] = true
+		}
+	}
+	return catalog
+}
+
+func getMachineCatalog() *machineCatalog {
+	machineCatalogMutex.Lock()
+	defer machineCatalogMutex.Unlock()
+	if loadedMachineCatalog != nil {
+		return loadedMachineCatalog
+	}
+
+	rows, err := stmtSelectMachineCatalog.Query()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectMachineCatalog,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return newMachineCatalog(nil)
+	}
+	defer rows.Close()
+
+	var machines []catalogMachine
+	for rows.Next() {
+		var opdbId, name string
+		var features sql.NullString
+		if err := rows.Scan(&opdbId, &name, &features); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectMachineCatalog,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for machine catalog")
+			continue
+		}
+		machines = append(machines, newCatalogMachine(opdbId, name, features.String))
+	}
+	loadedMachineCatalog = newMachineCatalog(machines)
+	return loadedMachineCatalog
+}
+
+// resetMachineCatalog drops the cached catalog so that machines are matched
+// against the latest OPDB import.
+func resetMachineCatalog() {
+	machineCatalogMutex.Lock()
+	defer machineCatalogMutex.Unlock()
+	loadedMachineCatalog = nil
+}
+
+// getTokenSimilarity compares two words between 0 and 1; words further apart
+// than the typo tolerance of search terms are not similar at all.
+func getTokenSimilarity(a string, b string) float64 {
+	if a == b {
+		return 1
+	}
+	distance := utils.EditDistance(a, b)
+	if distance > GetTypoTolerance(a) || distance > GetTypoTolerance(b) {
+		return 0
+	}
+	length := len([]rune(a))
+	if other := len([]rune(b)); other > length {
+		length = other
+	}
+	return 1 - float64(distance)/float64(length)
+}
+
+// getStringSimilarity compares two strings between 0 and 1 by their edit
+// distance relative to the longer string.
+func getStringSimilarity(a string, b string) float64 {
+	length := len([]rune(a))
+	if other := len([]rune(b)); other > length {
+		length = other
+	}
+	if length == 0 {
+		return 0
+	}
+	return 1 - float64(utils.EditDistance(a, b))/float64(length)
+}
+
+// harmonicMean combines how much of the machine name a query covers with how
+// much of the query the machine explains.
+func harmonicMean(recall float64, precision float64) float64 {
+	if recall+precision == 0 {
+		return 0
+	}
+	return 2 * recall * precision / (recall + precision)
+}
+
+// scoreMachine rates how well the words of a query name a machine. Every word
+// of the machine name is matched to the closest unused query word, a query
+// word equal to the initials of the name matches the entire name, e.g. TMNT,
+// and the remaining query words should be editions or features of the machine;
+// editions of other machines count half since the catalog may not list every
+// edition, e.g. Godzilla (Premium). Names written without spaces are compared
+// as a whole, e.g. Spiderman.
+func scoreMachine(query []string, machine catalogMachine, editions map[string]bool) float64 {
+	if len(query) == 0 || len(machine.name) == 0 {
+		return 0
+	}
+	used := make([]bool, len(query))
+	recall := 0.0
+	precision := 0.0
+	for i, token := range query {
+		if len(machine.name) > 1 && token == machine.acronym {
+			used[i] = true
+			recall = float64(len(machine.name))
+			precision++
+			break
+		}
+	}
+	if recall == 0 {
+		for _, token := range machine.name {
+			best := -1
+			bestSimilarity := 0.0
+			for i, candidate := range query {
+				if similarity := getTokenSimilarity(candidate, token); !used[i] && similarity > bestSimilarity {
+					best = i
+					bestSimilarity = similarity
+				}
+			}
+			if best >= 0 {
+				used[best] = true
+				recall += bestSimilarity
+				precision += bestSimilarity
+			}
+		}
+	}
+	features := 0.0
+	var name []string
+	for i, token := range query {
+		if machine.features[token] {
+			if !used[i] {
+				features++
+			}
+			continue
+		}
+		if !used[i] && editions[token] {
+			features += 0.5
+		}
+		name = append(name, token)
+	}
+	score := harmonicMean(recall/float64(len(machine.name)), (precision+features)/float64(len(query)))
+
+	// Compare the words that are not features as a whole
+	whole := getStringSimilarity(strings.Join(name, ""), strings.Join(machine.name, ""))
+	if whole > score {
+		score = whole
+	}
+	return score
+}
+
+// MatchMachineName resolves a free text machine name to the machines of the
+// OPDB catalog it most likely refers to, the best match first and machines in
+// the lineup first on equal confidence. A name with an alias resolves to the
+// machine of the alias with full confidence.
+func MatchMachineName(name string, limit int) []MachineMatch {
+	query := getMachineNameTokens(name)
+	if len(query) == 0 || limit < 1 {
+		return nil
+	}
+
+	var matches []MachineMatch
+	alias := getMachineAlias(strings.Join(query, " "))
+	if len(alias) > 0 {
+		if machine := GetMachine(alias); machine != nil {
+			matches = append(matches, MachineMatch{
+				Machine:    *machine,
+				Confidence: 1,
+				Alias:      true,
+			})
+		}
+	}
+
+	type scored struct {
+		opdbId     string
+		confidence float64
+	}
+	var candidates []scored
+	catalog := getMachineCatalog()
+	for _, machine := range catalog.machines {
+		if machine.opdbId == alias {
+			continue
+		}
+		if confidence := scoreMachine(query, machine, catalog.editions); confidence > 0 {
+			candidates = append(candidates, scored{
+				opdbId:     machine.opdbId,
+				confidence: confidence,
+			})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].confidence > candidates[j].confidence
+	})
+
+	// Machines on the same confidence as the last one returned compete for
+	// the last places by being in the lineup
+	var ranked []MachineMatch
+	for i, candidate := range candidates {
+		if i >= limit && candidate.confidence < candidates[limit-1].confidence {
+			break
+		}
+		if machine := GetMachine(candidate.opdbId); machine != nil {
+			ranked = append(ranked, MachineMatch{
+				Machine:    *machine,
+				Confidence: candidate.confidence,
+			})
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Confidence != ranked[j].Confidence {
+			return ranked[i].Confidence > ranked[j].Confidence
+		}
+		return ranked[i].Active && !ranked[j].Active
+	})
+	matches = append(matches, ranked...)
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// MatchMachine returns the machine a free text name refers to when the best
+// match is trusted, or nil.
+func MatchMachine(name string) *Machine {
+	matches := MatchMachineName(name, 1)
+	if len(matches) == 0 || matches[0].Confidence < MinMachineMatchConfidence {
+		return nil
+	}
+	return &matches[0].Machine
+}
+
+func getMachineAlias(alias string) string {
+	var opdbId string
+	err := stmtSelectMachineAlias.QueryRow(alias).Scan(&opdbId)
+	if err != nil && err != sql.ErrNoRows {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectMachineAlias,
+			"alias":     alias,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+	}
+	return opdbId
+}
+
+func GetMachineAliases() []MachineAlias {
+	rows, err := stmtSelectMachineAliases.Query()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectMachineAliases,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var aliases []MachineAlias
+	for rows.Next() {
+		var alias MachineAlias
+		if err := rows.Scan(&alias.Alias,
+			&alias.Name,
+			&alias.OpdbId,
+			&alias.MachineName,
+			&alias.CreatedBy,
+			&alias.CreatedAt); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectMachineAliases,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for machine alias")
+		} else {
+			aliases = append(aliases, alias)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectMachineAliases,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for machine alias")
+	}
+
+	return aliases
+}
+
+// AddMachineAlias resolves a free text name to a machine from now on,
+// replacing an alias of the same normalized name.
+func AddMachineAlias(name string, opdbId string, createdBy int) bool {
+	alias := NormalizeMachineName(name)
+	if len(alias) == 0 {
+		return false
+	}
+	if _, err := stmtInsertMachineAlias.Exec(alias, strings.TrimSpace(name), opdbId, createdBy, time.Now().Unix()); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlInsertMachineAlias,
+			"alias":     alias,
+			"opdb_id":   opdbId,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return false
+	}
+	return true
+}
+
+func DeleteMachineAlias(alias string) bool {
+	result, err := stmtDeleteMachineAlias.Exec(alias)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlDeleteMachineAlias,
+			"alias":     alias,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return false
+	}
+	deleted, _ := result.RowsAffected()
+
+	return deleted > 0
+}
+
+func closePreparedMatcherStatements() {
+	log.Debug("closing prepared matcher statements")
+	stmtSelectMachineCatalog.Close()
+	stmtSelectMachineAliases.Close()
+	stmtSelectMachineAlias.Close()
+	stmtInsertMachineAlias.Close()
+	stmtDeleteMachineAlias.Close()
+	log.Debug("prepared matcher statements closed")
+}
+
+func prepareMatcherStatements() {
+	log.Debug("preparing matcher statements")
+	stmtSelectMachineCatalog = prepare(sqlSelectMachineCatalog)
+	stmtSelectMachineAliases = prepare(sqlSelectMachineAliases)
+	stmtSelectMachineAlias = prepare(sqlSelectMachineAlias)
+	stmtInsertMachineAlias = prepare(sqlInsertMachineAlias)
+	stmtDeleteMachineAlias = prepare(sqlDeleteMachineAlias)
+	log.Debug("matcher statements prepared")
+}
@@ -0,0 +1,112 @@
+package db
+
+import (
+	"testing"
+)
+
+// testMachineCatalog is a part of the OPDB catalog with several editions of
+// the same machines.
+var testMachineCatalog = newMachineCatalog([]catalogMachine{
+	newCatalogMachine("G4ODR-MDXEy", "The Addams Family", ""),
+	newCatalogMachine("G4ODR-MLzY7", "The Addams Family Gold", "Limited edition"),
+	newCatalogMachine("G4do5-MDlN7", "Attack from Mars", ""),
+	newCatalogMachine("G5D94-MLnXq", "Spider-Man", "Pro edition"),
+	newCatalogMachine("G5D94-M3d5w", "Spider-Man (Vault Edition)", "Vault edition"),
+	newCatalogMachine("GrXlr-MOEE2", "The Amazing Spider-Man", "Widebody"),
+	newCatalogMachine("G5pe4-MePZv", "Medieval Madness", ""),
+	newCatalogMachine("G5pe4-MyNkp", "Medieval Madness (Remake)", "Remake"),
+	newCatalogMachine("G5po2-MeP6B", "Godzilla", ""),
+	newCatalogMachine("Gr8xn-MKN66", "Teenage Mutant Ninja Turtles", ""),
+	newCatalogMachine("Gd2Xb-Mq1qv", "Teenage Mutant Ninja Turtles (Pro)", "Pro edition"),
+	newCatalogMachine("Gd2Xb-MRjpZ-A9wqN", "Teenage Mutant Ninja Turtles (Premium)", "Premium edition"),
+})
+
+// getBestMachine returns the OPDB ID of the machine of the test catalog a name
+// most likely refers to and its confidence.
+func getBestMachine(name string) (string, float64) {
+	query := getMachineNameTokens(name)
+	best := ""
+	bestConfidence := 0.0
+	for _, machine := range testMachineCatalog.machines {
+		if confidence := scoreMachine(query, machine, testMachineCatalog.editions); confidence > bestConfidence {
+			best = machine.opdbId
+			bestConfidence = confidence
+		}
+	}
+	return best, bestConfidence
+}
+
+func TestNormalizeMachineName(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		normalized string
+	}{
+		{"The Addams Family", "addams family"},
+		{"  TMNT   Pro ", "tmnt pro"},
+		{"Godzilla (Premium)", "godzilla premium"},
+		{"Attack from Mars", "attack from mars"},
+		{"The", ""},
+	} {
+		if normalized := NormalizeMachineName(test.name); normalized != test.normalized {
+			t.Errorf("expected %q to normalize to %q, got %q", test.name, test.normalized, normalized)
+		}
+	}
+}
+
+func TestScoreMachine(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		opdbId        string
+		minConfidence float64
+	}{
+		{"The Addams Family", "G4ODR-MDXEy", 1},
+		{"Addams Family", "G4ODR-MDXEy", 1},
+		{"Adams Family", "G4ODR-MDXEy", MinMachineMatchConfidence},
+		{"Addams Family Gold", "G4ODR-MLzY7", 1},
+		{"AFM", "G4do5-MDlN7", MinMachineMatchConfidence},
+		{"Spiderman", "G5D94-MLnXq", MinMachineMatchConfidence},
+		{"Spider-Man Vault", "G5D94-M3d5w", MinMachineMatchConfidence},
+		{"Medieval Madness Remake", "G5pe4-MyNkp", MinMachineMatchConfidence},
+		{"TMNT Pro", "Gd2Xb-Mq1qv", MinMachineMatchConfidence},
+		{"TMNT Premium", "Gd2Xb-MRjpZ-A9wqN", MinMachineMatchConfidence},
+		{"Godzilla (Premium)", "G5po2-MeP6B", MinMachineMatchConfidence},
+	} {
+		opdbId, confidence := getBestMachine(test.name)
+		if opdbId != test.opdbId {
+			t.Errorf("expected %q to match %s, got %s", test.name, test.opdbId, opdbId)
+		}
+		if confidence < test.minConfidence {
+			t.Errorf("expected %q to match with a confidence of at least %.2f, got %.2f", test.name, test.minConfidence, confidence)
+		}
+	}
+}
+
+func TestScoreMachineUnrelated(t *testing.T) {
+	for _, name := range []string{
+		"Twilight Zone",
+		"Elvira",
+		"",
+	} {
+		if opdbId, confidence := getBestMachine(name); confidence >= MinMachineMatchConfidence {
+			t.Errorf("expected %q not to match trustfully, got %s with %.2f", name, opdbId, confidence)
+		}
+	}
+}
+
+func TestGetTypoTolerance(t *testing.T) {
+	for _, test := range []struct {
+		term      string
+		tolerance int
+	}{
+		{"", 0},
+		{"tmn", 0},
+		{"tilt", 1},
+		{"élvíra", 1},
+		{"godzilla", 2},
+		{"ann smith", 2},
+	} {
+		if tolerance := GetTypoTolerance(test.term); tolerance != test.tolerance {
+			t.Errorf("expected %d edits allowed for %q, got %d", test.tolerance, test.term, tolerance)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package db
+
+import (
+	"database/sql"
+	"sync/atomic"
+
+	"github.com/mikefero/tpl/log"
+)
+
+type Match struct {
+	Id            int
+	LeagueId      int
+	SeasonId      int
+	Team1Id       int
+	Team2Id       sql.NullInt64
+	Week          sql.NullInt64
+	Date          sql.NullInt64
+	SelectionSeed sql.NullInt64
+}
+
+type MatchFilter struct {
+	SeasonId int
+	TeamId   int
+	Week     int
+	Limit    int
+	Offset   int
+}
+
+type Result struct {
+	Id                int
+	MatchId           int
+	OpdbId            string
+	Team1APlayerId    sql.NullInt64
+	Team1APlayerScore sql.NullInt64
+	Team1BPlayerId    sql.NullInt64
+	Team1BPlayerScore sql.NullInt64
+	Team1Score        sql.NullInt64
+	Team2APlayerId    sql.NullInt64
+	Team2APlayerScore sql.NullInt64
+	Team2BPlayerId    sql.NullInt64
+	Team2BPlayerScore sql.NullInt64
+	Team2Score        sql.NullInt64
+	PhotoKey          sql.NullString
+	Team1APlayerSub   bool
+	Team1BPlayerSub   bool
+	Team2APlayerSub   bool
+	Team2BPlayerSub   bool
+}
+
+type ResultFilter struct {
+	MatchId int
+	OpdbId  string
+	Limit   int
+	Offset  int
+}
+
+var stmtSelectMatches *sql.Stmt
+var stmtCountMatches *sql.Stmt
+var stmtSelectMatch *sql.Stmt
+var stmtSelectResults *sql.Stmt
+var stmtCountResults *sql.Stmt
+var stmtInsertResult *sql.Stmt
+var stmtSelectResult *sql.Stmt
+var stmtUpdateResultPhoto *sql.Stmt
+
+func GetMatches(filter MatchFilter) []Match {
+	rows, err := stmtSelectMatches.Query(filter.SeasonId,
+		filter.TeamId,
+		filter.Week,
+		filter.Limit,
+		filter.Offset)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectMatches,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var matches []Match
+	for rows.Next() {
+		var match Match
+		if err := rows.Scan(&match.Id,
+			&match.LeagueId,
+			&match.SeasonId,
+			&match.Team1Id,
+			&match.Team2Id,
+			&match.Week,
+			&match.Date,
+			&match.SelectionSeed); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectMatches,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for match")
+		} else {
+			matches = append(matches, match)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectMatches,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for match")
+	}
+
+	return matches
+}
+
+func CountMatches(filter MatchFilter) int {
+	var count int
+	err := stmtCountMatches.QueryRow(filter.SeasonId,
+		filter.TeamId,
+		filter.Week).Scan(&count)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlCountMatches,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+	}
+
+	return count
+}
+
+func GetMatch(id int) *Match {
+	var match Match
+	err := stmtSelectMatch.QueryRow(id).Scan(&match.Id,
+		&match.LeagueId,
+		&match.SeasonId,
+		&match.Team1Id,
+		&match.Team2Id,
+		&match.Week,
+		&match.Date,
+		&match.SelectionSeed)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectMatch,
+				"id":        id,
+				"error":     err,
+			}).Error("unable to execute prepared SQL statement")
+		}
+		return nil
+	}
+
+	return &match
+}
+
+func GetResults(filter ResultFilter) []Result {
+	rows, err := stmtSelectResults.Query(filter.MatchId,
+		filter.OpdbId,
+		filter.Limit,
+		filter.Offset)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectResults,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var results []Result
+	for rows.Next() {
+		var result Result
+		if err := rows.Scan(&result.Id,
+			&result.MatchId,
+			&result.OpdbId,
+			&result.Team1APlayerId,
+			&result.Team1APlayerScore,
+			&result.Team1BPlayerId,
+			&result.Team1BPlayerScore,
+			&result.Team1Score,
+			&result.Team2APlayerId,
+			&result.Team2APlayerScore,
+			&result.Team2BPlayerId,
+			&result.Team2BPlayerScore,
+			&result.Team2Score,
+			&result.PhotoKey,
+			&result.Team1APlayerSub,
+			&result.Team1BPlayerSub,
+			&result.Team2APlayerSub,
+			&result.Team2BPlayerSub); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectResults,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for result")
+		} else {
+			results = append(results, result)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectResults,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for result")
+	}
+
+	return results
+}
+
+// resultsVersion changes whenever a result is recorded
+var resultsVersion int64
+
+// GetResultsVersion returns a number that changes whenever a result is
+// recorded so that statistics computed from the results can be cached.
+func GetResultsVersion() int64 {
+	return atomic.LoadInt64(&resultsVersion)
+}
+
+func InsertResult(result Result) *Result {
+	inserted, err := stmtInsertResult.Exec(result.MatchId,
+		result.OpdbId,
+		result.Team1APlayerId,
+		result.Team1APlayerScore,
+		result.Team1BPlayerId,
+		result.Team1BPlayerScore,
+		result.Team1Score,
+		result.Team2APlayerId,
+		result.Team2APlayerScore,
+		result.Team2BPlayerId,
+		result.Team2BPlayerScore,
+		result.Team2Score,
+		result.Team1APlayerSub,
+		result.Team1BPlayerSub,
+		result.Team2APlayerSub,
+		result.Team2BPlayerSub)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlInsertResult,
+			"match_id":  result.MatchId,
+			"opdb_id":   result.OpdbId,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	atomic.AddInt64(&resultsVersion, 1)
+	id, _ := inserted.LastInsertId()
+
+	return GetResult(int(id))
+}
+
+func GetResult(id int) *Result {
+	var result Result
+	err := stmtSelectResult.QueryRow(id).Scan(&result.Id,
+		&result.MatchId,
+		&result.OpdbId,
+		&result.Team1APlayerId,
+		&result.Team1APlayerScore,
+		&result.Team1BPlayerId,
+		&result.Team1BPlayerScore,
+		&result.Team1Score,
+		&result.Team2APlayerId,
+		&result.Team2APlayerScore,
+		&result.Team2BPlayerId,
+		&result.Team2BPlayerScore,
+		&result.Team2Score,
+		&result.PhotoKey,
+		&result.Team1APlayerSub,
+		&result.Team1BPlayerSub,
+		&result.Team2APlayerSub,
+		&result.Team2BPlayerSub)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectResult,
+				"id":        id,
+				"error":     err,
+			}).Error("unable to execute prepared SQL statement")
+		}
+		return nil
+	}
+
+	return &result
+}
+
+// SetResultPhoto attaches a photo to a result as proof of the scores.
+func SetResultPhoto(id int, photoKey string) bool {
+	updated, err := stmtUpdateResultPhoto.Exec(photoKey, id)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlUpdateResultPhoto,
+			"id":        id,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return false
+	}
+	count, _ := updated.RowsAffected()
+
+	return count > 0
+}
+
+func CountResults(filter ResultFilter) int {
+	var count int
+	err := stmtCountResults.QueryRow(filter.MatchId, filter.OpdbId).Scan(&count)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlCountResults,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+	}
+
+	return count
+}
+
+// CreateMatches schedules matches together so that a week is either fully
+// scheduled or not at all.
+func CreateMatches(matches []Match) bool {
+	tx, err := session.Begin()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("unable to begin transaction for creating matches")
+		return false
+	}
+
+	for _, match := range matches {
+		if _, err := tx.Exec(sqlInsertMatch, match.LeagueId,
+			match.SeasonId,
+			match.Team1Id,
+			match.Team2Id,
+			match.Week,
+			match.Date); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlInsertMatch,
+				"season_id": match.SeasonId,
+				"team_1_id": match.Team1Id,
+				"error":     err,
+			}).Error("unable to transactionally insert match")
+			tx.Rollback()
+			return false
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("unable to commit transaction for creating matches")
+		return false
+	}
+	return true
+}
+
+func closePreparedMatchesStatements() {
+	log.Debug("closing prepared matches statements")
+	stmtSelectMatches.Close()
+	stmtCountMatches.Close()
+	stmtSelectMatch.Close()
+	stmtSelectResults.Close()
+	stmtCountResults.Close()
+	stmtInsertResult.Close()
+	stmtSelectResult.Close()
+	stmtUpdateResultPhoto.Close()
+	log.Debug("prepared matches statements closed")
+}
+
+func prepareMatchesStatements() {
+	log.Debug("preparing matches statements")
+	stmtSelectMatches = prepare(sqlSelectMatches)
+	stmtCountMatches = prepare(sqlCountMatches)
+	stmtSelectMatch = prepare(sqlSelectMatch)
+	stmtSelectResults = prepare(sqlSelectResults)
+	stmtCountResults = prepare(sqlCountResults)
+	stmtInsertResult = prepare(sqlInsertResult)
+	stmtSelectResult = prepare(sqlSelectResult)
+	stmtUpdateResultPhoto = prepare(sqlUpdateResultPhoto)
+	log.Debug("matches statements prepared")
+}
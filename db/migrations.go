@@ -0,0 +1,211 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/mikefero/tpl/log"
+)
+
+// schemaMigrations upgrade a database created by an earlier version of TPL to
+// the current schema; the step at index i brings the database from schema
+// version i to i + 1. A database created from scratch already has the current
+// schema and starts at the last version. Databases created before the schema
+// was versioned start at version 0 whatever columns they already have, so every
+// step only adds the tables and columns that are missing.
+var schemaMigrations = []func(tx *sql.Tx){
+	// Seasons belong to a league and matches are scheduled by week and date
+	func(tx *sql.Tx) {
+		if txAddColumn(tx, "seasons", "league_id", "INTEGER REFERENCES leagues (id)") {
+			txExec(tx, sqlMigrateSeasonsLeague)
+		}
+		txAddColumn(tx, "matches", "week", "INTEGER")
+		txAddColumn(tx, "matches", "date", "INTEGER")
+	},
+	// Personal access tokens for API clients
+	func(tx *sql.Tx) {
+		txCreateTable(tx, "api_tokens", apiTokensTable)
+	},
+	// Machine details from the OPDB import and lineup history; the active
+	// machines enter the lineup history when it is created
+	func(tx *sql.Tx) {
+		txAddColumn(tx, "machines", "type", "STRING")
+		txAddColumn(tx, "machines", "display", "STRING")
+		txAddColumn(tx, "machines", "player_count", "INTEGER")
+		txAddColumn(tx, "machines", "description", "TEXT")
+		if txCreateTable(tx, "machine_lineup_history", machineLineupHistoryTable) {
+			txRecordLineupHistory(tx)
+		}
+	},
+	// Lineup overrides kept across Pinball Map syncs and user roles; the
+	// lineup synchronized so far came from Pinball Map
+	func(tx *sql.Tx) {
+		txAddColumn(tx, "machines", "active_source", "STRING")
+		if txAddColumn(tx, "machines", "pinballmap_active", "BOOLEAN NOT NULL DEFAULT false") {
+			txExec(tx, sqlMigrateMachinesPinballMapActive)
+		}
+		txAddColumn(tx, "users", "role", "STRING NOT NULL DEFAULT 'player'")
+	},
+	// Out of order machines and their maintenance tickets
+	func(tx *sql.Tx) {
+		txAddColumn(tx, "machines", "out_of_order", "BOOLEAN NOT NULL DEFAULT false")
+		txCreateTable(tx, "maintenance_tickets", maintenanceTicketsTable)
+		txCreateTable(tx, "maintenance_notes", maintenanceNotesTable)
+	},
+	// Verified high score boards
+	func(tx *sql.Tx) {
+		txCreateTable(tx, "high_scores", highScoresTable)
+	},
+	// Photos are stored in the photo store; high score photo links from
+	// before cannot be served from there and are dropped
+	func(tx *sql.Tx) {
+		if txCount(tx, sqlCountTableColumns, "high_scores", "photo_url") > 0 {
+			txExec(tx, sqlMigrateHighScoresPhotoKey)
+			txExec(tx, sqlMigrateHighScoresPhotoLinks)
+		}
+		txAddColumn(tx, "results", "photo_key", "STRING")
+	},
+	// Machine selection modes with seeded random draws
+	func(tx *sql.Tx) {
+		txAddColumn(tx, "leagues", "selection_mode", "STRING NOT NULL DEFAULT 'home_pick'")
+		txAddColumn(tx, "leagues", "no_repeat", "BOOLEAN NOT NULL DEFAULT true")
+		txAddColumn(tx, "matches", "selection_seed", "INTEGER")
+		txCreateTable(tx, "match_selections", matchSelectionsTable)
+	},
+	// Per-season machine usage limits
+	func(tx *sql.Tx) {
+		txAddColumn(tx, "leagues", "usage_limit", "INTEGER")
+	},
+	// Strikes tournaments
+	func(tx *sql.Tx) {
+		txCreateTable(tx, "tournaments", tournamentsTable)
+		txCreateTable(tx, "tournament_players", tournamentPlayersTable)
+		txCreateTable(tx, "tournament_games", tournamentGamesTable)
+		txCreateTable(tx, "tournament_game_players", tournamentGamePlayersTable)
+	},
+	// Swiss tournaments play a fixed number of rounds
+	func(tx *sql.Tx) {
+		txAddColumn(tx, "tournaments", "rounds", "INTEGER")
+	},
+	// Best game qualifying with ticket entries and seeded brackets
+	func(tx *sql.Tx) {
+		txAddColumn(tx, "tournaments", "entries", "INTEGER")
+		txAddColumn(tx, "tournaments", "counted_machines", "INTEGER")
+		txAddColumn(tx, "tournaments", "qualifiers", "INTEGER")
+		txAddColumn(tx, "tournaments", "bracket_id", "INTEGER REFERENCES tournaments (id)")
+		txAddColumn(tx, "tournament_players", "seed", "INTEGER")
+		txCreateTable(tx, "tournament_tickets", tournamentTicketsTable)
+		txCreateTable(tx, "tournament_scores", tournamentScoresTable)
+	},
+	// Group match play scoring and machine picks
+	func(tx *sql.Tx) {
+		txAddColumn(tx, "tournaments", "scoring", "STRING")
+		txAddColumn(tx, "tournament_games", "picked_by", "INTEGER REFERENCES users (id)")
+	},
+	// IFPA numbers of players and WPPR ranking snapshots; a column cannot be
+	// added as UNIQUE so IFPA numbers are kept unique by an index
+	func(tx *sql.Tx) {
+		if txAddColumn(tx, "users", "ifpa_id", "INTEGER") {
+			txExec(tx, sqlMigrateUsersIfpaIdIndex)
+		}
+		txAddColumn(tx, "users", "ifpa_verified_at", "INTEGER")
+		txCreateTable(tx, "ifpa_rankings", ifpaRankingsTable)
+	},
+	// Aliases of machine names used by integrations
+	func(tx *sql.Tx) {
+		txCreateTable(tx, "machine_aliases", machineAliasesTable)
+	},
+	// League sub pools and per-season sub limits
+	func(tx *sql.Tx) {
+		txAddColumn(tx, "leagues", "sub_limit", "INTEGER")
+		txAddColumn(tx, "results", "team_1_a_player_sub", "BOOLEAN NOT NULL DEFAULT false")
+		txAddColumn(tx, "results", "team_1_b_player_sub", "BOOLEAN NOT NULL DEFAULT false")
+		txAddColumn(tx, "results", "team_2_a_player_sub", "BOOLEAN NOT NULL DEFAULT false")
+		txAddColumn(tx, "results", "team_2_b_player_sub", "BOOLEAN NOT NULL DEFAULT false")
+		txCreateTable(tx, "league_subs", leagueSubsTable)
+	},
+	// Number of games per league match, which bounds machine selections
+	func(tx *sql.Tx) {
+		txAddColumn(tx, "leagues", "games", "INTEGER NOT NULL DEFAULT 5")
+	},
+}
+
+func txCount(tx *sql.Tx, statement string, args ...interface{}) int {
+	var count int
+	if err := tx.QueryRow(statement, args...).Scan(&count); err != nil {
+		log.WithFields(log.Fields{
+			"statement": statement,
+			"error":     err,
+		}).Panic("unable to transactionally execute SQL statement")
+	}
+	return count
+}
+
+// txCreateTable creates a table unless it already exists and tells whether it
+// was created.
+func txCreateTable(tx *sql.Tx, name string, table string) bool {
+	if txCount(tx, sqlCountTables, name) > 0 {
+		return false
+	}
+	txExec(tx, table)
+	return true
+}
+
+// txAddColumn adds a column to a table unless it already has it and tells
+// whether it was added; columns added this way cannot be NOT NULL without a
+// default.
+func txAddColumn(tx *sql.Tx, table string, column string, definition string) bool {
+	if txCount(tx, sqlCountTableColumns, table, column) > 0 {
+		return false
+	}
+	txExec(tx, "ALTER TABLE "+table+" ADD COLUMN "+column+" "+definition)
+	return true
+}
+
+func txSetSchemaVersion(tx *sql.Tx, version int) {
+	if _, err := tx.Exec(sqlInsertSchemaVersion, version, time.Now().Unix()); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlInsertSchemaVersion,
+			"version":   version,
+			"error":     err,
+		}).Panic("unable to transactionally execute SQL statement")
+	}
+}
+
+// migrateDatabase applies the schema migrations the database is missing, each
+// in its own transaction.
+func migrateDatabase() {
+	if _, err := session.Exec(schemaVersionTable); err != nil {
+		log.WithFields(log.Fields{
+			"statement": schemaVersionTable,
+			"error":     err,
+		}).Panic("unable to execute SQL statement")
+	}
+	var version int
+	if err := session.QueryRow(sqlSelectSchemaVersion).Scan(&version); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectSchemaVersion,
+			"error":     err,
+		}).Panic("unable to execute SQL statement")
+	}
+
+	for ; version < len(schemaMigrations); version++ {
+		log.WithFields(log.Fields{
+			"version": version + 1,
+		}).Info("migrating TPL database schema")
+		tx, err := session.Begin()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err,
+			}).Panic("unable to begin transaction for TPL database schema migration")
+		}
+		schemaMigrations[version](tx)
+		txSetSchemaVersion(tx, version+1)
+		if err := tx.Commit(); err != nil {
+			log.WithFields(log.Fields{
+				"version": version + 1,
+				"error":   err,
+			}).Panic("unable to commit transaction for TPL database schema migration")
+		}
+	}
+}
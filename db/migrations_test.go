@@ -0,0 +1,393 @@
+package db
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// baselineSchema is the schema of the databases created before the schema was
+// versioned.
+var baselineSchema = []string{
+	`CREATE TABLE leagues (
+  id     INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  name   STRING  NOT NULL,
+  active BOOLEAN NOT NULL);`,
+	`CREATE TABLE features (
+  id       INTEGER PRIMARY KEY AUTOINCREMENT,
+  features STRING  NOT NULL UNIQUE ON CONFLICT ABORT);`,
+	`CREATE TABLE machine_manufacturers (
+  id         INTEGER PRIMARY KEY ON CONFLICT IGNORE UNIQUE,
+  name       STRING  NOT NULL,
+  full_name  STRING  NOT NULL,
+  updated_at INTEGER NOT NULL);`,
+	`CREATE TABLE machines (
+  opdb_id              STRING  PRIMARY KEY ON CONFLICT IGNORE NOT NULL,
+  manufacturer_id      INTEGER REFERENCES machine_manufacturer (id) NOT NULL,
+  ipdb_id              INTEGER,
+  features_id          INTEGER REFERENCES features (id),
+  name                 STRING  NOT NULL,
+  manufacture_date     INTEGER,
+  backglass_image_uuid TEXT,
+  updated_at           INTEGER NOT NULL,
+  active               BOOLEAN NOT NULL);`,
+	`CREATE TABLE matches (
+  id        INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  league_id INTEGER REFERENCES leagues (id) NOT NULL,
+  season_id INTEGER REFERENCES seasons (id) NOT NULL,
+  team_1_id INTEGER REFERENCES teams (id) NOT NULL,
+  team_2_id INTEGER REFERENCES teams (id));`,
+	`CREATE TABLE results (
+  id                    INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  match_id              INTEGER REFERENCES matches (id) NOT NULL,
+  opdb_id               STRING  NOT NULL REFERENCES machines (opdb_id),
+  team_1_a_player_id    INTEGER REFERENCES users (id),
+  team_1_a_player_score INTEGER,
+  team_1_b_player_id    INTEGER REFERENCES users (id),
+  team_1_b_player_score INTEGER,
+  team_1_score          INTEGER,
+  team_2_a_player_id    INTEGER REFERENCES users (id),
+  team_2_a_player_score INTEGER,
+  team_2_b_player_id    INTEGER REFERENCES users (id),
+  team_2_b_player_score INTEGER,
+  team_2_score          INTEGER);`,
+	`CREATE TABLE seasons (
+  id         INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  name       STRING  NOT NULL,
+  start_date TIME    NOT NULL,
+  end_date   TIME);`,
+	`CREATE TABLE teams (
+  id        INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  league_id INTEGER REFERENCES leagues (id) NOT NULL,
+  name      STRING  NOT NULL UNIQUE,
+  a_player  INTEGER REFERENCES users (id) NOT NULL,
+  b_player  INTEGER REFERENCES users (id) NOT NULL,
+  active    BOOLEAN NOT NULL);`,
+	`CREATE TABLE users (
+  id        INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  league_id INTEGER REFERENCES leagues (id) NOT NULL,
+  email     STRING  UNIQUE NOT NULL,
+  password  STRING  NOT NULL,
+  name      STRING  NOT NULL,
+  initials  STRING,
+  active    BOOLEAN NOT NULL);`,
+}
+
+// openTestDatabase replaces the TPL database with an empty one in a temporary
+// directory for the duration of a test and executes the given statements.
+func openTestDatabase(t *testing.T, statements ...string) {
+	t.Helper()
+	previous := session
+	openDatabase(filepath.Join(t.TempDir(), "tpl.db"))
+	t.Cleanup(func() {
+		session.Close()
+		session = previous
+	})
+	for _, statement := range statements {
+		if _, err := session.Exec(statement); err != nil {
+			t.Fatalf("unable to execute %q: %v", statement, err)
+		}
+	}
+}
+
+// initializeTestDatabase replaces the TPL database with a new one created in a
+// temporary directory for the duration of a test, with its statements
+// prepared; the lineup is left empty.
+func initializeTestDatabase(t *testing.T, statements ...string) {
+	t.Helper()
+	previousSession := session
+	previousConfig := config
+	Initialize(Config{
+		Path:           filepath.Join(t.TempDir(), "tpl.db"),
+		OpdbExportPath: "opdb.json",
+	})
+	t.Cleanup(func() {
+		Close()
+		session = previousSession
+		config = previousConfig
+		resetSearchVocabulary()
+		resetMachineCatalog()
+	})
+	for _, statement := range statements {
+		if _, err := session.Exec(statement); err != nil {
+			t.Fatalf("unable to execute %q: %v", statement, err)
+		}
+	}
+}
+
+// getTestSchema returns the columns of every table of the database by table,
+// leaving out the search index whose tables depend on FTS5.
+func getTestSchema(t *testing.T) map[string][]string {
+	t.Helper()
+	rows, err := session.Query(`SELECT name FROM sqlite_master
+  WHERE type = 'table'
+    AND name NOT LIKE 'sqlite_%'
+    AND name NOT LIKE 'machines_search%'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, table)
+	}
+	rows.Close()
+
+	schema := map[string][]string{}
+	for _, table := range tables {
+		rows, err := session.Query(`SELECT name FROM pragma_table_info(?) ORDER BY name`, table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			var column string
+			if err := rows.Scan(&column); err != nil {
+				t.Fatal(err)
+			}
+			schema[table] = append(schema[table], column)
+		}
+		rows.Close()
+	}
+	return schema
+}
+
+func hasTable(t *testing.T, table string) bool {
+	t.Helper()
+	var count int
+	if err := session.QueryRow(sqlCountTables, table).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count > 0
+}
+
+func hasColumn(t *testing.T, table string, column string) bool {
+	t.Helper()
+	var count int
+	if err := session.QueryRow(sqlCountTableColumns, table, column).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count > 0
+}
+
+func getTestSchemaVersion(t *testing.T) int {
+	t.Helper()
+	var version int
+	if err := session.QueryRow(sqlSelectSchemaVersion).Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	return version
+}
+
+func TestMigrateDatabase(t *testing.T) {
+	openTestDatabase(t, append(baselineSchema,
+		`INSERT INTO leagues (id, name, active) VALUES (1, 'Monday', true), (2, 'Tuesday', true)`,
+		`INSERT INTO seasons (id, name, start_date) VALUES (1, 'Spring', 0), (2, 'Summer', 0)`,
+		`INSERT INTO matches (league_id, season_id, team_1_id) VALUES (2, 1, 1)`,
+		`INSERT INTO machines (opdb_id, manufacturer_id, name, updated_at, active) VALUES
+  ('G4ODR-MDXEy', 1, 'The Addams Family', 0, true),
+  ('G5pe4-MePZv', 1, 'Medieval Madness', 0, false)`,
+		`INSERT INTO users (id, league_id, email, password, name, active) VALUES (1, 1, 'ann@example.com', '', 'Ann', true)`)...)
+
+	migrateDatabase()
+	if version := getTestSchemaVersion(t); version != len(schemaMigrations) {
+		t.Fatalf("expected schema version %d, got %d", len(schemaMigrations), version)
+	}
+	for _, table := range []string{
+		"api_tokens",
+		"machine_lineup_history",
+		"maintenance_tickets",
+		"maintenance_notes",
+		"high_scores",
+		"match_selections",
+		"tournaments",
+		"tournament_players",
+		"tournament_games",
+		"tournament_game_players",
+		"tournament_tickets",
+		"tournament_scores",
+		"ifpa_rankings",
+		"machine_aliases",
+		"league_subs",
+	} {
+		if !hasTable(t, table) {
+			t.Errorf("expected table %s to be created", table)
+		}
+	}
+	for _, test := range []struct {
+		table  string
+		column string
+	}{
+		{"seasons", "league_id"},
+		{"matches", "week"},
+		{"matches", "date"},
+		{"machines", "type"},
+		{"machines", "display"},
+		{"machines", "player_count"},
+		{"machines", "description"},
+		{"machines", "active_source"},
+		{"machines", "pinballmap_active"},
+		{"users", "role"},
+		{"machines", "out_of_order"},
+		{"results", "photo_key"},
+		{"high_scores", "photo_key"},
+		{"leagues", "selection_mode"},
+		{"leagues", "no_repeat"},
+		{"matches", "selection_seed"},
+		{"leagues", "usage_limit"},
+		{"tournaments", "rounds"},
+		{"tournaments", "entries"},
+		{"tournaments", "counted_machines"},
+		{"tournaments", "qualifiers"},
+		{"tournaments", "bracket_id"},
+		{"tournament_players", "seed"},
+		{"tournaments", "scoring"},
+		{"tournament_games", "picked_by"},
+		{"users", "ifpa_id"},
+		{"users", "ifpa_verified_at"},
+		{"leagues", "sub_limit"},
+		{"results", "team_1_a_player_sub"},
+		{"results", "team_1_b_player_sub"},
+		{"results", "team_2_a_player_sub"},
+		{"results", "team_2_b_player_sub"},
+		{"leagues", "games"},
+	} {
+		if !hasColumn(t, test.table, test.column) {
+			t.Errorf("expected column %s.%s to be added", test.table, test.column)
+		}
+	}
+
+	for _, test := range []struct {
+		seasonId int
+		leagueId int
+	}{
+		{1, 2}, // league of its matches
+		{2, 1}, // first league without matches
+	} {
+		var leagueId int
+		if err := session.QueryRow(`SELECT league_id FROM seasons WHERE id = ?`, test.seasonId).Scan(&leagueId); err != nil {
+			t.Fatal(err)
+		}
+		if leagueId != test.leagueId {
+			t.Errorf("expected season %d to belong to league %d, got %d", test.seasonId, test.leagueId, leagueId)
+		}
+	}
+
+	for _, test := range []struct {
+		name      string
+		statement string
+		count     int
+	}{
+		{"active machines in the lineup history", `SELECT COUNT(*) FROM machine_lineup_history WHERE removed_at IS NULL`, 1},
+		{"machines active on Pinball Map", `SELECT COUNT(*) FROM machines WHERE pinballmap_active AND active_source = 'pinballmap'`, 1},
+		{"players", `SELECT COUNT(*) FROM users WHERE role = 'player'`, 1},
+		{"home pick leagues without repeats", `SELECT COUNT(*) FROM leagues WHERE selection_mode = 'home_pick' AND no_repeat`, 2},
+		{"leagues of five game matches", `SELECT COUNT(*) FROM leagues WHERE games = 5`, 2},
+	} {
+		var count int
+		if err := session.QueryRow(test.statement).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != test.count {
+			t.Errorf("expected %d %s, got %d", test.count, test.name, count)
+		}
+	}
+
+	// IFPA numbers stay unique
+	if _, err := session.Exec(`UPDATE users SET ifpa_id = 1234 WHERE id = 1`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.Exec(`INSERT INTO users (league_id, email, password, name, active, ifpa_id) VALUES (1, 'bob@example.com', '', 'Bob', true, 1234)`); err == nil {
+		t.Error("expected a duplicate IFPA number to be rejected")
+	}
+
+	// Migrating again leaves the database alone
+	migrateDatabase()
+	var count int
+	if err := session.QueryRow(`SELECT COUNT(*) FROM schema_version`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != len(schemaMigrations) {
+		t.Errorf("expected %d applied migrations, got %d", len(schemaMigrations), count)
+	}
+}
+
+func TestMigrateUnversionedDatabase(t *testing.T) {
+	// Databases created before the schema was versioned may already have
+	// some of the columns the migrations add
+	openTestDatabase(t, append(baselineSchema,
+		`ALTER TABLE matches ADD COLUMN week INTEGER`)...)
+
+	migrateDatabase()
+	if version := getTestSchemaVersion(t); version != len(schemaMigrations) {
+		t.Fatalf("expected schema version %d, got %d", len(schemaMigrations), version)
+	}
+	if !hasColumn(t, "matches", "date") {
+		t.Error("expected column matches.date to be added")
+	}
+}
+
+func TestMigrateHighScorePhotos(t *testing.T) {
+	// High scores recorded before photo uploads link to photos hosted
+	// elsewhere
+	openTestDatabase(t, append(baselineSchema,
+		`CREATE TABLE high_scores (
+  id          INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  opdb_id     STRING  NOT NULL,
+  user_id     INTEGER NOT NULL,
+  score       INTEGER NOT NULL,
+  photo_url   STRING,
+  result_id   INTEGER,
+  created_at  INTEGER NOT NULL,
+  verified_at INTEGER,
+  verified_by INTEGER,
+  UNIQUE (result_id, user_id))`,
+		`INSERT INTO high_scores (opdb_id, user_id, score, photo_url, created_at) VALUES
+  ('G4ODR-MDXEy', 1, 1000, 'https://example.com/score.jpg', 0),
+  ('G4ODR-MDXEy', 2, 2000, '0123456789abcdef0123456789abcdef', 0),
+  ('G4ODR-MDXEy', 3, 3000, NULL, 0)`)...)
+
+	migrateDatabase()
+	if hasColumn(t, "high_scores", "photo_url") || !hasColumn(t, "high_scores", "photo_key") {
+		t.Fatal("expected column high_scores.photo_url to be renamed to photo_key")
+	}
+	for _, test := range []struct {
+		userId   int
+		photoKey string
+	}{
+		{1, ""},
+		{2, "0123456789abcdef0123456789abcdef"},
+		{3, ""},
+	} {
+		var photoKey string
+		if err := session.QueryRow(`SELECT COALESCE(photo_key, '') FROM high_scores WHERE user_id = ?`, test.userId).Scan(&photoKey); err != nil {
+			t.Fatal(err)
+		}
+		if photoKey != test.photoKey {
+			t.Errorf("expected high score of user %d to have photo key %q, got %q", test.userId, test.photoKey, photoKey)
+		}
+	}
+}
+
+func TestMigratedSchemaMatchesNewSchema(t *testing.T) {
+	initializeTestDatabase(t)
+	expected := getTestSchema(t)
+
+	openTestDatabase(t, baselineSchema...)
+	migrateDatabase()
+	schema := getTestSchema(t)
+	if !reflect.DeepEqual(schema, expected) {
+		for table, columns := range expected {
+			if !reflect.DeepEqual(schema[table], columns) {
+				t.Errorf("expected table %s to have columns %v, got %v", table, columns, schema[table])
+			}
+		}
+		for table := range schema {
+			if _, exists := expected[table]; !exists {
+				t.Errorf("expected table %s not to exist", table)
+			}
+		}
+	}
+}
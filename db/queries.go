@@ -1,11 +1,32 @@
 package db
 
 // Tables
+const schemaVersionTable = `CREATE TABLE IF NOT EXISTS schema_version (
+  version    INTEGER PRIMARY KEY
+                     NOT NULL,
+  applied_at INTEGER NOT NULL);`
+
 const leaguesTable = `CREATE TABLE leagues (
-  id     INTEGER PRIMARY KEY AUTOINCREMENT
-                 NOT NULL,
-  name   STRING  NOT NULL,
-  active BOOLEAN NOT NULL);`
+  id             INTEGER PRIMARY KEY AUTOINCREMENT
+                         NOT NULL,
+  name           STRING  NOT NULL,
+  active         BOOLEAN NOT NULL,
+  selection_mode STRING  NOT NULL
+                         DEFAULT 'home_pick',
+  no_repeat      BOOLEAN NOT NULL
+                         DEFAULT true,
+  usage_limit    INTEGER,
+  sub_limit      INTEGER,
+  games          INTEGER NOT NULL
+                         DEFAULT 5);`
+
+const leagueSubsTable = `CREATE TABLE league_subs (
+  league_id INTEGER REFERENCES leagues (id)
+                    NOT NULL,
+  user_id   INTEGER REFERENCES users (id)
+                    NOT NULL,
+  added_at  INTEGER NOT NULL,
+  PRIMARY KEY (league_id, user_id));`
 
 const featuresTables = `CREATE TABLE features (
   id       INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -29,8 +50,99 @@ const machinesTable = `CREATE TABLE machines (
   name                 STRING  NOT NULL,
   manufacture_date     INTEGER,
   backglass_image_uuid TEXT,
+  type                 STRING,
+  display              STRING,
+  player_count         INTEGER,
+  description          TEXT,
   updated_at           INTEGER NOT NULL,
-  active               BOOLEAN NOT NULL);`
+  active               BOOLEAN NOT NULL,
+  active_source        STRING,
+  pinballmap_active    BOOLEAN NOT NULL
+                               DEFAULT false,
+  out_of_order         BOOLEAN NOT NULL
+                               DEFAULT false);`
+
+const machineLineupHistoryTable = `CREATE TABLE machine_lineup_history (
+  id         INTEGER PRIMARY KEY AUTOINCREMENT
+                     NOT NULL,
+  opdb_id    STRING  REFERENCES machines (opdb_id)
+                     NOT NULL,
+  added_at   INTEGER NOT NULL,
+  removed_at INTEGER);`
+
+const machineAliasesTable = `CREATE TABLE machine_aliases (
+  alias      STRING  PRIMARY KEY
+                     NOT NULL,
+  name       STRING  NOT NULL,
+  opdb_id    STRING  REFERENCES machines (opdb_id)
+                     NOT NULL,
+  created_by INTEGER REFERENCES users (id)
+                     NOT NULL,
+  created_at INTEGER NOT NULL);`
+
+const maintenanceTicketsTable = `CREATE TABLE maintenance_tickets (
+  id          INTEGER PRIMARY KEY AUTOINCREMENT
+                      NOT NULL,
+  opdb_id     STRING  REFERENCES machines (opdb_id)
+                      NOT NULL,
+  reporter_id INTEGER REFERENCES users (id)
+                      NOT NULL,
+  description STRING  NOT NULL,
+  state       STRING  NOT NULL
+                      DEFAULT 'open',
+  created_at  INTEGER NOT NULL,
+  updated_at  INTEGER NOT NULL);`
+
+const matchSelectionsTable = `CREATE TABLE match_selections (
+  id                INTEGER PRIMARY KEY AUTOINCREMENT
+                            NOT NULL,
+  match_id          INTEGER REFERENCES matches (id)
+                            NOT NULL,
+  game              INTEGER NOT NULL,
+  opdb_id           STRING  REFERENCES machines (opdb_id)
+                            NOT NULL,
+  picked_by_team_id INTEGER REFERENCES teams (id),
+  picked_by_user_id INTEGER REFERENCES users (id)
+                            NOT NULL,
+  draw_pool         STRING,
+  created_at        INTEGER NOT NULL,
+  UNIQUE (match_id, game));`
+
+const highScoresTable = `CREATE TABLE high_scores (
+  id          INTEGER PRIMARY KEY AUTOINCREMENT
+                      NOT NULL,
+  opdb_id     STRING  REFERENCES machines (opdb_id)
+                      NOT NULL,
+  user_id     INTEGER REFERENCES users (id)
+                      NOT NULL,
+  score       INTEGER NOT NULL,
+  photo_key   STRING,
+  result_id   INTEGER REFERENCES results (id),
+  created_at  INTEGER NOT NULL,
+  verified_at INTEGER,
+  verified_by INTEGER REFERENCES users (id),
+  UNIQUE (result_id, user_id));`
+
+const maintenanceNotesTable = `CREATE TABLE maintenance_notes (
+  id         INTEGER PRIMARY KEY AUTOINCREMENT
+                     NOT NULL,
+  ticket_id  INTEGER REFERENCES maintenance_tickets (id)
+                     NOT NULL,
+  author_id  INTEGER REFERENCES users (id)
+                     NOT NULL,
+  note       STRING  NOT NULL,
+  created_at INTEGER NOT NULL);`
+
+const machinesSearchTable = `CREATE VIRTUAL TABLE machines_search USING fts5 (
+  opdb_id UNINDEXED,
+  name,
+  manufacturer,
+  features,
+  tokenize = 'unicode61 remove_diacritics 2',
+  prefix = '2 3');`
+
+const machinesSearchVocabularyTable = `CREATE VIRTUAL TABLE machines_search_vocabulary USING fts5vocab (
+  machines_search, row);`
 
 const matchesTable = `CREATE TABLE matches (
   id        INTEGER PRIMARY KEY AUTOINCREMENT
@@ -41,7 +153,10 @@ const matchesTable = `CREATE TABLE matches (
                     NOT NULL,
   team_1_id INTEGER REFERENCES teams (id)
                     NOT NULL,
-  team_2_id INTEGER REFERENCES teams (id));`
+  team_2_id INTEGER REFERENCES teams (id),
+  week           INTEGER,
+  date           INTEGER,
+  selection_seed INTEGER);`
 
 const resultsTable = `CREATE TABLE results (
   id                    INTEGER PRIMARY KEY AUTOINCREMENT
@@ -52,18 +167,29 @@ const resultsTable = `CREATE TABLE results (
                                 REFERENCES machines (opdb_id),
   team_1_a_player_id    INTEGER REFERENCES users (id),
   team_1_a_player_score INTEGER,
+  team_1_a_player_sub   BOOLEAN NOT NULL
+                                DEFAULT false,
   team_1_b_player_id    INTEGER REFERENCES users (id),
   team_1_b_player_score INTEGER,
+  team_1_b_player_sub   BOOLEAN NOT NULL
+                                DEFAULT false,
   team_1_score          INTEGER,
   team_2_a_player_id    INTEGER REFERENCES users (id),
   team_2_a_player_score INTEGER,
+  team_2_a_player_sub   BOOLEAN NOT NULL
+                                DEFAULT false,
   team_2_b_player_id    INTEGER REFERENCES users (id),
   team_2_b_player_score INTEGER,
-  team_2_score          INTEGER);`
+  team_2_b_player_sub   BOOLEAN NOT NULL
+                                DEFAULT false,
+  team_2_score          INTEGER,
+  photo_key             STRING);`
 
 const seasonsTable = `CREATE TABLE seasons (
   id         INTEGER PRIMARY KEY AUTOINCREMENT
                      NOT NULL,
+  league_id  INTEGER REFERENCES leagues (id)
+                     NOT NULL,
   name       STRING  NOT NULL,
   start_date TIME    NOT NULL,
   end_date   TIME);`
@@ -81,6 +207,19 @@ const teamsTable = `CREATE TABLE teams (
                     NOT NULL,
   active    BOOLEAN NOT NULL);`
 
+const apiTokensTable = `CREATE TABLE api_tokens (
+  id           INTEGER PRIMARY KEY AUTOINCREMENT
+                       NOT NULL,
+  user_id      INTEGER REFERENCES users (id)
+                       NOT NULL,
+  name         STRING  NOT NULL,
+  token_hash   STRING  NOT NULL
+                       UNIQUE,
+  scopes       STRING  NOT NULL,
+  created_at   INTEGER NOT NULL,
+  last_used_at INTEGER,
+  revoked_at   INTEGER);`
+
 const usersTable = `CREATE TABLE users (
   id        INTEGER PRIMARY KEY AUTOINCREMENT
                     NOT NULL,
@@ -91,7 +230,138 @@ const usersTable = `CREATE TABLE users (
   password  STRING  NOT NULL,
   name      STRING  NOT NULL,
   initials  STRING,
-  active    BOOLEAN NOT NULL);`
+  role      STRING  NOT NULL
+                    DEFAULT 'player',
+  active    BOOLEAN NOT NULL,
+  ifpa_id   INTEGER UNIQUE,
+  ifpa_verified_at INTEGER);`
+
+const ifpaRankingsTable = `CREATE TABLE ifpa_rankings (
+  ifpa_id     INTEGER PRIMARY KEY
+                      NOT NULL,
+  name        STRING  NOT NULL,
+  rank        INTEGER NOT NULL,
+  wppr        REAL    NOT NULL,
+  country     STRING,
+  imported_at INTEGER NOT NULL);`
+
+const tournamentsTable = `CREATE TABLE tournaments (
+  id               INTEGER PRIMARY KEY AUTOINCREMENT
+                           NOT NULL,
+  name             STRING  NOT NULL,
+  format           STRING  NOT NULL
+                           DEFAULT 'strikes',
+  state            STRING  NOT NULL
+                           DEFAULT 'check_in',
+  group_size       INTEGER NOT NULL
+                           DEFAULT 2,
+  strike_limit     INTEGER NOT NULL
+                           DEFAULT 3,
+  rounds           INTEGER,
+  scoring          STRING,
+  entries          INTEGER,
+  counted_machines INTEGER,
+  qualifiers       INTEGER,
+  bracket_id       INTEGER REFERENCES tournaments (id),
+  seed             INTEGER NOT NULL,
+  created_by       INTEGER REFERENCES users (id)
+                           NOT NULL,
+  created_at       INTEGER NOT NULL);`
+
+const tournamentPlayersTable = `CREATE TABLE tournament_players (
+  tournament_id INTEGER REFERENCES tournaments (id)
+                        NOT NULL,
+  user_id       INTEGER REFERENCES users (id)
+                        NOT NULL,
+  seed          INTEGER,
+  checked_in_at INTEGER NOT NULL,
+  PRIMARY KEY (tournament_id, user_id));`
+
+const tournamentGamesTable = `CREATE TABLE tournament_games (
+  id            INTEGER PRIMARY KEY AUTOINCREMENT
+                        NOT NULL,
+  tournament_id INTEGER REFERENCES tournaments (id)
+                        NOT NULL,
+  round         INTEGER NOT NULL,
+  opdb_id       STRING  REFERENCES machines (opdb_id)
+                        NOT NULL,
+  draw_pool     STRING,
+  picked_by     INTEGER REFERENCES users (id),
+  completed_at  INTEGER);`
+
+const tournamentGamePlayersTable = `CREATE TABLE tournament_game_players (
+  game_id  INTEGER REFERENCES tournament_games (id)
+                   NOT NULL,
+  user_id  INTEGER REFERENCES users (id)
+                   NOT NULL,
+  position INTEGER NOT NULL,
+  place    INTEGER,
+  PRIMARY KEY (game_id, user_id));`
+
+const tournamentTicketsTable = `CREATE TABLE tournament_tickets (
+  id            INTEGER PRIMARY KEY AUTOINCREMENT
+                        NOT NULL,
+  tournament_id INTEGER REFERENCES tournaments (id)
+                        NOT NULL,
+  user_id       INTEGER REFERENCES users (id)
+                        NOT NULL,
+  opdb_id       STRING  REFERENCES machines (opdb_id)
+                        NOT NULL,
+  sold_by       INTEGER REFERENCES users (id)
+                        NOT NULL,
+  sold_at       INTEGER NOT NULL);`
+
+const tournamentScoresTable = `CREATE TABLE tournament_scores (
+  id           INTEGER PRIMARY KEY AUTOINCREMENT
+                       NOT NULL,
+  ticket_id    INTEGER REFERENCES tournament_tickets (id)
+                       NOT NULL
+                       UNIQUE,
+  score        INTEGER NOT NULL,
+  submitted_by INTEGER REFERENCES users (id)
+                       NOT NULL,
+  submitted_at INTEGER NOT NULL,
+  verified_by  INTEGER REFERENCES users (id),
+  verified_at  INTEGER);`
+
+// Schema migration queries
+const sqlSelectSchemaVersion = `SELECT COALESCE(MAX(version), 0)
+  FROM schema_version`
+
+const sqlInsertSchemaVersion = `INSERT INTO schema_version (
+  version, applied_at)
+  VALUES (?, ?);`
+
+const sqlCountTables = `SELECT COUNT(*)
+  FROM sqlite_master
+  WHERE type = 'table'
+    AND name = ?`
+
+const sqlCountTableColumns = `SELECT COUNT(*)
+  FROM pragma_table_info(?1)
+  WHERE name = ?2`
+
+// Seasons created before leagues owned them belong to the league of their matches
+const sqlMigrateSeasonsLeague = `UPDATE seasons
+  SET league_id = COALESCE(
+    (SELECT MIN(league_id) FROM matches WHERE season_id = seasons.id),
+    (SELECT MIN(id) FROM leagues))
+  WHERE league_id IS NULL`
+
+const sqlMigrateMachinesPinballMapActive = `UPDATE machines
+  SET pinballmap_active = active,
+    active_source = CASE WHEN active THEN 'pinballmap' END`
+
+const sqlMigrateHighScoresPhotoKey = `ALTER TABLE high_scores
+  RENAME COLUMN photo_url TO photo_key`
+
+const sqlMigrateHighScoresPhotoLinks = `UPDATE high_scores
+  SET photo_key = NULL
+  WHERE length(photo_key) != 32
+    OR photo_key GLOB '*[^0-9a-f]*'`
+
+const sqlMigrateUsersIfpaIdIndex = `CREATE UNIQUE INDEX users_ifpa_id
+  ON users (ifpa_id)`
 
 // Features table queries
 const sqlSelectIdFromFeatures = `SELECT id
@@ -102,25 +372,765 @@ const sqlSelectFeatures = `SELECT features
   FROM features
   WHERE id = ?`
 
+// Machine matching queries
+const sqlSelectMachineCatalog = `SELECT m.opdb_id, m.name, f.features
+  FROM machines m
+  LEFT JOIN features f ON f.id = m.features_id`
+
+const sqlSelectMachineAliases = `SELECT a.alias, a.name, a.opdb_id, m.name, a.created_by, a.created_at
+  FROM machine_aliases a
+  JOIN machines m ON m.opdb_id = a.opdb_id
+  ORDER BY a.alias`
+
+const sqlSelectMachineAlias = `SELECT opdb_id
+  FROM machine_aliases
+  WHERE alias = ?`
+
+const sqlInsertMachineAlias = `INSERT OR REPLACE INTO machine_aliases (
+  alias, name, opdb_id, created_by, created_at)
+  VALUES (?, ?, ?, ?, ?);`
+
+const sqlDeleteMachineAlias = `DELETE FROM machine_aliases
+  WHERE alias = ?`
+
 const sqlInsertFeatures = `INSERT INTO features (features)
     VALUES (?);`
 
 // Manufacturer table queries
 const sqlInsertMachineManufacturers = `INSERT INTO machine_manufacturers (
   id, name, full_name, updated_at)
-  VALUES (?, ?, ?, ?);`
+  VALUES (?, ?, ?, ?)
+  ON CONFLICT (id) DO UPDATE SET
+    name = excluded.name,
+    full_name = excluded.full_name,
+    updated_at = excluded.updated_at;`
 
 // Machine queries
 const sqlInsertMachines = `INSERT INTO machines (
-  opdb_id, manufacturer_id, ipdb_id, features_id, name, manufacture_date, backglass_image_uuid, updated_at, active)
-  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);`
+  opdb_id, manufacturer_id, ipdb_id, features_id, name, manufacture_date, backglass_image_uuid, type, display, player_count, description, updated_at, active)
+  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+  ON CONFLICT (opdb_id) DO UPDATE SET
+    manufacturer_id = excluded.manufacturer_id,
+    ipdb_id = excluded.ipdb_id,
+    features_id = excluded.features_id,
+    name = excluded.name,
+    manufacture_date = excluded.manufacture_date,
+    backglass_image_uuid = excluded.backglass_image_uuid,
+    type = excluded.type,
+    display = excluded.display,
+    player_count = excluded.player_count,
+    description = excluded.description,
+    updated_at = excluded.updated_at;`
 
-const sqlResetActiveMachines = `UPDATE machines SET active = false`
+const sqlResetActiveMachines = `UPDATE machines SET pinballmap_active = false`
 
 const sqlUpdateActiveMachines = `UPDATE machines
-  SET active = true
+  SET pinballmap_active = true
   WHERE opdb_id = ?`
 
-const sqlSelectAllActiveMachines = `SELECT opdb_id, manufacturer_id, ipdb_id, features_id, name, manufacture_date, backglass_image_uuid, updated_at
+// Machines whose lineup state was set by hand keep it until the override is cleared
+const sqlApplyPinballMapActiveMachines = `UPDATE machines
+  SET active = pinballmap_active,
+    active_source = CASE WHEN pinballmap_active THEN 'pinballmap' END
+  WHERE active_source IS NOT 'manual'`
+
+const sqlSelectMachine = `SELECT opdb_id, manufacturer_id, ipdb_id, features_id, name, manufacture_date, backglass_image_uuid, updated_at, active, out_of_order
   FROM machines
-  WHERE active = true`
+  WHERE opdb_id = ?`
+
+const sqlActiveMachinesFilter = `
+  FROM machines m
+  JOIN machine_manufacturers mm ON mm.id = m.manufacturer_id
+  LEFT JOIN features f ON f.id = m.features_id
+  WHERE m.active = true
+    AND (?1 = 0 OR m.manufacturer_id = ?1)
+    AND (?2 = '' OR m.name LIKE '%' || ?2 || '%')
+    AND (?3 = 0 OR CAST(strftime('%Y', m.manufacture_date, 'unixepoch') AS INTEGER) / 10 * 10 = ?3)
+    AND (?4 = '' OR ',' || f.features || ',' LIKE '%,' || ?4 || ',%')`
+
+const sqlSelectActiveMachines = `SELECT m.opdb_id, m.manufacturer_id, m.ipdb_id, m.features_id, m.name, m.manufacture_date, m.backglass_image_uuid, m.updated_at, m.out_of_order` +
+	sqlActiveMachinesFilter + `
+  ORDER BY CASE ?5 WHEN 'year' THEN m.manufacture_date END,
+    CASE ?5 WHEN 'manufacturer' THEN mm.name END,
+    m.name
+  LIMIT ?6 OFFSET ?7`
+
+const sqlCountActiveMachines = `SELECT COUNT(*)` + sqlActiveMachinesFilter
+
+const sqlSelectActiveManufacturers = `SELECT DISTINCT mm.id, mm.name, mm.full_name, mm.updated_at
+  FROM machine_manufacturers mm
+  JOIN machines m ON m.manufacturer_id = mm.id
+  WHERE m.active = true
+  ORDER BY mm.name`
+
+const sqlSelectActiveDecades = `SELECT DISTINCT CAST(strftime('%Y', manufacture_date, 'unixepoch') AS INTEGER) / 10 * 10 AS decade
+  FROM machines
+  WHERE active = true
+    AND manufacture_date IS NOT NULL
+  ORDER BY decade`
+
+const sqlSelectMachineMetadata = `SELECT type, display, player_count, description
+  FROM machines
+  WHERE opdb_id = ?`
+
+const sqlSelectMachineGames = `SELECT r.id, m.id, m.week, m.date, t1.name, t2.name, r.team_1_score, r.team_2_score, r.photo_key
+  FROM results r
+  JOIN matches m ON m.id = r.match_id
+  JOIN teams t1 ON t1.id = m.team_1_id
+  LEFT JOIN teams t2 ON t2.id = m.team_2_id
+  WHERE r.opdb_id = ?1
+  ORDER BY m.date DESC, r.id DESC
+  LIMIT ?2`
+
+const sqlCountMachineGames = `SELECT COUNT(*)
+  FROM results
+  WHERE opdb_id = ?`
+
+const sqlSelectMachineTopScores = `WITH scores AS (
+    SELECT match_id, team_1_a_player_id AS user_id, team_1_a_player_score AS score FROM results WHERE opdb_id = ?1
+    UNION ALL
+    SELECT match_id, team_1_b_player_id, team_1_b_player_score FROM results WHERE opdb_id = ?1
+    UNION ALL
+    SELECT match_id, team_2_a_player_id, team_2_a_player_score FROM results WHERE opdb_id = ?1
+    UNION ALL
+    SELECT match_id, team_2_b_player_id, team_2_b_player_score FROM results WHERE opdb_id = ?1)
+  SELECT u.id, u.name, s.score, m.date
+  FROM scores s
+  JOIN users u ON u.id = s.user_id
+  JOIN matches m ON m.id = s.match_id
+  WHERE s.score IS NOT NULL
+  ORDER BY s.score DESC
+  LIMIT ?2`
+
+const sqlSelectMachineAverageWinningScore = `SELECT AVG(MAX(
+    COALESCE(team_1_a_player_score, 0), COALESCE(team_1_b_player_score, 0),
+    COALESCE(team_2_a_player_score, 0), COALESCE(team_2_b_player_score, 0)))
+  FROM results
+  WHERE opdb_id = ?`
+
+// sqlSelectPlayedGames lists every league result in the order it was played
+const sqlSelectPlayedGames = `SELECT r.id, r.match_id, r.opdb_id,
+    r.team_1_a_player_id, r.team_1_a_player_score, r.team_1_b_player_id, r.team_1_b_player_score, r.team_1_score,
+    r.team_2_a_player_id, r.team_2_a_player_score, r.team_2_b_player_id, r.team_2_b_player_score, r.team_2_score, r.photo_key,
+    r.team_1_a_player_sub, r.team_1_b_player_sub, r.team_2_a_player_sub, r.team_2_b_player_sub,
+    mc.name, m.season_id, m.team_1_id, m.team_2_id, m.date
+  FROM results r
+  JOIN matches m ON m.id = r.match_id
+  JOIN machines mc ON mc.opdb_id = r.opdb_id
+  ORDER BY COALESCE(m.date, 0), r.id`
+
+const sqlSelectManufacturer = `SELECT id, name, full_name, updated_at
+  FROM machine_manufacturers
+  WHERE id = ?`
+
+// League queries
+const sqlSelectLeagues = `SELECT id, name, active, selection_mode, no_repeat, usage_limit, sub_limit, games
+  FROM leagues
+  WHERE (?1 IS NULL OR active = ?1)
+  ORDER BY name
+  LIMIT ?2 OFFSET ?3`
+
+const sqlCountLeagues = `SELECT COUNT(*)
+  FROM leagues
+  WHERE (?1 IS NULL OR active = ?1)`
+
+const sqlSelectLeague = `SELECT id, name, active, selection_mode, no_repeat, usage_limit, sub_limit, games
+  FROM leagues
+  WHERE id = ?`
+
+const sqlUpdateLeagueSelection = `UPDATE leagues
+  SET selection_mode = ?, no_repeat = ?, usage_limit = ?, games = ?
+  WHERE id = ?`
+
+const sqlUpdateLeagueSubLimit = `UPDATE leagues
+  SET sub_limit = ?
+  WHERE id = ?`
+
+// Sub queries
+const sqlSelectLeagueSubs = `SELECT s.league_id, s.user_id, u.name, s.added_at
+  FROM league_subs s
+  JOIN users u ON u.id = s.user_id
+  WHERE s.league_id = ?
+  ORDER BY u.name`
+
+const sqlSelectLeagueSub = `SELECT COUNT(*)
+  FROM league_subs
+  WHERE league_id = ?
+    AND user_id = ?`
+
+const sqlInsertLeagueSub = `INSERT OR IGNORE INTO league_subs (
+  league_id, user_id, added_at)
+  VALUES (?, ?, ?);`
+
+const sqlDeleteLeagueSub = `DELETE FROM league_subs
+  WHERE league_id = ?
+    AND user_id = ?`
+
+// sqlSelectTeamSubs lists every sub who played for a team in a season once
+// per match
+const sqlSelectTeamSubs = `WITH subs AS (
+    SELECT r.match_id, r.team_1_a_player_id AS user_id FROM results r JOIN matches m ON m.id = r.match_id
+      WHERE m.season_id = ?1 AND m.team_1_id = ?2 AND r.team_1_a_player_sub
+    UNION
+    SELECT r.match_id, r.team_1_b_player_id FROM results r JOIN matches m ON m.id = r.match_id
+      WHERE m.season_id = ?1 AND m.team_1_id = ?2 AND r.team_1_b_player_sub
+    UNION
+    SELECT r.match_id, r.team_2_a_player_id FROM results r JOIN matches m ON m.id = r.match_id
+      WHERE m.season_id = ?1 AND m.team_2_id = ?2 AND r.team_2_a_player_sub
+    UNION
+    SELECT r.match_id, r.team_2_b_player_id FROM results r JOIN matches m ON m.id = r.match_id
+      WHERE m.season_id = ?1 AND m.team_2_id = ?2 AND r.team_2_b_player_sub)
+  SELECT match_id, user_id
+  FROM subs
+  ORDER BY match_id, user_id`
+
+// sqlCountPlayerOtherTeamGames counts the games a player played for a team
+// other than the given one in a match or in any match of its week
+const sqlCountPlayerOtherTeamGames = `SELECT COUNT(*)
+  FROM results r
+  JOIN matches m ON m.id = r.match_id
+  WHERE m.season_id = ?1
+    AND (m.id = ?2 OR m.week = ?3)
+    AND ((m.team_1_id != ?4 AND ?5 IN (r.team_1_a_player_id, r.team_1_b_player_id))
+      OR (m.team_2_id != ?4 AND ?5 IN (r.team_2_a_player_id, r.team_2_b_player_id)))`
+
+// Season queries
+const sqlSelectSeasons = `SELECT id, league_id, name, start_date, end_date
+  FROM seasons
+  WHERE league_id = ?1
+  ORDER BY start_date DESC
+  LIMIT ?2 OFFSET ?3`
+
+const sqlCountSeasons = `SELECT COUNT(*)
+  FROM seasons
+  WHERE league_id = ?`
+
+const sqlSelectSeason = `SELECT id, league_id, name, start_date, end_date
+  FROM seasons
+  WHERE id = ?`
+
+const sqlSelectStandings = `WITH match_totals AS (
+    SELECT m.id, m.team_1_id, m.team_2_id,
+      COALESCE(SUM(r.team_1_score), 0) AS team_1_total,
+      COALESCE(SUM(r.team_2_score), 0) AS team_2_total,
+      SUM(CASE WHEN r.team_1_a_player_sub OR r.team_1_b_player_sub THEN 1 ELSE 0 END) AS team_1_sub_games,
+      SUM(CASE WHEN r.team_2_a_player_sub OR r.team_2_b_player_sub THEN 1 ELSE 0 END) AS team_2_sub_games
+    FROM matches m
+    JOIN results r ON r.match_id = m.id
+    WHERE m.season_id = ?1
+    GROUP BY m.id)
+  SELECT t.id, t.name,
+    COUNT(mt.id),
+    COALESCE(SUM(CASE WHEN (mt.team_1_id = t.id AND mt.team_1_total > mt.team_2_total)
+      OR (mt.team_2_id = t.id AND mt.team_2_total > mt.team_1_total) THEN 1 ELSE 0 END), 0),
+    COALESCE(SUM(CASE WHEN (mt.team_1_id = t.id AND mt.team_1_total < mt.team_2_total)
+      OR (mt.team_2_id = t.id AND mt.team_2_total < mt.team_1_total) THEN 1 ELSE 0 END), 0),
+    COALESCE(SUM(CASE WHEN mt.team_1_total = mt.team_2_total THEN 1 ELSE 0 END), 0),
+    COALESCE(SUM(CASE WHEN mt.team_1_id = t.id THEN mt.team_1_total ELSE mt.team_2_total END), 0),
+    COALESCE(SUM(CASE WHEN mt.team_1_id = t.id THEN mt.team_1_sub_games ELSE mt.team_2_sub_games END), 0)
+  FROM teams t
+  LEFT JOIN match_totals mt ON mt.team_1_id = t.id OR mt.team_2_id = t.id
+  WHERE t.league_id = (SELECT league_id FROM seasons WHERE id = ?1)
+  GROUP BY t.id
+  ORDER BY 7 DESC, 4 DESC, t.name`
+
+// Team queries
+const sqlSelectTeams = `SELECT id, league_id, name, a_player, b_player, active
+  FROM teams
+  WHERE league_id = ?1
+  ORDER BY name
+  LIMIT ?2 OFFSET ?3`
+
+const sqlCountTeams = `SELECT COUNT(*)
+  FROM teams
+  WHERE league_id = ?`
+
+const sqlSelectTeam = `SELECT id, league_id, name, a_player, b_player, active
+  FROM teams
+  WHERE id = ?`
+
+const sqlSelectPlayerTeams = `SELECT id, league_id, name, a_player, b_player, active
+  FROM teams
+  WHERE a_player = ?1
+    OR b_player = ?1
+  ORDER BY active DESC, name`
+
+// Match queries
+const sqlSelectMatches = `SELECT id, league_id, season_id, team_1_id, team_2_id, week, date, selection_seed
+  FROM matches
+  WHERE season_id = ?1
+    AND (?2 = 0 OR team_1_id = ?2 OR team_2_id = ?2)
+    AND (?3 = 0 OR week = ?3)
+  ORDER BY week, date, id
+  LIMIT ?4 OFFSET ?5`
+
+const sqlCountMatches = `SELECT COUNT(*)
+  FROM matches
+  WHERE season_id = ?1
+    AND (?2 = 0 OR team_1_id = ?2 OR team_2_id = ?2)
+    AND (?3 = 0 OR week = ?3)`
+
+const sqlSelectMatch = `SELECT id, league_id, season_id, team_1_id, team_2_id, week, date, selection_seed
+  FROM matches
+  WHERE id = ?`
+
+const sqlInsertMatch = `INSERT INTO matches (
+  league_id, season_id, team_1_id, team_2_id, week, date)
+  VALUES (?, ?, ?, ?, ?, ?);`
+
+// The seed is only set once so that every draw of a match can be reproduced
+const sqlUpdateMatchSelectionSeed = `UPDATE matches
+  SET selection_seed = ?
+  WHERE id = ?
+    AND selection_seed IS NULL`
+
+// Machine selection queries
+const sqlSelectSelectableMachines = `SELECT opdb_id
+  FROM machines
+  WHERE active = true
+    AND out_of_order = false
+  ORDER BY opdb_id`
+
+const sqlSelectMatchSelections = `SELECT s.id, s.match_id, s.game, s.opdb_id, m.name, s.picked_by_team_id, s.picked_by_user_id, u.name, s.draw_pool, s.created_at
+  FROM match_selections s
+  JOIN machines m ON m.opdb_id = s.opdb_id
+  JOIN users u ON u.id = s.picked_by_user_id
+  WHERE s.match_id = ?
+  ORDER BY s.game`
+
+// sqlSelectSeasonUsage counts how often each machine was used in a season; a
+// match with selections counts its selections and a match without them counts
+// its results.
+const sqlSelectSeasonUsage = `WITH season_usage AS (
+    SELECT s.opdb_id
+    FROM match_selections s
+    JOIN matches m ON m.id = s.match_id
+    WHERE m.season_id = ?1
+    UNION ALL
+    SELECT r.opdb_id
+    FROM results r
+    JOIN matches m ON m.id = r.match_id
+    WHERE m.season_id = ?1
+      AND NOT EXISTS (SELECT 1 FROM match_selections s WHERE s.match_id = r.match_id))
+  SELECT opdb_id, COUNT(*)
+  FROM season_usage
+  GROUP BY opdb_id`
+
+const sqlSelectSeasonWeeklyUsage = `SELECT r.opdb_id, mc.name, m.week, COUNT(*)
+  FROM results r
+  JOIN matches m ON m.id = r.match_id
+  JOIN machines mc ON mc.opdb_id = r.opdb_id
+  WHERE m.season_id = ?
+    AND m.week IS NOT NULL
+  GROUP BY r.opdb_id, m.week
+  ORDER BY mc.name, m.week`
+
+const sqlSelectSeasonWeeks = `SELECT DISTINCT week
+  FROM matches
+  WHERE season_id = ?
+    AND week IS NOT NULL
+  ORDER BY week`
+
+const sqlInsertMatchSelection = `INSERT INTO match_selections (
+  match_id, game, opdb_id, picked_by_team_id, picked_by_user_id, draw_pool, created_at)
+  VALUES (?, ?, ?, ?, ?, ?, ?);`
+
+// Result queries
+const sqlSelectResults = `SELECT id, match_id, opdb_id,
+    team_1_a_player_id, team_1_a_player_score, team_1_b_player_id, team_1_b_player_score, team_1_score,
+    team_2_a_player_id, team_2_a_player_score, team_2_b_player_id, team_2_b_player_score, team_2_score, photo_key,
+    team_1_a_player_sub, team_1_b_player_sub, team_2_a_player_sub, team_2_b_player_sub
+  FROM results
+  WHERE (?1 = 0 OR match_id = ?1)
+    AND (?2 = '' OR opdb_id = ?2)
+  ORDER BY id
+  LIMIT ?3 OFFSET ?4`
+
+const sqlCountResults = `SELECT COUNT(*)
+  FROM results
+  WHERE (?1 = 0 OR match_id = ?1)
+    AND (?2 = '' OR opdb_id = ?2)`
+
+const sqlInsertResult = `INSERT INTO results (
+  match_id, opdb_id,
+  team_1_a_player_id, team_1_a_player_score, team_1_b_player_id, team_1_b_player_score, team_1_score,
+  team_2_a_player_id, team_2_a_player_score, team_2_b_player_id, team_2_b_player_score, team_2_score,
+  team_1_a_player_sub, team_1_b_player_sub, team_2_a_player_sub, team_2_b_player_sub)
+  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+
+const sqlSelectResult = `SELECT id, match_id, opdb_id,
+    team_1_a_player_id, team_1_a_player_score, team_1_b_player_id, team_1_b_player_score, team_1_score,
+    team_2_a_player_id, team_2_a_player_score, team_2_b_player_id, team_2_b_player_score, team_2_score, photo_key,
+    team_1_a_player_sub, team_1_b_player_sub, team_2_a_player_sub, team_2_b_player_sub
+  FROM results
+  WHERE id = ?`
+
+const sqlUpdateResultPhoto = `UPDATE results
+  SET photo_key = ?
+  WHERE id = ?`
+
+// User queries
+const sqlSelectActiveUsers = `SELECT id, league_id, email, password, name, initials, role, active, ifpa_id, ifpa_verified_at
+  FROM users
+  WHERE active = true
+  ORDER BY name`
+
+const sqlSelectUser = `SELECT id, league_id, email, password, name, initials, role, active, ifpa_id, ifpa_verified_at
+  FROM users
+  WHERE id = ?`
+
+const sqlSelectUserByEmail = `SELECT id, league_id, email, password, name, initials, role, active, ifpa_id, ifpa_verified_at
+  FROM users
+  WHERE email = ?`
+
+const sqlSelectIfpaUsers = `SELECT id, league_id, email, password, name, initials, role, active, ifpa_id, ifpa_verified_at
+  FROM users
+  WHERE ifpa_id IS NOT NULL
+  ORDER BY ifpa_verified_at IS NOT NULL, name`
+
+const sqlUpdateUserIfpaId = `UPDATE users
+  SET ifpa_id = ?,
+    ifpa_verified_at = NULL
+  WHERE id = ?`
+
+const sqlVerifyUserIfpaId = `UPDATE users
+  SET ifpa_verified_at = ?
+  WHERE id = ?
+    AND ifpa_id IS NOT NULL`
+
+// IFPA ranking queries
+const sqlSelectIfpaRanking = `SELECT ifpa_id, name, rank, wppr, country, imported_at
+  FROM ifpa_rankings
+  WHERE ifpa_id = ?`
+
+const sqlSelectIfpaRankingsSnapshot = `SELECT COUNT(*), MAX(imported_at)
+  FROM ifpa_rankings`
+
+const sqlDeleteIfpaRankings = `DELETE FROM ifpa_rankings`
+
+const sqlInsertIfpaRanking = `INSERT INTO ifpa_rankings (
+  ifpa_id, name, rank, wppr, country, imported_at)
+  VALUES (?, ?, ?, ?, ?, ?);`
+
+// API token queries
+const sqlInsertApiToken = `INSERT INTO api_tokens (
+  user_id, name, token_hash, scopes, created_at)
+  VALUES (?, ?, ?, ?, ?);`
+
+const sqlSelectApiTokens = `SELECT t.id, t.user_id, u.role, t.name, t.scopes, t.created_at, t.last_used_at, t.revoked_at
+  FROM api_tokens t
+  JOIN users u ON u.id = t.user_id
+  WHERE t.user_id = ?
+  ORDER BY t.created_at DESC`
+
+const sqlSelectApiTokenByHash = `SELECT t.id, t.user_id, u.role, t.name, t.scopes, t.created_at, t.last_used_at, t.revoked_at
+  FROM api_tokens t
+  JOIN users u ON u.id = t.user_id
+  WHERE t.token_hash = ?
+    AND t.revoked_at IS NULL
+    AND u.active = true`
+
+const sqlUpdateApiTokenLastUsed = `UPDATE api_tokens
+  SET last_used_at = ?
+  WHERE id = ?`
+
+const sqlRevokeApiToken = `UPDATE api_tokens
+  SET revoked_at = ?
+  WHERE id = ?
+    AND user_id = ?
+    AND revoked_at IS NULL`
+
+// Machine lineup history queries
+const sqlCloseMachineLineupHistory = `UPDATE machine_lineup_history
+  SET removed_at = ?
+  WHERE removed_at IS NULL
+    AND opdb_id NOT IN (SELECT opdb_id FROM machines WHERE active = true)`
+
+const sqlOpenMachineLineupHistory = `INSERT INTO machine_lineup_history (opdb_id, added_at)
+  SELECT opdb_id, ?
+  FROM machines
+  WHERE active = true
+    AND opdb_id NOT IN (SELECT opdb_id FROM machine_lineup_history WHERE removed_at IS NULL)`
+
+const sqlUpdateLineupOverride = `UPDATE machines
+  SET active = ?,
+    active_source = 'manual'
+  WHERE opdb_id = ?`
+
+const sqlClearLineupOverride = `UPDATE machines
+  SET active = pinballmap_active,
+    active_source = CASE WHEN pinballmap_active THEN 'pinballmap' END
+  WHERE opdb_id = ?
+    AND active_source = 'manual'`
+
+const sqlSelectLineup = `SELECT opdb_id, name, active, active_source, pinballmap_active
+  FROM machines
+  WHERE active = true
+    OR active_source = 'manual'
+  ORDER BY active DESC, name`
+
+const sqlSelectLineupEntry = `SELECT opdb_id, name, active, active_source, pinballmap_active
+  FROM machines
+  WHERE opdb_id = ?`
+
+const sqlSelectMachineLineupHistory = `SELECT added_at, removed_at
+  FROM machine_lineup_history
+  WHERE opdb_id = ?
+  ORDER BY added_at DESC`
+
+// Maintenance queries
+const sqlUpdateMachineOutOfOrder = `UPDATE machines
+  SET out_of_order = ?
+  WHERE opdb_id = ?`
+
+const sqlInsertMaintenanceTicket = `INSERT INTO maintenance_tickets (
+  opdb_id, reporter_id, description, state, created_at, updated_at)
+  VALUES (?, ?, ?, 'open', ?, ?);`
+
+const sqlMaintenanceTicketColumns = `SELECT t.id, t.opdb_id, m.name, t.reporter_id, u.name, t.description, t.state, t.created_at, t.updated_at
+  FROM maintenance_tickets t
+  JOIN machines m ON m.opdb_id = t.opdb_id
+  JOIN users u ON u.id = t.reporter_id`
+
+const sqlSelectMaintenanceTickets = sqlMaintenanceTicketColumns + `
+  WHERE (?1 = '' OR t.state = ?1)
+    AND (?2 = '' OR t.opdb_id = ?2)
+  ORDER BY CASE t.state WHEN 'open' THEN 0 WHEN 'in_progress' THEN 1 ELSE 2 END,
+    t.updated_at DESC`
+
+const sqlSelectMaintenanceTicket = sqlMaintenanceTicketColumns + `
+  WHERE t.id = ?`
+
+const sqlUpdateMaintenanceTicketState = `UPDATE maintenance_tickets
+  SET state = ?, updated_at = ?
+  WHERE id = ?`
+
+const sqlInsertMaintenanceNote = `INSERT INTO maintenance_notes (
+  ticket_id, author_id, note, created_at)
+  VALUES (?, ?, ?, ?);`
+
+const sqlTouchMaintenanceTicket = `UPDATE maintenance_tickets
+  SET updated_at = ?
+  WHERE id = ?`
+
+const sqlSelectMaintenanceNotes = `SELECT n.id, n.ticket_id, n.author_id, u.name, n.note, n.created_at
+  FROM maintenance_notes n
+  JOIN users u ON u.id = n.author_id
+  WHERE n.ticket_id = ?
+  ORDER BY n.created_at, n.id`
+
+// High score queries
+const sqlInsertHighScore = `INSERT INTO high_scores (
+  opdb_id, user_id, score, photo_key, created_at)
+  VALUES (?, ?, ?, ?, ?);`
+
+const sqlHighScoreColumns = `SELECT h.id, h.opdb_id, m.name, h.user_id, u.name, h.score, h.photo_key, h.result_id, h.created_at, h.verified_at`
+
+// Each player appears once on a board with their best verified score
+const sqlRankedHighScores = `WITH ranked AS (
+    SELECT h.*,
+      ROW_NUMBER() OVER (PARTITION BY h.opdb_id, h.user_id ORDER BY h.score DESC, h.created_at) AS player_rank
+    FROM high_scores h
+    WHERE h.verified_at IS NOT NULL),
+  best AS (
+    SELECT *,
+      ROW_NUMBER() OVER (PARTITION BY opdb_id ORDER BY score DESC, created_at) AS board_rank
+    FROM ranked
+    WHERE player_rank = 1)
+  `
+
+const sqlSelectHighScoreBoard = sqlRankedHighScores + sqlHighScoreColumns + `
+  FROM best h
+  JOIN machines m ON m.opdb_id = h.opdb_id
+  JOIN users u ON u.id = h.user_id
+  WHERE h.opdb_id = ?1
+    AND h.board_rank <= ?2
+  ORDER BY h.board_rank`
+
+const sqlSelectHighScoreBoards = sqlRankedHighScores + sqlHighScoreColumns + `
+  FROM best h
+  JOIN machines m ON m.opdb_id = h.opdb_id
+  JOIN users u ON u.id = h.user_id
+  WHERE m.active = true
+    AND h.board_rank <= ?1
+  ORDER BY m.name, h.board_rank`
+
+const sqlSelectUnverifiedHighScores = sqlHighScoreColumns + `
+  FROM high_scores h
+  JOIN machines m ON m.opdb_id = h.opdb_id
+  JOIN users u ON u.id = h.user_id
+  WHERE h.verified_at IS NULL
+  ORDER BY h.created_at`
+
+const sqlVerifyHighScore = `UPDATE high_scores
+  SET verified_at = ?, verified_by = ?
+  WHERE id = ?
+    AND verified_at IS NULL`
+
+const sqlDeleteUnverifiedHighScore = `DELETE FROM high_scores
+  WHERE id = ?
+    AND verified_at IS NULL`
+
+// League scores were entered by staff so they are imported as verified
+const sqlImportLeagueHighScores = `INSERT OR IGNORE INTO high_scores (
+  opdb_id, user_id, score, result_id, created_at, verified_at, verified_by)
+  SELECT s.opdb_id, s.user_id, s.score, s.result_id, COALESCE(m.date, ?1), ?1, ?2
+  FROM (
+    SELECT id AS result_id, match_id, opdb_id, team_1_a_player_id AS user_id, team_1_a_player_score AS score FROM results
+    UNION ALL
+    SELECT id, match_id, opdb_id, team_1_b_player_id, team_1_b_player_score FROM results
+    UNION ALL
+    SELECT id, match_id, opdb_id, team_2_a_player_id, team_2_a_player_score FROM results
+    UNION ALL
+    SELECT id, match_id, opdb_id, team_2_b_player_id, team_2_b_player_score FROM results) s
+  JOIN matches m ON m.id = s.match_id
+  WHERE s.user_id IS NOT NULL
+    AND s.score IS NOT NULL`
+
+// Machine search queries
+const sqlDeleteMachinesSearch = `DELETE FROM machines_search`
+
+const sqlRebuildMachinesSearch = `INSERT INTO machines_search (
+  opdb_id, name, manufacturer, features)
+  SELECT m.opdb_id, m.name, mm.name || ' ' || mm.full_name, COALESCE(REPLACE(f.features, ',', ' '), '')
+    FROM machines m
+    JOIN machine_manufacturers mm ON mm.id = m.manufacturer_id
+    LEFT JOIN features f ON f.id = m.features_id`
+
+const sqlSelectMachinesSearchVocabulary = `SELECT term
+  FROM machines_search_vocabulary`
+
+const sqlSearchMachines = `SELECT m.opdb_id, m.manufacturer_id, m.ipdb_id, m.features_id, m.name, m.manufacture_date, m.backglass_image_uuid, m.updated_at, m.active, m.out_of_order,
+    bm25(machines_search, 0.0, 10.0, 2.0, 1.0) AS score
+  FROM machines_search s
+  JOIN machines m ON m.opdb_id = s.opdb_id
+  WHERE machines_search MATCH ?1
+  ORDER BY score, m.active DESC, m.name
+  LIMIT ?2 OFFSET ?3`
+
+const sqlCountSearchMachines = `SELECT COUNT(*)
+  FROM machines_search
+  WHERE machines_search MATCH ?`
+
+// Tournament queries
+const sqlSelectTournaments = `SELECT id, name, format, state, group_size, strike_limit, rounds, scoring, entries, counted_machines, qualifiers, bracket_id, seed, created_by, created_at
+  FROM tournaments
+  ORDER BY CASE state WHEN 'completed' THEN 1 ELSE 0 END, created_at DESC, id DESC
+  LIMIT ?1 OFFSET ?2`
+
+const sqlCountTournaments = `SELECT COUNT(*)
+  FROM tournaments`
+
+const sqlSelectTournament = `SELECT id, name, format, state, group_size, strike_limit, rounds, scoring, entries, counted_machines, qualifiers, bracket_id, seed, created_by, created_at
+  FROM tournaments
+  WHERE id = ?`
+
+const sqlInsertTournament = `INSERT INTO tournaments (
+  name, format, state, group_size, strike_limit, rounds, scoring, entries, counted_machines, qualifiers, seed, created_by, created_at)
+  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+
+const sqlUpdateTournamentState = `UPDATE tournaments
+  SET state = ?
+  WHERE id = ?`
+
+const sqlSelectTournamentPlayers = `SELECT p.tournament_id, p.user_id, u.name, p.seed, p.checked_in_at
+  FROM tournament_players p
+  JOIN users u ON u.id = p.user_id
+  WHERE p.tournament_id = ?
+  ORDER BY p.checked_in_at, p.user_id`
+
+const sqlInsertTournamentPlayer = `INSERT OR IGNORE INTO tournament_players (
+  tournament_id, user_id, seed, checked_in_at)
+  VALUES (?, ?, ?, ?);`
+
+const sqlUpdateTournamentPlayerSeed = `UPDATE tournament_players
+  SET seed = ?
+  WHERE tournament_id = ?
+    AND user_id = ?`
+
+const sqlDeleteTournamentPlayer = `DELETE FROM tournament_players
+  WHERE tournament_id = ?
+    AND user_id = ?`
+
+const sqlSelectTournamentGames = `SELECT g.id, g.tournament_id, g.round, g.opdb_id, m.name, g.draw_pool, g.picked_by, g.completed_at
+  FROM tournament_games g
+  JOIN machines m ON m.opdb_id = g.opdb_id
+  WHERE g.tournament_id = ?
+  ORDER BY g.round, g.id`
+
+const sqlSelectTournamentGamePlayers = `SELECT gp.game_id, gp.user_id, u.name, gp.position, gp.place
+  FROM tournament_game_players gp
+  JOIN tournament_games g ON g.id = gp.game_id
+  JOIN users u ON u.id = gp.user_id
+  WHERE g.tournament_id = ?
+  ORDER BY gp.game_id, gp.position`
+
+const sqlInsertTournamentGame = `INSERT INTO tournament_games (
+  tournament_id, round, opdb_id, draw_pool)
+  VALUES (?, ?, ?, ?);`
+
+const sqlInsertTournamentGamePlayer = `INSERT INTO tournament_game_players (
+  game_id, user_id, position)
+  VALUES (?, ?, ?);`
+
+const sqlUpdateTournamentGamePlace = `UPDATE tournament_game_players
+  SET place = ?
+  WHERE game_id = ?
+    AND user_id = ?`
+
+const sqlCompleteTournamentGame = `UPDATE tournament_games
+  SET completed_at = ?
+  WHERE id = ?
+    AND completed_at IS NULL`
+
+const sqlPickTournamentGameMachine = `UPDATE tournament_games
+  SET opdb_id = ?, picked_by = ?
+  WHERE id = ?
+    AND completed_at IS NULL`
+
+const sqlCloseQualifying = `UPDATE tournaments
+  SET state = 'completed', bracket_id = ?
+  WHERE id = ?
+    AND state != 'completed'`
+
+const sqlSelectTournamentTickets = `SELECT t.id, t.tournament_id, t.user_id, u.name, t.opdb_id, m.name, t.sold_by, t.sold_at, s.id IS NOT NULL
+  FROM tournament_tickets t
+  JOIN users u ON u.id = t.user_id
+  JOIN machines m ON m.opdb_id = t.opdb_id
+  LEFT JOIN tournament_scores s ON s.ticket_id = t.id
+  WHERE t.tournament_id = ?
+  ORDER BY t.sold_at, t.id`
+
+const sqlInsertTournamentTicket = `INSERT INTO tournament_tickets (
+  tournament_id, user_id, opdb_id, sold_by, sold_at)
+  VALUES (?, ?, ?, ?, ?);`
+
+const sqlSelectTournamentScores = `SELECT s.id, s.ticket_id, t.tournament_id, t.user_id, u.name, t.opdb_id, m.name, s.score, s.submitted_at, s.verified_at
+  FROM tournament_scores s
+  JOIN tournament_tickets t ON t.id = s.ticket_id
+  JOIN users u ON u.id = t.user_id
+  JOIN machines m ON m.opdb_id = t.opdb_id
+  WHERE t.tournament_id = ?
+  ORDER BY s.submitted_at, s.id`
+
+const sqlInsertTournamentScore = `INSERT INTO tournament_scores (
+  ticket_id, score, submitted_by, submitted_at, verified_by, verified_at)
+  SELECT t.id, ?4, ?5, ?6, ?7, ?8
+  FROM tournament_tickets t
+  WHERE t.tournament_id = ?1
+    AND t.user_id = ?2
+    AND t.opdb_id = ?3
+    AND NOT EXISTS (SELECT 1 FROM tournament_scores s WHERE s.ticket_id = t.id)
+  ORDER BY t.id
+  LIMIT 1`
+
+const sqlVerifyTournamentScore = `UPDATE tournament_scores
+  SET verified_by = ?, verified_at = ?
+  WHERE id = ?
+    AND verified_at IS NULL
+    AND ticket_id IN (SELECT id FROM tournament_tickets WHERE tournament_id = ?)`
+
+const sqlDeleteUnverifiedTournamentScore = `DELETE FROM tournament_scores
+  WHERE id = ?
+    AND verified_at IS NULL
+    AND ticket_id IN (SELECT id FROM tournament_tickets WHERE tournament_id = ?)`
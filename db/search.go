@@ -0,0 +1,261 @@
+package db
+
+import (
+	"database/sql"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/mikefero/tpl/log"
+	"github.com/mikefero/tpl/utils"
+)
+
+type SearchResult struct {
+	Machine
+	Score float64
+}
+
+var stmtSearchMachines *sql.Stmt
+var stmtCountSearchMachines *sql.Stmt
+var stmtSelectMachinesSearchVocabulary *sql.Stmt
+
+var fullTextSearch bool
+var searchVocabulary []string
+var searchVocabularyLoaded bool
+var searchVocabularyMutex sync.Mutex
+var reSearchTerm = regexp.MustCompile(`[\pL\pN]+`)
+
+// txCreateMachinesSearchTables creates the FTS5 index of the OPDB catalog;
+// FTS5 is only available when the SQLite driver is built with the sqlite_fts5
+// tag, without which the database is created without the index.
+func txCreateMachinesSearchTables(tx *sql.Tx) bool {
+	for _, table := range []string{machinesSearchTable, machinesSearchVocabularyTable} {
+		if _, err := tx.Exec(table); err != nil {
+			log.WithFields(log.Fields{
+				"statement": table,
+				"error":     err,
+			}).Warn("unable to create machines search tables; build with -tags sqlite_fts5 for full-text search")
+			return false
+		}
+	}
+	return true
+}
+
+// txRebuildMachinesSearch replaces the search index with the machines of the
+// OPDB catalog.
+func txRebuildMachinesSearch(tx *sql.Tx) {
+	txExec(tx, sqlDeleteMachinesSearch)
+	txExec(tx, sqlRebuildMachinesSearch)
+}
+
+// maybeCreateMachinesSearchIndex creates and fills the search index of a
+// database that does not have one yet, either because it predates full-text
+// search or because it was created without FTS5.
+func maybeCreateMachinesSearchIndex() {
+	var count int
+	if err := session.QueryRow(sqlCountTables, "machines_search").Scan(&count); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlCountTables,
+			"error":     err,
+		}).Panic("unable to execute SQL statement")
+	}
+	if count > 0 {
+		return
+	}
+
+	tx, err := session.Begin()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Panic("unable to begin transaction for machines search index creation")
+	}
+	if !txCreateMachinesSearchTables(tx) {
+		tx.Rollback()
+		return
+	}
+	txRebuildMachinesSearch(tx)
+	if err := tx.Commit(); err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Panic("unable to commit transaction for machines search index creation")
+	}
+	log.Info("machines search index created")
+}
+
+// HasFullTextSearch tells whether the FTS5 index of the machines is available;
+// machines cannot be searched without it so the server refuses to start.
+func HasFullTextSearch() bool {
+	return fullTextSearch
+}
+
+func getSearchVocabulary() []string {
+	searchVocabularyMutex.Lock()
+	defer searchVocabularyMutex.Unlock()
+	if searchVocabularyLoaded {
+		return searchVocabulary
+	}
+
+	rows, err := stmtSelectMachinesSearchVocabulary.Query()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectMachinesSearchVocabulary,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var vocabulary []string
+	for rows.Next() {
+		var term string
+		if err := rows.Scan(&term); err == nil {
+			vocabulary = append(vocabulary, term)
+		}
+	}
+	searchVocabulary = vocabulary
+	searchVocabularyLoaded = true
+	return searchVocabulary
+}
+
+// resetSearchVocabulary drops the cached vocabulary so that the terms of a
+// rebuilt search index are used for typo tolerance.
+func resetSearchVocabulary() {
+	searchVocabularyMutex.Lock()
+	defer searchVocabularyMutex.Unlock()
+	searchVocabulary = nil
+	searchVocabularyLoaded = false
+}
+
+// GetTypoTolerance returns the number of edits allowed for a word or name;
+// short ones must be spelled correctly to avoid matching everything.
+func GetTypoTolerance(term string) int {
+	switch length := len([]rune(term)); {
+	case length < 4:
+		return 0
+	case length < 8:
+		return 1
+	}
+	return 2
+}
+
+// newMatchExpression builds an FTS5 query where every term of the search
+// must match either as a prefix or as a known term within the typo tolerance.
+func newMatchExpression(query string) string {
+	var expressions []string
+	for _, term := range reSearchTerm.FindAllString(strings.ToLower(query), -1) {
+		alternatives := []string{`"` + term + `"*`}
+		if tolerance := GetTypoTolerance(term); tolerance > 0 {
+			for _, known := range getSearchVocabulary() {
+				if known != term && !strings.HasPrefix(known, term) && utils.EditDistance(known, term) <= tolerance {
+					alternatives = append(alternatives, `"`+known+`"`)
+				}
+			}
+		}
+		expressions = append(expressions, "("+strings.Join(alternatives, " OR ")+")")
+	}
+	return strings.Join(expressions, " AND ")
+}
+
+// SearchMachines searches the entire OPDB catalog by machine name,
+// manufacturer and features with the best matches first.
+func SearchMachines(query string, limit int, offset int) []SearchResult {
+	if !fullTextSearch {
+		return nil
+	}
+	match := newMatchExpression(query)
+	if len(match) == 0 {
+		return nil
+	}
+
+	rows, err := stmtSearchMachines.Query(match, limit, offset)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSearchMachines,
+			"query":     match,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var result SearchResult
+		if err := rows.Scan(&result.OpdbId,
+			&result.ManufacturerId,
+			&result.IpdbId,
+			&result.FeaturesId,
+			&result.Name,
+			&result.ManufactureDate,
+			&result.BackglassImageUuid,
+			&result.UpdatedAt,
+			&result.Active,
+			&result.OutOfOrder,
+			&result.Score); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSearchMachines,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for machine search")
+		} else {
+			results = append(results, result)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSearchMachines,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for machine search")
+	}
+
+	return results
+}
+
+func CountSearchMachines(query string) int {
+	if !fullTextSearch {
+		return 0
+	}
+	match := newMatchExpression(query)
+	if len(match) == 0 {
+		return 0
+	}
+
+	var count int
+	err := stmtCountSearchMachines.QueryRow(match).Scan(&count)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlCountSearchMachines,
+			"query":     match,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+	}
+
+	return count
+}
+
+func closePreparedSearchStatements() {
+	log.Debug("closing prepared search statements")
+	if fullTextSearch {
+		stmtSearchMachines.Close()
+		stmtCountSearchMachines.Close()
+		stmtSelectMachinesSearchVocabulary.Close()
+	}
+	log.Debug("prepared search statements closed")
+}
+
+func prepareSearchStatements() {
+	log.Debug("preparing search statements")
+	var err error
+	stmtSearchMachines, err = session.Prepare(sqlSearchMachines)
+	fullTextSearch = err == nil
+	if !fullTextSearch {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Warn("full-text search is unavailable; build with -tags sqlite_fts5 to search machines")
+	} else {
+		stmtCountSearchMachines = prepare(sqlCountSearchMachines)
+		stmtSelectMachinesSearchVocabulary = prepare(sqlSelectMachinesSearchVocabulary)
+	}
+	log.Debug("search statements prepared")
+}
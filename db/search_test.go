@@ -0,0 +1,72 @@
+//go:build sqlite_fts5
+// +build sqlite_fts5
+
+package db
+
+import "testing"
+
+// The search index depends on FTS5, so these tests only build with the
+// sqlite_fts5 tag and fail when the SQLite driver still lacks it:
+//
+//	go test -tags sqlite_fts5 ./...
+func TestImportOpdbSearchIndex(t *testing.T) {
+	openTestCatalog(t)
+	if !fullTextSearch {
+		t.Fatal("expected the search index to be created with FTS5")
+	}
+	for _, name := range []string{"The Addams Family", "The Addams Family Special Collectors Edition"} {
+		if count, err := ImportOpdb(writeTestOpdbExport(t, name)); err != nil || count != 2 {
+			t.Fatalf("expected 2 machines imported, got %d: %v", count, err)
+		}
+	}
+
+	for _, test := range []struct {
+		match string
+		count int
+	}{
+		{`"collectors"`, 1},
+		{`"williams"`, 1},
+		{`"dot"`, 1},
+		{`"addams"`, 1},
+	} {
+		var count int
+		if err := session.QueryRow(sqlCountSearchMachines, test.match).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != test.count {
+			t.Errorf("expected %d machines indexed for %s, got %d", test.count, test.match, count)
+		}
+	}
+}
+
+func TestSearchMachines(t *testing.T) {
+	initializeTestDatabase(t)
+	if !HasFullTextSearch() {
+		t.Fatal("expected full-text search with FTS5")
+	}
+
+	for _, test := range []struct {
+		name   string
+		query  string
+		opdbId string
+	}{
+		{"machine name", "Addams Family", "G4ODR-MDXEy"},
+		{"prefix", "medie", "G5pe4-MePZv"},
+		{"typo", "Medeival Madness", "G5pe4-MePZv"},
+		{"manufacturer", "williams medieval", "G5pe4-MePZv"},
+	} {
+		results := SearchMachines(test.query, 10, 0)
+		if len(results) == 0 || results[0].OpdbId != test.opdbId {
+			t.Errorf("%s: expected %s first for %q, got %+v", test.name, test.opdbId, test.query, results)
+		}
+		if count := CountSearchMachines(test.query); count < len(results) {
+			t.Errorf("%s: expected at least %d matches counted, got %d", test.name, len(results), count)
+		}
+	}
+
+	for _, query := range []string{"", "  ", "-", "qqqqqqqq"} {
+		if results := SearchMachines(query, 10, 0); len(results) != 0 || CountSearchMachines(query) != 0 {
+			t.Errorf("expected nothing found for %q, got %+v", query, results)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package db
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	mathrand "math/rand"
+	"strings"
+	"time"
+
+	"github.com/mikefero/tpl/log"
+)
+
+const SelectionModeHomePick = "home_pick"
+const SelectionModeAlternating = "alternating"
+const SelectionModeRandom = "random"
+
+var SelectionModes = []string{
+	SelectionModeHomePick,
+	SelectionModeAlternating,
+	SelectionModeRandom,
+}
+
+var ErrSelectionWrongSide = errors.New("the other team picks this game")
+var ErrSelectionNotPlayer = errors.New("only players of the match can select machines")
+var ErrSelectionPickRequired = errors.New("a machine must be picked for this game")
+var ErrSelectionRandom = errors.New("machines are drawn at random in this league")
+var ErrSelectionUnavailable = errors.New("machine is not in the lineup or is out of order")
+var ErrSelectionRepeated = errors.New("machine was already played in this match")
+var ErrSelectionUsageLimit = errors.New("machine reached its usage limit for the season")
+var ErrSelectionNoMachines = errors.New("no machines are available to select")
+var ErrSelectionConflict = errors.New("another machine was selected for this game at the same time")
+var ErrSelectionComplete = errors.New("every game of the match already has a machine")
+
+type Selection struct {
+	Id             int
+	MatchId        int
+	Game           int
+	OpdbId         string
+	MachineName    string
+	PickedByTeamId sql.NullInt64
+	PickedByUserId int
+	PickedByName   string
+	DrawPool       sql.NullString
+	CreatedAt      int64
+}
+
+var stmtSelectSelectableMachines *sql.Stmt
+var stmtSelectMatchSelections *sql.Stmt
+var stmtInsertMatchSelection *sql.Stmt
+var stmtUpdateMatchSelectionSeed *sql.Stmt
+
+func IsSelectionMode(mode string) bool {
+	for _, m := range SelectionModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPickingTeam returns the team that picks a game of a match; random draws
+// are not picked by either team. With alternating picks the home team picks
+// the odd games and the away team the even games.
+func GetPickingTeam(mode string, match Match, game int) sql.NullInt64 {
+	home := sql.NullInt64{
+		Int64: int64(match.Team1Id),
+		Valid: true,
+	}
+	switch mode {
+	case SelectionModeRandom:
+		return sql.NullInt64{}
+	case SelectionModeAlternating:
+		if game%2 == 0 && match.Team2Id.Valid {
+			return match.Team2Id
+		}
+	}
+	return home
+}
+
+// DrawMachine draws a machine from a pool for a game; the same seed, game and
+// pool always draw the same machine so that every draw can be audited.
+func DrawMachine(seed int64, game int, pool []string) string {
+	if len(pool) == 0 {
+		return ""
+	}
+	random := mathrand.New(mathrand.NewSource(seed + int64(game)))
+	return pool[random.Intn(len(pool))]
+}
+
+// GetSelectableMachines returns the active machines that are in service.
+func GetSelectableMachines() []string {
+	rows, err := stmtSelectSelectableMachines.Query()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectSelectableMachines,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var opdbIds []string
+	for rows.Next() {
+		var opdbId string
+		if err := rows.Scan(&opdbId); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectSelectableMachines,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for selectable machine")
+		} else {
+			opdbIds = append(opdbIds, opdbId)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectSelectableMachines,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for selectable machine")
+	}
+
+	return opdbIds
+}
+
+func GetMatchSelections(matchId int) []Selection {
+	rows, err := stmtSelectMatchSelections.Query(matchId)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectMatchSelections,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var selections []Selection
+	for rows.Next() {
+		var selection Selection
+		if err := rows.Scan(&selection.Id,
+			&selection.MatchId,
+			&selection.Game,
+			&selection.OpdbId,
+			&selection.MachineName,
+			&selection.PickedByTeamId,
+			&selection.PickedByUserId,
+			&selection.PickedByName,
+			&selection.DrawPool,
+			&selection.CreatedAt); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectMatchSelections,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for match selection")
+		} else {
+			selections = append(selections, selection)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectMatchSelections,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for match selection")
+	}
+
+	return selections
+}
+
+// GetAvailableMachines returns the machines that can be selected for the next
+// game of a match; machines that reached the usage limit of the league for the
+// season are left out.
+func GetAvailableMachines(league League, match Match, selections []Selection) []string {
+	played := map[string]bool{}
+	for _, selection := range selections {
+		played[selection.OpdbId] = true
+	}
+	var usage map[string]int
+	if league.UsageLimit.Valid {
+		usage = GetSeasonUsage(match.SeasonId)
+	}
+
+	var available []string
+	for _, opdbId := range GetSelectableMachines() {
+		if league.NoRepeat && played[opdbId] {
+			continue
+		}
+		if league.UsageLimit.Valid && int64(usage[opdbId]) >= league.UsageLimit.Int64 {
+			continue
+		}
+		available = append(available, opdbId)
+	}
+	return available
+}
+
+func isTeamPlayer(team *Team, userId int) bool {
+	return team != nil && (team.APlayer == userId || team.BPlayer == userId)
+}
+
+// IsMatchPlayer determines if a user plays for either team of a match.
+func IsMatchPlayer(match Match, userId int) bool {
+	if isTeamPlayer(GetTeam(match.Team1Id), userId) {
+		return true
+	}
+	return match.Team2Id.Valid && isTeamPlayer(GetTeam(int(match.Team2Id.Int64)), userId)
+}
+
+// newSeed returns a random non-negative seed for machine draws.
+func newSeed() (int64, error) {
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(random) >> 1), nil
+}
+
+// getSelectionSeed returns the seed of the random draws of a match, creating
+// it on the first draw.
+func getSelectionSeed(match Match) (int64, error) {
+	if match.SelectionSeed.Valid {
+		return match.SelectionSeed.Int64, nil
+	}
+	seed, err := newSeed()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := stmtUpdateMatchSelectionSeed.Exec(seed, match.Id); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlUpdateMatchSelectionSeed,
+			"match_id":  match.Id,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return 0, err
+	}
+
+	// Another draw may have set the seed first
+	if current := GetMatch(match.Id); current != nil && current.SelectionSeed.Valid {
+		return current.SelectionSeed.Int64, nil
+	}
+	return seed, nil
+}
+
+// SelectMachine selects the machine for the next game of a match following
+// the selection mode of its league, up to the number of games of its matches.
+// Picks are limited to the players of the picking team while random draws can
+// be made by either team; staff can select for both teams.
+func SelectMachine(match Match, user User, opdbId string) (*Selection, error) {
+	league := GetLeague(match.LeagueId)
+	if league == nil {
+		return nil, ErrSelectionNoMachines
+	}
+	selections := GetMatchSelections(match.Id)
+	game := len(selections) + 1
+
+	if !user.HasRole(RoleStaff) && !IsMatchPlayer(match, user.Id) {
+		return nil, ErrSelectionNotPlayer
+	}
+	if game > league.Games {
+		return nil, ErrSelectionComplete
+	}
+	pickingTeamId := GetPickingTeam(league.SelectionMode, match, game)
+	if pickingTeamId.Valid && !user.HasRole(RoleStaff) {
+		if !isTeamPlayer(GetTeam(int(pickingTeamId.Int64)), user.Id) {
+			return nil, ErrSelectionWrongSide
+		}
+	}
+
+	available := GetAvailableMachines(*league, match, selections)
+	var drawPool sql.NullString
+	if league.SelectionMode == SelectionModeRandom {
+		if len(opdbId) > 0 {
+			return nil, ErrSelectionRandom
+		}
+		if len(available) == 0 {
+			return nil, ErrSelectionNoMachines
+		}
+		if league.UsageLimit.Valid {
+			available = GetLeastUsedMachines(available, GetSeasonUsage(match.SeasonId))
+		}
+		seed, err := getSelectionSeed(match)
+		if err != nil {
+			return nil, err
+		}
+		opdbId = DrawMachine(seed, game, available)
+		drawPool = sql.NullString{
+			String: strings.Join(available, ","),
+			Valid:  true,
+		}
+	} else {
+		if len(opdbId) == 0 {
+			return nil, ErrSelectionPickRequired
+		}
+		isAvailable := false
+		for _, a := range available {
+			isAvailable = isAvailable || a == opdbId
+		}
+		if !isAvailable {
+			for _, selection := range selections {
+				if league.NoRepeat && selection.OpdbId == opdbId {
+					return nil, ErrSelectionRepeated
+				}
+			}
+			for _, selectable := range GetSelectableMachines() {
+				if selectable == opdbId {
+					return nil, ErrSelectionUsageLimit
+				}
+			}
+			return nil, ErrSelectionUnavailable
+		}
+	}
+
+	_, err := stmtInsertMatchSelection.Exec(match.Id,
+		game,
+		opdbId,
+		pickingTeamId,
+		user.Id,
+		drawPool,
+		time.Now().Unix())
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlInsertMatchSelection,
+			"match_id":  match.Id,
+			"game":      game,
+			"error":     err,
+		}).Warn("unable to execute prepared SQL statement")
+		return nil, ErrSelectionConflict
+	}
+
+	selections = GetMatchSelections(match.Id)
+	for i := range selections {
+		if selections[i].Game == game {
+			return &selections[i], nil
+		}
+	}
+	return nil, ErrSelectionConflict
+}
+
+// IsMachineSelected determines if a machine was selected for a match; matches
+// without selections accept any machine.
+func IsMachineSelected(matchId int, opdbId string) bool {
+	selections := GetMatchSelections(matchId)
+	for _, selection := range selections {
+		if selection.OpdbId == opdbId {
+			return true
+		}
+	}
+	return len(selections) == 0
+}
+
+func closePreparedSelectionStatements() {
+	log.Debug("closing prepared selection statements")
+	stmtSelectSelectableMachines.Close()
+	stmtSelectMatchSelections.Close()
+	stmtInsertMatchSelection.Close()
+	stmtUpdateMatchSelectionSeed.Close()
+	log.Debug("prepared selection statements closed")
+}
+
+func prepareSelectionStatements() {
+	log.Debug("preparing selection statements")
+	stmtSelectSelectableMachines = prepare(sqlSelectSelectableMachines)
+	stmtSelectMatchSelections = prepare(sqlSelectMatchSelections)
+	stmtInsertMatchSelection = prepare(sqlInsertMatchSelection)
+	stmtUpdateMatchSelectionSeed = prepare(sqlUpdateMatchSelectionSeed)
+	log.Debug("selection statements prepared")
+}
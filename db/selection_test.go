@@ -0,0 +1,177 @@
+package db
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// selectionFixture is a league in which Flippers (Ann and Bob) host Tilt (Cat
+// and Dan) twice; Flippers picked The Addams Family for the first game of the
+// second match. Eve is staff and Fay plays for no team. The Addams Family,
+// Attack from Mars and Medieval Madness are in the lineup while Godzilla is
+// out of order.
+var selectionFixture = []string{
+	`INSERT INTO leagues (id, name, active) VALUES (1, 'Monday', true)`,
+	`INSERT INTO seasons (id, league_id, name, start_date) VALUES (1, 1, 'Spring', 0)`,
+	`INSERT INTO users (id, league_id, email, password, name, role, active) VALUES
+  (1, 1, 'ann@example.com', '', 'Ann', 'player', true),
+  (2, 1, 'bob@example.com', '', 'Bob', 'player', true),
+  (3, 1, 'cat@example.com', '', 'Cat', 'player', true),
+  (4, 1, 'dan@example.com', '', 'Dan', 'player', true),
+  (5, 1, 'eve@example.com', '', 'Eve', 'staff', true),
+  (6, 1, 'fay@example.com', '', 'Fay', 'player', true)`,
+	`INSERT INTO teams (id, league_id, name, a_player, b_player, active) VALUES
+  (1, 1, 'Flippers', 1, 2, true),
+  (2, 1, 'Tilt', 3, 4, true)`,
+	`INSERT INTO matches (id, league_id, season_id, team_1_id, team_2_id, week) VALUES
+  (1, 1, 1, 1, 2, 1),
+  (2, 1, 1, 1, 2, 2)`,
+	`UPDATE machines SET active = true WHERE opdb_id IN ('G4ODR-MDXEy', 'G5pe4-MePZv', 'G4do5-MDlN7', 'G5po2-MeP6B')`,
+	`UPDATE machines SET out_of_order = true WHERE opdb_id = 'G5po2-MeP6B'`,
+	`INSERT INTO match_selections (match_id, game, opdb_id, picked_by_team_id, picked_by_user_id, created_at) VALUES
+  (2, 1, 'G4ODR-MDXEy', 1, 1, 0)`,
+}
+
+func isInPool(opdbId string, pool []string) bool {
+	for _, p := range pool {
+		if p == opdbId {
+			return true
+		}
+	}
+	return false
+}
+
+func TestGetPickingTeam(t *testing.T) {
+	home := sql.NullInt64{Int64: 1, Valid: true}
+	away := sql.NullInt64{Int64: 2, Valid: true}
+	match := Match{Team1Id: 1, Team2Id: away}
+	for _, test := range []struct {
+		name  string
+		mode  string
+		match Match
+		game  int
+		team  sql.NullInt64
+	}{
+		{"home pick", SelectionModeHomePick, match, 2, home},
+		{"alternating first game", SelectionModeAlternating, match, 1, home},
+		{"alternating second game", SelectionModeAlternating, match, 2, away},
+		{"alternating third game", SelectionModeAlternating, match, 3, home},
+		{"alternating fourth game", SelectionModeAlternating, match, 4, away},
+		{"alternating without an away team", SelectionModeAlternating, Match{Team1Id: 1}, 2, home},
+		{"random", SelectionModeRandom, match, 1, sql.NullInt64{}},
+	} {
+		if team := GetPickingTeam(test.mode, test.match, test.game); team != test.team {
+			t.Errorf("%s: expected game %d to be picked by %+v, got %+v", test.name, test.game, test.team, team)
+		}
+	}
+}
+
+func TestDrawMachine(t *testing.T) {
+	pool := []string{"G4ODR-MDXEy", "G4do5-MDlN7", "G5pe4-MePZv"}
+	for _, seed := range []int64{0, 42, 1 << 40} {
+		for game := 1; game <= 5; game++ {
+			opdbId := DrawMachine(seed, game, pool)
+			if !isInPool(opdbId, pool) {
+				t.Errorf("expected seed %d to draw from the pool for game %d, got %q", seed, game, opdbId)
+			}
+			if again := DrawMachine(seed, game, append([]string{}, pool...)); again != opdbId {
+				t.Errorf("expected seed %d to draw %q again for game %d, got %q", seed, opdbId, game, again)
+			}
+		}
+	}
+	if opdbId := DrawMachine(42, 1, nil); len(opdbId) != 0 {
+		t.Errorf("expected nothing drawn from an empty pool, got %q", opdbId)
+	}
+}
+
+func TestSelectMachine(t *testing.T) {
+	initializeTestDatabase(t, selectionFixture...)
+
+	for _, test := range []struct {
+		name       string
+		mode       string
+		noRepeat   bool
+		usageLimit int64
+		games      int
+		matchId    int
+		userId     int
+		opdbId     string
+		err        error
+	}{
+		{"player outside the match", SelectionModeHomePick, true, 0, 5, 1, 6, "G5pe4-MePZv", ErrSelectionNotPlayer},
+		{"away player in home pick", SelectionModeHomePick, true, 0, 5, 1, 3, "G5pe4-MePZv", ErrSelectionWrongSide},
+		{"away player on an odd game", SelectionModeAlternating, true, 0, 5, 1, 3, "G5pe4-MePZv", ErrSelectionWrongSide},
+		{"home player on an even game", SelectionModeAlternating, true, 0, 5, 2, 1, "G5pe4-MePZv", ErrSelectionWrongSide},
+		{"machine played in the match", SelectionModeHomePick, true, 0, 5, 2, 1, "G4ODR-MDXEy", ErrSelectionRepeated},
+		{"machine at its usage limit", SelectionModeHomePick, true, 1, 5, 1, 1, "G4ODR-MDXEy", ErrSelectionUsageLimit},
+		{"machine out of order", SelectionModeHomePick, true, 0, 5, 1, 1, "G5po2-MeP6B", ErrSelectionUnavailable},
+		{"missing pick", SelectionModeHomePick, true, 0, 5, 1, 1, "", ErrSelectionPickRequired},
+		{"pick in a random draw", SelectionModeRandom, true, 0, 5, 1, 1, "G5pe4-MePZv", ErrSelectionRandom},
+		{"every game selected", SelectionModeHomePick, true, 0, 1, 2, 5, "G5pe4-MePZv", ErrSelectionComplete},
+		{"staff for the away team", SelectionModeHomePick, true, 0, 5, 1, 5, "G5pe4-MePZv", nil},
+		{"machine played in the match without no-repeat", SelectionModeAlternating, false, 0, 5, 2, 3, "G4ODR-MDXEy", nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			usageLimit := sql.NullInt64{Int64: test.usageLimit, Valid: test.usageLimit > 0}
+			if !UpdateLeagueSelection(1, test.mode, test.noRepeat, usageLimit, test.games) {
+				t.Fatal("unable to update the league selection")
+			}
+			selection, err := SelectMachine(*GetMatch(test.matchId), *GetUser(test.userId), test.opdbId)
+			if err != test.err {
+				t.Fatalf("expected %v, got %v", test.err, err)
+			}
+			if err == nil && (selection.OpdbId != test.opdbId || selection.PickedByUserId != test.userId) {
+				t.Errorf("expected %s selected by user %d, got %+v", test.opdbId, test.userId, selection)
+			}
+		})
+	}
+}
+
+func TestGetAvailableMachinesUsageLimit(t *testing.T) {
+	initializeTestDatabase(t, selectionFixture...)
+
+	match := *GetMatch(1)
+	for _, test := range []struct {
+		name       string
+		usageLimit sql.NullInt64
+		available  []string
+	}{
+		{"without a usage limit", sql.NullInt64{}, []string{"G4ODR-MDXEy", "G4do5-MDlN7", "G5pe4-MePZv"}},
+		{"machine used once this season", sql.NullInt64{Int64: 1, Valid: true}, []string{"G4do5-MDlN7", "G5pe4-MePZv"}},
+		{"limit not reached", sql.NullInt64{Int64: 2, Valid: true}, []string{"G4ODR-MDXEy", "G4do5-MDlN7", "G5pe4-MePZv"}},
+	} {
+		league := League{Id: 1, NoRepeat: true, UsageLimit: test.usageLimit}
+		if available := GetAvailableMachines(league, match, nil); !reflect.DeepEqual(available, test.available) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.available, available)
+		}
+	}
+}
+
+func TestSelectMachineRandomDraw(t *testing.T) {
+	initializeTestDatabase(t, selectionFixture...)
+
+	if !UpdateLeagueSelection(1, SelectionModeRandom, true, sql.NullInt64{}, 5) {
+		t.Fatal("unable to update the league selection")
+	}
+	for game := 2; game <= 3; game++ {
+		selection, err := SelectMachine(*GetMatch(2), *GetUser(3), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		match := GetMatch(2)
+		if selection.Game != game || !match.SelectionSeed.Valid || !selection.DrawPool.Valid {
+			t.Fatalf("expected game %d drawn with a seed and pool, got %+v", game, selection)
+		}
+
+		// The draw is audited by drawing again from the seed and pool
+		pool := strings.Split(selection.DrawPool.String, ",")
+		if isInPool("G4ODR-MDXEy", pool) {
+			t.Errorf("expected the machine played in the match left out of the pool, got %v", pool)
+		}
+		if opdbId := DrawMachine(match.SelectionSeed.Int64, game, pool); opdbId != selection.OpdbId {
+			t.Errorf("expected game %d to draw %q again, got %q", game, selection.OpdbId, opdbId)
+		}
+	}
+}
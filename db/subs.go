@@ -0,0 +1,293 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mikefero/tpl/log"
+)
+
+var ErrSubNotInPool = errors.New("player is not on the team or in the sub pool of the league")
+var ErrSubOtherTeam = errors.New("player already played for another team this week")
+var ErrSubLimit = errors.New("team reached its sub limit for the season")
+
+// LeagueSub is a player of the sub pool of a league
+type LeagueSub struct {
+	LeagueId int
+	UserId   int
+	Name     string
+	AddedAt  int64
+}
+
+// TeamSub is a sub who played for a team in a match
+type TeamSub struct {
+	MatchId int
+	UserId  int
+}
+
+var stmtUpdateLeagueSubLimit *sql.Stmt
+var stmtSelectLeagueSubs *sql.Stmt
+var stmtSelectLeagueSub *sql.Stmt
+var stmtInsertLeagueSub *sql.Stmt
+var stmtDeleteLeagueSub *sql.Stmt
+var stmtSelectTeamSubs *sql.Stmt
+var stmtCountPlayerOtherTeamGames *sql.Stmt
+
+// UpdateLeagueSubLimit caps how many times each team of a league can field a
+// sub in a season; a sub playing several games of a match counts once.
+func UpdateLeagueSubLimit(id int, subLimit sql.NullInt64) bool {
+	result, err := stmtUpdateLeagueSubLimit.Exec(subLimit, id)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlUpdateLeagueSubLimit,
+			"id":        id,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return false
+	}
+	updated, _ := result.RowsAffected()
+
+	return updated > 0
+}
+
+func GetLeagueSubs(leagueId int) []LeagueSub {
+	rows, err := stmtSelectLeagueSubs.Query(leagueId)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectLeagueSubs,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var subs []LeagueSub
+	for rows.Next() {
+		var sub LeagueSub
+		if err := rows.Scan(&sub.LeagueId,
+			&sub.UserId,
+			&sub.Name,
+			&sub.AddedAt); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectLeagueSubs,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for league sub")
+		} else {
+			subs = append(subs, sub)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectLeagueSubs,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for league sub")
+	}
+
+	return subs
+}
+
+func IsLeagueSub(leagueId int, userId int) bool {
+	var count int
+	err := stmtSelectLeagueSub.QueryRow(leagueId, userId).Scan(&count)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectLeagueSub,
+			"league_id": leagueId,
+			"user_id":   userId,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+	}
+
+	return count > 0
+}
+
+func AddLeagueSub(leagueId int, userId int) bool {
+	if _, err := stmtInsertLeagueSub.Exec(leagueId, userId, time.Now().Unix()); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlInsertLeagueSub,
+			"league_id": leagueId,
+			"user_id":   userId,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return false
+	}
+	return true
+}
+
+func RemoveLeagueSub(leagueId int, userId int) bool {
+	result, err := stmtDeleteLeagueSub.Exec(leagueId, userId)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlDeleteLeagueSub,
+			"league_id": leagueId,
+			"user_id":   userId,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return false
+	}
+	deleted, _ := result.RowsAffected()
+
+	return deleted > 0
+}
+
+// GetTeamSubs returns the subs a team fielded in a season, once per match.
+func GetTeamSubs(seasonId int, teamId int) []TeamSub {
+	rows, err := stmtSelectTeamSubs.Query(seasonId, teamId)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectTeamSubs,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var subs []TeamSub
+	for rows.Next() {
+		var sub TeamSub
+		if err := rows.Scan(&sub.MatchId, &sub.UserId); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectTeamSubs,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for team sub")
+		} else {
+			subs = append(subs, sub)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectTeamSubs,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for team sub")
+	}
+
+	return subs
+}
+
+// playsForOtherTeam tells whether a player played for a team other than the
+// given one in a match or in any other match of its week.
+func playsForOtherTeam(match Match, teamId int, userId int) bool {
+	var count int
+	err := stmtCountPlayerOtherTeamGames.QueryRow(match.SeasonId,
+		match.Id,
+		match.Week,
+		teamId,
+		userId).Scan(&count)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlCountPlayerOtherTeamGames,
+			"match_id":  match.Id,
+			"user_id":   userId,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+	}
+
+	return count > 0
+}
+
+// FlagSubs checks that every player of a result plays for their side of the
+// match and flags the players who are not on their team as subs. Subs must be
+// in the sub pool of the league and count against the sub limit of their team
+// once per match; no player can play for two teams in the same week.
+func FlagSubs(match Match, result *Result) error {
+	league := GetLeague(match.LeagueId)
+	if league == nil {
+		return ErrSubNotInPool
+	}
+	sides := []struct {
+		teamId  sql.NullInt64
+		players [2]sql.NullInt64
+		subs    [2]*bool
+	}{
+		{
+			teamId:  sql.NullInt64{Int64: int64(match.Team1Id), Valid: true},
+			players: [2]sql.NullInt64{result.Team1APlayerId, result.Team1BPlayerId},
+			subs:    [2]*bool{&result.Team1APlayerSub, &result.Team1BPlayerSub},
+		},
+		{
+			teamId:  match.Team2Id,
+			players: [2]sql.NullInt64{result.Team2APlayerId, result.Team2BPlayerId},
+			subs:    [2]*bool{&result.Team2APlayerSub, &result.Team2BPlayerSub},
+		},
+	}
+
+	sideOf := map[int64]int64{}
+	for _, side := range sides {
+		var team *Team
+		if side.teamId.Valid {
+			team = GetTeam(int(side.teamId.Int64))
+		}
+		var subs []TeamSub
+		for i, player := range side.players {
+			*side.subs[i] = false
+			if !player.Valid {
+				continue
+			}
+			userId := int(player.Int64)
+			if other, exists := sideOf[player.Int64]; exists && other != side.teamId.Int64 {
+				return ErrSubOtherTeam
+			}
+			sideOf[player.Int64] = side.teamId.Int64
+			if team == nil || (!isTeamPlayer(team, userId) && !IsLeagueSub(league.Id, userId)) {
+				return ErrSubNotInPool
+			}
+			if playsForOtherTeam(match, team.Id, userId) {
+				return ErrSubOtherTeam
+			}
+			if isTeamPlayer(team, userId) {
+				continue
+			}
+			*side.subs[i] = true
+
+			if !league.SubLimit.Valid {
+				continue
+			}
+			if subs == nil {
+				subs = GetTeamSubs(match.SeasonId, team.Id)
+			}
+			fielded := false
+			for _, sub := range subs {
+				fielded = fielded || (sub.MatchId == match.Id && sub.UserId == userId)
+			}
+			if !fielded {
+				if int64(len(subs)) >= league.SubLimit.Int64 {
+					return ErrSubLimit
+				}
+				subs = append(subs, TeamSub{
+					MatchId: match.Id,
+					UserId:  userId,
+				})
+			}
+		}
+	}
+	return nil
+}
+
+func closePreparedSubsStatements() {
+	log.Debug("closing prepared subs statements")
+	stmtUpdateLeagueSubLimit.Close()
+	stmtSelectLeagueSubs.Close()
+	stmtSelectLeagueSub.Close()
+	stmtInsertLeagueSub.Close()
+	stmtDeleteLeagueSub.Close()
+	stmtSelectTeamSubs.Close()
+	stmtCountPlayerOtherTeamGames.Close()
+	log.Debug("prepared subs statements closed")
+}
+
+func prepareSubsStatements() {
+	log.Debug("preparing subs statements")
+	stmtUpdateLeagueSubLimit = prepare(sqlUpdateLeagueSubLimit)
+	stmtSelectLeagueSubs = prepare(sqlSelectLeagueSubs)
+	stmtSelectLeagueSub = prepare(sqlSelectLeagueSub)
+	stmtInsertLeagueSub = prepare(sqlInsertLeagueSub)
+	stmtDeleteLeagueSub = prepare(sqlDeleteLeagueSub)
+	stmtSelectTeamSubs = prepare(sqlSelectTeamSubs)
+	stmtCountPlayerOtherTeamGames = prepare(sqlCountPlayerOtherTeamGames)
+	log.Debug("subs statements prepared")
+}
@@ -0,0 +1,108 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+)
+
+// subsFixture is a league allowing one sub per team and season. Ann and Bob
+// play for Flippers, Cat and Dan for Tilt and Gus and Hal for Bumpers; Eve
+// and Ivy are in the sub pool while Fay is not. In week 1 Eve subbed for
+// Bumpers and in week 2 Ivy subbed for Tilt.
+var subsFixture = []string{
+	`INSERT INTO leagues (id, name, active, sub_limit) VALUES (1, 'Monday', true, 1)`,
+	`INSERT INTO seasons (id, league_id, name, start_date) VALUES (1, 1, 'Spring', 0)`,
+	`INSERT INTO users (id, league_id, email, password, name, active) VALUES
+  (1, 1, 'ann@example.com', '', 'Ann', true),
+  (2, 1, 'bob@example.com', '', 'Bob', true),
+  (3, 1, 'cat@example.com', '', 'Cat', true),
+  (4, 1, 'dan@example.com', '', 'Dan', true),
+  (5, 1, 'eve@example.com', '', 'Eve', true),
+  (6, 1, 'fay@example.com', '', 'Fay', true),
+  (7, 1, 'gus@example.com', '', 'Gus', true),
+  (8, 1, 'hal@example.com', '', 'Hal', true),
+  (9, 1, 'ivy@example.com', '', 'Ivy', true)`,
+	`INSERT INTO teams (id, league_id, name, a_player, b_player, active) VALUES
+  (1, 1, 'Flippers', 1, 2, true),
+  (2, 1, 'Tilt', 3, 4, true),
+  (3, 1, 'Bumpers', 7, 8, true)`,
+	`INSERT INTO league_subs (league_id, user_id, added_at) VALUES (1, 5, 0), (1, 9, 0)`,
+	`INSERT INTO matches (id, league_id, season_id, team_1_id, team_2_id, week) VALUES
+  (1, 1, 1, 1, 2, 1),
+  (2, 1, 1, 3, NULL, 1),
+  (3, 1, 1, 2, 1, 2)`,
+	`INSERT INTO results (match_id, opdb_id, team_1_a_player_id, team_1_b_player_id, team_1_a_player_sub) VALUES
+  (2, 'G4ODR-MDXEy', 5, 8, true)`,
+	`INSERT INTO results (match_id, opdb_id, team_1_a_player_id, team_1_b_player_id, team_2_a_player_id, team_2_b_player_id, team_1_a_player_sub) VALUES
+  (3, 'G4ODR-MDXEy', 9, 4, 1, 2, true)`,
+}
+
+func newSubsResult(team1 [2]int64, team2 [2]int64) Result {
+	player := func(id int64) sql.NullInt64 {
+		return sql.NullInt64{Int64: id, Valid: id > 0}
+	}
+	return Result{
+		OpdbId:         "G5pe4-MePZv",
+		Team1APlayerId: player(team1[0]),
+		Team1BPlayerId: player(team1[1]),
+		Team2APlayerId: player(team2[0]),
+		Team2BPlayerId: player(team2[1]),
+	}
+}
+
+func TestFlagSubs(t *testing.T) {
+	initializeTestDatabase(t, subsFixture...)
+
+	for _, test := range []struct {
+		name    string
+		matchId int
+		team1   [2]int64
+		team2   [2]int64
+		err     error
+		subs    [4]bool
+	}{
+		{"regular players", 1, [2]int64{1, 2}, [2]int64{3, 4}, nil, [4]bool{}},
+		{"missing player", 1, [2]int64{1, 0}, [2]int64{3, 4}, nil, [4]bool{}},
+		{"sub from the pool", 1, [2]int64{1, 9}, [2]int64{3, 4}, nil, [4]bool{false, true, false, false}},
+		{"player of the opponent team", 1, [2]int64{1, 3}, [2]int64{3, 4}, ErrSubNotInPool, [4]bool{}},
+		{"sub for both teams", 1, [2]int64{1, 9}, [2]int64{3, 9}, ErrSubOtherTeam, [4]bool{}},
+		{"player outside the pool", 1, [2]int64{1, 6}, [2]int64{3, 4}, ErrSubNotInPool, [4]bool{}},
+		{"sub for another team this week", 1, [2]int64{1, 5}, [2]int64{3, 4}, ErrSubOtherTeam, [4]bool{}},
+		{"sub beyond the limit", 1, [2]int64{1, 2}, [2]int64{3, 9}, ErrSubLimit, [4]bool{}},
+		{"sub again in the same match", 3, [2]int64{9, 4}, [2]int64{1, 2}, nil, [4]bool{true, false, false, false}},
+		{"second sub in the same match", 3, [2]int64{9, 5}, [2]int64{1, 2}, ErrSubLimit, [4]bool{}},
+		{"player without an opponent team", 2, [2]int64{7, 8}, [2]int64{1, 0}, ErrSubNotInPool, [4]bool{}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			match := GetMatch(test.matchId)
+			if match == nil {
+				t.Fatalf("expected match %d", test.matchId)
+			}
+			result := newSubsResult(test.team1, test.team2)
+			if err := FlagSubs(*match, &result); err != test.err {
+				t.Fatalf("expected %v, got %v", test.err, err)
+			}
+			if test.err != nil {
+				return
+			}
+			subs := [4]bool{result.Team1APlayerSub, result.Team1BPlayerSub, result.Team2APlayerSub, result.Team2BPlayerSub}
+			if subs != test.subs {
+				t.Errorf("expected subs %v, got %v", test.subs, subs)
+			}
+		})
+	}
+}
+
+func TestFlagSubsWithoutLimit(t *testing.T) {
+	initializeTestDatabase(t, append(subsFixture,
+		`UPDATE leagues SET sub_limit = NULL`)...)
+
+	match := GetMatch(1)
+	result := newSubsResult([2]int64{1, 2}, [2]int64{3, 9})
+	if err := FlagSubs(*match, &result); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !result.Team2BPlayerSub {
+		t.Error("expected the sub to be flagged")
+	}
+}
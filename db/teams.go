@@ -0,0 +1,123 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/mikefero/tpl/log"
+)
+
+type Team struct {
+	Id       int
+	LeagueId int
+	Name     string
+	APlayer  int
+	BPlayer  int
+	Active   bool
+}
+
+var stmtSelectTeams *sql.Stmt
+var stmtCountTeams *sql.Stmt
+var stmtSelectTeam *sql.Stmt
+var stmtSelectPlayerTeams *sql.Stmt
+
+func queryTeams(stmt *sql.Stmt, statement string, args ...interface{}) []Team {
+	rows, err := stmt.Query(args...)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": statement,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var teams []Team
+	for rows.Next() {
+		var team Team
+		if err := rows.Scan(&team.Id,
+			&team.LeagueId,
+			&team.Name,
+			&team.APlayer,
+			&team.BPlayer,
+			&team.Active); err != nil {
+			log.WithFields(log.Fields{
+				"statement": statement,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for team")
+		} else {
+			teams = append(teams, team)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": statement,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for team")
+	}
+
+	return teams
+}
+
+func GetTeams(leagueId int, limit int, offset int) []Team {
+	return queryTeams(stmtSelectTeams, sqlSelectTeams, leagueId, limit, offset)
+}
+
+// GetPlayerTeams returns the teams a user played on with active teams first.
+func GetPlayerTeams(userId int) []Team {
+	return queryTeams(stmtSelectPlayerTeams, sqlSelectPlayerTeams, userId)
+}
+
+func CountTeams(leagueId int) int {
+	var count int
+	err := stmtCountTeams.QueryRow(leagueId).Scan(&count)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlCountTeams,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+	}
+
+	return count
+}
+
+func GetTeam(id int) *Team {
+	var team Team
+	err := stmtSelectTeam.QueryRow(id).Scan(&team.Id,
+		&team.LeagueId,
+		&team.Name,
+		&team.APlayer,
+		&team.BPlayer,
+		&team.Active)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectTeam,
+				"id":        id,
+				"error":     err,
+			}).Error("unable to execute prepared SQL statement")
+		}
+		return nil
+	}
+
+	return &team
+}
+
+func closePreparedTeamsStatements() {
+	log.Debug("closing prepared teams statements")
+	stmtSelectTeams.Close()
+	stmtCountTeams.Close()
+	stmtSelectTeam.Close()
+	stmtSelectPlayerTeams.Close()
+	log.Debug("prepared teams statements closed")
+}
+
+func prepareTeamsStatements() {
+	log.Debug("preparing teams statements")
+	stmtSelectTeams = prepare(sqlSelectTeams)
+	stmtCountTeams = prepare(sqlCountTeams)
+	stmtSelectTeam = prepare(sqlSelectTeam)
+	stmtSelectPlayerTeams = prepare(sqlSelectPlayerTeams)
+	log.Debug("teams statements prepared")
+}
@@ -0,0 +1,244 @@
+package db
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/mikefero/tpl/log"
+)
+
+const ScopeReadMachines = "read:machines"
+const ScopeReadLeagues = "read:leagues"
+const ScopeWriteResults = "write:results"
+const ScopeWriteLineup = "write:lineup"
+
+var Scopes = []string{
+	ScopeReadMachines,
+	ScopeReadLeagues,
+	ScopeWriteResults,
+	ScopeWriteLineup,
+}
+
+// scopeRoles are the roles a user must have to use a scope; scopes that are
+// not listed are available to every user
+var scopeRoles = map[string]string{
+	ScopeWriteLineup: RoleAdmin,
+}
+
+const apiTokenPrefix = "tpl_"
+
+type ApiToken struct {
+	Id         int
+	UserId     int
+	UserRole   string
+	Name       string
+	Scopes     []string
+	CreatedAt  int64
+	LastUsedAt sql.NullInt64
+	RevokedAt  sql.NullInt64
+}
+
+var stmtInsertApiToken *sql.Stmt
+var stmtSelectApiTokens *sql.Stmt
+var stmtSelectApiTokenByHash *sql.Stmt
+var stmtUpdateApiTokenLastUsed *sql.Stmt
+var stmtRevokeApiToken *sql.Stmt
+
+// HasScope determines if a token was granted a scope that its user is still
+// allowed to use.
+func (token ApiToken) HasScope(scope string) bool {
+	for _, tokenScope := range token.Scopes {
+		if tokenScope == scope {
+			return CanUseScope(token.UserRole, scope)
+		}
+	}
+	return false
+}
+
+func CanUseScope(role string, scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			required, exists := scopeRoles[scope]
+			return !exists || HasRole(role, required)
+		}
+	}
+	return false
+}
+
+func GetScopes(role string) []string {
+	var scopes []string
+	for _, scope := range Scopes {
+		if CanUseScope(role, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+func hashApiToken(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+// CreateApiToken generates a personal access token for a user; only the hash
+// of the token is stored so the returned secret cannot be recovered later.
+func CreateApiToken(userId int, name string, scopes []string) (string, *ApiToken) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("unable to generate API token")
+		return "", nil
+	}
+	secret := apiTokenPrefix + base64.RawURLEncoding.EncodeToString(random)
+
+	token := ApiToken{
+		UserId:    userId,
+		Name:      name,
+		Scopes:    scopes,
+		CreatedAt: time.Now().Unix(),
+	}
+	result, err := stmtInsertApiToken.Exec(userId,
+		name,
+		hashApiToken(secret),
+		strings.Join(scopes, ","),
+		token.CreatedAt)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlInsertApiToken,
+			"user_id":   userId,
+			"name":      name,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return "", nil
+	}
+	id, _ := result.LastInsertId()
+	token.Id = int(id)
+
+	return secret, &token
+}
+
+func scanApiToken(scanner interface{ Scan(...interface{}) error }) (ApiToken, error) {
+	var token ApiToken
+	var scopes string
+	err := scanner.Scan(&token.Id,
+		&token.UserId,
+		&token.UserRole,
+		&token.Name,
+		&scopes,
+		&token.CreatedAt,
+		&token.LastUsedAt,
+		&token.RevokedAt)
+	if len(scopes) > 0 {
+		token.Scopes = strings.Split(scopes, ",")
+	}
+	return token, err
+}
+
+func GetApiTokens(userId int) []ApiToken {
+	rows, err := stmtSelectApiTokens.Query(userId)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectApiTokens,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var tokens []ApiToken
+	for rows.Next() {
+		if token, err := scanApiToken(rows); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectApiTokens,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for API token")
+		} else {
+			tokens = append(tokens, token)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectApiTokens,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for API token")
+	}
+
+	return tokens
+}
+
+// AuthenticateApiToken resolves the secret of an active token and records
+// its use.
+func AuthenticateApiToken(secret string) *ApiToken {
+	if !strings.HasPrefix(secret, apiTokenPrefix) {
+		return nil
+	}
+	token, err := scanApiToken(stmtSelectApiTokenByHash.QueryRow(hashApiToken(secret)))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectApiTokenByHash,
+				"error":     err,
+			}).Error("unable to execute prepared SQL statement")
+		}
+		return nil
+	}
+
+	now := time.Now().Unix()
+	if _, err := stmtUpdateApiTokenLastUsed.Exec(now, token.Id); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlUpdateApiTokenLastUsed,
+			"id":        token.Id,
+			"error":     err,
+		}).Warn("unable to record API token use")
+	}
+	token.LastUsedAt = sql.NullInt64{
+		Int64: now,
+		Valid: true,
+	}
+
+	return &token
+}
+
+func RevokeApiToken(userId int, id int) bool {
+	result, err := stmtRevokeApiToken.Exec(time.Now().Unix(), id, userId)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlRevokeApiToken,
+			"id":        id,
+			"user_id":   userId,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return false
+	}
+	revoked, _ := result.RowsAffected()
+
+	return revoked > 0
+}
+
+func closePreparedApiTokensStatements() {
+	log.Debug("closing prepared API tokens statements")
+	stmtInsertApiToken.Close()
+	stmtSelectApiTokens.Close()
+	stmtSelectApiTokenByHash.Close()
+	stmtUpdateApiTokenLastUsed.Close()
+	stmtRevokeApiToken.Close()
+	log.Debug("prepared API tokens statements closed")
+}
+
+func prepareApiTokensStatements() {
+	log.Debug("preparing API tokens statements")
+	stmtInsertApiToken = prepare(sqlInsertApiToken)
+	stmtSelectApiTokens = prepare(sqlSelectApiTokens)
+	stmtSelectApiTokenByHash = prepare(sqlSelectApiTokenByHash)
+	stmtUpdateApiTokenLastUsed = prepare(sqlUpdateApiTokenLastUsed)
+	stmtRevokeApiToken = prepare(sqlRevokeApiToken)
+	log.Debug("API tokens statements prepared")
+}
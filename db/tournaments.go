@@ -0,0 +1,1015 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/mikefero/tpl/log"
+)
+
+const TournamentFormatStrikes = "strikes"
+const TournamentFormatSwiss = "swiss"
+const TournamentFormatBracket = "bracket"
+const TournamentFormatQualifying = "qualifying"
+const TournamentFormatMatchPlay = "match_play"
+
+var TournamentFormats = []string{
+	TournamentFormatStrikes,
+	TournamentFormatSwiss,
+	TournamentFormatBracket,
+	TournamentFormatQualifying,
+	TournamentFormatMatchPlay,
+}
+
+const TournamentStateCheckIn = "check_in"
+const TournamentStateRunning = "running"
+const TournamentStateCompleted = "completed"
+
+// Tournament is a strikes, Swiss, bracket, qualifying or match play
+// tournament. Qualifying tournaments sell a number of entries per machine,
+// count the best machines of every player and advance the top qualifiers to a
+// bracket once closed. Match play tournaments award placement points by their
+// scoring.
+type Tournament struct {
+	Id              int
+	Name            string
+	Format          string
+	State           string
+	GroupSize       int
+	StrikeLimit     int
+	Rounds          sql.NullInt64
+	Scoring         sql.NullString
+	Entries         sql.NullInt64
+	CountedMachines sql.NullInt64
+	Qualifiers      sql.NullInt64
+	BracketId       sql.NullInt64
+	Seed            int64
+	CreatedBy       int
+	CreatedAt       int64
+}
+
+// TournamentPlayer is a checked in player; seeded players of a bracket are
+// placed by their seed.
+type TournamentPlayer struct {
+	TournamentId int
+	UserId       int
+	Name         string
+	Seed         sql.NullInt64
+	CheckedInAt  int64
+}
+
+// TournamentGamePlayer is a player of a tournament game; the position is the
+// order of play and the place is their finish once the game is recorded.
+type TournamentGamePlayer struct {
+	GameId   int
+	UserId   int
+	Name     string
+	Position int
+	Place    sql.NullInt64
+}
+
+type TournamentGame struct {
+	Id           int
+	TournamentId int
+	Round        int
+	OpdbId       string
+	MachineName  string
+	DrawPool     sql.NullString
+	PickedBy     sql.NullInt64
+	CompletedAt  sql.NullInt64
+	Players      []TournamentGamePlayer
+}
+
+// TournamentTicket is an entry a player bought on a machine of a qualifying
+// tournament; it is used once a score is submitted for it.
+type TournamentTicket struct {
+	Id           int
+	TournamentId int
+	UserId       int
+	Name         string
+	OpdbId       string
+	MachineName  string
+	SoldBy       int
+	SoldAt       int64
+	Used         bool
+}
+
+// TournamentScore is the score of a qualifying entry; unverified scores wait
+// for staff in the submission queue.
+type TournamentScore struct {
+	Id           int
+	TicketId     int
+	TournamentId int
+	UserId       int
+	Name         string
+	OpdbId       string
+	MachineName  string
+	Score        int64
+	SubmittedAt  int64
+	VerifiedAt   sql.NullInt64
+}
+
+var stmtSelectTournaments *sql.Stmt
+var stmtCountTournaments *sql.Stmt
+var stmtSelectTournament *sql.Stmt
+var stmtInsertTournament *sql.Stmt
+var stmtUpdateTournamentState *sql.Stmt
+var stmtSelectTournamentPlayers *sql.Stmt
+var stmtInsertTournamentPlayer *sql.Stmt
+var stmtDeleteTournamentPlayer *sql.Stmt
+var stmtSelectTournamentGames *sql.Stmt
+var stmtSelectTournamentGamePlayers *sql.Stmt
+var stmtPickTournamentGameMachine *sql.Stmt
+var stmtSelectTournamentTickets *sql.Stmt
+var stmtSelectTournamentScores *sql.Stmt
+var stmtInsertTournamentScore *sql.Stmt
+var stmtVerifyTournamentScore *sql.Stmt
+var stmtDeleteUnverifiedTournamentScore *sql.Stmt
+
+func IsTournamentFormat(format string) bool {
+	for _, f := range TournamentFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+func scanTournament(scanner interface{ Scan(...interface{}) error }) (Tournament, error) {
+	var tournament Tournament
+	err := scanner.Scan(&tournament.Id,
+		&tournament.Name,
+		&tournament.Format,
+		&tournament.State,
+		&tournament.GroupSize,
+		&tournament.StrikeLimit,
+		&tournament.Rounds,
+		&tournament.Scoring,
+		&tournament.Entries,
+		&tournament.CountedMachines,
+		&tournament.Qualifiers,
+		&tournament.BracketId,
+		&tournament.Seed,
+		&tournament.CreatedBy,
+		&tournament.CreatedAt)
+	return tournament, err
+}
+
+// GetTournaments returns the tournaments with the most recent unfinished
+// tournaments first.
+func GetTournaments(limit int, offset int) []Tournament {
+	rows, err := stmtSelectTournaments.Query(limit, offset)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectTournaments,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var tournaments []Tournament
+	for rows.Next() {
+		if tournament, err := scanTournament(rows); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectTournaments,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for tournament")
+		} else {
+			tournaments = append(tournaments, tournament)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectTournaments,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for tournament")
+	}
+
+	return tournaments
+}
+
+func CountTournaments() int {
+	var count int
+	err := stmtCountTournaments.QueryRow().Scan(&count)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlCountTournaments,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+	}
+
+	return count
+}
+
+func GetTournament(id int) *Tournament {
+	tournament, err := scanTournament(stmtSelectTournament.QueryRow(id))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectTournament,
+				"id":        id,
+				"error":     err,
+			}).Error("unable to execute prepared SQL statement")
+		}
+		return nil
+	}
+
+	return &tournament
+}
+
+// CreateTournament opens a tournament for check-in; its seed makes every
+// pairing and machine draw of the tournament reproducible. Strikes and bracket
+// tournaments run until a single player is left and Swiss tournaments for a
+// number of rounds.
+func CreateTournament(tournament Tournament) *Tournament {
+	seed, err := newSeed()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("unable to generate tournament seed")
+		return nil
+	}
+	result, err := stmtInsertTournament.Exec(tournament.Name,
+		tournament.Format,
+		TournamentStateCheckIn,
+		tournament.GroupSize,
+		tournament.StrikeLimit,
+		tournament.Rounds,
+		tournament.Scoring,
+		tournament.Entries,
+		tournament.CountedMachines,
+		tournament.Qualifiers,
+		seed,
+		tournament.CreatedBy,
+		time.Now().Unix())
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlInsertTournament,
+			"name":      tournament.Name,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	id, _ := result.LastInsertId()
+
+	return GetTournament(int(id))
+}
+
+// CreateBracket closes a qualifying tournament and checks the qualifiers into
+// the bracket it advances to, seeded in the order given.
+func CreateBracket(qualifyingId int, bracket Tournament, userIds []int) *Tournament {
+	seed, err := newSeed()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("unable to generate tournament seed")
+		return nil
+	}
+	tx, err := session.Begin()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("unable to begin transaction for creating bracket")
+		return nil
+	}
+
+	now := time.Now().Unix()
+	result, err := tx.Exec(sqlInsertTournament, bracket.Name,
+		bracket.Format,
+		TournamentStateCheckIn,
+		bracket.GroupSize,
+		bracket.StrikeLimit,
+		bracket.Rounds,
+		bracket.Scoring,
+		bracket.Entries,
+		bracket.CountedMachines,
+		bracket.Qualifiers,
+		seed,
+		bracket.CreatedBy,
+		now)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlInsertTournament,
+			"name":      bracket.Name,
+			"error":     err,
+		}).Error("unable to transactionally insert bracket")
+		tx.Rollback()
+		return nil
+	}
+	id, _ := result.LastInsertId()
+	for i, userId := range userIds {
+		if _, err := tx.Exec(sqlInsertTournamentPlayer, id, userId, i+1, now); err != nil {
+			log.WithFields(log.Fields{
+				"statement":     sqlInsertTournamentPlayer,
+				"tournament_id": id,
+				"user_id":       userId,
+				"error":         err,
+			}).Error("unable to transactionally insert bracket player")
+			tx.Rollback()
+			return nil
+		}
+	}
+	result, err = tx.Exec(sqlCloseQualifying, id, qualifyingId)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlCloseQualifying,
+			"id":        qualifyingId,
+			"error":     err,
+		}).Error("unable to transactionally close qualifying")
+		tx.Rollback()
+		return nil
+	}
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		tx.Rollback()
+		return nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("unable to commit transaction for creating bracket")
+		return nil
+	}
+	return GetTournament(int(id))
+}
+
+// ImportTournament records a tournament run elsewhere in its state with its
+// players checked in and its games with their places; games with a completion
+// time are recorded.
+func ImportTournament(tournament Tournament, userIds []int, games []TournamentGame) *Tournament {
+	seed, err := newSeed()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("unable to generate tournament seed")
+		return nil
+	}
+	tx, err := session.Begin()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("unable to begin transaction for importing tournament")
+		return nil
+	}
+
+	now := time.Now().Unix()
+	result, err := tx.Exec(sqlInsertTournament, tournament.Name,
+		tournament.Format,
+		tournament.State,
+		tournament.GroupSize,
+		tournament.StrikeLimit,
+		tournament.Rounds,
+		tournament.Scoring,
+		tournament.Entries,
+		tournament.CountedMachines,
+		tournament.Qualifiers,
+		seed,
+		tournament.CreatedBy,
+		now)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlInsertTournament,
+			"name":      tournament.Name,
+			"error":     err,
+		}).Error("unable to transactionally insert imported tournament")
+		tx.Rollback()
+		return nil
+	}
+	id, _ := result.LastInsertId()
+	for _, userId := range userIds {
+		if _, err := tx.Exec(sqlInsertTournamentPlayer, id, userId, nil, now); err != nil {
+			log.WithFields(log.Fields{
+				"statement":     sqlInsertTournamentPlayer,
+				"tournament_id": id,
+				"user_id":       userId,
+				"error":         err,
+			}).Error("unable to transactionally insert imported tournament player")
+			tx.Rollback()
+			return nil
+		}
+	}
+	for _, game := range games {
+		result, err := tx.Exec(sqlInsertTournamentGame, id, game.Round, game.OpdbId, game.DrawPool)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"statement":     sqlInsertTournamentGame,
+				"tournament_id": id,
+				"round":         game.Round,
+				"error":         err,
+			}).Error("unable to transactionally insert imported tournament game")
+			tx.Rollback()
+			return nil
+		}
+		gameId, _ := result.LastInsertId()
+		for _, player := range game.Players {
+			if _, err := tx.Exec(sqlInsertTournamentGamePlayer, gameId, player.UserId, player.Position); err != nil {
+				log.WithFields(log.Fields{
+					"statement": sqlInsertTournamentGamePlayer,
+					"game_id":   gameId,
+					"user_id":   player.UserId,
+					"error":     err,
+				}).Error("unable to transactionally insert imported tournament game player")
+				tx.Rollback()
+				return nil
+			}
+			if !player.Place.Valid {
+				continue
+			}
+			if _, err := tx.Exec(sqlUpdateTournamentGamePlace, player.Place, gameId, player.UserId); err != nil {
+				log.WithFields(log.Fields{
+					"statement": sqlUpdateTournamentGamePlace,
+					"game_id":   gameId,
+					"user_id":   player.UserId,
+					"error":     err,
+				}).Error("unable to transactionally update imported tournament game place")
+				tx.Rollback()
+				return nil
+			}
+		}
+		if game.CompletedAt.Valid {
+			if _, err := tx.Exec(sqlCompleteTournamentGame, game.CompletedAt, gameId); err != nil {
+				log.WithFields(log.Fields{
+					"statement": sqlCompleteTournamentGame,
+					"game_id":   gameId,
+					"error":     err,
+				}).Error("unable to transactionally complete imported tournament game")
+				tx.Rollback()
+				return nil
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("unable to commit transaction for importing tournament")
+		return nil
+	}
+	return GetTournament(int(id))
+}
+
+func UpdateTournamentState(id int, state string) bool {
+	result, err := stmtUpdateTournamentState.Exec(state, id)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlUpdateTournamentState,
+			"id":        id,
+			"state":     state,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return false
+	}
+	updated, _ := result.RowsAffected()
+
+	return updated > 0
+}
+
+// GetTournamentPlayers returns the checked in players of a tournament in the
+// order they checked in.
+func GetTournamentPlayers(tournamentId int) []TournamentPlayer {
+	rows, err := stmtSelectTournamentPlayers.Query(tournamentId)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectTournamentPlayers,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var players []TournamentPlayer
+	for rows.Next() {
+		var player TournamentPlayer
+		if err := rows.Scan(&player.TournamentId,
+			&player.UserId,
+			&player.Name,
+			&player.Seed,
+			&player.CheckedInAt); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectTournamentPlayers,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for tournament player")
+		} else {
+			players = append(players, player)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectTournamentPlayers,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for tournament player")
+	}
+
+	return players
+}
+
+// CheckInTournamentPlayer adds a player to a tournament; checking in twice
+// keeps the original check-in time.
+func CheckInTournamentPlayer(tournamentId int, userId int) bool {
+	if _, err := stmtInsertTournamentPlayer.Exec(tournamentId, userId, nil, time.Now().Unix()); err != nil {
+		log.WithFields(log.Fields{
+			"statement":     sqlInsertTournamentPlayer,
+			"tournament_id": tournamentId,
+			"user_id":       userId,
+			"error":         err,
+		}).Error("unable to execute prepared SQL statement")
+		return false
+	}
+	return true
+}
+
+func CheckOutTournamentPlayer(tournamentId int, userId int) bool {
+	result, err := stmtDeleteTournamentPlayer.Exec(tournamentId, userId)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement":     sqlDeleteTournamentPlayer,
+			"tournament_id": tournamentId,
+			"user_id":       userId,
+			"error":         err,
+		}).Error("unable to execute prepared SQL statement")
+		return false
+	}
+	deleted, _ := result.RowsAffected()
+
+	return deleted > 0
+}
+
+// SeedTournamentPlayers seeds the players of a tournament in the given order,
+// the first player as the top seed.
+func SeedTournamentPlayers(tournamentId int, userIds []int) bool {
+	tx, err := session.Begin()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("unable to begin transaction for seeding tournament players")
+		return false
+	}
+
+	for i, userId := range userIds {
+		if _, err := tx.Exec(sqlUpdateTournamentPlayerSeed, i+1, tournamentId, userId); err != nil {
+			log.WithFields(log.Fields{
+				"statement":     sqlUpdateTournamentPlayerSeed,
+				"tournament_id": tournamentId,
+				"user_id":       userId,
+				"error":         err,
+			}).Error("unable to transactionally seed tournament player")
+			tx.Rollback()
+			return false
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("unable to commit transaction for seeding tournament players")
+		return false
+	}
+	return true
+}
+
+func getTournamentGamePlayers(tournamentId int) map[int][]TournamentGamePlayer {
+	rows, err := stmtSelectTournamentGamePlayers.Query(tournamentId)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectTournamentGamePlayers,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	players := map[int][]TournamentGamePlayer{}
+	for rows.Next() {
+		var player TournamentGamePlayer
+		if err := rows.Scan(&player.GameId,
+			&player.UserId,
+			&player.Name,
+			&player.Position,
+			&player.Place); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectTournamentGamePlayers,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for tournament game player")
+		} else {
+			players[player.GameId] = append(players[player.GameId], player)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectTournamentGamePlayers,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for tournament game player")
+	}
+
+	return players
+}
+
+// GetTournamentGames returns the games of a tournament by round with their
+// players in the order of play.
+func GetTournamentGames(tournamentId int) []TournamentGame {
+	rows, err := stmtSelectTournamentGames.Query(tournamentId)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectTournamentGames,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var games []TournamentGame
+	for rows.Next() {
+		var game TournamentGame
+		if err := rows.Scan(&game.Id,
+			&game.TournamentId,
+			&game.Round,
+			&game.OpdbId,
+			&game.MachineName,
+			&game.DrawPool,
+			&game.PickedBy,
+			&game.CompletedAt); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectTournamentGames,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for tournament game")
+		} else {
+			games = append(games, game)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectTournamentGames,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for tournament game")
+	}
+
+	players := getTournamentGamePlayers(tournamentId)
+	for i := range games {
+		games[i].Players = players[games[i].Id]
+	}
+	return games
+}
+
+// CreateTournamentRound records the games of a round with their machines and
+// players in the order of play.
+func CreateTournamentRound(tournamentId int, round int, games []TournamentGame) bool {
+	tx, err := session.Begin()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("unable to begin transaction for creating tournament round")
+		return false
+	}
+
+	for _, game := range games {
+		result, err := tx.Exec(sqlInsertTournamentGame, tournamentId, round, game.OpdbId, game.DrawPool)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"statement":     sqlInsertTournamentGame,
+				"tournament_id": tournamentId,
+				"round":         round,
+				"error":         err,
+			}).Error("unable to transactionally insert tournament game")
+			tx.Rollback()
+			return false
+		}
+		gameId, _ := result.LastInsertId()
+		for _, player := range game.Players {
+			if _, err := tx.Exec(sqlInsertTournamentGamePlayer, gameId, player.UserId, player.Position); err != nil {
+				log.WithFields(log.Fields{
+					"statement": sqlInsertTournamentGamePlayer,
+					"game_id":   gameId,
+					"user_id":   player.UserId,
+					"error":     err,
+				}).Error("unable to transactionally insert tournament game player")
+				tx.Rollback()
+				return false
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("unable to commit transaction for creating tournament round")
+		return false
+	}
+	return true
+}
+
+// RecordTournamentGame records the finishing place of every player of a game
+// by user ID; a game can only be recorded once.
+func RecordTournamentGame(gameId int, places map[int]int) bool {
+	tx, err := session.Begin()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("unable to begin transaction for recording tournament game")
+		return false
+	}
+
+	result, err := tx.Exec(sqlCompleteTournamentGame, time.Now().Unix(), gameId)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlCompleteTournamentGame,
+			"game_id":   gameId,
+			"error":     err,
+		}).Error("unable to transactionally update tournament game")
+		tx.Rollback()
+		return false
+	}
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		tx.Rollback()
+		return false
+	}
+	for userId, place := range places {
+		if _, err := tx.Exec(sqlUpdateTournamentGamePlace, place, gameId, userId); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlUpdateTournamentGamePlace,
+				"game_id":   gameId,
+				"user_id":   userId,
+				"error":     err,
+			}).Error("unable to transactionally update tournament game place")
+			tx.Rollback()
+			return false
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("unable to commit transaction for recording tournament game")
+		return false
+	}
+	return true
+}
+
+// PickTournamentGameMachine replaces the drawn machine of a game that was not
+// recorded yet with the machine a player picked.
+func PickTournamentGameMachine(gameId int, opdbId string, pickedBy int) bool {
+	result, err := stmtPickTournamentGameMachine.Exec(opdbId, pickedBy, gameId)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlPickTournamentGameMachine,
+			"game_id":   gameId,
+			"opdb_id":   opdbId,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return false
+	}
+	updated, _ := result.RowsAffected()
+
+	return updated > 0
+}
+
+// GetTournamentTickets returns the entries sold in a qualifying tournament in
+// the order they were sold.
+func GetTournamentTickets(tournamentId int) []TournamentTicket {
+	rows, err := stmtSelectTournamentTickets.Query(tournamentId)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectTournamentTickets,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var tickets []TournamentTicket
+	for rows.Next() {
+		var ticket TournamentTicket
+		if err := rows.Scan(&ticket.Id,
+			&ticket.TournamentId,
+			&ticket.UserId,
+			&ticket.Name,
+			&ticket.OpdbId,
+			&ticket.MachineName,
+			&ticket.SoldBy,
+			&ticket.SoldAt,
+			&ticket.Used); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectTournamentTickets,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for tournament ticket")
+		} else {
+			tickets = append(tickets, ticket)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectTournamentTickets,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for tournament ticket")
+	}
+
+	return tickets
+}
+
+// SellTournamentTickets records the entries a player bought on a machine and
+// checks the player in.
+func SellTournamentTickets(tournamentId int, userId int, opdbId string, quantity int, soldBy int) bool {
+	tx, err := session.Begin()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("unable to begin transaction for selling tournament tickets")
+		return false
+	}
+
+	now := time.Now().Unix()
+	if _, err := tx.Exec(sqlInsertTournamentPlayer, tournamentId, userId, nil, now); err != nil {
+		log.WithFields(log.Fields{
+			"statement":     sqlInsertTournamentPlayer,
+			"tournament_id": tournamentId,
+			"user_id":       userId,
+			"error":         err,
+		}).Error("unable to transactionally insert tournament player")
+		tx.Rollback()
+		return false
+	}
+	for i := 0; i < quantity; i++ {
+		if _, err := tx.Exec(sqlInsertTournamentTicket, tournamentId, userId, opdbId, soldBy, now); err != nil {
+			log.WithFields(log.Fields{
+				"statement":     sqlInsertTournamentTicket,
+				"tournament_id": tournamentId,
+				"user_id":       userId,
+				"opdb_id":       opdbId,
+				"error":         err,
+			}).Error("unable to transactionally insert tournament ticket")
+			tx.Rollback()
+			return false
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("unable to commit transaction for selling tournament tickets")
+		return false
+	}
+	return true
+}
+
+// GetTournamentScores returns the scores submitted in a qualifying tournament
+// in the order they were submitted.
+func GetTournamentScores(tournamentId int) []TournamentScore {
+	rows, err := stmtSelectTournamentScores.Query(tournamentId)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectTournamentScores,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var scores []TournamentScore
+	for rows.Next() {
+		var score TournamentScore
+		if err := rows.Scan(&score.Id,
+			&score.TicketId,
+			&score.TournamentId,
+			&score.UserId,
+			&score.Name,
+			&score.OpdbId,
+			&score.MachineName,
+			&score.Score,
+			&score.SubmittedAt,
+			&score.VerifiedAt); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectTournamentScores,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for tournament score")
+		} else {
+			scores = append(scores, score)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectTournamentScores,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for tournament score")
+	}
+
+	return scores
+}
+
+// SubmitTournamentScore uses the oldest unused entry of a player on a machine
+// for a score; scores submitted by staff are verified right away. It returns
+// false when no entry is left.
+func SubmitTournamentScore(tournamentId int, userId int, opdbId string, score int64, submittedBy int, verified bool) bool {
+	now := time.Now().Unix()
+	var verifiedBy, verifiedAt sql.NullInt64
+	if verified {
+		verifiedBy = sql.NullInt64{Int64: int64(submittedBy), Valid: true}
+		verifiedAt = sql.NullInt64{Int64: now, Valid: true}
+	}
+	result, err := stmtInsertTournamentScore.Exec(tournamentId,
+		userId,
+		opdbId,
+		score,
+		submittedBy,
+		now,
+		verifiedBy,
+		verifiedAt)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement":     sqlInsertTournamentScore,
+			"tournament_id": tournamentId,
+			"user_id":       userId,
+			"opdb_id":       opdbId,
+			"error":         err,
+		}).Error("unable to execute prepared SQL statement")
+		return false
+	}
+	inserted, _ := result.RowsAffected()
+
+	return inserted > 0
+}
+
+func VerifyTournamentScore(tournamentId int, id int, verifiedBy int) bool {
+	result, err := stmtVerifyTournamentScore.Exec(verifiedBy, time.Now().Unix(), id, tournamentId)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlVerifyTournamentScore,
+			"id":        id,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return false
+	}
+	updated, _ := result.RowsAffected()
+
+	return updated > 0
+}
+
+// RejectTournamentScore removes an unverified score so that its entry can be
+// used again.
+func RejectTournamentScore(tournamentId int, id int) bool {
+	result, err := stmtDeleteUnverifiedTournamentScore.Exec(id, tournamentId)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlDeleteUnverifiedTournamentScore,
+			"id":        id,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return false
+	}
+	deleted, _ := result.RowsAffected()
+
+	return deleted > 0
+}
+
+func closePreparedTournamentsStatements() {
+	log.Debug("closing prepared tournaments statements")
+	stmtSelectTournaments.Close()
+	stmtCountTournaments.Close()
+	stmtSelectTournament.Close()
+	stmtInsertTournament.Close()
+	stmtUpdateTournamentState.Close()
+	stmtSelectTournamentPlayers.Close()
+	stmtInsertTournamentPlayer.Close()
+	stmtDeleteTournamentPlayer.Close()
+	stmtSelectTournamentGames.Close()
+	stmtSelectTournamentGamePlayers.Close()
+	stmtPickTournamentGameMachine.Close()
+	stmtSelectTournamentTickets.Close()
+	stmtSelectTournamentScores.Close()
+	stmtInsertTournamentScore.Close()
+	stmtVerifyTournamentScore.Close()
+	stmtDeleteUnverifiedTournamentScore.Close()
+	log.Debug("prepared tournaments statements closed")
+}
+
+func prepareTournamentsStatements() {
+	log.Debug("preparing tournaments statements")
+	stmtSelectTournaments = prepare(sqlSelectTournaments)
+	stmtCountTournaments = prepare(sqlCountTournaments)
+	stmtSelectTournament = prepare(sqlSelectTournament)
+	stmtInsertTournament = prepare(sqlInsertTournament)
+	stmtUpdateTournamentState = prepare(sqlUpdateTournamentState)
+	stmtSelectTournamentPlayers = prepare(sqlSelectTournamentPlayers)
+	stmtInsertTournamentPlayer = prepare(sqlInsertTournamentPlayer)
+	stmtDeleteTournamentPlayer = prepare(sqlDeleteTournamentPlayer)
+	stmtSelectTournamentGames = prepare(sqlSelectTournamentGames)
+	stmtSelectTournamentGamePlayers = prepare(sqlSelectTournamentGamePlayers)
+	stmtPickTournamentGameMachine = prepare(sqlPickTournamentGameMachine)
+	stmtSelectTournamentTickets = prepare(sqlSelectTournamentTickets)
+	stmtSelectTournamentScores = prepare(sqlSelectTournamentScores)
+	stmtInsertTournamentScore = prepare(sqlInsertTournamentScore)
+	stmtVerifyTournamentScore = prepare(sqlVerifyTournamentScore)
+	stmtDeleteUnverifiedTournamentScore = prepare(sqlDeleteUnverifiedTournamentScore)
+	log.Debug("tournaments statements prepared")
+}
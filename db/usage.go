@@ -0,0 +1,214 @@
+package db
+
+import (
+	"database/sql"
+	"sort"
+
+	"github.com/mikefero/tpl/log"
+)
+
+type WeeklyUsage struct {
+	OpdbId      string
+	MachineName string
+	Week        int
+	Games       int
+}
+
+// MachineUsage is the use of a machine over a season; games are counted from
+// results per week while uses are what the league usage limit is checked
+// against.
+type MachineUsage struct {
+	OpdbId      string
+	MachineName string
+	Games       int
+	Uses        int
+	Weeks       map[int]int
+}
+
+var stmtSelectSeasonUsage *sql.Stmt
+var stmtSelectSeasonWeeklyUsage *sql.Stmt
+var stmtSelectSeasonWeeks *sql.Stmt
+
+// GetSeasonUsage returns how many times each machine was used in a season,
+// which is what the usage limit of a league is checked against.
+func GetSeasonUsage(seasonId int) map[string]int {
+	usage := map[string]int{}
+	rows, err := stmtSelectSeasonUsage.Query(seasonId)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectSeasonUsage,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return usage
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var opdbId string
+		var count int
+		if err := rows.Scan(&opdbId, &count); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectSeasonUsage,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for season usage")
+		} else {
+			usage[opdbId] = count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectSeasonUsage,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for season usage")
+	}
+
+	return usage
+}
+
+// GetSeasonWeeklyUsage returns the number of games played on each machine per
+// week of a season ordered by machine name and week.
+func GetSeasonWeeklyUsage(seasonId int) []WeeklyUsage {
+	rows, err := stmtSelectSeasonWeeklyUsage.Query(seasonId)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectSeasonWeeklyUsage,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var usage []WeeklyUsage
+	for rows.Next() {
+		var weekly WeeklyUsage
+		if err := rows.Scan(&weekly.OpdbId,
+			&weekly.MachineName,
+			&weekly.Week,
+			&weekly.Games); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectSeasonWeeklyUsage,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for weekly usage")
+		} else {
+			usage = append(usage, weekly)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectSeasonWeeklyUsage,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for weekly usage")
+	}
+
+	return usage
+}
+
+func GetSeasonWeeks(seasonId int) []int {
+	rows, err := stmtSelectSeasonWeeks.Query(seasonId)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectSeasonWeeks,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var weeks []int
+	for rows.Next() {
+		var week int
+		if err := rows.Scan(&week); err != nil {
+			log.WithFields(log.Fields{
+				"statement": sqlSelectSeasonWeeks,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for season week")
+		} else {
+			weeks = append(weeks, week)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlSelectSeasonWeeks,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for season week")
+	}
+
+	return weeks
+}
+
+// GetSeasonMachineUsage returns the usage of every machine played during a
+// season along with the active machines that were not played, most played
+// first.
+func GetSeasonMachineUsage(seasonId int) []MachineUsage {
+	uses := GetSeasonUsage(seasonId)
+	var usage []MachineUsage
+	indexes := map[string]int{}
+	for _, weekly := range GetSeasonWeeklyUsage(seasonId) {
+		index, exists := indexes[weekly.OpdbId]
+		if !exists {
+			index = len(usage)
+			indexes[weekly.OpdbId] = index
+			usage = append(usage, MachineUsage{
+				OpdbId:      weekly.OpdbId,
+				MachineName: weekly.MachineName,
+				Uses:        uses[weekly.OpdbId],
+				Weeks:       map[int]int{},
+			})
+		}
+		usage[index].Games += weekly.Games
+		usage[index].Weeks[weekly.Week] = weekly.Games
+	}
+	for _, entry := range GetLineup() {
+		if _, exists := indexes[entry.OpdbId]; entry.Active && !exists {
+			usage = append(usage, MachineUsage{
+				OpdbId:      entry.OpdbId,
+				MachineName: entry.Name,
+				Uses:        uses[entry.OpdbId],
+				Weeks:       map[int]int{},
+			})
+		}
+	}
+
+	sort.SliceStable(usage, func(i, j int) bool {
+		return usage[i].Games > usage[j].Games
+	})
+	return usage
+}
+
+// GetLeastUsedMachines narrows a pool of machines down to the ones used the
+// fewest times, keeping their order.
+func GetLeastUsedMachines(pool []string, usage map[string]int) []string {
+	var leastUsed []string
+	for _, opdbId := range pool {
+		if len(leastUsed) > 0 && usage[opdbId] > usage[leastUsed[0]] {
+			continue
+		}
+		if len(leastUsed) > 0 && usage[opdbId] < usage[leastUsed[0]] {
+			leastUsed = nil
+		}
+		leastUsed = append(leastUsed, opdbId)
+	}
+	return leastUsed
+}
+
+func closePreparedUsageStatements() {
+	log.Debug("closing prepared usage statements")
+	stmtSelectSeasonUsage.Close()
+	stmtSelectSeasonWeeklyUsage.Close()
+	stmtSelectSeasonWeeks.Close()
+	log.Debug("prepared usage statements closed")
+}
+
+func prepareUsageStatements() {
+	log.Debug("preparing usage statements")
+	stmtSelectSeasonUsage = prepare(sqlSelectSeasonUsage)
+	stmtSelectSeasonWeeklyUsage = prepare(sqlSelectSeasonWeeklyUsage)
+	stmtSelectSeasonWeeks = prepare(sqlSelectSeasonWeeks)
+	log.Debug("usage statements prepared")
+}
@@ -0,0 +1,224 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/mikefero/tpl/log"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const RolePlayer = "player"
+const RoleStaff = "staff"
+const RoleAdmin = "admin"
+
+// Each role includes the permissions of the roles before it
+var roles = []string{
+	RolePlayer,
+	RoleStaff,
+	RoleAdmin,
+}
+
+type User struct {
+	Id       int
+	LeagueId int
+	Email    string
+	Password string
+	Name     string
+	Initials sql.NullString
+	Role     string
+	Active   bool
+	// IfpaId is the IFPA player number linked by the player, trusted for
+	// seeding once an admin verified it
+	IfpaId         sql.NullInt64
+	IfpaVerifiedAt sql.NullInt64
+}
+
+var stmtSelectUser *sql.Stmt
+var stmtSelectUserByEmail *sql.Stmt
+var stmtSelectActiveUsers *sql.Stmt
+var stmtSelectIfpaUsers *sql.Stmt
+var stmtUpdateUserIfpaId *sql.Stmt
+var stmtVerifyUserIfpaId *sql.Stmt
+
+func scanUser(row *sql.Row, statement string) *User {
+	var user User
+	err := row.Scan(&user.Id,
+		&user.LeagueId,
+		&user.Email,
+		&user.Password,
+		&user.Name,
+		&user.Initials,
+		&user.Role,
+		&user.Active,
+		&user.IfpaId,
+		&user.IfpaVerifiedAt)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.WithFields(log.Fields{
+				"statement": statement,
+				"error":     err,
+			}).Error("unable to execute prepared SQL statement")
+		}
+		return nil
+	}
+
+	return &user
+}
+
+func getRoleRank(role string) int {
+	for rank, r := range roles {
+		if r == role {
+			return rank
+		}
+	}
+	return -1
+}
+
+// HasRole determines if a role grants the permissions of another role.
+func HasRole(role string, required string) bool {
+	return getRoleRank(role) >= getRoleRank(required)
+}
+
+func (user User) HasRole(role string) bool {
+	return HasRole(user.Role, role)
+}
+
+func GetUser(id int) *User {
+	return scanUser(stmtSelectUser.QueryRow(id), sqlSelectUser)
+}
+
+func getUsers(stmt *sql.Stmt, statement string) []User {
+	rows, err := stmt.Query()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": statement,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return nil
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.Id,
+			&user.LeagueId,
+			&user.Email,
+			&user.Password,
+			&user.Name,
+			&user.Initials,
+			&user.Role,
+			&user.Active,
+			&user.IfpaId,
+			&user.IfpaVerifiedAt); err != nil {
+			log.WithFields(log.Fields{
+				"statement": statement,
+				"result":    rows,
+				"error":     err,
+			}).Warn("unable to scan result for user")
+		} else {
+			users = append(users, user)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.WithFields(log.Fields{
+			"statement": statement,
+			"result":    rows,
+			"error":     err,
+		}).Error("unable to scan result for user")
+	}
+
+	return users
+}
+
+func GetActiveUsers() []User {
+	return getUsers(stmtSelectActiveUsers, sqlSelectActiveUsers)
+}
+
+// GetIfpaUsers returns the users who linked an IFPA number, the unverified
+// ones first.
+func GetIfpaUsers() []User {
+	return getUsers(stmtSelectIfpaUsers, sqlSelectIfpaUsers)
+}
+
+// SetUserIfpaId links an IFPA number to a user, or unlinks it when the number
+// is not valid, and resets its verification. Linking a number that belongs to
+// another user fails.
+func SetUserIfpaId(id int, ifpaId sql.NullInt64) bool {
+	result, err := stmtUpdateUserIfpaId.Exec(ifpaId, id)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlUpdateUserIfpaId,
+			"id":        id,
+			"ifpa_id":   ifpaId,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return false
+	}
+	updated, _ := result.RowsAffected()
+
+	return updated > 0
+}
+
+func VerifyUserIfpaId(id int) bool {
+	result, err := stmtVerifyUserIfpaId.Exec(time.Now().Unix(), id)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"statement": sqlVerifyUserIfpaId,
+			"id":        id,
+			"error":     err,
+		}).Error("unable to execute prepared SQL statement")
+		return false
+	}
+	verified, _ := result.RowsAffected()
+
+	return verified > 0
+}
+
+func AuthenticateUser(email string, password string) *User {
+	user := scanUser(stmtSelectUserByEmail.QueryRow(email), sqlSelectUserByEmail)
+	if user == nil || !user.Active {
+		return nil
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		log.WithFields(log.Fields{
+			"email": email,
+		}).Debug("password mismatch during user authentication")
+		return nil
+	}
+
+	return user
+}
+
+func HashPassword(password string) string {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Panic("unable to hash password")
+	}
+	return string(hash)
+}
+
+func closePreparedUsersStatements() {
+	log.Debug("closing prepared users statements")
+	stmtSelectUser.Close()
+	stmtSelectUserByEmail.Close()
+	stmtSelectActiveUsers.Close()
+	stmtSelectIfpaUsers.Close()
+	stmtUpdateUserIfpaId.Close()
+	stmtVerifyUserIfpaId.Close()
+	log.Debug("prepared users statements closed")
+}
+
+func prepareUsersStatements() {
+	log.Debug("preparing users statements")
+	stmtSelectUser = prepare(sqlSelectUser)
+	stmtSelectUserByEmail = prepare(sqlSelectUserByEmail)
+	stmtSelectActiveUsers = prepare(sqlSelectActiveUsers)
+	stmtSelectIfpaUsers = prepare(sqlSelectIfpaUsers)
+	stmtUpdateUserIfpaId = prepare(sqlUpdateUserIfpaId)
+	stmtVerifyUserIfpaId = prepare(sqlVerifyUserIfpaId)
+	log.Debug("users statements prepared")
+}
@@ -0,0 +1,303 @@
+package html
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikefero/tpl/db"
+	"github.com/mikefero/tpl/tournament"
+)
+
+func renderAdminLineup(ctx *gin.Context, status int, data gin.H) {
+	data["title"] = "Lineup"
+	data["description"] = "Manage the machine lineup of The Pinball Lounge"
+	data["lineup"] = db.GetLineup()
+	render(ctx, status, "admin_lineup.tmpl", data)
+}
+
+func handleAdminLineup(ctx *gin.Context) {
+	renderAdminLineup(ctx, http.StatusOK, gin.H{})
+}
+
+func handleAdminAddToLineup(ctx *gin.Context) {
+	opdbId := strings.TrimSpace(ctx.PostForm("opdb_id"))
+	if !db.SetLineupOverride(opdbId, true) {
+		renderAdminLineup(ctx, http.StatusBadRequest, gin.H{
+			"error": "Machine " + opdbId + " is not in the OPDB catalog",
+		})
+		return
+	}
+
+	ctx.Redirect(http.StatusSeeOther, "/admin/lineup")
+}
+
+func handleAdminRemoveFromLineup(ctx *gin.Context) {
+	if !db.SetLineupOverride(ctx.Param("opdb_id"), false) {
+		renderAdminLineup(ctx, http.StatusNotFound, gin.H{
+			"error": "Machine not found",
+		})
+		return
+	}
+
+	ctx.Redirect(http.StatusSeeOther, "/admin/lineup")
+}
+
+func handleAdminClearLineupOverride(ctx *gin.Context) {
+	if !db.ClearLineupOverride(ctx.Param("opdb_id")) {
+		renderAdminLineup(ctx, http.StatusNotFound, gin.H{
+			"error": "Machine has no lineup override",
+		})
+		return
+	}
+
+	ctx.Redirect(http.StatusSeeOther, "/admin/lineup")
+}
+
+func handleAdminSyncLineup(ctx *gin.Context) {
+	db.SyncActiveMachines()
+	ctx.Redirect(http.StatusSeeOther, "/admin/lineup")
+}
+
+func renderAdminLeagues(ctx *gin.Context, status int, data gin.H) {
+	data["title"] = "Leagues"
+	data["description"] = "Manage the leagues of The Pinball Lounge"
+	data["leagues"] = db.GetLeagues(sql.NullBool{}, db.CountLeagues(sql.NullBool{}), 0)
+	data["selectionModes"] = db.SelectionModes
+	var seasons []db.Season
+	weeks := map[int]int64{}
+	for _, league := range data["leagues"].([]db.League) {
+		for _, season := range db.GetSeasons(league.Id, db.CountSeasons(league.Id), 0) {
+			filter := db.MatchFilter{
+				SeasonId: season.Id,
+			}
+			filter.Limit = db.CountMatches(filter)
+			for _, match := range db.GetMatches(filter) {
+				if match.Week.Valid && match.Week.Int64 > weeks[season.Id] {
+					weeks[season.Id] = match.Week.Int64
+				}
+			}
+			seasons = append(seasons, season)
+		}
+	}
+	data["seasons"] = seasons
+	data["weeks"] = weeks
+	subs := map[int][]db.LeagueSub{}
+	for _, league := range data["leagues"].([]db.League) {
+		subs[league.Id] = db.GetLeagueSubs(league.Id)
+	}
+	data["subs"] = subs
+	data["users"] = db.GetActiveUsers()
+	render(ctx, status, "admin_leagues.tmpl", data)
+}
+
+func handleAdminLeagues(ctx *gin.Context) {
+	renderAdminLeagues(ctx, http.StatusOK, gin.H{})
+}
+
+func handleAdminUpdateLeague(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	mode := ctx.PostForm("selection_mode")
+	if !db.IsSelectionMode(mode) {
+		renderAdminLeagues(ctx, http.StatusBadRequest, gin.H{
+			"error": "Unknown machine selection mode " + mode,
+		})
+		return
+	}
+	var usageLimit sql.NullInt64
+	if limit := strings.TrimSpace(ctx.PostForm("usage_limit")); len(limit) > 0 {
+		usageLimit.Int64, err = strconv.ParseInt(limit, 10, 64)
+		if err != nil || usageLimit.Int64 < 1 {
+			renderAdminLeagues(ctx, http.StatusBadRequest, gin.H{
+				"error": "Usage limit must be a positive number of games",
+			})
+			return
+		}
+		usageLimit.Valid = true
+	}
+	games, err := strconv.Atoi(strings.TrimSpace(ctx.PostForm("games")))
+	if err != nil || games < 1 {
+		renderAdminLeagues(ctx, http.StatusBadRequest, gin.H{
+			"error": "Matches must have a positive number of games",
+		})
+		return
+	}
+	if !db.UpdateLeagueSelection(id, mode, ctx.PostForm("no_repeat") == "true", usageLimit, games) {
+		renderAdminLeagues(ctx, http.StatusNotFound, gin.H{
+			"error": "League not found",
+		})
+		return
+	}
+
+	ctx.Redirect(http.StatusSeeOther, "/admin/leagues")
+}
+
+func handleAdminAddLeagueSub(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil || db.GetLeague(id) == nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	userId, err := strconv.Atoi(ctx.PostForm("user_id"))
+	if err != nil {
+		renderAdminLeagues(ctx, http.StatusBadRequest, gin.H{
+			"error": "A player is required",
+		})
+		return
+	}
+	if user := db.GetUser(userId); user == nil || !user.Active {
+		renderAdminLeagues(ctx, http.StatusBadRequest, gin.H{
+			"error": "Player not found",
+		})
+		return
+	}
+	if !db.AddLeagueSub(id, userId) {
+		renderAdminLeagues(ctx, http.StatusInternalServerError, gin.H{
+			"error": "Unable to add the sub",
+		})
+		return
+	}
+
+	ctx.Redirect(http.StatusSeeOther, "/admin/leagues#subs-"+strconv.Itoa(id))
+}
+
+func handleAdminRemoveLeagueSub(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	userId, err := strconv.Atoi(ctx.Param("user_id"))
+	if err != nil || !db.RemoveLeagueSub(id, userId) {
+		renderAdminLeagues(ctx, http.StatusNotFound, gin.H{
+			"error": "Sub not found",
+		})
+		return
+	}
+
+	ctx.Redirect(http.StatusSeeOther, "/admin/leagues#subs-"+strconv.Itoa(id))
+}
+
+func handleAdminUpdateSubLimit(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	var subLimit sql.NullInt64
+	if limit := strings.TrimSpace(ctx.PostForm("sub_limit")); len(limit) > 0 {
+		subLimit.Int64, err = strconv.ParseInt(limit, 10, 64)
+		if err != nil || subLimit.Int64 < 0 {
+			renderAdminLeagues(ctx, http.StatusBadRequest, gin.H{
+				"error": "Sub limit must be a number of subs",
+			})
+			return
+		}
+		subLimit.Valid = true
+	}
+	if !db.UpdateLeagueSubLimit(id, subLimit) {
+		renderAdminLeagues(ctx, http.StatusNotFound, gin.H{
+			"error": "League not found",
+		})
+		return
+	}
+
+	ctx.Redirect(http.StatusSeeOther, "/admin/leagues#subs-"+strconv.Itoa(id))
+}
+
+func handleAdminPairSeasonWeek(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	season := db.GetSeason(id)
+	if season == nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	var date sql.NullInt64
+	if value := strings.TrimSpace(ctx.PostForm("date")); len(value) > 0 {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			renderAdminLeagues(ctx, http.StatusBadRequest, gin.H{
+				"error": "Date must be formatted as YYYY-MM-DD",
+			})
+			return
+		}
+		date = sql.NullInt64{
+			Int64: parsed.Unix(),
+			Valid: true,
+		}
+	}
+
+	if _, err := tournament.PairSeasonWeek(*season, date); err != nil {
+		renderAdminLeagues(ctx, http.StatusUnprocessableEntity, gin.H{
+			"error": strings.ToUpper(err.Error()[:1]) + err.Error()[1:],
+		})
+		return
+	}
+	ctx.Redirect(http.StatusSeeOther, "/admin/leagues#season-"+strconv.Itoa(season.Id))
+}
+
+// renderAdminAliases lists the machine aliases and, when a name is tested,
+// the machines the name matches with their confidence.
+func renderAdminAliases(ctx *gin.Context, status int, data gin.H) {
+	name := strings.TrimSpace(ctx.Query("name"))
+	if len(name) > 0 {
+		data["name"] = name
+		data["matches"] = db.MatchMachineName(name, 10)
+	}
+	data["title"] = "Aliases"
+	data["description"] = "Manage the machine names matched to the OPDB catalog"
+	data["aliases"] = db.GetMachineAliases()
+	data["minConfidence"] = db.MinMachineMatchConfidence
+	render(ctx, status, "admin_aliases.tmpl", data)
+}
+
+func handleAdminAliases(ctx *gin.Context) {
+	renderAdminAliases(ctx, http.StatusOK, gin.H{})
+}
+
+func handleAdminAddAlias(ctx *gin.Context) {
+	name := strings.TrimSpace(ctx.PostForm("name"))
+	opdbId := strings.TrimSpace(ctx.PostForm("opdb_id"))
+	if len(db.NormalizeMachineName(name)) == 0 {
+		renderAdminAliases(ctx, http.StatusBadRequest, gin.H{
+			"error": "Alias must contain letters or digits",
+		})
+		return
+	}
+	if db.GetMachine(opdbId) == nil {
+		renderAdminAliases(ctx, http.StatusBadRequest, gin.H{
+			"error": "Machine " + opdbId + " is not in the OPDB catalog",
+		})
+		return
+	}
+	if !db.AddMachineAlias(name, opdbId, getSessionUser(ctx).Id) {
+		renderAdminAliases(ctx, http.StatusInternalServerError, gin.H{
+			"error": "Unable to add the alias",
+		})
+		return
+	}
+
+	ctx.Redirect(http.StatusSeeOther, "/admin/aliases")
+}
+
+func handleAdminDeleteAlias(ctx *gin.Context) {
+	if !db.DeleteMachineAlias(ctx.PostForm("alias")) {
+		renderAdminAliases(ctx, http.StatusNotFound, gin.H{
+			"error": "Alias not found",
+		})
+		return
+	}
+
+	ctx.Redirect(http.StatusSeeOther, "/admin/aliases")
+}
@@ -0,0 +1,37 @@
+package html
+
+import (
+	"strconv"
+	"strings"
+)
+
+// getChartPoints scales values to the points of an SVG polyline of the given
+// size with the first value on the left and larger values towards the top.
+func getChartPoints(values []float64, width int, height int) string {
+	if len(values) == 0 {
+		return ""
+	}
+	minimum, maximum := values[0], values[0]
+	for _, value := range values {
+		if value < minimum {
+			minimum = value
+		}
+		if value > maximum {
+			maximum = value
+		}
+	}
+
+	var points []string
+	for i, value := range values {
+		x := float64(width) / 2
+		if len(values) > 1 {
+			x = float64(width) * float64(i) / float64(len(values)-1)
+		}
+		y := float64(height) / 2
+		if maximum > minimum {
+			y = float64(height) * (maximum - value) / (maximum - minimum)
+		}
+		points = append(points, strconv.FormatFloat(x, 'f', 1, 64)+","+strconv.FormatFloat(y, 'f', 1, 64))
+	}
+	return strings.Join(points, " ")
+}
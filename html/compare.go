@@ -0,0 +1,86 @@
+package html
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikefero/tpl/db"
+	"github.com/mikefero/tpl/stats"
+)
+
+const compareTypePlayer = "player"
+const compareTypeTeam = "team"
+
+type compareOption struct {
+	Id   int
+	Name string
+}
+
+// getCompareOptions returns the active players or every team of every league
+// that can be compared.
+func getCompareOptions(compareType string) []compareOption {
+	var options []compareOption
+	if compareType == compareTypeTeam {
+		for _, league := range db.GetLeagues(sql.NullBool{}, db.CountLeagues(sql.NullBool{}), 0) {
+			for _, team := range db.GetTeams(league.Id, db.CountTeams(league.Id), 0) {
+				options = append(options, compareOption{
+					Id:   team.Id,
+					Name: team.Name + " (" + league.Name + ")",
+				})
+			}
+		}
+		return options
+	}
+
+	for _, user := range db.GetActiveUsers() {
+		options = append(options, compareOption{
+			Id:   user.Id,
+			Name: user.Name,
+		})
+	}
+	return options
+}
+
+func getCompareName(options []compareOption, id int) string {
+	for _, option := range options {
+		if option.Id == id {
+			return option.Name
+		}
+	}
+	return ""
+}
+
+func handleCompare(ctx *gin.Context) {
+	compareType := ctx.Query("type")
+	if compareType != compareTypeTeam {
+		compareType = compareTypePlayer
+	}
+	options := getCompareOptions(compareType)
+	a, _ := strconv.Atoi(ctx.Query("a"))
+	b, _ := strconv.Atoi(ctx.Query("b"))
+	nameA := getCompareName(options, a)
+	nameB := getCompareName(options, b)
+
+	data := gin.H{
+		"title":       "Compare",
+		"description": "Head-to-head records of players and teams at The Pinball Lounge",
+		"type":        compareType,
+		"options":     options,
+		"a":           a,
+		"b":           b,
+		"nameA":       nameA,
+		"nameB":       nameB,
+	}
+	if len(nameA) > 0 && len(nameB) > 0 && a != b {
+		if compareType == compareTypeTeam {
+			data["rivalry"] = stats.GetTeamRivalry(a, b)
+		} else {
+			data["rivalry"] = stats.GetPlayerRivalry(a, b)
+		}
+		data["title"] = nameA + " vs " + nameB
+	}
+
+	render(ctx, http.StatusOK, "compare.tmpl", data)
+}
@@ -0,0 +1,125 @@
+package html
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikefero/tpl/db"
+)
+
+func formatTimestamp(timestamp int64) string {
+	return time.Unix(timestamp, 0).Format("2006-01-02 15:04")
+}
+
+// formatScore groups the digits of a pinball score, e.g. 12,345,670.
+func formatScore(score interface{}) string {
+	var value int64
+	switch s := score.(type) {
+	case int64:
+		value = s
+	case int:
+		value = int64(s)
+	case float64:
+		value = int64(s + 0.5)
+	}
+
+	digits := strconv.FormatInt(value, 10)
+	negative := strings.HasPrefix(digits, "-")
+	digits = strings.TrimPrefix(digits, "-")
+	var grouped string
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			grouped += ","
+		}
+		grouped += string(digit)
+	}
+	if negative {
+		grouped = "-" + grouped
+	}
+	return grouped
+}
+
+func formatDate(timestamp sql.NullInt64) string {
+	if !timestamp.Valid {
+		return ""
+	}
+	return time.Unix(timestamp.Int64, 0).Format("January 2, 2006")
+}
+
+// formatTicketState returns the display name of a maintenance ticket state,
+// e.g. In progress.
+func formatTicketState(state string) string {
+	name := strings.ReplaceAll(state, "_", " ")
+	if len(name) == 0 {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// formatSelectionMode returns the display name of a league machine selection
+// mode, e.g. Random draw.
+func formatSelectionMode(mode string) string {
+	switch mode {
+	case db.SelectionModeHomePick:
+		return "Home team picks"
+	case db.SelectionModeAlternating:
+		return "Alternating picks"
+	case db.SelectionModeRandom:
+		return "Random draw"
+	}
+	return mode
+}
+
+// formatTournamentState returns the display name of a tournament state, e.g.
+// Check-in.
+func formatTournamentState(state string) string {
+	switch state {
+	case db.TournamentStateCheckIn:
+		return "Check-in"
+	case db.TournamentStateRunning:
+		return "Running"
+	case db.TournamentStateCompleted:
+		return "Completed"
+	}
+	return state
+}
+
+// getUsageColor returns the heatmap background of a week of machine usage
+// relative to the busiest week of any machine.
+func getUsageColor(games int, busiest int) string {
+	if games == 0 || busiest == 0 {
+		return "transparent"
+	}
+	alpha := int(255 * (0.15 + 0.85*float64(games)/float64(busiest)))
+	return fmt.Sprintf("#4e5e30%02x", alpha)
+}
+
+// formatMargin returns a signed average margin, e.g. +1,250,000 for pinball
+// scores or +1.5 for team points.
+func formatMargin(margin float64) string {
+	switch {
+	case margin > -0.05 && margin < 0.05:
+		return "0"
+	case margin > -100 && margin < 100:
+		return fmt.Sprintf("%+.1f", margin)
+	case margin > 0:
+		return "+" + formatScore(margin)
+	}
+	return "-" + formatScore(-margin)
+}
+
+func formatPercent(ratio float64) string {
+	return strconv.Itoa(int(ratio*100+0.5)) + "%"
+}
+
+// formatDifficulty returns the display name of a machine difficulty, e.g.
+// Skill-heavy.
+func formatDifficulty(classification string) string {
+	if len(classification) == 0 {
+		return classification
+	}
+	return strings.ToUpper(classification[:1]) + classification[1:]
+}
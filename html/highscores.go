@@ -0,0 +1,103 @@
+package html
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikefero/tpl/db"
+)
+
+type highScoreBoard struct {
+	OpdbId      string
+	MachineName string
+	HighScores  []db.HighScore
+}
+
+// getHighScoreBoards groups the grand champions of every machine into boards.
+func getHighScoreBoards() []highScoreBoard {
+	var boards []highScoreBoard
+	for _, highScore := range db.GetHighScoreBoards() {
+		if len(boards) == 0 || boards[len(boards)-1].OpdbId != highScore.OpdbId {
+			boards = append(boards, highScoreBoard{
+				OpdbId:      highScore.OpdbId,
+				MachineName: highScore.MachineName,
+			})
+		}
+		board := &boards[len(boards)-1]
+		board.HighScores = append(board.HighScores, highScore)
+	}
+	return boards
+}
+
+func renderHighScores(ctx *gin.Context, status int, data gin.H) {
+	data["title"] = "High Scores"
+	data["description"] = "Grand champions of every machine at The Pinball Lounge"
+	data["boards"] = getHighScoreBoards()
+	if user := getSessionUser(ctx); user != nil && user.HasRole(db.RoleStaff) {
+		data["unverified"] = db.GetUnverifiedHighScores()
+	}
+	render(ctx, status, "highscores.tmpl", data)
+}
+
+func handleHighScores(ctx *gin.Context) {
+	renderHighScores(ctx, http.StatusOK, gin.H{})
+}
+
+func handleSubmitHighScore(ctx *gin.Context) {
+	opdbId := ctx.Param("opdb_id")
+	machine := db.GetMachine(opdbId)
+	if machine == nil || !machine.Active {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	score, err := strconv.ParseInt(strings.ReplaceAll(strings.TrimSpace(ctx.PostForm("score")), ",", ""), 10, 64)
+	if err != nil || score <= 0 {
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	photoKey, err := getPhotoUpload(ctx, "photo")
+	if err != nil {
+		ctx.String(http.StatusBadRequest, "Unable to upload photo; a JPEG, PNG or GIF image no larger than 10 MB is required")
+		ctx.Abort()
+		return
+	}
+	if !db.SubmitHighScore(opdbId, getSessionUser(ctx).Id, score, photoKey) {
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Redirect(http.StatusSeeOther, "/machines/"+opdbId+"?submitted=true")
+}
+
+func handleVerifyHighScore(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil || !db.VerifyHighScore(id, getSessionUser(ctx).Id) {
+		renderHighScores(ctx, http.StatusNotFound, gin.H{
+			"error": "High score not found",
+		})
+		return
+	}
+
+	ctx.Redirect(http.StatusSeeOther, "/highscores")
+}
+
+func handleRejectHighScore(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil || !db.RejectHighScore(id) {
+		renderHighScores(ctx, http.StatusNotFound, gin.H{
+			"error": "High score not found",
+		})
+		return
+	}
+
+	ctx.Redirect(http.StatusSeeOther, "/highscores")
+}
+
+func handleImportLeagueHighScores(ctx *gin.Context) {
+	imported := db.ImportLeagueHighScores(getSessionUser(ctx).Id)
+	renderHighScores(ctx, http.StatusOK, gin.H{
+		"message": strconv.Itoa(imported) + " league scores imported",
+	})
+}
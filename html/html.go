@@ -3,14 +3,16 @@ package html
 import (
 	"html/template"
 	"net/http"
-	"regexp"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mikefero/tpl/api"
+	"github.com/mikefero/tpl/db"
 	"github.com/mikefero/tpl/log"
+	"github.com/mikefero/tpl/storage"
 )
 
 func handleRoot(ctx *gin.Context) {
-	ctx.HTML(http.StatusOK, "index.tmpl", gin.H{
+	render(ctx, http.StatusOK, "index.tmpl", gin.H{
 		"title":       "The Pinball Lounge",
 		"description": "The Pinball Lounge in Ovideo, Florida",
 	})
@@ -18,8 +20,8 @@ func handleRoot(ctx *gin.Context) {
 
 func ListenAndServe() {
 	log.Debug("initializing regular expressions")
-	reMachineName = regexp.MustCompile(`(?i)\(.*\)`)
-	reMachineFeatures = regexp.MustCompile(`(?i) play| edition| table | model| game`)
+	reMachineName = db.ReMachineName
+	reMachineFeatures = db.ReMachineFeatures
 	log.Debug("regular expressions initialized")
 
 	log.Debug("initializing gin router")
@@ -30,6 +32,17 @@ func ListenAndServe() {
 		"getMachineFeatureColor": getMachineFeatureColor,
 		"getMachineYear":         getMachineYear,
 		"getMachineImageURL":     getMachineImageURL,
+		"formatTimestamp":        formatTimestamp,
+		"formatDate":             formatDate,
+		"formatScore":            formatScore,
+		"formatTicketState":      formatTicketState,
+		"formatSelectionMode":    formatSelectionMode,
+		"formatTournamentState":  formatTournamentState,
+		"getUsageColor":          getUsageColor,
+		"formatPercent":          formatPercent,
+		"formatMargin":           formatMargin,
+		"formatDifficulty":       formatDifficulty,
+		"getChartPoints":         getChartPoints,
 	})
 	log.Debug("gin router initialized")
 
@@ -38,9 +51,93 @@ func ListenAndServe() {
 	router.LoadHTMLGlob("html/templates/*.tmpl")
 	log.Debug("assets and templates initialized")
 
+	log.Debug("initializing sessions")
+	initializeSessionSecret()
+	log.Debug("sessions initialized")
+
+	log.Debug("initializing photo storage and image cache")
+	storage.InitializePhotoStore()
+	storage.InitializeImageCache()
+	log.Debug("photo storage and image cache initialized")
+
 	log.Debug("initializing endpoints")
-	router.GET("/", handleRoot)
-	router.GET("/machines", handleMachines)
+	pages := router.Group("/", loadSession)
+	pages.GET("/", handleRoot)
+	pages.GET("/machines", handleMachines)
+	pages.GET("/machines/:opdb_id", handleMachine)
+	pages.POST("/machines/:opdb_id/tickets", requireUser, handleCreateTicket)
+	pages.POST("/machines/:opdb_id/out-of-order", requireRole(db.RoleStaff), handleSetOutOfOrder)
+	pages.POST("/machines/:opdb_id/highscores", requireUser, handleSubmitHighScore)
+	pages.GET("/search", handleSearch)
+	pages.GET("/difficulty", handleDifficulty)
+	pages.GET("/players/:id", handlePlayer)
+	pages.GET("/compare", handleCompare)
+	pages.GET("/highscores", handleHighScores)
+	pages.GET("/images/:uuid", handleImage)
+	pages.GET("/photos/:key", handlePhoto)
+	pages.GET("/photos/:key/thumbnail", handlePhotoThumbnail)
+	pages.GET("/matches/:id", handleMatch)
+	pages.POST("/matches/:id/selections", requireUser, handleSelectMachine)
+	pages.GET("/seasons/:id/usage", handleSeasonUsage)
+	pages.GET("/tournaments", handleTournaments)
+	pages.POST("/tournaments", requireRole(db.RoleStaff), handleCreateTournament)
+	pages.GET("/tournaments/:id", handleTournament)
+	pages.POST("/tournaments/:id/check-in", requireUser, handleTournamentCheckIn)
+	pages.POST("/tournaments/:id/check-out", requireUser, handleTournamentCheckOut)
+	pages.POST("/tournaments/:id/rounds", requireRole(db.RoleStaff), handleStartTournamentRound)
+	pages.POST("/tournaments/:id/games/:game_id", requireUser, handleRecordTournamentGame)
+	pages.POST("/tournaments/:id/games/:game_id/machine", requireUser, handlePickTournamentMachine)
+	pages.POST("/tournaments/:id/tickets", requireRole(db.RoleStaff), handleSellTournamentTickets)
+	pages.POST("/tournaments/:id/scores", requireUser, handleSubmitTournamentScore)
+	pages.POST("/tournaments/:id/scores/:score_id/verify", requireRole(db.RoleStaff), handleVerifyTournamentScore)
+	pages.POST("/tournaments/:id/scores/:score_id/reject", requireRole(db.RoleStaff), handleRejectTournamentScore)
+	pages.POST("/tournaments/:id/bracket", requireRole(db.RoleStaff), handleCloseQualifying)
+	pages.GET("/tournaments/:id/matchplay/players", requireRole(db.RoleStaff), handleMatchplayPlayers)
+	pages.GET("/tournaments/:id/matchplay/arenas", requireRole(db.RoleStaff), handleMatchplayArenas)
+	pages.GET("/matchplay", requireRole(db.RoleStaff), handleMatchplay)
+	pages.POST("/matchplay/preview", requireRole(db.RoleStaff), handlePreviewMatchplay)
+	pages.POST("/matchplay/import", requireRole(db.RoleStaff), handleImportMatchplay)
+	pages.POST("/results/:id/photo", requireRole(db.RoleStaff), handleUploadResultPhoto)
+	pages.GET("/login", handleLogin)
+	pages.POST("/login", handleLoginSubmit)
+	pages.GET("/logout", handleLogout)
+	profile := pages.Group("/profile", requireUser)
+	profile.GET("", handleProfile)
+	profile.POST("/tokens", handleCreateApiToken)
+	profile.POST("/tokens/:id/revoke", handleRevokeApiToken)
+	profile.POST("/ifpa", handleUpdateIfpaId)
+	highScores := pages.Group("/highscores", requireRole(db.RoleStaff))
+	highScores.POST("/import", handleImportLeagueHighScores)
+	highScores.POST("/:id/verify", handleVerifyHighScore)
+	highScores.POST("/:id/reject", handleRejectHighScore)
+	maintenance := pages.Group("/maintenance", requireRole(db.RoleStaff))
+	maintenance.GET("", handleMaintenance)
+	maintenance.GET("/:id", handleTicket)
+	maintenance.POST("/:id/notes", handleAddTicketNote)
+	maintenance.POST("/:id/state", handleUpdateTicketState)
+	admin := pages.Group("/admin", requireRole(db.RoleAdmin))
+	admin.GET("/leagues", handleAdminLeagues)
+	admin.POST("/leagues/:id", handleAdminUpdateLeague)
+	admin.POST("/leagues/:id/subs", handleAdminAddLeagueSub)
+	admin.POST("/leagues/:id/subs/:user_id/remove", handleAdminRemoveLeagueSub)
+	admin.POST("/leagues/:id/sub-limit", handleAdminUpdateSubLimit)
+	admin.POST("/seasons/:id/weeks", handleAdminPairSeasonWeek)
+	admin.GET("/lineup", handleAdminLineup)
+	admin.POST("/lineup", handleAdminAddToLineup)
+	admin.POST("/lineup/sync", handleAdminSyncLineup)
+	admin.POST("/lineup/:opdb_id/remove", handleAdminRemoveFromLineup)
+	admin.POST("/lineup/:opdb_id/reset", handleAdminClearLineupOverride)
+	admin.GET("/aliases", handleAdminAliases)
+	admin.POST("/aliases", handleAdminAddAlias)
+	admin.POST("/aliases/delete", handleAdminDeleteAlias)
+	admin.GET("/ifpa", handleAdminIfpa)
+	admin.GET("/ifpa/tournaments/:id", handleAdminIfpaTournament)
+	admin.POST("/ifpa/tournaments/:id", handleAdminIfpaTournament)
+	admin.GET("/ifpa/seasons/:id", handleAdminIfpaSeason)
+	admin.POST("/ifpa/seasons/:id", handleAdminIfpaSeason)
+	admin.POST("/ifpa/players/:id/verify", handleAdminVerifyIfpaId)
+	admin.POST("/ifpa/players/:id/unlink", handleAdminUnlinkIfpaId)
+	api.Initialize(router)
 	log.Debug("endpoints initialized")
 
 	log.Debug("starting gin router")
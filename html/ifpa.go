@@ -0,0 +1,200 @@
+package html
+
+import (
+	"bytes"
+	"database/sql"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikefero/tpl/db"
+	"github.com/mikefero/tpl/ifpa"
+)
+
+var reFileName = regexp.MustCompile(`[^a-z0-9]+`)
+
+// ifpaExport is a completed tournament or ended season offered for export
+type ifpaExport struct {
+	Name string
+	Path string
+	Date sql.NullInt64
+}
+
+// ifpaPlayer is a player with a linked IFPA number and the ranking of that
+// number in the last snapshot, if any.
+type ifpaPlayer struct {
+	User    db.User
+	Ranking *db.IfpaRanking
+}
+
+func renderAdminIfpa(ctx *gin.Context, status int, data gin.H) {
+	var exports []ifpaExport
+	for _, t := range db.GetTournaments(db.CountTournaments(), 0) {
+		if t.State == db.TournamentStateCompleted {
+			exports = append(exports, ifpaExport{
+				Name: t.Name,
+				Path: "/admin/ifpa/tournaments/" + strconv.Itoa(t.Id),
+				Date: sql.NullInt64{Int64: t.CreatedAt, Valid: true},
+			})
+		}
+	}
+	for _, league := range db.GetLeagues(sql.NullBool{}, db.CountLeagues(sql.NullBool{}), 0) {
+		for _, season := range db.GetSeasons(league.Id, db.CountSeasons(league.Id), 0) {
+			if season.EndDate.Valid && season.EndDate.Int64 <= time.Now().Unix() {
+				exports = append(exports, ifpaExport{
+					Name: league.Name + " " + season.Name,
+					Path: "/admin/ifpa/seasons/" + strconv.Itoa(season.Id),
+					Date: season.EndDate,
+				})
+			}
+		}
+	}
+
+	var players []ifpaPlayer
+	for _, user := range db.GetIfpaUsers() {
+		players = append(players, ifpaPlayer{
+			User:    user,
+			Ranking: db.GetIfpaRanking(user.IfpaId.Int64),
+		})
+	}
+
+	data["title"] = "IFPA"
+	data["description"] = "Export tournament and season results for IFPA"
+	data["exports"] = exports
+	data["players"] = players
+	data["snapshot"] = db.GetIfpaRankingsSnapshot()
+	render(ctx, status, "admin_ifpa.tmpl", data)
+}
+
+func handleAdminIfpa(ctx *gin.Context) {
+	renderAdminIfpa(ctx, http.StatusOK, gin.H{})
+}
+
+func handleAdminVerifyIfpaId(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil || !db.VerifyUserIfpaId(id) {
+		renderAdminIfpa(ctx, http.StatusNotFound, gin.H{
+			"error": "Player has no IFPA number to verify",
+		})
+		return
+	}
+
+	ctx.Redirect(http.StatusSeeOther, "/admin/ifpa")
+}
+
+// handleAdminUnlinkIfpaId removes an IFPA number that does not belong to the
+// player who linked it.
+func handleAdminUnlinkIfpaId(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil || !db.SetUserIfpaId(id, sql.NullInt64{}) {
+		renderAdminIfpa(ctx, http.StatusNotFound, gin.H{
+			"error": "Player not found",
+		})
+		return
+	}
+
+	ctx.Redirect(http.StatusSeeOther, "/admin/ifpa")
+}
+
+func renderAdminIfpaExport(ctx *gin.Context, status int, name string, results []ifpa.Result, data gin.H) {
+	data["title"] = "IFPA Export"
+	data["description"] = "Export the results of " + name + " for IFPA"
+	data["name"] = name
+	data["results"] = results
+	if _, exists := data["problems"]; !exists {
+		data["problems"] = map[int]string{}
+	}
+	render(ctx, status, "admin_ifpa.tmpl", data)
+}
+
+// exportIfpaResults shows the results of an event with their IFPA numbers and
+// new player flags on GET and downloads them as a CSV file on POST once every
+// player has one or the other.
+func exportIfpaResults(ctx *gin.Context, name string, results []ifpa.Result, err error) {
+	if err != nil {
+		renderAdminIfpaExport(ctx, http.StatusUnprocessableEntity, name, nil, gin.H{
+			"error": strings.ToUpper(err.Error()[:1]) + err.Error()[1:],
+		})
+		return
+	}
+	if ctx.Request.Method != http.MethodPost {
+		renderAdminIfpaExport(ctx, http.StatusOK, name, results, gin.H{})
+		return
+	}
+
+	problems := map[int]string{}
+	for i := range results {
+		id := strconv.Itoa(results[i].UserId)
+		results[i].NewPlayer = ctx.PostForm("new_"+id) == "true"
+		if value := strings.TrimSpace(ctx.PostForm("ifpa_" + id)); len(value) > 0 {
+			ifpaId, err := strconv.Atoi(value)
+			if err != nil || ifpaId < 1 {
+				problems[results[i].UserId] = "IFPA numbers are positive whole numbers"
+				continue
+			}
+			results[i].IfpaId = ifpaId
+		}
+	}
+	for userId, problem := range ifpa.Validate(results) {
+		if _, exists := problems[userId]; !exists {
+			problems[userId] = problem
+		}
+	}
+	if len(problems) > 0 {
+		renderAdminIfpaExport(ctx, http.StatusUnprocessableEntity, name, results, gin.H{
+			"error":    "Every player needs an IFPA number or the new player flag",
+			"problems": problems,
+		})
+		return
+	}
+
+	var buffer bytes.Buffer
+	if err := ifpa.WriteCSV(&buffer, results); err != nil {
+		renderAdminIfpaExport(ctx, http.StatusInternalServerError, name, results, gin.H{
+			"error": "Unable to write the IFPA export",
+		})
+		return
+	}
+	fileName := strings.Trim(reFileName.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	ctx.Header("Content-Disposition", `attachment; filename="`+fileName+`-ifpa.csv"`)
+	ctx.Data(http.StatusOK, "text/csv; charset=utf-8", buffer.Bytes())
+}
+
+func handleAdminIfpaTournament(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	t := db.GetTournament(id)
+	if t == nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	results, err := ifpa.GetTournamentResults(*t)
+	exportIfpaResults(ctx, t.Name, results, err)
+}
+
+func handleAdminIfpaSeason(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	season := db.GetSeason(id)
+	if season == nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	name := season.Name
+	if league := db.GetLeague(season.LeagueId); league != nil {
+		name = league.Name + " " + season.Name
+	}
+
+	results, err := ifpa.GetSeasonResults(*season)
+	exportIfpaResults(ctx, name, results, err)
+}
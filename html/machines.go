@@ -4,11 +4,14 @@ import (
 	"database/sql"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mikefero/tpl/db"
+	"github.com/mikefero/tpl/stats"
+	"github.com/mikefero/tpl/storage"
 )
 
 var reMachineName *regexp.Regexp
@@ -29,6 +32,20 @@ func getMachineFeatures(id sql.NullInt64) string {
 	return strings.TrimSpace(features)
 }
 
+func getMachineFeatureList(id sql.NullInt64) []string {
+	var features []string
+	if id.Valid {
+		for _, feature := range strings.Split(db.GetFeatures(int(id.Int64)), ",") {
+			feature = strings.TrimSpace(reMachineFeatures.ReplaceAllString(feature, ""))
+			if len(feature) > 0 {
+				features = append(features, feature)
+			}
+		}
+	}
+
+	return features
+}
+
 func getMachineFeatureColor(id sql.NullInt64) string {
 	var featuresColor string
 	features := getMachineFeatures(id)
@@ -58,18 +75,91 @@ func getMachineYear(timestamp sql.NullInt64) int {
 }
 
 func getMachineImageURL(uuid sql.NullString) string {
-	var imageUrl = "http://www.thepinballlounge.com/pb/wp_0fa0cf0b/images/img165275761bbe29f98e.gif"
+	var imageUrl = "/images/" + storage.PlaceholderImage
 	if uuid.Valid {
-		imageUrl = "https://img.opdb.org/" + uuid.String + "-medium.jpg"
+		imageUrl = "/images/" + uuid.String
 	}
 
 	return imageUrl
 }
 
+func getMachineFilter(ctx *gin.Context) db.MachineFilter {
+	filter := db.MachineFilter{
+		Name:  strings.TrimSpace(ctx.Query("q")),
+		Sort:  db.SortMachinesByName,
+		Limit: -1,
+	}
+	filter.ManufacturerId, _ = strconv.Atoi(ctx.Query("manufacturer"))
+	filter.Decade, _ = strconv.Atoi(ctx.Query("decade"))
+	if edition := db.GetEdition(ctx.Query("edition")); edition != nil {
+		filter.Feature = edition.Feature
+	}
+	if sort := ctx.Query("sort"); db.IsMachineSort(sort) {
+		filter.Sort = sort
+	}
+
+	return filter
+}
+
 func handleMachines(ctx *gin.Context) {
-	ctx.HTML(http.StatusOK, "machines.tmpl", gin.H{
-		"title":       "Available Pinball Machines",
-		"description": "Available pinball machines at The Pinball Lounge in Ovideo, Florida",
-		"machines":    db.GetAllActiveMachines(),
+	render(ctx, http.StatusOK, "machines.tmpl", gin.H{
+		"title":         "Available Pinball Machines",
+		"description":   "Available pinball machines at The Pinball Lounge in Ovideo, Florida",
+		"machines":      db.GetActiveMachines(getMachineFilter(ctx)),
+		"manufacturers": db.GetActiveManufacturers(),
+		"decades":       db.GetActiveDecades(),
+		"editions":      db.Editions,
+		"query":         ctx.Request.URL.Query(),
+	})
+}
+
+func handleMachine(ctx *gin.Context) {
+	machine := db.GetMachine(ctx.Param("opdb_id"))
+	if machine == nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	name := getMachineName(machine.Name)
+	render(ctx, http.StatusOK, "machine.tmpl", gin.H{
+		"title":               name,
+		"description":         name + " at The Pinball Lounge in Ovideo, Florida",
+		"machine":             machine,
+		"manufacturer":        db.GetManufacturer(machine.ManufacturerId),
+		"metadata":            db.GetMachineMetadata(machine.OpdbId),
+		"features":            getMachineFeatureList(machine.FeaturesId),
+		"lineup":              db.GetMachineLineupHistory(machine.OpdbId),
+		"gamesPlayed":         db.CountMachineGames(machine.OpdbId),
+		"games":               db.GetMachineGames(machine.OpdbId, 10),
+		"topScores":           db.GetMachineTopScores(machine.OpdbId, 10),
+		"averageWinningScore": db.GetMachineAverageWinningScore(machine.OpdbId),
+		"difficulty":          stats.GetMachineDifficulty(machine.OpdbId),
+		"tickets":             db.GetTickets("", machine.OpdbId),
+		"highScores":          db.GetHighScoreBoard(machine.OpdbId),
+		"submitted":           ctx.Query("submitted") == "true",
+	})
+}
+
+func handleDifficulty(ctx *gin.Context) {
+	render(ctx, http.StatusOK, "difficulty.tmpl", gin.H{
+		"title":        "Machine Difficulty",
+		"description":  "Swingy and skill-heavy pinball machines at The Pinball Lounge from league results",
+		"difficulties": stats.GetMachineDifficulties(),
+		"minimum":      stats.MinimumRatedMatchups,
+	})
+}
+
+func handleSearch(ctx *gin.Context) {
+	query := strings.TrimSpace(ctx.Query("q"))
+	var results []db.SearchResult
+	if len(query) > 0 {
+		results = db.SearchMachines(query, 50, 0)
+	}
+
+	render(ctx, http.StatusOK, "search.tmpl", gin.H{
+		"title":       "Search Pinball Machines",
+		"description": "Search the Open Pinball Database catalog",
+		"q":           query,
+		"results":     results,
 	})
 }
@@ -0,0 +1,120 @@
+package html
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikefero/tpl/db"
+)
+
+func handleCreateTicket(ctx *gin.Context) {
+	opdbId := ctx.Param("opdb_id")
+	description := strings.TrimSpace(ctx.PostForm("description"))
+	if len(description) == 0 || db.GetMachine(opdbId) == nil {
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if db.CreateTicket(opdbId, getSessionUser(ctx).Id, description) == nil {
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Redirect(http.StatusSeeOther, "/machines/"+opdbId)
+}
+
+func handleSetOutOfOrder(ctx *gin.Context) {
+	opdbId := ctx.Param("opdb_id")
+	outOfOrder, err := strconv.ParseBool(ctx.PostForm("out_of_order"))
+	if err != nil {
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if !db.SetMachineOutOfOrder(opdbId, outOfOrder) {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	ctx.Redirect(http.StatusSeeOther, "/machines/"+opdbId)
+}
+
+func handleMaintenance(ctx *gin.Context) {
+	state := ctx.Query("state")
+	if len(state) > 0 && !db.IsTicketState(state) {
+		state = ""
+	}
+
+	render(ctx, http.StatusOK, "maintenance.tmpl", gin.H{
+		"title":       "Maintenance",
+		"description": "Machine maintenance tickets at The Pinball Lounge",
+		"states":      db.TicketStates,
+		"state":       state,
+		"tickets":     db.GetTickets(state, ""),
+	})
+}
+
+func getTicket(ctx *gin.Context) *db.Ticket {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return nil
+	}
+	ticket := db.GetTicket(id)
+	if ticket == nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+	}
+	return ticket
+}
+
+func handleTicket(ctx *gin.Context) {
+	ticket := getTicket(ctx)
+	if ticket == nil {
+		return
+	}
+
+	render(ctx, http.StatusOK, "ticket.tmpl", gin.H{
+		"title":       getMachineName(ticket.MachineName) + " Maintenance",
+		"description": "Maintenance ticket for " + getMachineName(ticket.MachineName),
+		"ticket":      ticket,
+		"machine":     db.GetMachine(ticket.OpdbId),
+		"notes":       db.GetTicketNotes(ticket.Id),
+		"states":      db.TicketStates,
+	})
+}
+
+func handleAddTicketNote(ctx *gin.Context) {
+	ticket := getTicket(ctx)
+	if ticket == nil {
+		return
+	}
+	note := strings.TrimSpace(ctx.PostForm("note"))
+	if len(note) == 0 {
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if !db.AddTicketNote(ticket.Id, getSessionUser(ctx).Id, note) {
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Redirect(http.StatusSeeOther, "/maintenance/"+strconv.Itoa(ticket.Id))
+}
+
+func handleUpdateTicketState(ctx *gin.Context) {
+	ticket := getTicket(ctx)
+	if ticket == nil {
+		return
+	}
+	state := ctx.PostForm("state")
+	if !db.IsTicketState(state) {
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if !db.UpdateTicketState(ticket.Id, state) {
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Redirect(http.StatusSeeOther, "/maintenance/"+strconv.Itoa(ticket.Id))
+}
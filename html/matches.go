@@ -0,0 +1,143 @@
+package html
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikefero/tpl/db"
+)
+
+func getMatch(ctx *gin.Context) *db.Match {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return nil
+	}
+	match := db.GetMatch(id)
+	if match == nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+	}
+	return match
+}
+
+func renderMatch(ctx *gin.Context, status int, match *db.Match, data gin.H) {
+	league := db.GetLeague(match.LeagueId)
+	if league == nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	team1 := db.GetTeam(match.Team1Id)
+	var team2 *db.Team
+	if match.Team2Id.Valid {
+		team2 = db.GetTeam(int(match.Team2Id.Int64))
+	}
+	if team1 == nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	selections := db.GetMatchSelections(match.Id)
+	game := len(selections) + 1
+	pickingTeam := team1
+	if pickingTeamId := db.GetPickingTeam(league.SelectionMode, *match, game); pickingTeamId.Valid &&
+		pickingTeamId.Int64 != int64(team1.Id) {
+		pickingTeam = team2
+	}
+	// Underplayed machines are listed first to balance usage over the season
+	usage := db.GetSeasonUsage(match.SeasonId)
+	var machines []db.Machine
+	for _, opdbId := range db.GetAvailableMachines(*league, *match, selections) {
+		if machine := db.GetMachine(opdbId); machine != nil {
+			machines = append(machines, *machine)
+		}
+	}
+	sort.SliceStable(machines, func(i, j int) bool {
+		if usage[machines[i].OpdbId] != usage[machines[j].OpdbId] {
+			return usage[machines[i].OpdbId] < usage[machines[j].OpdbId]
+		}
+		return getMachineName(machines[i].Name) < getMachineName(machines[j].Name)
+	})
+
+	data["title"] = team1.Name + " Match"
+	data["description"] = "Machine selections for a " + league.Name + " match"
+	data["match"] = match
+	data["league"] = league
+	data["team1"] = team1
+	data["team2"] = team2
+	data["selections"] = selections
+	data["game"] = game
+	data["pickingTeam"] = pickingTeam
+	data["machines"] = machines
+	data["usage"] = usage
+	render(ctx, status, "match.tmpl", data)
+}
+
+func handleMatch(ctx *gin.Context) {
+	match := getMatch(ctx)
+	if match == nil {
+		return
+	}
+
+	renderMatch(ctx, http.StatusOK, match, gin.H{})
+}
+
+func handleSelectMachine(ctx *gin.Context) {
+	match := getMatch(ctx)
+	if match == nil {
+		return
+	}
+
+	opdbId := strings.TrimSpace(ctx.PostForm("opdb_id"))
+	if _, err := db.SelectMachine(*match, *getSessionUser(ctx), opdbId); err != nil {
+		status := http.StatusUnprocessableEntity
+		if err == db.ErrSelectionNotPlayer || err == db.ErrSelectionWrongSide {
+			status = http.StatusForbidden
+		}
+		renderMatch(ctx, status, match, gin.H{
+			"error": strings.ToUpper(err.Error()[:1]) + err.Error()[1:],
+		})
+		return
+	}
+
+	ctx.Redirect(http.StatusSeeOther, "/matches/"+strconv.Itoa(match.Id))
+}
+
+func handleSeasonUsage(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	season := db.GetSeason(id)
+	if season == nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	league := db.GetLeague(season.LeagueId)
+	if league == nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	usage := db.GetSeasonMachineUsage(season.Id)
+	busiestWeek := 0
+	for _, machine := range usage {
+		for _, games := range machine.Weeks {
+			if games > busiestWeek {
+				busiestWeek = games
+			}
+		}
+	}
+	render(ctx, http.StatusOK, "usage.tmpl", gin.H{
+		"title":       season.Name + " Machine Usage",
+		"description": "Machine usage per week during the " + league.Name + " " + season.Name + " season",
+		"season":      season,
+		"league":      league,
+		"weeks":       db.GetSeasonWeeks(season.Id),
+		"usage":       usage,
+		"busiestWeek": busiestWeek,
+	})
+}
@@ -0,0 +1,218 @@
+package html
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikefero/tpl/db"
+	"github.com/mikefero/tpl/matchplay"
+)
+
+// matchplayPlayer is a Matchplay player with the user it is mapped to, zero
+// when no player has a close name.
+type matchplayPlayer struct {
+	Player matchplay.Player
+	UserId int
+}
+
+// matchplayArena is a Matchplay arena with the machine it is mapped to and
+// the number of games played on it.
+type matchplayArena struct {
+	Arena  matchplay.Arena
+	OpdbId string
+	Games  int
+}
+
+func getLineup() []db.Machine {
+	return db.GetActiveMachines(db.MachineFilter{
+		Sort:  db.SortMachinesByName,
+		Limit: -1,
+	})
+}
+
+func renderMatchplay(ctx *gin.Context, status int, data gin.H) {
+	data["title"] = "Matchplay Import"
+	data["description"] = "Import a Matchplay tournament"
+	render(ctx, status, "matchplay.tmpl", data)
+}
+
+// renderMatchplayPreview shows how the players and arenas of a Matchplay
+// export map to players and machines so that staff can correct the mapping
+// before importing it.
+func renderMatchplayPreview(ctx *gin.Context, status int, export string, t *matchplay.Tournament, players map[int64]int, arenas map[int64]string, data gin.H) {
+	var mappedPlayers []matchplayPlayer
+	for _, player := range t.Players {
+		mappedPlayers = append(mappedPlayers, matchplayPlayer{
+			Player: player,
+			UserId: players[player.PlayerId],
+		})
+	}
+	games := map[int64]int{}
+	for _, game := range t.Games {
+		games[game.ArenaId]++
+	}
+	machines := getLineup()
+	inLineup := map[string]bool{}
+	for _, machine := range machines {
+		inLineup[machine.OpdbId] = true
+	}
+	var mappedArenas []matchplayArena
+	for _, arena := range t.Arenas {
+		mappedArenas = append(mappedArenas, matchplayArena{
+			Arena:  arena,
+			OpdbId: arenas[arena.ArenaId],
+			Games:  games[arena.ArenaId],
+		})
+		if opdbId := arenas[arena.ArenaId]; len(opdbId) > 0 && !inLineup[opdbId] {
+			if machine := db.GetMachine(opdbId); machine != nil {
+				machines = append(machines, *machine)
+				inLineup[opdbId] = true
+			}
+		}
+	}
+	format, err := matchplay.GetFormat(t.Type)
+	if err == nil && format == db.TournamentFormatStrikes && t.StrikeLimit < 1 {
+		format, err = "", matchplay.ErrMissingStrikeLimit
+	}
+	if err != nil {
+		data["error"] = strings.ToUpper(err.Error()[:1]) + err.Error()[1:]
+	}
+
+	data["export"] = export
+	data["tournament"] = t
+	data["format"] = format
+	data["players"] = mappedPlayers
+	data["arenas"] = mappedArenas
+	data["users"] = db.GetActiveUsers()
+	data["machines"] = machines
+	renderMatchplay(ctx, status, data)
+}
+
+func handleMatchplay(ctx *gin.Context) {
+	renderMatchplay(ctx, http.StatusOK, gin.H{})
+}
+
+// handlePreviewMatchplay reads an uploaded Matchplay tournament export and
+// maps its players and arenas by name.
+func handlePreviewMatchplay(ctx *gin.Context) {
+	header, err := ctx.FormFile("export")
+	if err != nil {
+		renderMatchplay(ctx, http.StatusBadRequest, gin.H{
+			"error": "A Matchplay tournament export is required",
+		})
+		return
+	}
+	if header.Size > matchplay.MaxExportSize {
+		renderMatchplay(ctx, http.StatusRequestEntityTooLarge, gin.H{
+			"error": "The Matchplay tournament export is too large",
+		})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		renderMatchplay(ctx, http.StatusBadRequest, gin.H{
+			"error": "Unable to read the Matchplay tournament export",
+		})
+		return
+	}
+	defer file.Close()
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		renderMatchplay(ctx, http.StatusBadRequest, gin.H{
+			"error": "Unable to read the Matchplay tournament export",
+		})
+		return
+	}
+	t, err := matchplay.Parse(data)
+	if err != nil {
+		renderMatchplay(ctx, http.StatusUnprocessableEntity, gin.H{
+			"error": strings.ToUpper(err.Error()[:1]) + err.Error()[1:],
+		})
+		return
+	}
+
+	players := matchplay.MatchPlayers(t.Players, db.GetActiveUsers())
+	arenas := matchplay.MatchArenas(t.Arenas)
+	renderMatchplayPreview(ctx, http.StatusOK, string(data), t, players, arenas, gin.H{})
+}
+
+// handleImportMatchplay imports a previewed Matchplay tournament export with
+// the players and arenas mapped by staff.
+func handleImportMatchplay(ctx *gin.Context) {
+	export := ctx.PostForm("export")
+	t, err := matchplay.Parse([]byte(export))
+	if err != nil {
+		renderMatchplay(ctx, http.StatusUnprocessableEntity, gin.H{
+			"error": strings.ToUpper(err.Error()[:1]) + err.Error()[1:],
+		})
+		return
+	}
+	players := map[int64]int{}
+	for _, player := range t.Players {
+		userId, err := strconv.Atoi(ctx.PostForm("player_" + strconv.FormatInt(player.PlayerId, 10)))
+		if err != nil {
+			continue
+		}
+		if user := db.GetUser(userId); user != nil && user.Active {
+			players[player.PlayerId] = user.Id
+		}
+	}
+	arenas := map[int64]string{}
+	for _, arena := range t.Arenas {
+		opdbId := ctx.PostForm("arena_" + strconv.FormatInt(arena.ArenaId, 10))
+		if len(opdbId) > 0 && db.GetMachine(opdbId) != nil {
+			arenas[arena.ArenaId] = opdbId
+		}
+	}
+
+	imported, err := matchplay.Import(*t, players, arenas, getSessionUser(ctx).Id)
+	if err != nil {
+		renderMatchplayPreview(ctx, http.StatusUnprocessableEntity, export, t, players, arenas, gin.H{
+			"error": strings.ToUpper(err.Error()[:1]) + err.Error()[1:],
+		})
+		return
+	}
+	ctx.Redirect(http.StatusSeeOther, "/tournaments/"+strconv.Itoa(imported.Id))
+}
+
+// sendMatchplayList downloads a Matchplay list of a tournament as a text
+// file.
+func sendMatchplayList(ctx *gin.Context, t *db.Tournament, list string, names []string) {
+	var buffer bytes.Buffer
+	if err := matchplay.WriteList(&buffer, names); err != nil {
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	fileName := strings.Trim(reFileName.ReplaceAllString(strings.ToLower(t.Name), "-"), "-")
+	ctx.Header("Content-Disposition", `attachment; filename="`+fileName+`-`+list+`.txt"`)
+	ctx.Data(http.StatusOK, "text/plain; charset=utf-8", buffer.Bytes())
+}
+
+func handleMatchplayPlayers(ctx *gin.Context) {
+	t := getTournament(ctx)
+	if t == nil {
+		return
+	}
+
+	sendMatchplayList(ctx, t, "players", matchplay.GetPlayerList(db.GetTournamentPlayers(t.Id)))
+}
+
+// handleMatchplayArenas lists the machines of the lineup that are in service.
+func handleMatchplayArenas(ctx *gin.Context) {
+	t := getTournament(ctx)
+	if t == nil {
+		return
+	}
+
+	var machines []db.Machine
+	for _, machine := range getLineup() {
+		if !machine.OutOfOrder {
+			machines = append(machines, machine)
+		}
+	}
+	sendMatchplayList(ctx, t, "arenas", matchplay.GetArenaList(machines))
+}
@@ -0,0 +1,131 @@
+package html
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikefero/tpl/db"
+	"github.com/mikefero/tpl/log"
+	"github.com/mikefero/tpl/storage"
+)
+
+// getPhotoUpload stores the photo uploaded in a form field; the photo is
+// optional so a missing field results in an invalid key without an error.
+func getPhotoUpload(ctx *gin.Context, field string) (sql.NullString, error) {
+	header, err := ctx.FormFile(field)
+	if err == http.ErrMissingFile {
+		return sql.NullString{}, nil
+	}
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	if header.Size > storage.MaxPhotoSize {
+		return sql.NullString{}, storage.ErrPhotoTooLarge
+	}
+	file, err := header.Open()
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	defer file.Close()
+
+	key, err := storage.SavePhoto(file)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{
+		String: key,
+		Valid:  true,
+	}, nil
+}
+
+// serveJPEG writes a stored image; stored images are never modified so they
+// can be cached indefinitely.
+func serveJPEG(ctx *gin.Context, image io.ReadCloser) {
+	defer image.Close()
+	ctx.Header("Cache-Control", "public, max-age=31536000, immutable")
+	ctx.Header("Content-Type", "image/jpeg")
+	ctx.Status(http.StatusOK)
+	io.Copy(ctx.Writer, image)
+}
+
+func servePhoto(ctx *gin.Context, thumbnail bool) {
+	photo, err := storage.OpenPhoto(ctx.Param("key"), thumbnail)
+	if err != nil {
+		if err != storage.ErrBlobNotFound {
+			log.WithFields(log.Fields{
+				"key":   ctx.Param("key"),
+				"error": err,
+			}).Error("unable to open photo")
+		}
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	serveJPEG(ctx, photo)
+}
+
+// handleImage serves backglass images from the image cache; the placeholder
+// is served instead when an image cannot be fetched from upstream.
+func handleImage(ctx *gin.Context) {
+	uuid := ctx.Param("uuid")
+	size := ctx.DefaultQuery("size", storage.ImageSizeMedium)
+	if !storage.IsImageUuid(uuid) || !storage.IsImageSize(size) {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	image, err := storage.OpenImage(uuid, size)
+	if err != nil && uuid != storage.PlaceholderImage {
+		log.WithFields(log.Fields{
+			"uuid":  uuid,
+			"error": err,
+		}).Warn("unable to open image; serving placeholder")
+		image, err = storage.OpenImage(storage.PlaceholderImage, size)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"uuid":  uuid,
+			"error": err,
+		}).Warn("unable to open image")
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	serveJPEG(ctx, image)
+}
+
+func handlePhoto(ctx *gin.Context) {
+	servePhoto(ctx, false)
+}
+
+func handlePhotoThumbnail(ctx *gin.Context) {
+	servePhoto(ctx, true)
+}
+
+func handleUploadResultPhoto(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	result := db.GetResult(id)
+	if result == nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	photoKey, err := getPhotoUpload(ctx, "photo")
+	if err != nil || !photoKey.Valid {
+		ctx.String(http.StatusBadRequest, "Unable to upload photo; a JPEG, PNG or GIF image no larger than 10 MB is required")
+		ctx.Abort()
+		return
+	}
+	if !db.SetResultPhoto(result.Id, photoKey.String) {
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Redirect(http.StatusSeeOther, "/machines/"+result.OpdbId)
+}
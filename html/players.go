@@ -0,0 +1,62 @@
+package html
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikefero/tpl/db"
+	"github.com/mikefero/tpl/stats"
+)
+
+type playerTeam struct {
+	Team       db.Team
+	LeagueName string
+}
+
+// getPlayerNames returns the names of players by ID for the opponents listed
+// on a profile.
+func getPlayerNames(opponents []stats.OpponentRecord) map[int]string {
+	names := map[int]string{}
+	for _, opponent := range opponents {
+		if user := db.GetUser(opponent.OpponentId); user != nil {
+			names[opponent.OpponentId] = user.Name
+		}
+	}
+	return names
+}
+
+func handlePlayer(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	player := db.GetUser(id)
+	if player == nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	var teams []playerTeam
+	for _, team := range db.GetPlayerTeams(player.Id) {
+		membership := playerTeam{
+			Team: team,
+		}
+		if league := db.GetLeague(team.LeagueId); league != nil {
+			membership.LeagueName = league.Name
+		}
+		teams = append(teams, membership)
+	}
+	playerStats := stats.GetPlayer(player.Id)
+
+	render(ctx, http.StatusOK, "player.tmpl", gin.H{
+		"title":       player.Name,
+		"description": "League record of " + player.Name + " at The Pinball Lounge",
+		"player":      player,
+		"teams":       teams,
+		"stats":       playerStats,
+		"names":       getPlayerNames(playerStats.Opponents),
+		"ranking":     db.GetUserIfpaRanking(*player),
+	})
+}
@@ -0,0 +1,101 @@
+package html
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikefero/tpl/db"
+)
+
+func renderProfile(ctx *gin.Context, status int, data gin.H) {
+	user := getSessionUser(ctx)
+	data["title"] = user.Name
+	data["description"] = "Player profile for " + user.Name
+	data["tokens"] = db.GetApiTokens(user.Id)
+	data["scopes"] = db.GetScopes(user.Role)
+	if user.IfpaId.Valid {
+		data["ranking"] = db.GetIfpaRanking(user.IfpaId.Int64)
+	}
+	render(ctx, status, "profile.tmpl", data)
+}
+
+func handleProfile(ctx *gin.Context) {
+	renderProfile(ctx, http.StatusOK, gin.H{})
+}
+
+func handleCreateApiToken(ctx *gin.Context) {
+	name := strings.TrimSpace(ctx.PostForm("name"))
+	scopes := ctx.PostFormArray("scopes")
+	if len(name) == 0 || len(scopes) == 0 {
+		renderProfile(ctx, http.StatusBadRequest, gin.H{
+			"error": "A token requires a name and at least one scope",
+		})
+		return
+	}
+	for _, scope := range scopes {
+		if !db.CanUseScope(getSessionUser(ctx).Role, scope) {
+			renderProfile(ctx, http.StatusBadRequest, gin.H{
+				"error": "Scope " + scope + " is not available",
+			})
+			return
+		}
+	}
+
+	secret, token := db.CreateApiToken(getSessionUser(ctx).Id, name, scopes)
+	if token == nil {
+		renderProfile(ctx, http.StatusInternalServerError, gin.H{
+			"error": "Unable to create token",
+		})
+		return
+	}
+	renderProfile(ctx, http.StatusCreated, gin.H{
+		"secret": secret,
+	})
+}
+
+func handleRevokeApiToken(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil || !db.RevokeApiToken(getSessionUser(ctx).Id, id) {
+		renderProfile(ctx, http.StatusNotFound, gin.H{
+			"error": "Token not found",
+		})
+		return
+	}
+
+	ctx.Redirect(http.StatusSeeOther, "/profile")
+}
+
+// handleUpdateIfpaId links the IFPA number of the player, or unlinks it when
+// left empty; a changed number waits for an admin to verify it again.
+func handleUpdateIfpaId(ctx *gin.Context) {
+	user := getSessionUser(ctx)
+	var ifpaId sql.NullInt64
+	if value := strings.TrimSpace(ctx.PostForm("ifpa_id")); len(value) > 0 {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id < 1 {
+			renderProfile(ctx, http.StatusBadRequest, gin.H{
+				"ifpaError": "IFPA numbers are positive whole numbers",
+			})
+			return
+		}
+		ifpaId = sql.NullInt64{
+			Int64: id,
+			Valid: true,
+		}
+	}
+	if ifpaId == user.IfpaId {
+		ctx.Redirect(http.StatusSeeOther, "/profile")
+		return
+	}
+
+	if !db.SetUserIfpaId(user.Id, ifpaId) {
+		renderProfile(ctx, http.StatusConflict, gin.H{
+			"ifpaError": "IFPA number " + strconv.FormatInt(ifpaId.Int64, 10) + " is linked to another player",
+		})
+		return
+	}
+	ctx.Redirect(http.StatusSeeOther, "/profile")
+}
@@ -0,0 +1,174 @@
+package html
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikefero/tpl/db"
+	"github.com/mikefero/tpl/log"
+)
+
+const sessionCookieName = "tpl_session"
+const sessionDuration = 14 * 24 * time.Hour
+const sessionUserKey = "user"
+
+var sessionSecret []byte
+
+func initializeSessionSecret() {
+	if secret := os.Getenv("TPL_SESSION_SECRET"); len(secret) > 0 {
+		sessionSecret = []byte(secret)
+		return
+	}
+
+	log.Warn("TPL_SESSION_SECRET is not set; sessions will not survive a restart")
+	sessionSecret = make([]byte, 32)
+	if _, err := rand.Read(sessionSecret); err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Panic("unable to generate session secret")
+	}
+}
+
+func signSession(value string) string {
+	mac := hmac.New(sha256.New, sessionSecret)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func setSession(ctx *gin.Context, user *db.User) {
+	expires := time.Now().Add(sessionDuration).Unix()
+	value := fmt.Sprintf("%d.%d", user.Id, expires)
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(sessionCookieName, value+"."+signSession(value), int(sessionDuration.Seconds()), "/", "", false, true)
+}
+
+func clearSession(ctx *gin.Context) {
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(sessionCookieName, "", -1, "/", "", false, true)
+}
+
+// loadSession resolves the user of a signed session cookie, if any, so that
+// handlers and templates can access the logged in user.
+func loadSession(ctx *gin.Context) {
+	cookie, err := ctx.Cookie(sessionCookieName)
+	if err != nil {
+		return
+	}
+
+	parts := strings.Split(cookie, ".")
+	if len(parts) != 3 {
+		return
+	}
+	value := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(signSession(value)), []byte(parts[2])) {
+		log.WithFields(log.Fields{
+			"cookie": cookie,
+		}).Warn("session cookie signature mismatch")
+		return
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return
+	}
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return
+	}
+
+	if user := db.GetUser(id); user != nil && user.Active {
+		ctx.Set(sessionUserKey, user)
+	}
+}
+
+func getSessionUser(ctx *gin.Context) *db.User {
+	if value, exists := ctx.Get(sessionUserKey); exists {
+		return value.(*db.User)
+	}
+	return nil
+}
+
+func requireUser(ctx *gin.Context) {
+	if getSessionUser(ctx) == nil {
+		ctx.Redirect(http.StatusSeeOther, "/login?next="+url.QueryEscape(ctx.Request.URL.RequestURI()))
+		ctx.Abort()
+	}
+}
+
+func requireRole(role string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user := getSessionUser(ctx)
+		if user == nil {
+			requireUser(ctx)
+			return
+		}
+		if !user.HasRole(role) {
+			ctx.String(http.StatusForbidden, http.StatusText(http.StatusForbidden))
+			ctx.Abort()
+		}
+	}
+}
+
+// render executes a template with the logged in user available to the
+// header.
+func render(ctx *gin.Context, status int, name string, data gin.H) {
+	data["user"] = getSessionUser(ctx)
+	ctx.HTML(status, name, data)
+}
+
+// isLocalPath tells whether a redirect target stays on this site; browsers
+// read a backslash as a slash and drop tabs and newlines, so targets such as
+// /\evil.com lead to another host.
+func isLocalPath(next string) bool {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.ContainsAny(next, "\\\t\r\n") {
+		return false
+	}
+	target, err := url.Parse(next)
+	return err == nil && len(target.Host) == 0 && len(target.Scheme) == 0
+}
+
+func getRedirect(ctx *gin.Context) string {
+	next := ctx.Query("next")
+	if !isLocalPath(next) {
+		return "/profile"
+	}
+	return next
+}
+
+func handleLogin(ctx *gin.Context) {
+	render(ctx, http.StatusOK, "login.tmpl", gin.H{
+		"title":       "Login",
+		"description": "Login to The Pinball Lounge",
+		"next":        getRedirect(ctx),
+	})
+}
+
+func handleLoginSubmit(ctx *gin.Context) {
+	user := db.AuthenticateUser(ctx.PostForm("email"), ctx.PostForm("password"))
+	if user == nil {
+		render(ctx, http.StatusUnauthorized, "login.tmpl", gin.H{
+			"title":       "Login",
+			"description": "Login to The Pinball Lounge",
+			"next":        getRedirect(ctx),
+			"error":       "Invalid email or password",
+		})
+		return
+	}
+
+	setSession(ctx, user)
+	ctx.Redirect(http.StatusSeeOther, getRedirect(ctx))
+}
+
+func handleLogout(ctx *gin.Context) {
+	clearSession(ctx)
+	ctx.Redirect(http.StatusSeeOther, "/")
+}
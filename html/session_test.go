@@ -0,0 +1,53 @@
+package html
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIsLocalPath(t *testing.T) {
+	for _, test := range []struct {
+		next  string
+		local bool
+	}{
+		{"/profile", true},
+		{"/machines?sort=year", true},
+		{"/machines/G4ODR-MDXEy#scores", true},
+		{"", false},
+		{"profile", false},
+		{"https://evil.com", false},
+		{"//evil.com", false},
+		{"/\\evil.com", false},
+		{"\\/evil.com", false},
+		{"/\t/evil.com", false},
+		{"/\n/evil.com", false},
+	} {
+		if local := isLocalPath(test.next); local != test.local {
+			t.Errorf("isLocalPath(%q) = %t, expected %t", test.next, local, test.local)
+		}
+	}
+}
+
+func TestGetRedirect(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, test := range []struct {
+		query    string
+		redirect string
+	}{
+		{"", "/profile"},
+		{"?next=%2Fmachines%3Fsort%3Dyear", "/machines?sort=year"},
+		{"?next=%2F%5Cevil.com", "/profile"},
+		{"?next=%2F%2Fevil.com", "/profile"},
+		{"?next=%2F%09%2Fevil.com", "/profile"},
+		{"?next=https%3A%2F%2Fevil.com", "/profile"},
+	} {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/login"+test.query, nil)
+		if redirect := getRedirect(ctx); redirect != test.redirect {
+			t.Errorf("getRedirect(%q) = %q, expected %q", test.query, redirect, test.redirect)
+		}
+	}
+}
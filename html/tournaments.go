@@ -0,0 +1,472 @@
+package html
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikefero/tpl/db"
+	"github.com/mikefero/tpl/tournament"
+)
+
+// tournamentRefresh is how often in seconds the standings of a running
+// tournament reload
+const tournamentRefresh = 30
+
+func handleTournaments(ctx *gin.Context) {
+	renderTournaments(ctx, http.StatusOK, gin.H{})
+}
+
+func renderTournaments(ctx *gin.Context, status int, data gin.H) {
+	data["title"] = "Tournaments"
+	data["description"] = "Strikes, Swiss, match play, bracket and qualifying tournaments at The Pinball Lounge"
+	data["tournaments"] = db.GetTournaments(db.CountTournaments(), 0)
+	data["groupSizes"] = tournament.GroupSizes
+	data["formats"] = db.TournamentFormats
+	data["scorings"] = tournament.Scorings
+	render(ctx, status, "tournaments.tmpl", data)
+}
+
+// getPositiveInt parses a positive number from the form.
+func getPositiveInt(ctx *gin.Context, name string) (int64, bool) {
+	value, err := strconv.ParseInt(ctx.PostForm(name), 10, 64)
+	return value, err == nil && value > 0
+}
+
+func handleCreateTournament(ctx *gin.Context) {
+	t := db.Tournament{
+		Name:      strings.TrimSpace(ctx.PostForm("name")),
+		Format:    ctx.PostForm("format"),
+		GroupSize: 2,
+		CreatedBy: getSessionUser(ctx).Id,
+	}
+	if !db.IsTournamentFormat(t.Format) {
+		renderTournaments(ctx, http.StatusBadRequest, gin.H{
+			"error": "Unknown tournament format " + t.Format,
+		})
+		return
+	}
+	var err error
+	var ok bool
+	switch t.Format {
+	case db.TournamentFormatSwiss:
+		if t.Rounds.Int64, ok = getPositiveInt(ctx, "rounds"); !ok {
+			renderTournaments(ctx, http.StatusUnprocessableEntity, gin.H{
+				"error": "Rounds must be a positive number",
+			})
+			return
+		}
+		t.Rounds.Valid = true
+	case db.TournamentFormatMatchPlay:
+		if t.Rounds.Int64, ok = getPositiveInt(ctx, "rounds"); !ok {
+			renderTournaments(ctx, http.StatusUnprocessableEntity, gin.H{
+				"error": "Rounds must be a positive number",
+			})
+			return
+		}
+		t.Rounds.Valid = true
+		t.Scoring.String = ctx.PostForm("scoring")
+		if !tournament.IsScoring(t.Scoring.String) {
+			renderTournaments(ctx, http.StatusUnprocessableEntity, gin.H{
+				"error": "Unknown match play scoring " + t.Scoring.String,
+			})
+			return
+		}
+		t.Scoring.Valid = true
+		t.GroupSize = tournament.MatchPlayGroupSize
+	case db.TournamentFormatBracket:
+		t.StrikeLimit = 1
+	case db.TournamentFormatQualifying:
+		if t.Entries.Int64, ok = getPositiveInt(ctx, "entries"); !ok {
+			renderTournaments(ctx, http.StatusUnprocessableEntity, gin.H{
+				"error": "Entries per machine must be a positive number",
+			})
+			return
+		}
+		t.Entries.Valid = true
+		if t.Qualifiers.Int64, ok = getPositiveInt(ctx, "qualifiers"); !ok || t.Qualifiers.Int64 < 2 {
+			renderTournaments(ctx, http.StatusUnprocessableEntity, gin.H{
+				"error": "At least two players must qualify for the bracket",
+			})
+			return
+		}
+		t.Qualifiers.Valid = true
+		if len(ctx.PostForm("counted_machines")) > 0 {
+			if t.CountedMachines.Int64, ok = getPositiveInt(ctx, "counted_machines"); !ok {
+				renderTournaments(ctx, http.StatusUnprocessableEntity, gin.H{
+					"error": "Counted machines must be a positive number",
+				})
+				return
+			}
+			t.CountedMachines.Valid = true
+		}
+	default:
+		t.GroupSize, err = strconv.Atoi(ctx.PostForm("group_size"))
+		if err != nil || !tournament.IsGroupSize(t.GroupSize) {
+			renderTournaments(ctx, http.StatusUnprocessableEntity, gin.H{
+				"error": "Groups must have 2 or 4 players",
+			})
+			return
+		}
+		t.StrikeLimit, err = strconv.Atoi(ctx.PostForm("strike_limit"))
+		if err != nil || t.StrikeLimit < 1 {
+			renderTournaments(ctx, http.StatusUnprocessableEntity, gin.H{
+				"error": "Strikes must be a positive number",
+			})
+			return
+		}
+	}
+	if len(t.Name) == 0 {
+		renderTournaments(ctx, http.StatusUnprocessableEntity, gin.H{
+			"error": "A tournament name is required",
+		})
+		return
+	}
+
+	created := db.CreateTournament(t)
+	if created == nil {
+		renderTournaments(ctx, http.StatusInternalServerError, gin.H{
+			"error": "Unable to create the tournament",
+		})
+		return
+	}
+	ctx.Redirect(http.StatusSeeOther, "/tournaments/"+strconv.Itoa(created.Id))
+}
+
+func getTournament(ctx *gin.Context) *db.Tournament {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return nil
+	}
+	t := db.GetTournament(id)
+	if t == nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+	}
+	return t
+}
+
+func renderTournament(ctx *gin.Context, status int, t *db.Tournament, data gin.H) {
+	players := db.GetTournamentPlayers(t.Id)
+	games := db.GetTournamentGames(t.Id)
+	round := tournament.GetRounds(games)
+	checkedIn := map[int]bool{}
+	for _, player := range players {
+		checkedIn[player.UserId] = true
+	}
+	var current []db.TournamentGame
+	for _, game := range games {
+		if game.Round == round {
+			current = append(current, game)
+		}
+	}
+
+	data["title"] = t.Name
+	data["description"] = "Live standings of the " + t.Name + " tournament"
+	data["tournament"] = t
+	data["players"] = players
+	data["checkedIn"] = checkedIn
+	data["standings"] = tournament.GetStandings(*t, players, games)
+	data["round"] = round
+	data["nextRound"] = round + 1
+	data["roundComplete"] = tournament.IsRoundComplete(games, round)
+	data["current"] = current
+	data["games"] = games
+	if t.State == db.TournamentStateCheckIn || t.Format == db.TournamentFormatQualifying {
+		data["users"] = db.GetActiveUsers()
+	}
+	if t.Format == db.TournamentFormatMatchPlay {
+		data["pickable"] = getPickableMachines(games, current)
+	}
+	if t.Format == db.TournamentFormatQualifying {
+		addQualifying(ctx, t, data)
+	} else if t.State == db.TournamentStateRunning {
+		data["refresh"] = tournamentRefresh
+	}
+	render(ctx, status, "tournament.tmpl", data)
+}
+
+func handleTournament(ctx *gin.Context) {
+	t := getTournament(ctx)
+	if t == nil {
+		return
+	}
+
+	renderTournament(ctx, http.StatusOK, t, gin.H{})
+}
+
+func renderTournamentError(ctx *gin.Context, t *db.Tournament, err error) {
+	status := http.StatusUnprocessableEntity
+	if err == tournament.ErrNotGamePlayer || err == tournament.ErrNotPicker {
+		status = http.StatusForbidden
+	}
+	renderTournament(ctx, status, t, gin.H{
+		"error": strings.ToUpper(err.Error()[:1]) + err.Error()[1:],
+	})
+}
+
+// getFormPlayer returns the player a form is for; staff can pick other players
+// in the form.
+func getFormPlayer(ctx *gin.Context) int {
+	user := getSessionUser(ctx)
+	if userId, err := strconv.Atoi(ctx.PostForm("user_id")); err == nil && user.HasRole(db.RoleStaff) {
+		return userId
+	}
+	return user.Id
+}
+
+func handleTournamentCheckIn(ctx *gin.Context) {
+	t := getTournament(ctx)
+	if t == nil {
+		return
+	}
+
+	userId := getFormPlayer(ctx)
+	if player := db.GetUser(userId); player == nil || !player.Active {
+		renderTournament(ctx, http.StatusUnprocessableEntity, t, gin.H{
+			"error": "Only active players can check in",
+		})
+		return
+	}
+	if err := tournament.CheckIn(*t, userId); err != nil {
+		renderTournamentError(ctx, t, err)
+		return
+	}
+	ctx.Redirect(http.StatusSeeOther, "/tournaments/"+strconv.Itoa(t.Id))
+}
+
+func handleTournamentCheckOut(ctx *gin.Context) {
+	t := getTournament(ctx)
+	if t == nil {
+		return
+	}
+
+	if err := tournament.CheckOut(*t, getFormPlayer(ctx)); err != nil {
+		renderTournamentError(ctx, t, err)
+		return
+	}
+	ctx.Redirect(http.StatusSeeOther, "/tournaments/"+strconv.Itoa(t.Id))
+}
+
+func handleStartTournamentRound(ctx *gin.Context) {
+	t := getTournament(ctx)
+	if t == nil {
+		return
+	}
+
+	if err := tournament.StartRound(*t); err != nil {
+		renderTournamentError(ctx, t, err)
+		return
+	}
+	ctx.Redirect(http.StatusSeeOther, "/tournaments/"+strconv.Itoa(t.Id))
+}
+
+func handleRecordTournamentGame(ctx *gin.Context) {
+	t := getTournament(ctx)
+	if t == nil {
+		return
+	}
+	gameId, err := strconv.Atoi(ctx.Param("game_id"))
+	if err != nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	var finish []int
+	for _, value := range ctx.PostFormArray("finish") {
+		userId, err := strconv.Atoi(value)
+		if err != nil {
+			renderTournamentError(ctx, t, tournament.ErrInvalidPlaces)
+			return
+		}
+		finish = append(finish, userId)
+	}
+	if err := tournament.RecordGame(*t, gameId, *getSessionUser(ctx), finish); err != nil {
+		if err == tournament.ErrGameNotFound {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		renderTournamentError(ctx, t, err)
+		return
+	}
+	ctx.Redirect(http.StatusSeeOther, "/tournaments/"+strconv.Itoa(t.Id))
+}
+
+// getPickableMachines returns the machines that can be picked for every game
+// of the current round that was not recorded yet.
+func getPickableMachines(games []db.TournamentGame, current []db.TournamentGame) map[int][]db.Machine {
+	lineup := map[string]db.Machine{}
+	for _, machine := range db.GetActiveMachines(db.MachineFilter{
+		Sort:  db.SortMachinesByName,
+		Limit: -1,
+	}) {
+		lineup[machine.OpdbId] = machine
+	}
+	pool := db.GetSelectableMachines()
+	pickable := map[int][]db.Machine{}
+	for _, game := range current {
+		if game.CompletedAt.Valid {
+			continue
+		}
+		for _, opdbId := range tournament.GetPickableMachines(games, game, pool) {
+			pickable[game.Id] = append(pickable[game.Id], lineup[opdbId])
+		}
+		sort.Slice(pickable[game.Id], func(i, j int) bool {
+			return pickable[game.Id][i].Name < pickable[game.Id][j].Name
+		})
+	}
+	return pickable
+}
+
+func handlePickTournamentMachine(ctx *gin.Context) {
+	t := getTournament(ctx)
+	if t == nil {
+		return
+	}
+	gameId, err := strconv.Atoi(ctx.Param("game_id"))
+	if err != nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	if err := tournament.PickMachine(*t, gameId, *getSessionUser(ctx), ctx.PostForm("opdb_id")); err != nil {
+		if err == tournament.ErrGameNotFound {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		renderTournamentError(ctx, t, err)
+		return
+	}
+	ctx.Redirect(http.StatusSeeOther, "/tournaments/"+strconv.Itoa(t.Id))
+}
+
+// addQualifying adds the leaderboard, machine rankings, entries of the player
+// and the score queue of a qualifying tournament. The page does not reload by
+// itself so that scores being typed in are not lost.
+func addQualifying(ctx *gin.Context, t *db.Tournament, data gin.H) {
+	tickets := db.GetTournamentTickets(t.Id)
+	scores := db.GetTournamentScores(t.Id)
+	var pending []db.TournamentScore
+	for _, score := range scores {
+		if !score.VerifiedAt.Valid {
+			pending = append(pending, score)
+		}
+	}
+
+	data["qualifiers"] = tournament.GetQualifiers(*t, tickets, scores)
+	data["rankings"] = tournament.RankMachines(scores)
+	data["pending"] = pending
+	data["tickets"] = len(tickets)
+	data["lineup"] = db.GetActiveMachines(db.MachineFilter{
+		Sort:  db.SortMachinesByName,
+		Limit: -1,
+	})
+	if user := getSessionUser(ctx); user != nil {
+		data["entries"] = tournament.GetEntries(tickets, user.Id)
+	}
+	if t.BracketId.Valid {
+		data["bracket"] = db.GetTournament(int(t.BracketId.Int64))
+	}
+}
+
+func handleSellTournamentTickets(ctx *gin.Context) {
+	t := getTournament(ctx)
+	if t == nil {
+		return
+	}
+
+	userId, err := strconv.Atoi(ctx.PostForm("user_id"))
+	if player := db.GetUser(userId); err != nil || player == nil || !player.Active {
+		renderTournament(ctx, http.StatusUnprocessableEntity, t, gin.H{
+			"error": "Only active players can buy entries",
+		})
+		return
+	}
+	quantity, err := strconv.Atoi(ctx.PostForm("quantity"))
+	if err != nil {
+		renderTournamentError(ctx, t, tournament.ErrInvalidQuantity)
+		return
+	}
+	if err := tournament.SellTickets(*t, userId, ctx.PostForm("opdb_id"), quantity, getSessionUser(ctx).Id); err != nil {
+		renderTournamentError(ctx, t, err)
+		return
+	}
+	ctx.Redirect(http.StatusSeeOther, "/tournaments/"+strconv.Itoa(t.Id))
+}
+
+func handleSubmitTournamentScore(ctx *gin.Context) {
+	t := getTournament(ctx)
+	if t == nil {
+		return
+	}
+
+	score, err := strconv.ParseInt(strings.ReplaceAll(strings.TrimSpace(ctx.PostForm("score")), ",", ""), 10, 64)
+	if err != nil {
+		renderTournamentError(ctx, t, tournament.ErrInvalidScore)
+		return
+	}
+	if err := tournament.SubmitScore(*t, *getSessionUser(ctx), getFormPlayer(ctx), ctx.PostForm("opdb_id"), score); err != nil {
+		renderTournamentError(ctx, t, err)
+		return
+	}
+	ctx.Redirect(http.StatusSeeOther, "/tournaments/"+strconv.Itoa(t.Id))
+}
+
+func getScoreId(ctx *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(ctx.Param("score_id"))
+	if err != nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return 0, false
+	}
+	return id, true
+}
+
+func handleVerifyTournamentScore(ctx *gin.Context) {
+	t := getTournament(ctx)
+	if t == nil {
+		return
+	}
+	id, ok := getScoreId(ctx)
+	if !ok {
+		return
+	}
+
+	if err := tournament.VerifyScore(*t, id, getSessionUser(ctx).Id); err != nil {
+		renderTournamentError(ctx, t, err)
+		return
+	}
+	ctx.Redirect(http.StatusSeeOther, "/tournaments/"+strconv.Itoa(t.Id)+"#queue")
+}
+
+func handleRejectTournamentScore(ctx *gin.Context) {
+	t := getTournament(ctx)
+	if t == nil {
+		return
+	}
+	id, ok := getScoreId(ctx)
+	if !ok {
+		return
+	}
+
+	if err := tournament.RejectScore(*t, id); err != nil {
+		renderTournamentError(ctx, t, err)
+		return
+	}
+	ctx.Redirect(http.StatusSeeOther, "/tournaments/"+strconv.Itoa(t.Id)+"#queue")
+}
+
+func handleCloseQualifying(ctx *gin.Context) {
+	t := getTournament(ctx)
+	if t == nil {
+		return
+	}
+
+	bracket, err := tournament.CloseQualifying(*t, getSessionUser(ctx).Id)
+	if err != nil {
+		renderTournamentError(ctx, t, err)
+		return
+	}
+	ctx.Redirect(http.StatusSeeOther, "/tournaments/"+strconv.Itoa(bracket.Id))
+}
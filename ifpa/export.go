@@ -0,0 +1,143 @@
+package ifpa
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/mikefero/tpl/db"
+	"github.com/mikefero/tpl/tournament"
+)
+
+var ErrTournamentNotCompleted = errors.New("only completed tournaments can be exported")
+var ErrSeasonNotCompleted = errors.New("only seasons that have ended can be exported")
+var ErrNoResults = errors.New("no player has a final position to export")
+
+// Header is the header row of an IFPA results submission
+var Header = []string{"Position", "Player Name", "IFPA ID"}
+
+// Result is the final position of a player submitted to IFPA. Players without
+// an IFPA number must be flagged as new players so that IFPA creates one.
+type Result struct {
+	Position  int
+	UserId    int
+	Name      string
+	IfpaId    int
+	NewPlayer bool
+}
+
+// newResult returns the final position of a player with the IFPA number the
+// player linked, if any was verified; unverified numbers may belong to someone
+// else.
+func newResult(position int, userId int, name string) Result {
+	result := Result{
+		Position: position,
+		UserId:   userId,
+		Name:     name,
+	}
+	if user := db.GetUser(userId); user != nil && user.IfpaId.Valid && user.IfpaVerifiedAt.Valid {
+		result.IfpaId = int(user.IfpaId.Int64)
+	}
+	return result
+}
+
+// GetTournamentResults returns the final positions of a completed tournament;
+// qualifying tournaments are ranked by their qualifying leaderboard.
+func GetTournamentResults(t db.Tournament) ([]Result, error) {
+	if t.State != db.TournamentStateCompleted {
+		return nil, ErrTournamentNotCompleted
+	}
+
+	var results []Result
+	if t.Format == db.TournamentFormatQualifying {
+		for _, qualifier := range tournament.GetQualifyingStandings(t) {
+			results = append(results, newResult(qualifier.Position, qualifier.UserId, qualifier.Name))
+		}
+	} else {
+		for _, standing := range tournament.GetTournamentStandings(t) {
+			results = append(results, newResult(standing.Position, standing.UserId, standing.Name))
+		}
+	}
+	if len(results) == 0 {
+		return nil, ErrNoResults
+	}
+	return results, nil
+}
+
+// GetSeasonResults returns the final positions of the players of a season that
+// has ended. Both players of a team share the position of their team and teams
+// on the same points and wins share a position.
+func GetSeasonResults(season db.Season) ([]Result, error) {
+	if !season.EndDate.Valid || season.EndDate.Int64 > time.Now().Unix() {
+		return nil, ErrSeasonNotCompleted
+	}
+
+	var results []Result
+	var previous db.Standing
+	position := 0
+	for _, standing := range db.GetStandings(season.Id) {
+		if standing.Played == 0 {
+			continue
+		}
+		if position == 0 || standing.Points != previous.Points || standing.Wins != previous.Wins {
+			position = len(results) + 1
+		}
+		previous = standing
+		team := db.GetTeam(standing.TeamId)
+		if team == nil {
+			continue
+		}
+		for _, userId := range []int{team.APlayer, team.BPlayer} {
+			if user := db.GetUser(userId); user != nil {
+				results = append(results, newResult(position, user.Id, user.Name))
+			}
+		}
+	}
+	if len(results) == 0 {
+		return nil, ErrNoResults
+	}
+	return results, nil
+}
+
+// Validate returns a problem for every player without an IFPA number who is
+// not flagged as a new player and for every IFPA number used more than once,
+// keyed by user ID.
+func Validate(results []Result) map[int]string {
+	problems := map[int]string{}
+	used := map[int]int{}
+	for _, result := range results {
+		switch {
+		case result.IfpaId > 0:
+			if userId, exists := used[result.IfpaId]; exists {
+				problems[result.UserId] = "IFPA number " + strconv.Itoa(result.IfpaId) + " is also used by another player"
+				problems[userId] = problems[result.UserId]
+			}
+			used[result.IfpaId] = result.UserId
+		case !result.NewPlayer:
+			problems[result.UserId] = "An IFPA number or the new player flag is required"
+		}
+	}
+	return problems
+}
+
+// WriteCSV writes the results in IFPA's submission format; new players are
+// submitted without an IFPA number.
+func WriteCSV(w io.Writer, results []Result) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(Header); err != nil {
+		return err
+	}
+	for _, result := range results {
+		ifpaId := ""
+		if result.IfpaId > 0 {
+			ifpaId = strconv.Itoa(result.IfpaId)
+		}
+		if err := writer.Write([]string{strconv.Itoa(result.Position), result.Name, ifpaId}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
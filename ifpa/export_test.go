@@ -0,0 +1,199 @@
+package ifpa
+
+import (
+	"bytes"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mikefero/tpl/db"
+)
+
+// testSeasonFixture is a season that has ended in which Flippers and Bumpers
+// won their match by the same points while Tilt and Slings lost theirs; Ann
+// of Flippers linked an IFPA number that was verified while the number Bob
+// linked was not, and Spinners never played.
+var testSeasonFixture = []string{
+	`INSERT INTO leagues (id, name, active) VALUES (1, 'Monday', true)`,
+	`INSERT INTO seasons (id, league_id, name, start_date, end_date) VALUES (1, 1, 'Spring', 0, 1)`,
+	`INSERT INTO users (id, league_id, email, password, name, active, ifpa_id, ifpa_verified_at) VALUES
+  (1, 1, 'ann@example.com', '', 'Ann', true, 1234, 1),
+  (2, 1, 'bob@example.com', '', 'Bob', true, 5678, NULL),
+  (3, 1, 'cat@example.com', '', 'Cat', true, NULL, NULL),
+  (4, 1, 'dan@example.com', '', 'Dan', true, NULL, NULL),
+  (5, 1, 'eve@example.com', '', 'Eve', true, NULL, NULL),
+  (6, 1, 'fay@example.com', '', 'Fay', true, NULL, NULL),
+  (7, 1, 'gus@example.com', '', 'Gus', true, NULL, NULL),
+  (8, 1, 'hal@example.com', '', 'Hal', true, NULL, NULL),
+  (9, 1, 'ivy@example.com', '', 'Ivy', true, NULL, NULL),
+  (10, 1, 'jon@example.com', '', 'Jon', true, NULL, NULL)`,
+	`INSERT INTO teams (id, league_id, name, a_player, b_player, active) VALUES
+  (1, 1, 'Flippers', 1, 2, true),
+  (2, 1, 'Tilt', 3, 4, true),
+  (3, 1, 'Bumpers', 5, 6, true),
+  (4, 1, 'Slings', 7, 8, true),
+  (5, 1, 'Spinners', 9, 10, true)`,
+	`INSERT INTO matches (id, league_id, season_id, team_1_id, team_2_id, week) VALUES
+  (1, 1, 1, 1, 2, 1),
+  (2, 1, 1, 3, 4, 1)`,
+	`INSERT INTO results (match_id, opdb_id, team_1_score, team_2_score) VALUES
+  (1, 'G4ODR-MDXEy', 3, 1),
+  (2, 'G4ODR-MDXEy', 3, 1)`,
+}
+
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "tpl-ifpa")
+	if err != nil {
+		panic(err)
+	}
+	status := func() int {
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "tpl.db")
+		db.Initialize(db.Config{
+			Path:           path,
+			OpdbExportPath: filepath.Join("..", "db", "opdb.json"),
+		})
+		defer db.Close()
+		session, err := sql.Open("sqlite3", path)
+		if err != nil {
+			panic(err)
+		}
+		defer session.Close()
+		for _, statement := range testSeasonFixture {
+			if _, err := session.Exec(statement); err != nil {
+				panic(err)
+			}
+		}
+
+		return m.Run()
+	}()
+	os.Exit(status)
+}
+
+func TestValidate(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		results  []Result
+		problems []int
+	}{
+		{
+			name: "linked and new players",
+			results: []Result{
+				{Position: 1, UserId: 1, Name: "Ann", IfpaId: 1234},
+				{Position: 2, UserId: 2, Name: "Bob", NewPlayer: true},
+			},
+		},
+		{
+			name: "player without an IFPA number",
+			results: []Result{
+				{Position: 1, UserId: 1, Name: "Ann", IfpaId: 1234},
+				{Position: 2, UserId: 2, Name: "Bob"},
+			},
+			problems: []int{2},
+		},
+		{
+			name: "IFPA number used twice",
+			results: []Result{
+				{Position: 1, UserId: 1, Name: "Ann", IfpaId: 1234},
+				{Position: 2, UserId: 2, Name: "Bob", IfpaId: 5678},
+				{Position: 3, UserId: 3, Name: "Cat", IfpaId: 1234},
+			},
+			problems: []int{1, 3},
+		},
+		{
+			name: "new player with an IFPA number",
+			results: []Result{
+				{Position: 1, UserId: 1, Name: "Ann", IfpaId: 1234, NewPlayer: true},
+			},
+		},
+	} {
+		problems := Validate(test.results)
+		var userIds []int
+		for _, result := range test.results {
+			if _, exists := problems[result.UserId]; exists {
+				userIds = append(userIds, result.UserId)
+			}
+		}
+		if len(problems) != len(userIds) || !reflect.DeepEqual(userIds, test.problems) {
+			t.Errorf("%s: expected problems for %v, got %v", test.name, test.problems, problems)
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buffer bytes.Buffer
+	err := WriteCSV(&buffer, []Result{
+		{Position: 1, UserId: 1, Name: "Ann", IfpaId: 1234},
+		{Position: 1, UserId: 2, Name: "Bob O'Neil, Jr.", IfpaId: 5678},
+		{Position: 3, UserId: 3, Name: "Cat", NewPlayer: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Position,Player Name,IFPA ID\n" +
+		"1,Ann,1234\n" +
+		"1,\"Bob O'Neil, Jr.\",5678\n" +
+		"3,Cat,\n"
+	if buffer.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buffer.String())
+	}
+}
+
+func TestGetResultsNotCompleted(t *testing.T) {
+	if _, err := GetTournamentResults(db.Tournament{State: db.TournamentStateRunning}); err != ErrTournamentNotCompleted {
+		t.Errorf("expected %v for a running tournament, got %v", ErrTournamentNotCompleted, err)
+	}
+	for _, endDate := range []sql.NullInt64{
+		{},
+		{Int64: time.Now().Add(24 * time.Hour).Unix(), Valid: true},
+	} {
+		if _, err := GetSeasonResults(db.Season{EndDate: endDate}); err != ErrSeasonNotCompleted {
+			t.Errorf("expected %v for a season ending at %v, got %v", ErrSeasonNotCompleted, endDate, err)
+		}
+	}
+}
+
+func TestNewResult(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		userId int
+		ifpaId int
+	}{
+		{"verified IFPA number", 1, 1234},
+		{"unverified IFPA number", 2, 0},
+		{"without an IFPA number", 3, 0},
+		{"missing user", 99, 0},
+	} {
+		if result := newResult(1, test.userId, "Player"); result.IfpaId != test.ifpaId {
+			t.Errorf("%s: expected IFPA number %d, got %d", test.name, test.ifpaId, result.IfpaId)
+		}
+	}
+}
+
+func TestGetSeasonResults(t *testing.T) {
+	season := db.GetSeason(1)
+	if season == nil {
+		t.Fatal("expected season 1")
+	}
+	results, err := GetSeasonResults(*season)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Result{
+		{Position: 1, UserId: 5, Name: "Eve"},
+		{Position: 1, UserId: 6, Name: "Fay"},
+		{Position: 1, UserId: 1, Name: "Ann", IfpaId: 1234},
+		{Position: 1, UserId: 2, Name: "Bob"},
+		{Position: 5, UserId: 7, Name: "Gus"},
+		{Position: 5, UserId: 8, Name: "Hal"},
+		{Position: 5, UserId: 3, Name: "Cat"},
+		{Position: 5, UserId: 4, Name: "Dan"},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("expected %+v, got %+v", expected, results)
+	}
+}
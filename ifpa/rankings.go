@@ -0,0 +1,191 @@
+package ifpa
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mikefero/tpl/db"
+	json "github.com/tidwall/gjson"
+)
+
+var ErrUnknownRankingsFormat = errors.New("unknown IFPA rankings file format")
+var ErrMissingRankingsColumn = errors.New("IFPA rankings file is missing a column")
+var ErrInvalidRanking = errors.New("IFPA rankings file has an invalid ranking")
+var ErrNoRankings = errors.New("IFPA rankings file has no rankings")
+var ErrRankingsNotImported = errors.New("IFPA rankings could not be imported")
+
+// RankingsParser reads the rankings of an IFPA ranking snapshot. Parsers are
+// the boundary between the format of a snapshot file and the stored rankings;
+// supporting a new format only requires a new parser.
+type RankingsParser func(r io.Reader) ([]db.IfpaRanking, error)
+
+// RankingsParsers are the parsers of the supported snapshot formats keyed by
+// file extension.
+var RankingsParsers = map[string]RankingsParser{
+	".csv":  ParseRankingsCSV,
+	".json": ParseRankingsJSON,
+}
+
+// rankingsColumns are the accepted headers of every column of a CSV snapshot;
+// the country column is optional.
+var rankingsColumns = map[string][]string{
+	"ifpa_id": {"ifpa id", "ifpa_id", "player id", "player_id"},
+	"name":    {"name", "player name", "player_name"},
+	"rank":    {"rank", "wppr rank", "current rank", "current_rank", "current_wppr_rank"},
+	"wppr":    {"wppr", "wppr points", "wppr_points"},
+	"country": {"country", "country name", "country_name"},
+}
+
+func newRanking(ifpaId string, name string, rank string, wppr string, country string) (db.IfpaRanking, error) {
+	id, err := strconv.ParseInt(strings.TrimSpace(ifpaId), 10, 64)
+	if err != nil || id < 1 {
+		return db.IfpaRanking{}, ErrInvalidRanking
+	}
+	position, err := strconv.Atoi(strings.TrimSpace(rank))
+	if err != nil || position < 1 {
+		return db.IfpaRanking{}, ErrInvalidRanking
+	}
+	points, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(wppr), ",", ""), 64)
+	if err != nil || points < 0 {
+		return db.IfpaRanking{}, ErrInvalidRanking
+	}
+	name = strings.TrimSpace(name)
+	if len(name) == 0 {
+		return db.IfpaRanking{}, ErrInvalidRanking
+	}
+	ranking := db.IfpaRanking{
+		IfpaId: id,
+		Name:   name,
+		Rank:   position,
+		Wppr:   points,
+	}
+	if country = strings.TrimSpace(country); len(country) > 0 {
+		ranking.Country = sql.NullString{
+			String: country,
+			Valid:  true,
+		}
+	}
+	return ranking, nil
+}
+
+// ParseRankingsCSV reads a CSV snapshot with a header row naming the IFPA
+// number, name, rank, WPPR points and optionally country columns.
+func ParseRankingsCSV(r io.Reader) ([]db.IfpaRanking, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for column, names := range rankingsColumns {
+		for i, value := range header {
+			for _, name := range names {
+				if strings.EqualFold(strings.TrimSpace(value), name) {
+					columns[column] = i
+				}
+			}
+		}
+		if _, exists := columns[column]; !exists && column != "country" {
+			return nil, ErrMissingRankingsColumn
+		}
+	}
+
+	var rankings []db.IfpaRanking
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		country := ""
+		if i, exists := columns["country"]; exists {
+			country = record[i]
+		}
+		ranking, err := newRanking(record[columns["ifpa_id"]],
+			record[columns["name"]],
+			record[columns["rank"]],
+			record[columns["wppr"]],
+			country)
+		if err != nil {
+			return nil, err
+		}
+		rankings = append(rankings, ranking)
+	}
+	return rankings, nil
+}
+
+// ParseRankingsJSON reads a snapshot saved from the rankings of the IFPA API,
+// an object with a rankings array of players.
+func ParseRankingsJSON(r io.Reader) ([]db.IfpaRanking, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !json.ValidBytes(data) {
+		return nil, ErrInvalidRanking
+	}
+
+	var rankings []db.IfpaRanking
+	json.GetBytes(data, "rankings").ForEach(func(key, value json.Result) bool {
+		name := value.Get("name").String()
+		if len(strings.TrimSpace(name)) == 0 {
+			name = value.Get("first_name").String() + " " + value.Get("last_name").String()
+		}
+		rank := value.Get("current_rank")
+		if !rank.Exists() {
+			rank = value.Get("current_wppr_rank")
+		}
+		var ranking db.IfpaRanking
+		ranking, err = newRanking(value.Get("player_id").String(),
+			name,
+			rank.String(),
+			value.Get("wppr_points").String(),
+			value.Get("country_name").String())
+		if err != nil {
+			return false
+		}
+		rankings = append(rankings, ranking)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rankings, nil
+}
+
+// ImportRankings replaces the stored IFPA rankings with the snapshot in a
+// local file, parsed by the parser of its extension, and returns the number
+// of players ranked.
+func ImportRankings(path string) (int, error) {
+	parse, exists := RankingsParsers[strings.ToLower(filepath.Ext(path))]
+	if !exists {
+		return 0, ErrUnknownRankingsFormat
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	rankings, err := parse(file)
+	if err != nil {
+		return 0, err
+	}
+	if len(rankings) == 0 {
+		return 0, ErrNoRankings
+	}
+	if !db.ReplaceIfpaRankings(rankings) {
+		return 0, ErrRankingsNotImported
+	}
+	return len(rankings), nil
+}
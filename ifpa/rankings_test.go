@@ -0,0 +1,168 @@
+package ifpa
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mikefero/tpl/db"
+)
+
+var testRankings = []db.IfpaRanking{
+	{IfpaId: 1234, Name: "Ann Smith", Rank: 1, Wppr: 1250.5, Country: sql.NullString{String: "Canada", Valid: true}},
+	{IfpaId: 5678, Name: "Bob O'Neil", Rank: 2, Wppr: 980},
+}
+
+func TestParseRankingsCSV(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		data     string
+		rankings []db.IfpaRanking
+		err      error
+	}{
+		{
+			name: "IFPA headers",
+			data: "IFPA ID,Player Name,WPPR Rank,WPPR Points,Country\n" +
+				"1234,Ann Smith,1,\"1,250.50\",Canada\n" +
+				"5678, Bob O'Neil ,2,980,\n",
+			rankings: testRankings,
+		},
+		{
+			name: "API headers in another order",
+			data: "current_rank,player_id,wppr_points,player_name,country_name\n" +
+				"1,1234,1250.5,Ann Smith,Canada\n" +
+				"2,5678,980,Bob O'Neil,\n",
+			rankings: testRankings,
+		},
+		{
+			name: "without country",
+			data: "player_id,name,rank,wppr\n" +
+				"5678,Bob O'Neil,2,980\n",
+			rankings: testRankings[1:],
+		},
+		{
+			name: "header only",
+			data: "player_id,name,rank,wppr\n",
+		},
+		{
+			name: "missing rank column",
+			data: "player_id,name,wppr\n" +
+				"5678,Bob O'Neil,980\n",
+			err: ErrMissingRankingsColumn,
+		},
+		{
+			name: "invalid IFPA number",
+			data: "player_id,name,rank,wppr\n" +
+				"0,Bob O'Neil,2,980\n",
+			err: ErrInvalidRanking,
+		},
+		{
+			name: "invalid rank",
+			data: "player_id,name,rank,wppr\n" +
+				"5678,Bob O'Neil,unranked,980\n",
+			err: ErrInvalidRanking,
+		},
+		{
+			name: "negative points",
+			data: "player_id,name,rank,wppr\n" +
+				"5678,Bob O'Neil,2,-1\n",
+			err: ErrInvalidRanking,
+		},
+		{
+			name: "missing name",
+			data: "player_id,name,rank,wppr\n" +
+				"5678, ,2,980\n",
+			err: ErrInvalidRanking,
+		},
+	} {
+		rankings, err := ParseRankingsCSV(strings.NewReader(test.data))
+		if err != test.err {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+			continue
+		}
+		if !reflect.DeepEqual(rankings, test.rankings) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.rankings, rankings)
+		}
+	}
+}
+
+func TestParseRankingsJSON(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		data     string
+		rankings []db.IfpaRanking
+		err      error
+	}{
+		{
+			name: "API rankings",
+			data: `{"rankings": [
+  {"player_id": "1234", "name": "Ann Smith", "current_rank": "1", "wppr_points": "1250.50", "country_name": "Canada"},
+  {"player_id": 5678, "first_name": "Bob", "last_name": "O'Neil", "current_wppr_rank": 2, "wppr_points": 980}
+]}`,
+			rankings: testRankings,
+		},
+		{
+			name: "without rankings",
+			data: `{"total": 0}`,
+		},
+		{
+			name: "invalid JSON",
+			data: `{"rankings": [`,
+			err:  ErrInvalidRanking,
+		},
+		{
+			name: "invalid ranking",
+			data: `{"rankings": [{"player_id": "1234", "name": "Ann Smith", "wppr_points": "1250.50"}]}`,
+			err:  ErrInvalidRanking,
+		},
+	} {
+		rankings, err := ParseRankingsJSON(strings.NewReader(test.data))
+		if err != test.err {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+			continue
+		}
+		if !reflect.DeepEqual(rankings, test.rankings) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.rankings, rankings)
+		}
+	}
+}
+
+func TestImportRankings(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	for _, test := range []struct {
+		name string
+		path string
+		err  error
+	}{
+		{"unknown format", write("rankings.xlsx", ""), ErrUnknownRankingsFormat},
+		{"no rankings", write("empty.csv", "player_id,name,rank,wppr\n"), ErrNoRankings},
+		{"invalid rankings", write("invalid.json", `{"rankings": [{"player_id": "x"}]}`), ErrInvalidRanking},
+	} {
+		if _, err := ImportRankings(test.path); err != test.err {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+	if _, err := ImportRankings(filepath.Join(dir, "missing.csv")); err == nil {
+		t.Error("expected a missing file to be rejected")
+	}
+
+	count, err := ImportRankings(write("RANKINGS.CSV", "player_id,name,rank,wppr\n1234,Ann Smith,3,500\n"))
+	if err != nil || count != 1 {
+		t.Fatalf("expected 1 ranking imported, got %d (%v)", count, err)
+	}
+	ranking := db.GetIfpaRanking(1234)
+	if ranking == nil || ranking.Rank != 3 || ranking.Wppr != 500 {
+		t.Errorf("expected the imported ranking of 1234, got %+v", ranking)
+	}
+}
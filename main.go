@@ -1,11 +1,104 @@
 package main
 
 import (
+	"flag"
+	"os"
+
 	"github.com/mikefero/tpl/db"
 	"github.com/mikefero/tpl/html"
+	"github.com/mikefero/tpl/ifpa"
+	"github.com/mikefero/tpl/log"
+	"github.com/mikefero/tpl/storage"
 )
 
+// prewarmImages fills the image cache with the backglass of every active
+// machine so that pages render without waiting on the upstream image host.
+func prewarmImages() int {
+	storage.InitializeImageCache()
+	var uuids []string
+	for _, machine := range db.GetActiveMachines(db.MachineFilter{
+		Sort:  db.SortMachinesByName,
+		Limit: -1,
+	}) {
+		if machine.BackglassImageUuid.Valid {
+			uuids = append(uuids, machine.BackglassImageUuid.String)
+		}
+	}
+	failed := storage.PrewarmImages(uuids)
+	log.WithFields(log.Fields{
+		"images": len(uuids),
+		"failed": failed,
+	}).Info("image cache prewarmed")
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// importIfpaRankings replaces the stored IFPA rankings with a ranking snapshot
+// file.
+func importIfpaRankings(path string) int {
+	players, err := ifpa.ImportRankings(path)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  path,
+			"error": err,
+		}).Error("unable to import IFPA rankings")
+		return 1
+	}
+	log.WithFields(log.Fields{
+		"path":    path,
+		"players": players,
+	}).Info("IFPA rankings imported")
+	return 0
+}
+
+// importOpdb updates the machines catalog and its search index from an OPDB
+// export file.
+func importOpdb(path string) int {
+	machines, err := db.ImportOpdb(path)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  path,
+			"error": err,
+		}).Error("unable to import OPDB export")
+		return 1
+	}
+	log.WithFields(log.Fields{
+		"path":     path,
+		"machines": machines,
+	}).Info("OPDB export imported")
+	return 0
+}
+
 func main() {
+	prewarm := flag.Bool("prewarm-images", false, "fetch the images of all active machines into the image cache and exit")
+	rankings := flag.String("import-ifpa-rankings", "", "replace the IFPA rankings with a CSV or JSON ranking snapshot file and exit")
+	opdb := flag.String("import-opdb", "", "update the machines catalog from an OPDB export file and exit")
+	flag.Parse()
+	db.Initialize(db.DefaultConfig)
+
+	if *prewarm {
+		status := prewarmImages()
+		db.Close()
+		os.Exit(status)
+	}
+	if len(*rankings) > 0 {
+		status := importIfpaRankings(*rankings)
+		db.Close()
+		os.Exit(status)
+	}
+	if len(*opdb) > 0 {
+		status := importOpdb(*opdb)
+		db.Close()
+		os.Exit(status)
+	}
+	if !db.HasFullTextSearch() {
+		log.Error("machine search requires FTS5; build TPL with -tags sqlite_fts5")
+		db.Close()
+		os.Exit(1)
+	}
+
 	defer db.Close()
 	html.ListenAndServe()
 }
@@ -0,0 +1,40 @@
+package matchplay
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/mikefero/tpl/db"
+)
+
+// GetPlayerList returns the names of the checked in players of a tournament
+// in the order they checked in.
+func GetPlayerList(players []db.TournamentPlayer) []string {
+	var names []string
+	for _, player := range players {
+		names = append(names, player.Name)
+	}
+	return names
+}
+
+// GetArenaList returns the OPDB names of machines, which Matchplay recognizes
+// when adding arenas.
+func GetArenaList(machines []db.Machine) []string {
+	var names []string
+	for _, machine := range machines {
+		names = append(names, machine.Name)
+	}
+	return names
+}
+
+// WriteList writes names one per line, the format of the player and arena
+// lists pasted into a Matchplay tournament.
+func WriteList(w io.Writer, names []string) error {
+	writer := bufio.NewWriter(w)
+	for _, name := range names {
+		if _, err := writer.WriteString(name + "\n"); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,317 @@
+package matchplay
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mikefero/tpl/db"
+	"github.com/mikefero/tpl/tournament"
+	"github.com/mikefero/tpl/utils"
+	json "github.com/tidwall/gjson"
+)
+
+// MaxExportSize is the largest Matchplay tournament export accepted
+const MaxExportSize = 5 << 20
+
+// DefaultRounds is the number of rounds of an imported Swiss or match play
+// tournament that did not play a round yet
+const DefaultRounds = 5
+
+var ErrInvalidExport = errors.New("the file is not a Matchplay tournament export")
+var ErrUnsupportedType = errors.New("the Matchplay tournament type is not supported")
+var ErrMissingStrikeLimit = errors.New("the Matchplay strikes tournament export has no strike limit")
+var ErrUnmappedPlayer = errors.New("every Matchplay player must be mapped to a player")
+var ErrDuplicatePlayer = errors.New("two Matchplay players cannot be mapped to the same player")
+var ErrUnmappedArena = errors.New("every Matchplay arena with games must be mapped to a machine")
+var ErrNotImported = errors.New("the tournament could not be imported")
+
+var reName = regexp.MustCompile(`[^\pL\pN]+`)
+
+// Player is a player of a Matchplay tournament
+type Player struct {
+	PlayerId int64
+	Name     string
+}
+
+// Arena is a machine of a Matchplay tournament; arenas may carry the OPDB ID
+// of their machine.
+type Arena struct {
+	ArenaId int64
+	Name    string
+	OpdbId  string
+}
+
+// Game is a game of a Matchplay tournament with its players in the order of
+// play and, once completed, in the order they finished.
+type Game struct {
+	GameId          int64
+	Round           int
+	ArenaId         int64
+	PlayerIds       []int64
+	ResultPositions []int64
+	Completed       bool
+}
+
+// Tournament is a Matchplay tournament export; the strike limit is zero when
+// the export has none.
+type Tournament struct {
+	Name        string
+	Type        string
+	StrikeLimit int
+	Completed   bool
+	Players     []Player
+	Arenas      []Arena
+	Games       []Game
+}
+
+// Parse reads a Matchplay tournament export, either the tournament object of
+// the Matchplay API or the data envelope around it, including its players,
+// arenas, rounds and games.
+func Parse(data []byte) (*Tournament, error) {
+	if !json.ValidBytes(data) {
+		return nil, ErrInvalidExport
+	}
+	root := json.ParseBytes(data)
+	if envelope := root.Get("data"); envelope.IsObject() {
+		root = envelope
+	}
+	if !root.Get("name").Exists() || !root.Get("players").IsArray() {
+		return nil, ErrInvalidExport
+	}
+
+	export := Tournament{
+		Name:        strings.TrimSpace(root.Get("name").String()),
+		Type:        root.Get("type").String(),
+		StrikeLimit: int(root.Get("options.strikeLimit").Int()),
+		Completed:   root.Get("status").String() == "completed",
+	}
+	for _, player := range root.Get("players").Array() {
+		export.Players = append(export.Players, Player{
+			PlayerId: player.Get("playerId").Int(),
+			Name:     strings.TrimSpace(player.Get("name").String()),
+		})
+	}
+	for _, arena := range root.Get("arenas").Array() {
+		export.Arenas = append(export.Arenas, Arena{
+			ArenaId: arena.Get("arenaId").Int(),
+			Name:    strings.TrimSpace(arena.Get("name").String()),
+			OpdbId:  arena.Get("opdbId").String(),
+		})
+	}
+
+	// Rounds are numbered by their index, or in the order of their IDs when the
+	// export has no rounds
+	rounds := map[int64]int{}
+	for _, round := range root.Get("rounds").Array() {
+		rounds[round.Get("roundId").Int()] = int(round.Get("index").Int()) + 1
+	}
+	if len(rounds) == 0 {
+		var roundIds []int64
+		for _, game := range root.Get("games").Array() {
+			if _, exists := rounds[game.Get("roundId").Int()]; !exists {
+				rounds[game.Get("roundId").Int()] = 0
+				roundIds = append(roundIds, game.Get("roundId").Int())
+			}
+		}
+		sort.Slice(roundIds, func(i, j int) bool {
+			return roundIds[i] < roundIds[j]
+		})
+		for i, roundId := range roundIds {
+			rounds[roundId] = i + 1
+		}
+	}
+	for _, value := range root.Get("games").Array() {
+		game := Game{
+			GameId:  value.Get("gameId").Int(),
+			Round:   rounds[value.Get("roundId").Int()],
+			ArenaId: value.Get("arenaId").Int(),
+		}
+		for _, playerId := range value.Get("playerIds").Array() {
+			game.PlayerIds = append(game.PlayerIds, playerId.Int())
+		}
+		for _, playerId := range value.Get("resultPositions").Array() {
+			game.ResultPositions = append(game.ResultPositions, playerId.Int())
+		}
+		game.Completed = value.Get("status").String() == "completed" && len(game.ResultPositions) == len(game.PlayerIds)
+		export.Games = append(export.Games, game)
+	}
+	sort.SliceStable(export.Games, func(i, j int) bool {
+		return export.Games[i].Round < export.Games[j].Round
+	})
+	return &export, nil
+}
+
+// GetFormat returns the tournament format of a Matchplay tournament type;
+// group brackets are Swiss tournaments paired head-to-head.
+func GetFormat(matchplayType string) (string, error) {
+	switch matchplayType {
+	case "strike_knockout":
+		return db.TournamentFormatStrikes, nil
+	case "group_bracket":
+		return db.TournamentFormatSwiss, nil
+	case "single_elimination":
+		return db.TournamentFormatBracket, nil
+	case "group_matchplay":
+		return db.TournamentFormatMatchPlay, nil
+	}
+	return "", ErrUnsupportedType
+}
+
+// normalizeName lower cases a name and reduces everything but its letters
+// and digits to single spaces so that names differing in punctuation match.
+func normalizeName(name string) string {
+	return strings.TrimSpace(reName.ReplaceAllString(strings.ToLower(name), " "))
+}
+
+// matchName returns the index of the closest of the names within the edit
+// tolerance of a name, or -1 when none is close enough.
+func matchName(name string, names []string) int {
+	name = normalizeName(name)
+	best := -1
+	bestDistance := db.GetTypoTolerance(name) + 1
+	for i, candidate := range names {
+		if distance := utils.EditDistance(name, normalizeName(candidate)); distance < bestDistance {
+			best = i
+			bestDistance = distance
+		}
+	}
+	return best
+}
+
+// MatchPlayers maps every Matchplay player to the user with the closest name,
+// keyed by Matchplay player ID; players without a close name are left out.
+func MatchPlayers(players []Player, users []db.User) map[int64]int {
+	var names []string
+	for _, user := range users {
+		names = append(names, user.Name)
+	}
+	matches := map[int64]int{}
+	for _, player := range players {
+		if i := matchName(player.Name, names); i >= 0 {
+			matches[player.PlayerId] = users[i].Id
+		}
+	}
+	return matches
+}
+
+// MatchArenas maps every Matchplay arena to a machine, keyed by arena ID: the
+// machine of its OPDB ID or else the machine its name is trusted to match.
+func MatchArenas(arenas []Arena) map[int64]string {
+	matches := map[int64]string{}
+	for _, arena := range arenas {
+		if len(arena.OpdbId) > 0 && db.GetMachine(arena.OpdbId) != nil {
+			matches[arena.ArenaId] = arena.OpdbId
+		} else if machine := db.MatchMachine(arena.Name); machine != nil {
+			matches[arena.ArenaId] = machine.OpdbId
+		}
+	}
+	return matches
+}
+
+// Import records a Matchplay tournament with its players mapped to users and
+// its arenas mapped to machines. Games keep their rounds, order of play and
+// finishing places; the tournament continues here unless it was completed.
+func Import(export Tournament, players map[int64]int, arenas map[int64]string, createdBy int) (*db.Tournament, error) {
+	format, err := GetFormat(export.Type)
+	if err != nil {
+		return nil, err
+	}
+	if format == db.TournamentFormatStrikes && export.StrikeLimit < 1 {
+		return nil, ErrMissingStrikeLimit
+	}
+	var userIds []int
+	mapped := map[int]bool{}
+	for _, player := range export.Players {
+		userId, exists := players[player.PlayerId]
+		if !exists {
+			return nil, ErrUnmappedPlayer
+		}
+		if mapped[userId] {
+			return nil, ErrDuplicatePlayer
+		}
+		mapped[userId] = true
+		userIds = append(userIds, userId)
+	}
+
+	t := db.Tournament{
+		Name:        export.Name,
+		Format:      format,
+		State:       db.TournamentStateCheckIn,
+		GroupSize:   2,
+		StrikeLimit: export.StrikeLimit,
+		CreatedBy:   createdBy,
+	}
+	now := time.Now().Unix()
+	rounds := 0
+	var games []db.TournamentGame
+	for _, game := range export.Games {
+		opdbId, exists := arenas[game.ArenaId]
+		if !exists {
+			return nil, ErrUnmappedArena
+		}
+		imported := db.TournamentGame{
+			Round:  game.Round,
+			OpdbId: opdbId,
+		}
+		places := map[int64]int64{}
+		if game.Completed {
+			imported.CompletedAt.Int64, imported.CompletedAt.Valid = now, true
+			for i, playerId := range game.ResultPositions {
+				places[playerId] = int64(i + 1)
+			}
+		}
+		for i, playerId := range game.PlayerIds {
+			userId, exists := players[playerId]
+			if !exists {
+				return nil, ErrUnmappedPlayer
+			}
+			player := db.TournamentGamePlayer{
+				UserId:   userId,
+				Position: i + 1,
+			}
+			if place, exists := places[playerId]; exists {
+				player.Place.Int64, player.Place.Valid = place, true
+			}
+			imported.Players = append(imported.Players, player)
+		}
+		if len(imported.Players) > t.GroupSize {
+			t.GroupSize = len(imported.Players)
+		}
+		if game.Round > rounds {
+			rounds = game.Round
+		}
+		games = append(games, imported)
+	}
+
+	// Swiss and match play tournaments keep the rounds played so far and run
+	// the rounds of a new tournament when none was played yet
+	if rounds == 0 {
+		rounds = DefaultRounds
+	}
+	switch format {
+	case db.TournamentFormatSwiss:
+		t.GroupSize = 2
+		t.Rounds.Int64, t.Rounds.Valid = int64(rounds), true
+	case db.TournamentFormatMatchPlay:
+		t.GroupSize = tournament.MatchPlayGroupSize
+		t.Rounds.Int64, t.Rounds.Valid = int64(rounds), true
+		t.Scoring.String, t.Scoring.Valid = tournament.ScoringPapa, true
+	case db.TournamentFormatBracket:
+		t.GroupSize, t.StrikeLimit = 2, 1
+	}
+	if len(games) > 0 {
+		t.State = db.TournamentStateRunning
+	}
+	if export.Completed {
+		t.State = db.TournamentStateCompleted
+	}
+
+	created := db.ImportTournament(t, userIds, games)
+	if created == nil {
+		return nil, ErrNotImported
+	}
+	return created, nil
+}
@@ -0,0 +1,202 @@
+package matchplay
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/mikefero/tpl/db"
+)
+
+// testExport is a strikes tournament in the data envelope of the Matchplay
+// API with a completed first round and a second round in progress.
+const testExport = `{
+  "data": {
+    "tournamentId": 1234,
+    "name": " Thursday Strikes ",
+    "type": "strike_knockout",
+    "status": "started",
+    "options": {"strikeLimit": 4},
+    "players": [
+      {"playerId": 11, "name": "Ann"},
+      {"playerId": 12, "name": "Bob"},
+      {"playerId": 13, "name": "Cat"}
+    ],
+    "arenas": [
+      {"arenaId": 21, "name": "The Addams Family", "opdbId": "G4ODR-MDXEy"},
+      {"arenaId": 22, "name": "Medieval Madness"}
+    ],
+    "rounds": [
+      {"roundId": 32, "index": 1},
+      {"roundId": 31, "index": 0}
+    ],
+    "games": [
+      {"gameId": 42, "roundId": 32, "arenaId": 22, "playerIds": [13, 11], "resultPositions": [11], "status": "started"},
+      {"gameId": 41, "roundId": 31, "arenaId": 21, "playerIds": [11, 12], "resultPositions": [12, 11], "status": "completed"}
+    ]
+  }
+}`
+
+func TestParse(t *testing.T) {
+	export, err := Parse([]byte(testExport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Tournament{
+		Name:        "Thursday Strikes",
+		Type:        "strike_knockout",
+		StrikeLimit: 4,
+		Players: []Player{
+			{PlayerId: 11, Name: "Ann"},
+			{PlayerId: 12, Name: "Bob"},
+			{PlayerId: 13, Name: "Cat"},
+		},
+		Arenas: []Arena{
+			{ArenaId: 21, Name: "The Addams Family", OpdbId: "G4ODR-MDXEy"},
+			{ArenaId: 22, Name: "Medieval Madness"},
+		},
+		Games: []Game{
+			{GameId: 41, Round: 1, ArenaId: 21, PlayerIds: []int64{11, 12}, ResultPositions: []int64{12, 11}, Completed: true},
+			{GameId: 42, Round: 2, ArenaId: 22, PlayerIds: []int64{13, 11}, ResultPositions: []int64{11}},
+		},
+	}
+	if !reflect.DeepEqual(export, expected) {
+		t.Errorf("expected %+v, got %+v", expected, export)
+	}
+}
+
+func TestParseRoundsWithoutIndex(t *testing.T) {
+	export, err := Parse([]byte(`{
+  "name": "Swiss",
+  "type": "group_bracket",
+  "status": "completed",
+  "players": [],
+  "games": [
+    {"gameId": 3, "roundId": 9},
+    {"gameId": 1, "roundId": 7},
+    {"gameId": 2, "roundId": 8}
+  ]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !export.Completed || export.StrikeLimit != 0 {
+		t.Errorf("expected a completed tournament without strike limit, got %+v", export)
+	}
+	for i, round := range []int{1, 2, 3} {
+		if export.Games[i].Round != round || export.Games[i].GameId != int64(round) {
+			t.Errorf("expected game %d in round %d, got game %d in round %d", round, round, export.Games[i].GameId, export.Games[i].Round)
+		}
+	}
+}
+
+func TestParseInvalidExport(t *testing.T) {
+	for _, data := range []string{
+		``,
+		`not json`,
+		`[]`,
+		`{"name": "No players"}`,
+		`{"data": {"players": []}}`,
+	} {
+		if _, err := Parse([]byte(data)); err != ErrInvalidExport {
+			t.Errorf("expected %q to be rejected with %v, got %v", data, ErrInvalidExport, err)
+		}
+	}
+}
+
+func TestGetFormat(t *testing.T) {
+	for _, test := range []struct {
+		matchplayType string
+		format        string
+		err           error
+	}{
+		{"strike_knockout", db.TournamentFormatStrikes, nil},
+		{"group_bracket", db.TournamentFormatSwiss, nil},
+		{"single_elimination", db.TournamentFormatBracket, nil},
+		{"group_matchplay", db.TournamentFormatMatchPlay, nil},
+		{"double_elimination", "", ErrUnsupportedType},
+		{"group_strike_knockout", "", ErrUnsupportedType},
+		{"Strike_Knockout", "", ErrUnsupportedType},
+		{"best_game", "", ErrUnsupportedType},
+		{"", "", ErrUnsupportedType},
+	} {
+		format, err := GetFormat(test.matchplayType)
+		if format != test.format || err != test.err {
+			t.Errorf("expected %q to be %q (%v), got %q (%v)", test.matchplayType, test.format, test.err, format, err)
+		}
+	}
+}
+
+func TestImportRejected(t *testing.T) {
+	parsed, err := Parse([]byte(testExport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	players := map[int64]int{11: 1, 12: 2, 13: 3}
+	arenas := map[int64]string{21: "G4ODR-MDXEy", 22: "G5pe4-MePZv"}
+
+	for _, test := range []struct {
+		name   string
+		change func(export *Tournament, players map[int64]int, arenas map[int64]string)
+		err    error
+	}{
+		{"unsupported type", func(export *Tournament, players map[int64]int, arenas map[int64]string) {
+			export.Type = "flip_frenzy"
+		}, ErrUnsupportedType},
+		{"strikes without strike limit", func(export *Tournament, players map[int64]int, arenas map[int64]string) {
+			export.StrikeLimit = 0
+		}, ErrMissingStrikeLimit},
+		{"unmapped player", func(export *Tournament, players map[int64]int, arenas map[int64]string) {
+			delete(players, 12)
+		}, ErrUnmappedPlayer},
+		{"duplicate player", func(export *Tournament, players map[int64]int, arenas map[int64]string) {
+			players[13] = 1
+		}, ErrDuplicatePlayer},
+		{"unmapped arena", func(export *Tournament, players map[int64]int, arenas map[int64]string) {
+			delete(arenas, 22)
+		}, ErrUnmappedArena},
+	} {
+		export := *parsed
+		testPlayers := map[int64]int{}
+		for id, userId := range players {
+			testPlayers[id] = userId
+		}
+		testArenas := map[int64]string{}
+		for id, opdbId := range arenas {
+			testArenas[id] = opdbId
+		}
+		test.change(&export, testPlayers, testArenas)
+		if _, err := Import(export, testPlayers, testArenas, 1); err != test.err {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestMatchPlayers(t *testing.T) {
+	users := []db.User{
+		{Id: 1, Name: "Ann Smith"},
+		{Id: 2, Name: "Bob O'Neil"},
+		{Id: 3, Name: "Al"},
+	}
+	matches := MatchPlayers([]Player{
+		{PlayerId: 11, Name: "ann smith"},
+		{PlayerId: 12, Name: "Bob ONeil"},
+		{PlayerId: 13, Name: "Ed"},
+		{PlayerId: 14, Name: "Anne Smyth"},
+	}, users)
+	expected := map[int64]int{11: 1, 12: 2, 14: 1}
+	if !reflect.DeepEqual(matches, expected) {
+		t.Errorf("expected %v, got %v", expected, matches)
+	}
+}
+
+func TestWriteList(t *testing.T) {
+	var buffer bytes.Buffer
+	names := GetArenaList([]db.Machine{{Name: "The Addams Family"}, {Name: "Medieval Madness"}})
+	if err := WriteList(&buffer, names); err != nil {
+		t.Fatal(err)
+	}
+	if buffer.String() != "The Addams Family\nMedieval Madness\n" {
+		t.Errorf("expected one name per line, got %q", buffer.String())
+	}
+}
@@ -0,0 +1,189 @@
+package stats
+
+import (
+	"database/sql"
+	"sort"
+
+	"github.com/mikefero/tpl/db"
+)
+
+// RecentMeetings is the number of most recent meetings kept in a rivalry
+const RecentMeetings = 10
+
+// Meeting is a game or a match between two players or teams from the point of
+// view of the first one; matches between teams have no machine and their
+// scores are the team points of the match.
+type Meeting struct {
+	MatchId       int
+	OpdbId        string
+	MachineName   string
+	Date          sql.NullInt64
+	Score         int64
+	OpponentScore int64
+}
+
+type MachineRivalry struct {
+	Record
+	OpdbId        string
+	MachineName   string
+	AverageMargin float64
+}
+
+// Rivalry is the all-time record of a player or team against another one; the
+// margin is how much the first one scored more than the second one on average.
+type Rivalry struct {
+	Record
+	AverageMargin float64
+	Machines      []MachineRivalry
+	Recent        []Meeting
+}
+
+type rivalry struct {
+	machines map[string]*MachineRivalry
+	opdbIds  []string
+	margins  map[string]int64
+}
+
+// Outcome is 1 for a win of the first player or team, 0 for a loss and 0.5 for
+// a tie.
+func (meeting Meeting) Outcome() float64 {
+	return Matchup{Score: meeting.Score, OpponentScore: meeting.OpponentScore}.Outcome()
+}
+
+func (rivalry *rivalry) add(meeting Meeting) {
+	machine, exists := rivalry.machines[meeting.OpdbId]
+	if !exists {
+		machine = &MachineRivalry{
+			OpdbId:      meeting.OpdbId,
+			MachineName: meeting.MachineName,
+		}
+		rivalry.machines[meeting.OpdbId] = machine
+		rivalry.opdbIds = append(rivalry.opdbIds, meeting.OpdbId)
+	}
+	machine.addOutcome(meeting.Outcome())
+	rivalry.margins[meeting.OpdbId] += meeting.Score - meeting.OpponentScore
+}
+
+// getMachines returns the machine records ordered from the most meetings.
+func (rivalry *rivalry) getMachines() []MachineRivalry {
+	var machines []MachineRivalry
+	for _, opdbId := range rivalry.opdbIds {
+		machine := *rivalry.machines[opdbId]
+		machine.AverageMargin = float64(rivalry.margins[opdbId]) / float64(machine.Matchups())
+		machines = append(machines, machine)
+	}
+	sort.SliceStable(machines, func(i, j int) bool {
+		if machines[i].Matchups() != machines[j].Matchups() {
+			return machines[i].Matchups() > machines[j].Matchups()
+		}
+		return machines[i].WinRate() > machines[j].WinRate()
+	})
+	return machines
+}
+
+// getRivalry computes the overall record from meetings in the order they were
+// played and keeps the most recent ones first.
+func getRivalry(meetings []Meeting, machines []MachineRivalry) Rivalry {
+	rivalry := Rivalry{
+		Machines: machines,
+	}
+	var margin int64
+	for _, meeting := range meetings {
+		rivalry.addOutcome(meeting.Outcome())
+		margin += meeting.Score - meeting.OpponentScore
+	}
+	if len(meetings) > 0 {
+		rivalry.AverageMargin = float64(margin) / float64(len(meetings))
+	}
+	for i := len(meetings) - 1; i >= 0 && len(rivalry.Recent) < RecentMeetings; i-- {
+		rivalry.Recent = append(rivalry.Recent, meetings[i])
+	}
+	return rivalry
+}
+
+// ComparePlayers computes the record of a player against another one from the
+// league games in the order they were played. Every game where they played on
+// opposing teams is a meeting and the margin is in machine score.
+func ComparePlayers(games []db.PlayedGame, playerId int, opponentId int) Rivalry {
+	machines := rivalry{
+		machines: map[string]*MachineRivalry{},
+		margins:  map[string]int64{},
+	}
+	var meetings []Meeting
+	for _, matchup := range Rate(games).GetPlayerMatchups(playerId) {
+		if matchup.OpponentId != opponentId {
+			continue
+		}
+		meeting := Meeting{
+			MatchId:       matchup.MatchId,
+			OpdbId:        matchup.OpdbId,
+			MachineName:   matchup.MachineName,
+			Date:          matchup.Date,
+			Score:         matchup.Score,
+			OpponentScore: matchup.OpponentScore,
+		}
+		machines.add(meeting)
+		meetings = append(meetings, meeting)
+	}
+	return getRivalry(meetings, machines.getMachines())
+}
+
+// CompareTeams computes the record of a team against another one from the
+// league games in the order they were played. Machines are compared by the
+// team points of each game and the overall record by the team points of each
+// match.
+func CompareTeams(games []db.PlayedGame, teamId int, opponentId int) Rivalry {
+	machines := rivalry{
+		machines: map[string]*MachineRivalry{},
+		margins:  map[string]int64{},
+	}
+	var meetings []Meeting
+	matches := map[int]int{}
+	for _, game := range games {
+		result := game.Result
+		if !game.Team2Id.Valid || !result.Team1Score.Valid || !result.Team2Score.Valid {
+			continue
+		}
+		meeting := Meeting{
+			MatchId:       result.MatchId,
+			OpdbId:        result.OpdbId,
+			MachineName:   game.MachineName,
+			Date:          game.Date,
+			Score:         result.Team1Score.Int64,
+			OpponentScore: result.Team2Score.Int64,
+		}
+		switch {
+		case game.Team1Id == teamId && game.Team2Id.Int64 == int64(opponentId):
+		case game.Team1Id == opponentId && game.Team2Id.Int64 == int64(teamId):
+			meeting.Score, meeting.OpponentScore = meeting.OpponentScore, meeting.Score
+		default:
+			continue
+		}
+		machines.add(meeting)
+
+		i, exists := matches[result.MatchId]
+		if !exists {
+			i = len(meetings)
+			matches[result.MatchId] = i
+			meetings = append(meetings, Meeting{
+				MatchId: result.MatchId,
+				Date:    game.Date,
+			})
+		}
+		meetings[i].Score += meeting.Score
+		meetings[i].OpponentScore += meeting.OpponentScore
+	}
+	return getRivalry(meetings, machines.getMachines())
+}
+
+// GetPlayerRivalry computes the record of a player against another one from
+// the league results.
+func GetPlayerRivalry(playerId int, opponentId int) Rivalry {
+	return ComparePlayers(db.GetPlayedGames(), playerId, opponentId)
+}
+
+// GetTeamRivalry computes the record of a team against another one from the
+// league results.
+func GetTeamRivalry(teamId int, opponentId int) Rivalry {
+	return CompareTeams(db.GetPlayedGames(), teamId, opponentId)
+}
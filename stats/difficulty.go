@@ -0,0 +1,168 @@
+package stats
+
+import (
+	"database/sql"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/mikefero/tpl/db"
+)
+
+const DifficultySwingy = "swingy"
+const DifficultyBalanced = "balanced"
+const DifficultySkillHeavy = "skill-heavy"
+
+// MinimumRatedMatchups is the number of matchups between players of different
+// ratings needed before a machine is classified
+const MinimumRatedMatchups = 10
+
+// The favorite win rates at or below which a machine is swingy and at or above
+// which it is skill-heavy
+const swingyWinRate = 0.55
+const skillHeavyWinRate = 0.65
+
+// MachineDifficulty describes how predictable the outcome of a machine is.
+// The spread is the interquartile range of the scores relative to the median
+// and the favorite win rate is how often the higher rated player wins, with
+// ties counting as half a win.
+type MachineDifficulty struct {
+	OpdbId          string
+	MachineName     string
+	Scores          int
+	MedianScore     float64
+	LowerQuartile   float64
+	UpperQuartile   float64
+	Spread          float64
+	RatedMatchups   int
+	FavoriteWins    float64
+	FavoriteWinRate float64
+}
+
+// Classification returns whether a machine is swingy, balanced or
+// skill-heavy; machines without enough rated matchups are not classified.
+func (difficulty MachineDifficulty) Classification() string {
+	switch {
+	case difficulty.RatedMatchups < MinimumRatedMatchups:
+		return ""
+	case difficulty.FavoriteWinRate <= swingyWinRate:
+		return DifficultySwingy
+	case difficulty.FavoriteWinRate >= skillHeavyWinRate:
+		return DifficultySkillHeavy
+	}
+	return DifficultyBalanced
+}
+
+// GetQuantile returns the quantile of sorted values interpolating between the
+// closest values.
+func GetQuantile(sorted []float64, quantile float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	position := quantile * float64(len(sorted)-1)
+	lower := int(math.Floor(position))
+	upper := int(math.Ceil(position))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(position-float64(lower))
+}
+
+// GetDifficulties computes the difficulty of every machine from league games
+// in the order they were played, ordered from the most swingy machine to the
+// most skill-heavy one with unclassified machines last.
+func GetDifficulties(games []db.PlayedGame) []MachineDifficulty {
+	scores := map[string][]float64{}
+	difficulties := map[string]*MachineDifficulty{}
+	for _, game := range games {
+		result := game.Result
+		if _, exists := difficulties[result.OpdbId]; !exists {
+			difficulties[result.OpdbId] = &MachineDifficulty{
+				OpdbId:      result.OpdbId,
+				MachineName: game.MachineName,
+			}
+		}
+		for _, score := range []sql.NullInt64{
+			result.Team1APlayerScore,
+			result.Team1BPlayerScore,
+			result.Team2APlayerScore,
+			result.Team2BPlayerScore,
+		} {
+			if score.Valid {
+				scores[result.OpdbId] = append(scores[result.OpdbId], float64(score.Int64))
+			}
+		}
+	}
+
+	for _, matchup := range Rate(games).Matchups {
+		if matchup.Rating == matchup.OpponentRating {
+			continue
+		}
+		if matchup.Rating < matchup.OpponentRating {
+			matchup = matchup.Reverse()
+		}
+		difficulty := difficulties[matchup.OpdbId]
+		difficulty.RatedMatchups++
+		difficulty.FavoriteWins += matchup.Outcome()
+	}
+
+	var sorted []MachineDifficulty
+	for opdbId, difficulty := range difficulties {
+		machineScores := scores[opdbId]
+		sort.Float64s(machineScores)
+		difficulty.Scores = len(machineScores)
+		difficulty.MedianScore = GetQuantile(machineScores, 0.5)
+		difficulty.LowerQuartile = GetQuantile(machineScores, 0.25)
+		difficulty.UpperQuartile = GetQuantile(machineScores, 0.75)
+		if difficulty.MedianScore > 0 {
+			difficulty.Spread = (difficulty.UpperQuartile - difficulty.LowerQuartile) / difficulty.MedianScore
+		}
+		if difficulty.RatedMatchups > 0 {
+			difficulty.FavoriteWinRate = difficulty.FavoriteWins / float64(difficulty.RatedMatchups)
+		}
+		sorted = append(sorted, *difficulty)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		classifiedI := len(sorted[i].Classification()) > 0
+		classifiedJ := len(sorted[j].Classification()) > 0
+		if classifiedI != classifiedJ {
+			return classifiedI
+		}
+		if sorted[i].FavoriteWinRate != sorted[j].FavoriteWinRate {
+			return sorted[i].FavoriteWinRate < sorted[j].FavoriteWinRate
+		}
+		return sorted[i].OpdbId < sorted[j].OpdbId
+	})
+	return sorted
+}
+
+// machineDifficulties caches the difficulties computed from the league
+// results since every player must be rated to compute them
+var machineDifficulties struct {
+	sync.Mutex
+	loaded       bool
+	version      int64
+	difficulties []MachineDifficulty
+}
+
+// GetMachineDifficulties returns the difficulty of every machine played in
+// the league; difficulties are computed again once a result is recorded.
+func GetMachineDifficulties() []MachineDifficulty {
+	version := db.GetResultsVersion()
+	machineDifficulties.Lock()
+	defer machineDifficulties.Unlock()
+	if !machineDifficulties.loaded || machineDifficulties.version != version {
+		machineDifficulties.difficulties = GetDifficulties(db.GetPlayedGames())
+		machineDifficulties.version = version
+		machineDifficulties.loaded = true
+	}
+	return machineDifficulties.difficulties
+}
+
+// GetMachineDifficulty returns the difficulty of a machine; players are rated
+// from every league game so the favorite of each matchup is known.
+func GetMachineDifficulty(opdbId string) *MachineDifficulty {
+	for _, difficulty := range GetMachineDifficulties() {
+		if difficulty.OpdbId == opdbId {
+			return &difficulty
+		}
+	}
+	return nil
+}
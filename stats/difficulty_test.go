@@ -0,0 +1,189 @@
+package stats
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mikefero/tpl/db"
+)
+
+// databasePath is the league database the tests reading results use; it has
+// two teams and a match between them without results.
+var databasePath string
+
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "tpl-stats")
+	if err != nil {
+		panic(err)
+	}
+	status := func() int {
+		defer os.RemoveAll(dir)
+		databasePath = filepath.Join(dir, "tpl.db")
+		db.Initialize(db.Config{
+			Path:           databasePath,
+			OpdbExportPath: filepath.Join("..", "db", "opdb.json"),
+		})
+		defer db.Close()
+		execTestStatements(
+			`INSERT INTO leagues (id, name, active) VALUES (1, 'Monday', true)`,
+			`INSERT INTO seasons (id, league_id, name, start_date) VALUES (1, 1, 'Spring', 0)`,
+			`INSERT INTO users (id, league_id, email, password, name, active) VALUES
+  (1, 1, 'ann@example.com', '', 'Ann', true),
+  (2, 1, 'bob@example.com', '', 'Bob', true),
+  (3, 1, 'cat@example.com', '', 'Cat', true),
+  (4, 1, 'dan@example.com', '', 'Dan', true)`,
+			`INSERT INTO teams (id, league_id, name, a_player, b_player, active) VALUES
+  (1, 1, 'Flippers', 1, 2, true),
+  (2, 1, 'Tilt', 3, 4, true)`,
+			`INSERT INTO matches (id, league_id, season_id, team_1_id, team_2_id, week) VALUES (1, 1, 1, 1, 2, 1)`)
+
+		return m.Run()
+	}()
+	os.Exit(status)
+}
+
+func execTestStatements(statements ...string) {
+	session, err := sql.Open("sqlite3", databasePath)
+	if err != nil {
+		panic(err)
+	}
+	defer session.Close()
+	for _, statement := range statements {
+		if _, err := session.Exec(statement); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func TestGetQuantile(t *testing.T) {
+	for _, test := range []struct {
+		sorted   []float64
+		quantile float64
+		expected float64
+	}{
+		{nil, 0.5, 0},
+		{[]float64{100}, 0.25, 100},
+		{[]float64{100, 300}, 0.5, 200},
+		{[]float64{100, 300}, 0.25, 150},
+		{[]float64{100, 200, 300, 400, 500}, 0.5, 300},
+		{[]float64{100, 200, 300, 400, 500}, 0.25, 200},
+		{[]float64{100, 200, 300, 400, 500}, 0.75, 400},
+		{[]float64{100, 200, 300, 400}, 0.75, 325},
+		{[]float64{100, 200, 300, 400}, 0, 100},
+		{[]float64{100, 200, 300, 400}, 1, 400},
+	} {
+		if quantile := GetQuantile(test.sorted, test.quantile); !isClose(quantile, test.expected) {
+			t.Errorf("expected quantile %v of %v to be %v, got %v", test.quantile, test.sorted, test.expected, quantile)
+		}
+	}
+}
+
+func TestClassification(t *testing.T) {
+	for _, test := range []struct {
+		ratedMatchups   int
+		favoriteWinRate float64
+		classification  string
+	}{
+		{MinimumRatedMatchups - 1, 1, ""},
+		{MinimumRatedMatchups, 0.5, DifficultySwingy},
+		{MinimumRatedMatchups, 0.55, DifficultySwingy},
+		{MinimumRatedMatchups, 0.6, DifficultyBalanced},
+		{MinimumRatedMatchups, 0.65, DifficultySkillHeavy},
+		{MinimumRatedMatchups, 1, DifficultySkillHeavy},
+	} {
+		difficulty := MachineDifficulty{
+			RatedMatchups:   test.ratedMatchups,
+			FavoriteWinRate: test.favoriteWinRate,
+		}
+		if classification := difficulty.Classification(); classification != test.classification {
+			t.Errorf("expected %d rated matchups won %v by the favorite to be %q, got %q", test.ratedMatchups, test.favoriteWinRate, test.classification, classification)
+		}
+	}
+}
+
+func TestGetDifficulties(t *testing.T) {
+	// The favorite always wins on Attack from Mars and every other game on
+	// Medieval Madness; The Addams Family is played once
+	var games []db.PlayedGame
+	for i := 0; i <= MinimumRatedMatchups; i++ {
+		games = append(games, newTestGame(len(games)+1, "G4do5-MDlN7", []testPlayer{{1, 200, false}}, []testPlayer{{2, 100, false}}))
+	}
+	for i := 0; i < 2*MinimumRatedMatchups; i++ {
+		ratings := Rate(games).Players
+		favoriteWins := i%2 == 1
+		score := int64(100)
+		if favoriteWins == (ratings[1] > ratings[2]) {
+			score = 200
+		}
+		games = append(games, newTestGame(len(games)+1, "G5pe4-MePZv", []testPlayer{{1, score, false}}, []testPlayer{{2, 150, false}}))
+	}
+	games = append(games, newTestGame(len(games)+1, "G4ODR-MDXEy", []testPlayer{{1, 100, false}}, []testPlayer{{2, 300, false}}))
+
+	difficulties := GetDifficulties(games)
+	if len(difficulties) != 3 {
+		t.Fatalf("expected 3 machines, got %d", len(difficulties))
+	}
+	for i, test := range []struct {
+		opdbId          string
+		classification  string
+		ratedMatchups   int
+		favoriteWinRate float64
+	}{
+		{"G5pe4-MePZv", DifficultySwingy, 2 * MinimumRatedMatchups, 0.5},
+		{"G4do5-MDlN7", DifficultySkillHeavy, MinimumRatedMatchups, 1},
+		{"G4ODR-MDXEy", "", 1, 0},
+	} {
+		difficulty := difficulties[i]
+		if difficulty.OpdbId != test.opdbId {
+			t.Errorf("expected %s at position %d, got %s", test.opdbId, i, difficulty.OpdbId)
+			continue
+		}
+		if classification := difficulty.Classification(); classification != test.classification {
+			t.Errorf("expected %s to be %q, got %q", test.opdbId, test.classification, classification)
+		}
+		if difficulty.RatedMatchups != test.ratedMatchups || !isClose(difficulty.FavoriteWinRate, test.favoriteWinRate) {
+			t.Errorf("expected %s to have %d rated matchups won %v by the favorite, got %d won %v",
+				test.opdbId, test.ratedMatchups, test.favoriteWinRate, difficulty.RatedMatchups, difficulty.FavoriteWinRate)
+		}
+	}
+
+	addams := difficulties[2]
+	if addams.Scores != 2 || addams.MedianScore != 200 || addams.LowerQuartile != 150 || addams.UpperQuartile != 250 || addams.Spread != 0.5 {
+		t.Errorf("expected the scores of The Addams Family to spread 0.5 around 200, got %+v", addams)
+	}
+}
+
+func TestGetMachineDifficultiesCache(t *testing.T) {
+	if difficulties := GetMachineDifficulties(); len(difficulties) != 0 {
+		t.Fatalf("expected no difficulties without results, got %v", difficulties)
+	}
+
+	result := newTestGame(0, "G4ODR-MDXEy", []testPlayer{{1, 200, false}}, []testPlayer{{3, 100, false}}).Result
+	if db.InsertResult(result) == nil {
+		t.Fatal("unable to record result")
+	}
+	difficulty := GetMachineDifficulty("G4ODR-MDXEy")
+	if difficulty == nil || difficulty.Scores != 2 {
+		t.Fatalf("expected the difficulty of the recorded result, got %+v", difficulty)
+	}
+
+	// Results written to the database directly keep the cached difficulties
+	// until a result is recorded
+	execTestStatements(`INSERT INTO results (match_id, opdb_id, team_1_a_player_id, team_1_a_player_score, team_2_a_player_id, team_2_a_player_score)
+  VALUES (1, 'G5pe4-MePZv', 2, 100, 4, 200)`)
+	if difficulty := GetMachineDifficulty("G5pe4-MePZv"); difficulty != nil {
+		t.Errorf("expected the difficulties to be cached, got %+v", difficulty)
+	}
+	if db.InsertResult(result) == nil {
+		t.Fatal("unable to record result")
+	}
+	if difficulty := GetMachineDifficulty("G5pe4-MePZv"); difficulty == nil {
+		t.Error("expected the difficulties to be computed again")
+	}
+	if difficulty := GetMachineDifficulty("G4ODR-MDXEy"); difficulty == nil || difficulty.Scores != 4 {
+		t.Errorf("expected the difficulty of both recorded results, got %+v", difficulty)
+	}
+}
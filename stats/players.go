@@ -0,0 +1,186 @@
+package stats
+
+import (
+	"database/sql"
+	"sort"
+
+	"github.com/mikefero/tpl/db"
+)
+
+// Record counts the matchups a player won, lost and tied
+type Record struct {
+	Wins   int
+	Losses int
+	Ties   int
+}
+
+// GameScore is the score of a player in a league game
+type GameScore struct {
+	ResultId int
+	MatchId  int
+	Date     sql.NullInt64
+	Score    int64
+}
+
+type MachineRecord struct {
+	Record
+	OpdbId      string
+	MachineName string
+	Scores      []GameScore
+	BestScore   int64
+}
+
+type OpponentRecord struct {
+	Record
+	OpponentId int
+}
+
+type PlayerStats struct {
+	Record
+	PlayerId      int
+	Rating        float64
+	RatingHistory []RatingChange
+	Machines      []MachineRecord
+	Opponents     []OpponentRecord
+}
+
+func (record *Record) addOutcome(outcome float64) {
+	switch outcome {
+	case 1:
+		record.Wins++
+	case 0:
+		record.Losses++
+	default:
+		record.Ties++
+	}
+}
+
+func (record *Record) add(matchup Matchup) {
+	record.addOutcome(matchup.Outcome())
+}
+
+func (record Record) Matchups() int {
+	return record.Wins + record.Losses + record.Ties
+}
+
+// WinRate returns the share of matchups won with ties counting as half a win.
+func (record Record) WinRate() float64 {
+	if record.Matchups() == 0 {
+		return 0
+	}
+	return (float64(record.Wins) + float64(record.Ties)/2) / float64(record.Matchups())
+}
+
+// GetScores returns the scores of a machine in the order they were played.
+func (record MachineRecord) GetScores() []float64 {
+	var scores []float64
+	for _, score := range record.Scores {
+		scores = append(scores, float64(score.Score))
+	}
+	return scores
+}
+
+// GetRatings returns the ratings of a player in the order they were earned
+// starting from the initial rating.
+func (player PlayerStats) GetRatings() []float64 {
+	ratings := []float64{InitialRating}
+	for _, change := range player.RatingHistory {
+		ratings = append(ratings, change.Rating)
+	}
+	return ratings
+}
+
+func getPlayerScore(result db.Result, playerId int) (int64, bool) {
+	for _, player := range [][2]sql.NullInt64{
+		{result.Team1APlayerId, result.Team1APlayerScore},
+		{result.Team1BPlayerId, result.Team1BPlayerScore},
+		{result.Team2APlayerId, result.Team2APlayerScore},
+		{result.Team2BPlayerId, result.Team2BPlayerScore},
+	} {
+		if player[0].Valid && player[0].Int64 == int64(playerId) && player[1].Valid {
+			return player[1].Int64, true
+		}
+	}
+	return 0, false
+}
+
+// GetPlayerStats computes the record of a player from league games in the
+// order they were played. Machines are ordered from the best win rate and
+// opponents from the most matchups.
+func GetPlayerStats(games []db.PlayedGame, playerId int) PlayerStats {
+	ratings := Rate(games)
+	player := PlayerStats{
+		PlayerId:      playerId,
+		Rating:        InitialRating,
+		RatingHistory: ratings.History[playerId],
+	}
+	if rating, exists := ratings.Players[playerId]; exists {
+		player.Rating = rating
+	}
+
+	machines := map[string]*MachineRecord{}
+	var opdbIds []string
+	for _, game := range games {
+		score, played := getPlayerScore(game.Result, playerId)
+		if !played {
+			continue
+		}
+		machine, exists := machines[game.Result.OpdbId]
+		if !exists {
+			machine = &MachineRecord{
+				OpdbId:      game.Result.OpdbId,
+				MachineName: game.MachineName,
+			}
+			machines[game.Result.OpdbId] = machine
+			opdbIds = append(opdbIds, game.Result.OpdbId)
+		}
+		machine.Scores = append(machine.Scores, GameScore{
+			ResultId: game.Result.Id,
+			MatchId:  game.Result.MatchId,
+			Date:     game.Date,
+			Score:    score,
+		})
+		if score > machine.BestScore {
+			machine.BestScore = score
+		}
+	}
+
+	opponents := map[int]*OpponentRecord{}
+	var opponentIds []int
+	for _, matchup := range ratings.GetPlayerMatchups(playerId) {
+		player.add(matchup)
+		machines[matchup.OpdbId].add(matchup)
+		opponent, exists := opponents[matchup.OpponentId]
+		if !exists {
+			opponent = &OpponentRecord{
+				OpponentId: matchup.OpponentId,
+			}
+			opponents[matchup.OpponentId] = opponent
+			opponentIds = append(opponentIds, matchup.OpponentId)
+		}
+		opponent.add(matchup)
+	}
+
+	for _, opdbId := range opdbIds {
+		player.Machines = append(player.Machines, *machines[opdbId])
+	}
+	sort.SliceStable(player.Machines, func(i, j int) bool {
+		if player.Machines[i].WinRate() != player.Machines[j].WinRate() {
+			return player.Machines[i].WinRate() > player.Machines[j].WinRate()
+		}
+		return player.Machines[i].Matchups() > player.Machines[j].Matchups()
+	})
+	for _, opponentId := range opponentIds {
+		player.Opponents = append(player.Opponents, *opponents[opponentId])
+	}
+	sort.SliceStable(player.Opponents, func(i, j int) bool {
+		return player.Opponents[i].Matchups() > player.Opponents[j].Matchups()
+	})
+
+	return player
+}
+
+// GetPlayer computes the record of a player from the league results.
+func GetPlayer(playerId int) PlayerStats {
+	return GetPlayerStats(db.GetPlayedGames(), playerId)
+}
@@ -0,0 +1,185 @@
+package stats
+
+import (
+	"database/sql"
+	"math"
+
+	"github.com/mikefero/tpl/db"
+)
+
+// InitialRating is the rating of a player before their first league game
+const InitialRating = 1500.0
+
+// ratingFactor is the most a player's rating can move in a single game
+const ratingFactor = 32.0
+
+// Matchup is a game between two players of opposing teams on the same
+// machine; ratings are the ratings of the players before the game. Matchups
+// with a sub on either side do not change ratings.
+type Matchup struct {
+	ResultId       int
+	MatchId        int
+	OpdbId         string
+	MachineName    string
+	Date           sql.NullInt64
+	PlayerId       int
+	Score          int64
+	Rating         float64
+	OpponentId     int
+	OpponentScore  int64
+	OpponentRating float64
+	Sub            bool
+}
+
+// RatingChange is a player's rating after a league game
+type RatingChange struct {
+	ResultId int
+	MatchId  int
+	OpdbId   string
+	Date     sql.NullInt64
+	Rating   float64
+}
+
+type Ratings struct {
+	Players  map[int]float64
+	History  map[int][]RatingChange
+	Matchups []Matchup
+}
+
+// playerResult is a player of a result with their score and sub flag
+type playerResult struct {
+	id    sql.NullInt64
+	score sql.NullInt64
+	sub   bool
+}
+
+type playerScore struct {
+	id    int
+	score int64
+	sub   bool
+}
+
+// Outcome is 1 for a win of the player, 0 for a loss and 0.5 for a tie.
+func (matchup Matchup) Outcome() float64 {
+	switch {
+	case matchup.Score > matchup.OpponentScore:
+		return 1
+	case matchup.Score < matchup.OpponentScore:
+		return 0
+	}
+	return 0.5
+}
+
+// Reverse returns the matchup from the point of view of the opponent.
+func (matchup Matchup) Reverse() Matchup {
+	matchup.PlayerId, matchup.OpponentId = matchup.OpponentId, matchup.PlayerId
+	matchup.Score, matchup.OpponentScore = matchup.OpponentScore, matchup.Score
+	matchup.Rating, matchup.OpponentRating = matchup.OpponentRating, matchup.Rating
+	return matchup
+}
+
+// GetExpectedOutcome returns the chance of a player beating an opponent.
+func GetExpectedOutcome(rating float64, opponentRating float64) float64 {
+	return 1 / (1 + math.Pow(10, (opponentRating-rating)/400))
+}
+
+func getPlayerScores(players ...playerResult) []playerScore {
+	var scores []playerScore
+	for _, player := range players {
+		if player.id.Valid && player.score.Valid {
+			scores = append(scores, playerScore{
+				id:    int(player.id.Int64),
+				score: player.score.Int64,
+				sub:   player.sub,
+			})
+		}
+	}
+	return scores
+}
+
+// Rate computes Elo ratings from league games, which must be in the order they
+// were played. Every player is matched against each player of the opposing
+// team and their rating moves by at most ratingFactor per game. Games with a
+// sub are kept as matchups but leave the ratings of both players unchanged
+// since subs only fill in for a team.
+func Rate(games []db.PlayedGame) Ratings {
+	ratings := Ratings{
+		Players: map[int]float64{},
+		History: map[int][]RatingChange{},
+	}
+	getRating := func(id int) float64 {
+		if rating, exists := ratings.Players[id]; exists {
+			return rating
+		}
+		return InitialRating
+	}
+
+	for _, game := range games {
+		result := game.Result
+		team1 := getPlayerScores(playerResult{result.Team1APlayerId, result.Team1APlayerScore, result.Team1APlayerSub},
+			playerResult{result.Team1BPlayerId, result.Team1BPlayerScore, result.Team1BPlayerSub})
+		team2 := getPlayerScores(playerResult{result.Team2APlayerId, result.Team2APlayerScore, result.Team2APlayerSub},
+			playerResult{result.Team2BPlayerId, result.Team2BPlayerScore, result.Team2BPlayerSub})
+		if len(team1) == 0 || len(team2) == 0 {
+			continue
+		}
+
+		changes := map[int]float64{}
+		for _, player := range team1 {
+			for _, opponent := range team2 {
+				matchup := Matchup{
+					ResultId:       result.Id,
+					MatchId:        result.MatchId,
+					OpdbId:         result.OpdbId,
+					MachineName:    game.MachineName,
+					Date:           game.Date,
+					PlayerId:       player.id,
+					Score:          player.score,
+					Rating:         getRating(player.id),
+					OpponentId:     opponent.id,
+					OpponentScore:  opponent.score,
+					OpponentRating: getRating(opponent.id),
+					Sub:            player.sub || opponent.sub,
+				}
+				ratings.Matchups = append(ratings.Matchups, matchup)
+				if matchup.Sub {
+					continue
+				}
+
+				change := matchup.Outcome() - GetExpectedOutcome(matchup.Rating, matchup.OpponentRating)
+				changes[player.id] += ratingFactor * change / float64(len(team2))
+				changes[opponent.id] -= ratingFactor * change / float64(len(team1))
+			}
+		}
+		for id, change := range changes {
+			ratings.Players[id] = getRating(id) + change
+			ratings.History[id] = append(ratings.History[id], RatingChange{
+				ResultId: result.Id,
+				MatchId:  result.MatchId,
+				OpdbId:   result.OpdbId,
+				Date:     game.Date,
+				Rating:   ratings.Players[id],
+			})
+		}
+	}
+
+	return ratings
+}
+
+// GetRatings rates every player from the league results.
+func GetRatings() Ratings {
+	return Rate(db.GetPlayedGames())
+}
+
+// GetPlayerMatchups returns the matchups of a player from their point of view.
+func (ratings Ratings) GetPlayerMatchups(playerId int) []Matchup {
+	var matchups []Matchup
+	for _, matchup := range ratings.Matchups {
+		if matchup.PlayerId == playerId {
+			matchups = append(matchups, matchup)
+		} else if matchup.OpponentId == playerId {
+			matchups = append(matchups, matchup.Reverse())
+		}
+	}
+	return matchups
+}
@@ -0,0 +1,161 @@
+package stats
+
+import (
+	"database/sql"
+	"math"
+	"testing"
+
+	"github.com/mikefero/tpl/db"
+)
+
+// testPlayer is a player of a test game with their score; subs are flagged.
+type testPlayer struct {
+	id    int64
+	score int64
+	sub   bool
+}
+
+// newTestGame returns a league game on a machine between the players of two
+// teams.
+func newTestGame(id int, opdbId string, team1 []testPlayer, team2 []testPlayer) db.PlayedGame {
+	result := db.Result{
+		Id:      id,
+		MatchId: 1,
+		OpdbId:  opdbId,
+	}
+	players := []struct {
+		id    *sql.NullInt64
+		score *sql.NullInt64
+		sub   *bool
+	}{
+		{&result.Team1APlayerId, &result.Team1APlayerScore, &result.Team1APlayerSub},
+		{&result.Team1BPlayerId, &result.Team1BPlayerScore, &result.Team1BPlayerSub},
+		{&result.Team2APlayerId, &result.Team2APlayerScore, &result.Team2APlayerSub},
+		{&result.Team2BPlayerId, &result.Team2BPlayerScore, &result.Team2BPlayerSub},
+	}
+	for side, team := range [][]testPlayer{team1, team2} {
+		for i, player := range team {
+			*players[2*side+i].id = sql.NullInt64{Int64: player.id, Valid: true}
+			*players[2*side+i].score = sql.NullInt64{Int64: player.score, Valid: true}
+			*players[2*side+i].sub = player.sub
+		}
+	}
+	return db.PlayedGame{
+		Result:      result,
+		MachineName: opdbId,
+		Team1Id:     1,
+		Team2Id:     sql.NullInt64{Int64: 2, Valid: true},
+	}
+}
+
+func isClose(a float64, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestGetExpectedOutcome(t *testing.T) {
+	for _, test := range []struct {
+		rating         float64
+		opponentRating float64
+		expected       float64
+	}{
+		{1500, 1500, 0.5},
+		{1900, 1500, 10.0 / 11.0},
+		{1500, 1900, 1.0 / 11.0},
+		{2300, 1500, 100.0 / 101.0},
+	} {
+		if expected := GetExpectedOutcome(test.rating, test.opponentRating); !isClose(expected, test.expected) {
+			t.Errorf("expected %v against %v to win %v, got %v", test.rating, test.opponentRating, test.expected, expected)
+		}
+	}
+}
+
+func TestRate(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		games   []db.PlayedGame
+		ratings map[int]float64
+	}{
+		{
+			name: "win between new players",
+			games: []db.PlayedGame{
+				newTestGame(1, "G4ODR-MDXEy", []testPlayer{{1, 200, false}}, []testPlayer{{2, 100, false}}),
+			},
+			ratings: map[int]float64{1: 1516, 2: 1484},
+		},
+		{
+			name: "tie between new players",
+			games: []db.PlayedGame{
+				newTestGame(1, "G4ODR-MDXEy", []testPlayer{{1, 100, false}}, []testPlayer{{2, 100, false}}),
+			},
+			ratings: map[int]float64{1: 1500, 2: 1500},
+		},
+		{
+			name: "doubles split the change between opponents",
+			games: []db.PlayedGame{
+				newTestGame(1, "G4ODR-MDXEy", []testPlayer{{1, 400, false}, {2, 300, false}}, []testPlayer{{3, 200, false}, {4, 100, false}}),
+			},
+			ratings: map[int]float64{1: 1516, 2: 1516, 3: 1484, 4: 1484},
+		},
+		{
+			name: "games are rated in order",
+			games: []db.PlayedGame{
+				newTestGame(1, "G4ODR-MDXEy", []testPlayer{{1, 200, false}}, []testPlayer{{2, 100, false}}),
+				newTestGame(2, "G4ODR-MDXEy", []testPlayer{{1, 100, false}}, []testPlayer{{2, 200, false}}),
+			},
+			ratings: map[int]float64{
+				1: 1516 - 32*GetExpectedOutcome(1516, 1484),
+				2: 1484 + 32*GetExpectedOutcome(1516, 1484),
+			},
+		},
+		{
+			name: "subs leave ratings unchanged",
+			games: []db.PlayedGame{
+				newTestGame(1, "G4ODR-MDXEy", []testPlayer{{1, 400, false}, {5, 300, true}}, []testPlayer{{3, 200, false}}),
+			},
+			ratings: map[int]float64{1: 1516, 3: 1492},
+		},
+		{
+			name: "games without an opponent are not rated",
+			games: []db.PlayedGame{
+				newTestGame(1, "G4ODR-MDXEy", []testPlayer{{1, 400, false}}, nil),
+			},
+			ratings: map[int]float64{},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ratings := Rate(test.games)
+			if len(ratings.Players) != len(test.ratings) {
+				t.Fatalf("expected %d rated players, got %v", len(test.ratings), ratings.Players)
+			}
+			for id, expected := range test.ratings {
+				if rating := ratings.Players[id]; !isClose(rating, expected) {
+					t.Errorf("expected player %d to be rated %v, got %v", id, expected, rating)
+				}
+			}
+		})
+	}
+}
+
+func TestRateMatchups(t *testing.T) {
+	ratings := Rate([]db.PlayedGame{
+		newTestGame(1, "G4ODR-MDXEy", []testPlayer{{1, 200, false}}, []testPlayer{{2, 100, false}, {5, 300, true}}),
+		newTestGame(2, "G4ODR-MDXEy", []testPlayer{{1, 100, false}}, []testPlayer{{2, 200, false}}),
+	})
+	if len(ratings.Matchups) != 3 {
+		t.Fatalf("expected 3 matchups, got %d", len(ratings.Matchups))
+	}
+	if !ratings.Matchups[1].Sub {
+		t.Error("expected the matchup against a sub to be flagged")
+	}
+	if last := ratings.Matchups[2]; last.Rating != 1508 || last.OpponentRating != 1484 {
+		t.Errorf("expected matchups to hold the ratings before the game, got %v and %v", last.Rating, last.OpponentRating)
+	}
+	if history := ratings.History[1]; len(history) != 2 || history[0].Rating != 1508 {
+		t.Errorf("expected the rating history of player 1 to start at 1508, got %v", history)
+	}
+
+	matchups := ratings.GetPlayerMatchups(2)
+	if len(matchups) != 2 || matchups[0].PlayerId != 2 || matchups[0].Outcome() != 0 || matchups[1].Outcome() != 1 {
+		t.Errorf("expected the matchups of player 2 from their point of view, got %v", matchups)
+	}
+}
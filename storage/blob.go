@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// ErrBlobNotFound is returned when a blob does not exist in a store
+var ErrBlobNotFound = errors.New("blob not found")
+
+// ErrInvalidKey is returned for keys that could escape the store
+var ErrInvalidKey = errors.New("invalid blob key")
+
+var reBlobKey = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// BlobStore persists opaque binary objects by key so that uploads can move
+// from local disk to another backend without changing their callers.
+type BlobStore interface {
+	Put(key string, data io.Reader) error
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// LocalBlobStore keeps blobs as files within a directory.
+type LocalBlobStore struct {
+	root string
+}
+
+func NewLocalBlobStore(root string) (*LocalBlobStore, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	return &LocalBlobStore{
+		root: root,
+	}, nil
+}
+
+func (store *LocalBlobStore) getPath(key string) (string, error) {
+	if !reBlobKey.MatchString(key) {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(store.root, key), nil
+}
+
+// Put writes the blob to a temporary file first so that readers never see a
+// partially written blob.
+func (store *LocalBlobStore) Put(key string, data io.Reader) error {
+	path, err := store.getPath(key)
+	if err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(store.root, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := io.Copy(file, data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+func (store *LocalBlobStore) Get(key string) (io.ReadCloser, error) {
+	path, err := store.getPath(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, ErrBlobNotFound
+	}
+	return file, err
+}
+
+func (store *LocalBlobStore) Delete(key string) error {
+	path, err := store.getPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// newTestExif returns an APP1 segment holding an EXIF orientation in the byte
+// order of a TIFF header.
+func newTestExif(order binary.ByteOrder, orientation int) []byte {
+	tiff := make([]byte, 26)
+	copy(tiff, "II")
+	if order == binary.BigEndian {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	order.PutUint16(tiff[10:], exifOrientationTag)
+	order.PutUint16(tiff[12:], 3)
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], uint16(orientation))
+	return newTestSegment(0xE1, append([]byte("Exif\x00\x00"), tiff...))
+}
+
+func newTestSegment(marker byte, payload []byte) []byte {
+	segment := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// newTestJPEG encodes an image whose left half is red and right half is blue
+// with segments inserted after its start of image marker.
+func newTestJPEG(t *testing.T, width int, height int, segments ...[]byte) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: 255, A: 255})
+			if x >= width/2 {
+				img.Set(x, y, color.RGBA{B: 255, A: 255})
+			}
+		}
+	}
+	var buffer bytes.Buffer
+	if err := jpeg.Encode(&buffer, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buffer.Bytes()
+	return append(append(data[:2:2], bytes.Join(segments, nil)...), data[2:]...)
+}
+
+func TestGetExifOrientation(t *testing.T) {
+	soi := []byte{0xFF, 0xD8}
+	exif := newTestExif(binary.LittleEndian, 6)
+	for _, test := range []struct {
+		name        string
+		data        []byte
+		orientation int
+	}{
+		{"empty", nil, 1},
+		{"not a JPEG", []byte("\x89PNG\r\n\x1a\n"), 1},
+		{"without EXIF", newTestJPEG(t, 4, 4), 1},
+		{"after other segments", newTestJPEG(t, 4, 4, newTestSegment(0xE0, []byte("JFIF\x00")), exif), 6},
+		{"big endian", append(soi, newTestExif(binary.BigEndian, 8)...), 8},
+		{"truncated segment", append(soi, exif[:len(exif)-4]...), 1},
+		{"segment shorter than its length", append(soi, 0xFF, 0xE1, 0, 1), 1},
+		{"APP1 without EXIF", append(soi, newTestSegment(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00"))...), 1},
+		{"EXIF after the image data", append(soi, append(newTestSegment(0xDA, []byte{0}), exif...)...), 1},
+		{"unknown byte order", append(soi, newTestSegment(0xE1, []byte("Exif\x00\x00XX*\x00\x08\x00\x00\x00"))...), 1},
+		{"TIFF header only", append(soi, newTestSegment(0xE1, []byte("Exif\x00\x00II*\x00"))...), 1},
+		{"IFD outside the TIFF", append(soi, newTestSegment(0xE1, []byte("Exif\x00\x00II*\x00\xff\x00\x00\x00"))...), 1},
+		{"more entries than the IFD holds", append(soi, newTestSegment(0xE1, []byte("Exif\x00\x00II*\x00\x08\x00\x00\x00\x09\x00"))...), 1},
+		{"orientation out of range", append(soi, newTestExif(binary.LittleEndian, 9)...), 1},
+	} {
+		if orientation := getExifOrientation(test.data); orientation != test.orientation {
+			t.Errorf("%s: expected orientation %d, got %d", test.name, test.orientation, orientation)
+		}
+	}
+
+	for orientation := 1; orientation <= 8; orientation++ {
+		data := newTestJPEG(t, 4, 4, newTestExif(binary.LittleEndian, orientation))
+		if parsed := getExifOrientation(data); parsed != orientation {
+			t.Errorf("expected orientation %d, got %d", orientation, parsed)
+		}
+	}
+
+	// Every truncation of a photo is parsed without reading past its end
+	data := newTestJPEG(t, 4, 4, newTestExif(binary.BigEndian, 3))
+	for i := range data {
+		getExifOrientation(data[:i])
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	// A 3x2 image where the pixels are numbered from left to right and top to
+	// bottom; the table follows where the top corners end up upright
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for i := 0; i < 6; i++ {
+		src.Set(i%3, i/3, color.RGBA{R: uint8(i + 1), A: 255})
+	}
+	topLeft, topRight := src.RGBAAt(0, 0), src.RGBAAt(2, 0)
+	for _, test := range []struct {
+		orientation int
+		width       int
+		height      int
+		topLeft     image.Point
+		topRight    image.Point
+	}{
+		{0, 3, 2, image.Pt(0, 0), image.Pt(2, 0)},
+		{1, 3, 2, image.Pt(0, 0), image.Pt(2, 0)},
+		{2, 3, 2, image.Pt(2, 0), image.Pt(0, 0)},
+		{3, 3, 2, image.Pt(2, 1), image.Pt(0, 1)},
+		{4, 3, 2, image.Pt(0, 1), image.Pt(2, 1)},
+		{5, 2, 3, image.Pt(0, 0), image.Pt(0, 2)},
+		{6, 2, 3, image.Pt(1, 0), image.Pt(1, 2)},
+		{7, 2, 3, image.Pt(1, 2), image.Pt(1, 0)},
+		{8, 2, 3, image.Pt(0, 2), image.Pt(0, 0)},
+		{9, 3, 2, image.Pt(0, 0), image.Pt(2, 0)},
+	} {
+		dst := applyOrientation(src, test.orientation)
+		if dst.Rect.Dx() != test.width || dst.Rect.Dy() != test.height {
+			t.Errorf("orientation %d: expected %dx%d, got %v", test.orientation, test.width, test.height, dst.Rect)
+			continue
+		}
+		if dst.RGBAAt(test.topLeft.X, test.topLeft.Y) != topLeft || dst.RGBAAt(test.topRight.X, test.topRight.Y) != topRight {
+			t.Errorf("orientation %d: expected the top corners at %v and %v", test.orientation, test.topLeft, test.topRight)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikefero/tpl/log"
+)
+
+const defaultImageCachePath = "images"
+const defaultImageUpstream = "https://img.opdb.org"
+const defaultPlaceholderImageURL = "http://www.thepinballlounge.com/pb/wp_0fa0cf0b/images/img165275761bbe29f98e.gif"
+
+// PlaceholderImage is the image served for machines without a backglass
+const PlaceholderImage = "placeholder"
+
+const ImageSizeSmall = "small"
+const ImageSizeMedium = "medium"
+const ImageSizeLarge = "large"
+
+// imageDimensions are the longest sides of each cached image size
+var imageDimensions = map[string]int{
+	ImageSizeSmall:  320,
+	ImageSizeMedium: 640,
+	ImageSizeLarge:  1280,
+}
+
+var ImageSizes = []string{
+	ImageSizeSmall,
+	ImageSizeMedium,
+	ImageSizeLarge,
+}
+
+var ErrImageNotFound = errors.New("image not found")
+
+var imageCache BlobStore
+var imageUpstream string
+var placeholderImageURL string
+var imageClient = &http.Client{
+	Timeout: 30 * time.Second,
+}
+var imageLocks sync.Map
+var reImageUuid = regexp.MustCompile(`^[0-9a-fA-F-]{36}$`)
+
+// InitializeImageCache caches backglass images on local disk in
+// TPL_IMAGE_CACHE_PATH, or the images directory when it is unset. Images are
+// fetched from TPL_IMAGE_UPSTREAM, which defaults to the OPDB image host.
+func InitializeImageCache() {
+	path := os.Getenv("TPL_IMAGE_CACHE_PATH")
+	if len(path) == 0 {
+		path = defaultImageCachePath
+	}
+	store, err := NewLocalBlobStore(path)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  path,
+			"error": err,
+		}).Panic("unable to create image cache")
+	}
+	imageCache = store
+
+	imageUpstream = strings.TrimSuffix(os.Getenv("TPL_IMAGE_UPSTREAM"), "/")
+	if len(imageUpstream) == 0 {
+		imageUpstream = defaultImageUpstream
+	}
+	placeholderImageURL = os.Getenv("TPL_IMAGE_PLACEHOLDER")
+	if len(placeholderImageURL) == 0 {
+		placeholderImageURL = defaultPlaceholderImageURL
+	}
+}
+
+func IsImageSize(size string) bool {
+	_, exists := imageDimensions[size]
+	return exists
+}
+
+func IsImageUuid(uuid string) bool {
+	return uuid == PlaceholderImage || reImageUuid.MatchString(uuid)
+}
+
+func getImageBlobKey(uuid string, size string) string {
+	return uuid + "-" + size + ".jpg"
+}
+
+func getUpstreamImageURL(uuid string) string {
+	if uuid == PlaceholderImage {
+		return placeholderImageURL
+	}
+	return imageUpstream + "/" + uuid + "-large.jpg"
+}
+
+// fetchImage downloads the largest upstream image and caches every size of it.
+func fetchImage(uuid string) error {
+	url := getUpstreamImageURL(uuid)
+	response, err := imageClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return ErrImageNotFound
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", response.StatusCode, url)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(response.Body, 2*MaxPhotoSize))
+	if err != nil {
+		return err
+	}
+	img, err := decodePhoto(data)
+	if err != nil {
+		return err
+	}
+	for _, size := range ImageSizes {
+		encoded, err := encodePhoto(resize(img, imageDimensions[size]))
+		if err != nil {
+			return err
+		}
+		if err := imageCache.Put(getImageBlobKey(uuid, size), encoded); err != nil {
+			return err
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"uuid": uuid,
+		"url":  url,
+	}).Debug("cached upstream image")
+	return nil
+}
+
+// OpenImage returns a cached image as a JPEG, fetching it from upstream on a
+// cache miss; concurrent misses for the same image fetch it once.
+func OpenImage(uuid string, size string) (io.ReadCloser, error) {
+	if !IsImageUuid(uuid) || !IsImageSize(size) {
+		return nil, ErrImageNotFound
+	}
+	key := getImageBlobKey(uuid, size)
+	if image, err := imageCache.Get(key); err != ErrBlobNotFound {
+		return image, err
+	}
+
+	lock, _ := imageLocks.LoadOrStore(uuid, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+	if image, err := imageCache.Get(key); err != ErrBlobNotFound {
+		return image, err
+	}
+	if err := fetchImage(uuid); err != nil {
+		return nil, err
+	}
+	return imageCache.Get(key)
+}
+
+// PrewarmImages fetches every image that is not already cached and returns
+// the number of images that could not be fetched.
+func PrewarmImages(uuids []string) int {
+	failed := 0
+	for _, uuid := range append([]string{PlaceholderImage}, uuids...) {
+		image, err := OpenImage(uuid, ImageSizeMedium)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"uuid":  uuid,
+				"error": err,
+			}).Warn("unable to cache image")
+			failed++
+			continue
+		}
+		image.Close()
+	}
+	return failed
+}
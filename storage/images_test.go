@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testImageUuid = "3f2c8a9e-1b4d-4e6f-8a0b-2c4d6e8f0a1b"
+const missingImageUuid = "00000000-0000-0000-0000-000000000404"
+const brokenImageUuid = "00000000-0000-0000-0000-000000000500"
+
+// testUpstream serves a backglass and a placeholder image, counting the
+// requests made for each path.
+type testUpstream struct {
+	image    []byte
+	release  chan struct{}
+	requests sync.Map
+}
+
+func (upstream *testUpstream) count(path string) int64 {
+	count, _ := upstream.requests.LoadOrStore(path, new(int64))
+	return atomic.LoadInt64(count.(*int64))
+}
+
+func (upstream *testUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	count, _ := upstream.requests.LoadOrStore(r.URL.Path, new(int64))
+	atomic.AddInt64(count.(*int64), 1)
+	if upstream.release != nil {
+		<-upstream.release
+	}
+	switch r.URL.Path {
+	case "/" + missingImageUuid + "-large.jpg":
+		w.WriteHeader(http.StatusNotFound)
+	case "/" + brokenImageUuid + "-large.jpg":
+		w.WriteHeader(http.StatusInternalServerError)
+	default:
+		w.Write(upstream.image)
+	}
+}
+
+// setTestImageCache caches images in a temporary directory, fetching them
+// from a test upstream for the duration of a test.
+func setTestImageCache(t *testing.T, upstream *testUpstream) {
+	t.Helper()
+	store, err := NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(upstream)
+	previousCache, previousUpstream, previousPlaceholder := imageCache, imageUpstream, placeholderImageURL
+	imageCache = store
+	imageUpstream = server.URL
+	placeholderImageURL = server.URL + "/placeholder.jpg"
+	t.Cleanup(func() {
+		server.Close()
+		imageCache, imageUpstream, placeholderImageURL = previousCache, previousUpstream, previousPlaceholder
+	})
+}
+
+func openTestImage(t *testing.T, uuid string, size string) image.Point {
+	t.Helper()
+	reader, err := OpenImage(uuid, size)
+	if err != nil {
+		t.Fatalf("unable to open the %s image of %s: %v", size, uuid, err)
+	}
+	defer reader.Close()
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("expected the %s image of %s to be a JPEG, got %v", size, uuid, err)
+	}
+	return image.Pt(config.Width, config.Height)
+}
+
+func TestOpenImage(t *testing.T) {
+	upstream := &testUpstream{
+		image: newTestJPEG(t, 1600, 800),
+	}
+	setTestImageCache(t, upstream)
+
+	for _, test := range []struct {
+		size string
+		want image.Point
+	}{
+		{ImageSizeSmall, image.Pt(320, 160)},
+		{ImageSizeMedium, image.Pt(640, 320)},
+		{ImageSizeLarge, image.Pt(1280, 640)},
+	} {
+		if size := openTestImage(t, testImageUuid, test.size); size != test.want {
+			t.Errorf("expected a %s image of %v, got %v", test.size, test.want, size)
+		}
+	}
+	if count := upstream.count("/" + testImageUuid + "-large.jpg"); count != 1 {
+		t.Errorf("expected every size cached from a single fetch, got %d fetches", count)
+	}
+
+	for _, test := range []struct {
+		name string
+		uuid string
+		size string
+	}{
+		{"invalid uuid", "../../tpl.db", ImageSizeSmall},
+		{"invalid size", testImageUuid, "huge"},
+		{"missing upstream", missingImageUuid, ImageSizeSmall},
+	} {
+		if _, err := OpenImage(test.uuid, test.size); err != ErrImageNotFound {
+			t.Errorf("%s: expected %v, got %v", test.name, ErrImageNotFound, err)
+		}
+	}
+	if count := upstream.count("/../../tpl.db-large.jpg"); count != 0 {
+		t.Errorf("expected invalid uuids not to be fetched, got %d fetches", count)
+	}
+}
+
+func TestOpenImageUpstreamFailure(t *testing.T) {
+	upstream := &testUpstream{
+		image: newTestJPEG(t, 400, 200),
+	}
+	setTestImageCache(t, upstream)
+
+	// Failures are not cached so that the image is fetched again later while
+	// the placeholder is served in the meantime
+	for attempt := int64(1); attempt <= 2; attempt++ {
+		if _, err := OpenImage(brokenImageUuid, ImageSizeMedium); err == nil || !strings.Contains(err.Error(), "500") {
+			t.Errorf("expected the upstream status, got %v", err)
+		}
+		if count := upstream.count("/" + brokenImageUuid + "-large.jpg"); count != attempt {
+			t.Errorf("expected %d fetches, got %d", attempt, count)
+		}
+	}
+	if size := openTestImage(t, PlaceholderImage, ImageSizeMedium); size != image.Pt(400, 200) {
+		t.Errorf("expected the placeholder at its own size, got %v", size)
+	}
+	if count := upstream.count("/placeholder.jpg"); count != 1 {
+		t.Errorf("expected the placeholder fetched from its own URL once, got %d fetches", count)
+	}
+}
+
+func TestOpenImageConcurrentMisses(t *testing.T) {
+	upstream := &testUpstream{
+		image:   newTestJPEG(t, 400, 200),
+		release: make(chan struct{}),
+	}
+	setTestImageCache(t, upstream)
+
+	var wg sync.WaitGroup
+	sizes := make([]image.Point, 8)
+	for i := range sizes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reader, err := OpenImage(testImageUuid, ImageSizeSmall)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer reader.Close()
+			config, err := jpeg.DecodeConfig(reader)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			sizes[i] = image.Pt(config.Width, config.Height)
+		}(i)
+	}
+
+	// Hold the first fetch until every request had the time to miss the cache
+	time.Sleep(100 * time.Millisecond)
+	close(upstream.release)
+	wg.Wait()
+
+	if count := upstream.count("/" + testImageUuid + "-large.jpg"); count != 1 {
+		t.Errorf("expected concurrent misses to fetch once, got %d fetches", count)
+	}
+	for _, size := range sizes {
+		if size != image.Pt(320, 160) {
+			t.Errorf("expected every request to get the small image, got %v", size)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+
+	"github.com/mikefero/tpl/log"
+)
+
+const defaultPhotoPath = "photos"
+
+// MaxPhotoSize is the largest upload accepted in bytes
+const MaxPhotoSize = 10 << 20
+
+const maxPhotoPixels = 40000000
+const photoDimension = 2048
+const thumbnailDimension = 320
+const photoQuality = 85
+
+var ErrPhotoTooLarge = errors.New("photo must not be larger than 10 MB")
+var ErrPhotoType = errors.New("photo must be a JPEG, PNG or GIF image")
+var ErrPhotoInvalid = errors.New("photo could not be decoded")
+
+var PhotoTypes = []string{
+	"image/jpeg",
+	"image/png",
+	"image/gif",
+}
+
+var photoStore BlobStore
+var rePhotoKey = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// InitializePhotoStore stores photos on local disk in TPL_PHOTO_PATH, or in
+// the photos directory when it is unset.
+func InitializePhotoStore() {
+	path := os.Getenv("TPL_PHOTO_PATH")
+	if len(path) == 0 {
+		path = defaultPhotoPath
+	}
+	store, err := NewLocalBlobStore(path)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  path,
+			"error": err,
+		}).Panic("unable to create photo store")
+	}
+	SetPhotoStore(store)
+}
+
+func SetPhotoStore(store BlobStore) {
+	photoStore = store
+}
+
+func IsPhotoKey(key string) bool {
+	return rePhotoKey.MatchString(key)
+}
+
+func getPhotoBlobKey(key string, thumbnail bool) string {
+	if thumbnail {
+		return key + "-thumbnail.jpg"
+	}
+	return key + ".jpg"
+}
+
+func isPhotoType(contentType string) bool {
+	for _, photoType := range PhotoTypes {
+		if photoType == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+// resize scales an image down with an area average so that its longest side
+// fits within a dimension; smaller images are returned unchanged.
+func resize(src *image.RGBA, dimension int) *image.RGBA {
+	width, height := src.Rect.Dx(), src.Rect.Dy()
+	if width <= dimension && height <= dimension {
+		return src
+	}
+	dstWidth, dstHeight := dimension, height*dimension/width
+	if height > width {
+		dstWidth, dstHeight = width*dimension/height, dimension
+	}
+	if dstWidth < 1 {
+		dstWidth = 1
+	}
+	if dstHeight < 1 {
+		dstHeight = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < dstHeight; y++ {
+		y0, y1 := y*height/dstHeight, (y+1)*height/dstHeight
+		for x := 0; x < dstWidth; x++ {
+			x0, x1 := x*width/dstWidth, (x+1)*width/dstWidth
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				offset := src.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += int(src.Pix[offset+c])
+					}
+					offset += 4
+				}
+			}
+			count := (x1 - x0) * (y1 - y0)
+			offset := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[offset+c] = uint8(sum[c] / count)
+			}
+		}
+	}
+	return dst
+}
+
+// decodePhoto validates the type and dimensions of an image before decoding it
+// upright.
+func decodePhoto(data []byte) (*image.RGBA, error) {
+	if !isPhotoType(http.DetectContentType(data)) {
+		return nil, ErrPhotoType
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width*config.Height > maxPhotoPixels {
+		return nil, ErrPhotoInvalid
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrPhotoInvalid
+	}
+	return applyOrientation(toRGBA(img), getExifOrientation(data)), nil
+}
+
+func encodePhoto(img image.Image) (*bytes.Buffer, error) {
+	var buffer bytes.Buffer
+	err := jpeg.Encode(&buffer, img, &jpeg.Options{
+		Quality: photoQuality,
+	})
+	return &buffer, err
+}
+
+// SavePhoto validates an uploaded image and stores it along with a thumbnail.
+// Photos are re-encoded as JPEG which strips EXIF metadata such as the
+// location a photo was taken at.
+func SavePhoto(upload io.Reader) (string, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(upload, MaxPhotoSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > MaxPhotoSize {
+		return "", ErrPhotoTooLarge
+	}
+	upright, err := decodePhoto(data)
+	if err != nil {
+		return "", err
+	}
+
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	key := hex.EncodeToString(random)
+	for _, thumbnail := range []bool{false, true} {
+		dimension := photoDimension
+		if thumbnail {
+			dimension = thumbnailDimension
+		}
+		encoded, err := encodePhoto(resize(upright, dimension))
+		if err != nil {
+			return "", err
+		}
+		if err := photoStore.Put(getPhotoBlobKey(key, thumbnail), encoded); err != nil {
+			log.WithFields(log.Fields{
+				"key":   key,
+				"error": err,
+			}).Error("unable to store photo")
+			return "", err
+		}
+	}
+
+	return key, nil
+}
+
+// OpenPhoto returns a stored photo, or its thumbnail, as a JPEG.
+func OpenPhoto(key string, thumbnail bool) (io.ReadCloser, error) {
+	if !IsPhotoKey(key) {
+		return nil, ErrBlobNotFound
+	}
+	return photoStore.Get(getPhotoBlobKey(key, thumbnail))
+}
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"testing"
+)
+
+// setTestPhotoStore stores photos in a temporary directory for the duration
+// of a test.
+func setTestPhotoStore(t *testing.T) {
+	t.Helper()
+	store, err := NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := photoStore
+	SetPhotoStore(store)
+	t.Cleanup(func() {
+		SetPhotoStore(previous)
+	})
+}
+
+func newTestPNG(t *testing.T, width int, height int) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestResize(t *testing.T) {
+	for _, test := range []struct {
+		width     int
+		height    int
+		dimension int
+		size      image.Point
+	}{
+		{4000, 1000, 2048, image.Pt(2048, 512)},
+		{1000, 4000, 2048, image.Pt(512, 2048)},
+		{2048, 2048, 2048, image.Pt(2048, 2048)},
+		{100, 50, 320, image.Pt(100, 50)},
+		{5000, 1, 320, image.Pt(320, 1)},
+	} {
+		dst := resize(image.NewRGBA(image.Rect(0, 0, test.width, test.height)), test.dimension)
+		if dst.Rect.Size() != test.size {
+			t.Errorf("expected %dx%d to fit %d as %v, got %v", test.width, test.height, test.dimension, test.size, dst.Rect.Size())
+		}
+	}
+
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.Set(1, 0, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	if average := resize(src, 1).RGBAAt(0, 0); average != (color.RGBA{R: 127, G: 127, B: 127, A: 127}) {
+		t.Errorf("expected black and white to average to gray, got %v", average)
+	}
+}
+
+func TestSavePhotoRejected(t *testing.T) {
+	setTestPhotoStore(t)
+
+	// GIF headers are enough to declare the dimensions of an image
+	gif := []byte("GIF89a\x58\x1b\x58\x1b\x00\x00\x00")
+	for _, test := range []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"larger than the upload limit", append(newTestPNG(t, 1, 1), make([]byte, MaxPhotoSize)...), ErrPhotoTooLarge},
+		{"text", []byte("not a photo"), ErrPhotoType},
+		{"bitmap", append([]byte("BM"), make([]byte, 64)...), ErrPhotoType},
+		{"truncated PNG", newTestPNG(t, 8, 8)[:40], ErrPhotoInvalid},
+		{"truncated JPEG", newTestJPEG(t, 64, 64)[:200], ErrPhotoInvalid},
+		{"too many pixels", gif, ErrPhotoInvalid},
+	} {
+		if _, err := SavePhoto(bytes.NewReader(test.data)); err != test.err {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestSavePhoto(t *testing.T) {
+	setTestPhotoStore(t)
+
+	for _, test := range []struct {
+		name      string
+		data      []byte
+		photo     image.Point
+		thumbnail image.Point
+	}{
+		{"small PNG", newTestPNG(t, 100, 50), image.Pt(100, 50), image.Pt(100, 50)},
+		{"large JPEG", newTestJPEG(t, 3000, 1000), image.Pt(2048, 682), image.Pt(320, 106)},
+		{"rotated JPEG", newTestJPEG(t, 3000, 1000, newTestExif(binary.BigEndian, 6)), image.Pt(682, 2048), image.Pt(106, 320)},
+		{"malformed EXIF", newTestJPEG(t, 30, 10, newTestSegment(0xE1, []byte("Exif\x00\x00MM\x00*\xff\xff\xff\xff"))), image.Pt(30, 10), image.Pt(30, 10)},
+	} {
+		key, err := SavePhoto(bytes.NewReader(test.data))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !IsPhotoKey(key) {
+			t.Errorf("%s: expected a photo key, got %q", test.name, key)
+		}
+		for thumbnail, size := range map[bool]image.Point{false: test.photo, true: test.thumbnail} {
+			photo, err := OpenPhoto(key, thumbnail)
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			data, err := ioutil.ReadAll(photo)
+			photo.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			img, err := jpeg.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("%s: expected a JPEG, got %v", test.name, err)
+			}
+			if img.Bounds().Size() != size {
+				t.Errorf("%s: expected a %v photo (thumbnail %t), got %v", test.name, size, thumbnail, img.Bounds().Size())
+			}
+			if bytes.Contains(data, []byte("Exif\x00\x00")) {
+				t.Errorf("%s: expected the EXIF metadata to be stripped", test.name)
+			}
+		}
+	}
+
+	// The red left half of a photo turned clockwise ends up on top
+	key, err := SavePhoto(bytes.NewReader(newTestJPEG(t, 40, 20, newTestExif(binary.LittleEndian, 6))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	photo, err := OpenPhoto(key, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer photo.Close()
+	img, err := jpeg.Decode(photo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, b, _ := img.At(10, 5).RGBA(); r < b {
+		t.Errorf("expected the top of the upright photo to be red, got %v", img.At(10, 5))
+	}
+	if r, _, b, _ := img.At(10, 35).RGBA(); r > b {
+		t.Errorf("expected the bottom of the upright photo to be blue, got %v", img.At(10, 35))
+	}
+}
+
+func TestOpenPhotoInvalidKey(t *testing.T) {
+	setTestPhotoStore(t)
+
+	for _, key := range []string{"", "../tpl.db", "0123456789abcdef0123456789ABCDEF"} {
+		if _, err := OpenPhoto(key, false); err != ErrBlobNotFound {
+			t.Errorf("expected %q not to be found, got %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package tournament
+
+import (
+	"sort"
+
+	"github.com/mikefero/tpl/db"
+)
+
+// GetBracketOrder returns the seeds of a single elimination bracket of a power
+// of two size from the top slot down, so that the top seeds can only meet in
+// the last rounds.
+func GetBracketOrder(size int) []int {
+	order := []int{1}
+	for len(order) < size {
+		var next []int
+		for _, seed := range order {
+			next = append(next, seed, 2*len(order)+1-seed)
+		}
+		order = next
+	}
+	return order
+}
+
+// SeedByRanking orders the players of a bracket by the WPPR rank of their
+// verified IFPA number, keyed by user ID; players without a rank follow the
+// ranked players in the order they checked in.
+func SeedByRanking(players []db.TournamentPlayer, ranks map[int]int) []int {
+	seeded := append([]db.TournamentPlayer{}, players...)
+	sort.SliceStable(seeded, func(i, j int) bool {
+		a, aRanked := ranks[seeded[i].UserId]
+		b, bRanked := ranks[seeded[j].UserId]
+		if aRanked != bRanked {
+			return aRanked
+		}
+		return a < b
+	})
+	var userIds []int
+	for _, player := range seeded {
+		userIds = append(userIds, player.UserId)
+	}
+	return userIds
+}
+
+// seedBracket seeds the players of a bracket created by hand by their WPPR
+// rank before its first round; brackets seeded by qualifying keep their seeds.
+func seedBracket(tournament db.Tournament, players []db.TournamentPlayer) ([]db.TournamentPlayer, bool) {
+	ranks := map[int]int{}
+	for _, player := range players {
+		if player.Seed.Valid {
+			return players, true
+		}
+		if user := db.GetUser(player.UserId); user != nil {
+			if ranking := db.GetUserIfpaRanking(*user); ranking != nil {
+				ranks[player.UserId] = ranking.Rank
+			}
+		}
+	}
+	if !db.SeedTournamentPlayers(tournament.Id, SeedByRanking(players, ranks)) {
+		return nil, false
+	}
+	return db.GetTournamentPlayers(tournament.Id), true
+}
+
+// getBracketSlots returns the player of every slot of a bracket round, zero
+// for a slot left empty. Players are placed by their seed and then by the
+// order they checked in; the top seeds get the byes of the first round and the
+// winner of every pair of slots moves on to the next round.
+func getBracketSlots(players []db.TournamentPlayer, games []db.TournamentGame, round int) []int {
+	seeded := append([]db.TournamentPlayer{}, players...)
+	sort.SliceStable(seeded, func(i, j int) bool {
+		if seeded[i].Seed.Valid != seeded[j].Seed.Valid {
+			return seeded[i].Seed.Valid
+		}
+		return seeded[i].Seed.Int64 < seeded[j].Seed.Int64
+	})
+	size := 1
+	for size < len(seeded) {
+		size *= 2
+	}
+	var slots []int
+	for _, seed := range GetBracketOrder(size) {
+		if seed <= len(seeded) {
+			slots = append(slots, seeded[seed-1].UserId)
+		} else {
+			slots = append(slots, 0)
+		}
+	}
+
+	winners := map[int]map[int]bool{}
+	for _, game := range games {
+		for _, player := range game.Players {
+			if player.Place.Valid && player.Place.Int64 == 1 {
+				if winners[game.Round] == nil {
+					winners[game.Round] = map[int]bool{}
+				}
+				winners[game.Round][player.UserId] = true
+			}
+		}
+	}
+	for r := 1; r < round && len(slots) > 1; r++ {
+		var next []int
+		for i := 0; i < len(slots); i += 2 {
+			a, b := slots[i], slots[i+1]
+			switch {
+			case a == 0:
+				next = append(next, b)
+			case b == 0 || winners[r][a]:
+				next = append(next, a)
+			case winners[r][b]:
+				next = append(next, b)
+			default:
+				next = append(next, 0)
+			}
+		}
+		slots = next
+	}
+	return slots
+}
+
+// getBracketGroups pairs the players of neighbouring bracket slots with the
+// player of the upper slot playing first.
+func getBracketGroups(players []db.TournamentPlayer, games []db.TournamentGame, round int) [][]int {
+	slots := getBracketSlots(players, games, round)
+	var groups [][]int
+	for i := 0; i+1 < len(slots); i += 2 {
+		if slots[i] != 0 && slots[i+1] != 0 {
+			groups = append(groups, []int{slots[i], slots[i+1]})
+		}
+	}
+	return groups
+}
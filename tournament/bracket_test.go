@@ -0,0 +1,70 @@
+package tournament
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/mikefero/tpl/db"
+)
+
+func TestGetBracketOrder(t *testing.T) {
+	for _, test := range []struct {
+		size  int
+		order []int
+	}{
+		{1, []int{1}},
+		{2, []int{1, 2}},
+		{4, []int{1, 4, 2, 3}},
+		{8, []int{1, 8, 4, 5, 2, 7, 3, 6}},
+	} {
+		if order := GetBracketOrder(test.size); !reflect.DeepEqual(order, test.order) {
+			t.Errorf("expected a bracket of %d to be seeded %v, got %v", test.size, test.order, order)
+		}
+	}
+}
+
+func TestGetBracketSlots(t *testing.T) {
+	// Player 5 checked in without a seed and follows the seeded players
+	players := newTestPlayers(5, 1, 2, 3, 4)
+	for i := 1; i < len(players); i++ {
+		players[i].Seed = sql.NullInt64{Int64: int64(i), Valid: true}
+	}
+	games := []db.TournamentGame{
+		newTestGame(1, 1, "G4ODR-MDXEy", []int{4, 5}, []int{2, 1}),
+		newTestGame(2, 2, "G4ODR-MDXEy", []int{1, 5}, []int{1, 2}),
+		newTestGame(3, 2, "G5pe4-MePZv", []int{2, 3}, nil),
+	}
+	for _, test := range []struct {
+		round  int
+		slots  []int
+		groups [][]int
+	}{
+		{1, []int{1, 0, 4, 5, 2, 0, 3, 0}, [][]int{{4, 5}}},
+		{2, []int{1, 5, 2, 3}, [][]int{{1, 5}, {2, 3}}},
+		{3, []int{1, 0}, nil},
+	} {
+		if slots := getBracketSlots(players, games, test.round); !reflect.DeepEqual(slots, test.slots) {
+			t.Errorf("expected the slots of round %d to be %v, got %v", test.round, test.slots, slots)
+		}
+		if groups := getBracketGroups(players, games, test.round); !reflect.DeepEqual(groups, test.groups) {
+			t.Errorf("expected the games of round %d to be %v, got %v", test.round, test.groups, groups)
+		}
+	}
+}
+
+func TestSeedByRanking(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		ranks   map[int]int
+		userIds []int
+	}{
+		{"by WPPR rank", map[int]int{1: 250, 2: 12, 3: 1, 4: 4000}, []int{3, 2, 1, 4}},
+		{"unranked players last", map[int]int{3: 250, 4: 12}, []int{4, 3, 1, 2}},
+		{"without rankings", nil, []int{1, 2, 3, 4}},
+	} {
+		if userIds := SeedByRanking(newTestPlayers(1, 2, 3, 4), test.ranks); !reflect.DeepEqual(userIds, test.userIds) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.userIds, userIds)
+		}
+	}
+}
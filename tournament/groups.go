@@ -0,0 +1,97 @@
+package tournament
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// GroupSizes are the group sizes a tournament can be played with
+var GroupSizes = []int{2, 4}
+
+func IsGroupSize(size int) bool {
+	for _, s := range GroupSizes {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
+
+// GetGroupSizes splits players into groups of the given size. Head-to-head
+// groups leave the odd player out with a bye while four player groups are
+// filled up with three player groups so that nobody sits out.
+func GetGroupSizes(players int, groupSize int) []int {
+	var sizes []int
+	if groupSize <= 2 || players < 4 {
+		for i := 0; i+2 <= players; i += 2 {
+			sizes = append(sizes, 2)
+		}
+		if groupSize > 2 && players == 3 {
+			sizes = []int{3}
+		}
+		return sizes
+	}
+	if players == 5 {
+		return []int{3, 2}
+	}
+
+	groups := (players + groupSize - 1) / groupSize
+	smaller := groups*groupSize - players
+	for i := 0; i < groups; i++ {
+		if i < groups-smaller {
+			sizes = append(sizes, groupSize)
+		} else {
+			sizes = append(sizes, groupSize-1)
+		}
+	}
+	return sizes
+}
+
+// Shuffle orders players at random and then by strikes so that players with
+// the same number of strikes meet in a different order every round; the same
+// seed and round always give the same order.
+func Shuffle(standings []Standing, seed int64, round int) []Standing {
+	shuffled := append([]Standing{}, standings...)
+	random := rand.New(rand.NewSource(seed + int64(round)))
+	random.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	sort.SliceStable(shuffled, func(i, j int) bool {
+		return shuffled[i].Strikes < shuffled[j].Strikes
+	})
+	return shuffled
+}
+
+// MakeGroups fills groups of the given sizes from ordered players. Each group
+// starts with the first player left and takes the following players it met
+// the least so far, looking only a few players ahead so that groups stay close
+// in standing.
+func MakeGroups(order []int, sizes []int, meetings map[int]map[int]int) [][]int {
+	var groups [][]int
+	remaining := append([]int{}, order...)
+	for _, size := range sizes {
+		if len(remaining) < size {
+			break
+		}
+		group := []int{remaining[0]}
+		remaining = remaining[1:]
+		for len(group) < size {
+			best := 0
+			bestMeetings := -1
+			for i := 0; i < len(remaining) && i < 2*size; i++ {
+				count := 0
+				for _, player := range group {
+					count += meetings[player][remaining[i]]
+				}
+				if bestMeetings < 0 || count < bestMeetings {
+					best = i
+					bestMeetings = count
+				}
+			}
+			group = append(group, remaining[best])
+			remaining = append(remaining[:best], remaining[best+1:]...)
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
@@ -0,0 +1,90 @@
+package tournament
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetGroupSizes(t *testing.T) {
+	for _, test := range []struct {
+		players   int
+		groupSize int
+		sizes     []int
+	}{
+		{0, 2, nil},
+		{1, 4, nil},
+		{7, 2, []int{2, 2, 2}},
+		{8, 2, []int{2, 2, 2, 2}},
+		{2, 4, []int{2}},
+		{3, 4, []int{3}},
+		{5, 4, []int{3, 2}},
+		{6, 4, []int{3, 3}},
+		{7, 4, []int{4, 3}},
+		{8, 4, []int{4, 4}},
+		{9, 4, []int{3, 3, 3}},
+		{10, 4, []int{4, 3, 3}},
+		{11, 4, []int{4, 4, 3}},
+	} {
+		if sizes := GetGroupSizes(test.players, test.groupSize); !reflect.DeepEqual(sizes, test.sizes) {
+			t.Errorf("expected %d players in groups of %d to be split into %v, got %v", test.players, test.groupSize, test.sizes, sizes)
+		}
+	}
+}
+
+func TestMakeGroups(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		order    []int
+		sizes    []int
+		meetings map[int]map[int]int
+		groups   [][]int
+	}{
+		{"in order", []int{1, 2, 3, 4}, []int{2, 2}, nil, [][]int{{1, 2}, {3, 4}}},
+		{"avoiding rematches", []int{1, 2, 3, 4}, []int{2, 2}, map[int]map[int]int{1: {2: 1}, 2: {1: 1}}, [][]int{{1, 3}, {2, 4}}},
+		{"met the least", []int{1, 2, 3, 4}, []int{2, 2}, map[int]map[int]int{1: {2: 2, 3: 1, 4: 1}}, [][]int{{1, 3}, {2, 4}}},
+		{"mixed sizes", []int{1, 2, 3, 4, 5, 6, 7}, []int{4, 3}, nil, [][]int{{1, 2, 3, 4}, {5, 6, 7}}},
+		{"players left out", []int{1, 2, 3}, []int{2}, nil, [][]int{{1, 2}}},
+	} {
+		if groups := MakeGroups(test.order, test.sizes, test.meetings); !reflect.DeepEqual(groups, test.groups) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.groups, groups)
+		}
+	}
+}
+
+func TestShuffle(t *testing.T) {
+	standings := []Standing{
+		{UserId: 1, Strikes: 2},
+		{UserId: 2},
+		{UserId: 3, Strikes: 1},
+		{UserId: 4},
+		{UserId: 5, Strikes: 1},
+	}
+	shuffled := Shuffle(standings, 42, 1)
+	if !reflect.DeepEqual(shuffled, Shuffle(standings, 42, 1)) {
+		t.Error("expected the same seed and round to give the same order")
+	}
+	for i := 1; i < len(shuffled); i++ {
+		if shuffled[i-1].Strikes > shuffled[i].Strikes {
+			t.Errorf("expected players ordered by strikes, got %+v", shuffled)
+		}
+	}
+	if standings[0].UserId != 1 {
+		t.Error("expected the standings to be left unchanged")
+	}
+}
+
+func TestAssignByes(t *testing.T) {
+	ordered := []Standing{
+		{UserId: 1},
+		{UserId: 2, Strikes: 1},
+		{UserId: 3, Strikes: 2, Byes: 1},
+		{UserId: 4, Strikes: 2},
+	}
+	var userIds []int
+	for _, standing := range assignByes(ordered, 2) {
+		userIds = append(userIds, standing.UserId)
+	}
+	if !reflect.DeepEqual(userIds, []int{1, 3}) {
+		t.Errorf("expected the players with the most strikes and fewest byes to sit out, got %v", userIds)
+	}
+}
@@ -0,0 +1,162 @@
+package tournament
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/mikefero/tpl/db"
+)
+
+var ErrWeekInProgress = errors.New("the matches of the current week do not all have results")
+var ErrNotEnoughTeams = errors.New("at least two active teams are needed to schedule a week")
+var ErrWeekNotCreated = errors.New("the week could not be scheduled")
+
+// SeasonStanding is the Swiss standing of a team in a season
+type SeasonStanding struct {
+	SwissStanding
+	TeamName string
+}
+
+func getSeasonMatches(season db.Season) []db.Match {
+	filter := db.MatchFilter{
+		SeasonId: season.Id,
+	}
+	filter.Limit = db.CountMatches(filter)
+	return db.GetMatches(filter)
+}
+
+// getSeasonGames returns the matches of a season with results from the point
+// of view of each team, won by the team with the most team points; matches
+// without an away team are byes.
+func getSeasonGames(matches []db.Match) []SwissGame {
+	var games []SwissGame
+	for _, match := range matches {
+		if !match.Team2Id.Valid {
+			games = append(games, SwissGame{Id: match.Team1Id})
+			continue
+		}
+		filter := db.ResultFilter{
+			MatchId: match.Id,
+		}
+		filter.Limit = db.CountResults(filter)
+		results := db.GetResults(filter)
+		if len(results) == 0 {
+			continue
+		}
+		var team1, team2 int64
+		for _, result := range results {
+			team1 += result.Team1Score.Int64
+			team2 += result.Team2Score.Int64
+		}
+		outcome := getOutcome(team1, team2)
+		games = append(games,
+			SwissGame{Id: match.Team1Id, OpponentId: int(match.Team2Id.Int64), Outcome: outcome},
+			SwissGame{Id: int(match.Team2Id.Int64), OpponentId: match.Team1Id, Outcome: 1 - outcome})
+	}
+	return games
+}
+
+func getActiveTeams(season db.Season) []db.Team {
+	var active []db.Team
+	for _, team := range db.GetTeams(season.LeagueId, db.CountTeams(season.LeagueId), 0) {
+		if team.Active {
+			active = append(active, team)
+		}
+	}
+	return active
+}
+
+// GetSeasonStandings ranks the active teams of a season by Swiss score with
+// the Buchholz score and strength of schedule as tiebreaks.
+func GetSeasonStandings(season db.Season) []SeasonStanding {
+	names := map[int]string{}
+	var ids []int
+	for _, team := range getActiveTeams(season) {
+		names[team.Id] = team.Name
+		ids = append(ids, team.Id)
+	}
+
+	var standings []SeasonStanding
+	for _, standing := range GetSwissStandings(ids, getSeasonGames(getSeasonMatches(season))) {
+		standings = append(standings, SeasonStanding{
+			SwissStanding: standing,
+			TeamName:      names[standing.Id],
+		})
+	}
+	return standings
+}
+
+// PairSeasonWeek schedules the next week of a season for leagues where teams
+// do not all play each other, pairing teams on the same Swiss score that have
+// not met yet. The team that hosted fewer matches plays at home and an odd
+// team out gets a bye, scheduled as a match without an away team. Every match
+// of the previous week must have results first.
+func PairSeasonWeek(season db.Season, date sql.NullInt64) (int, error) {
+	matches := getSeasonMatches(season)
+	week := 0
+	for _, match := range matches {
+		if match.Week.Valid && int(match.Week.Int64) > week {
+			week = int(match.Week.Int64)
+		}
+	}
+	for _, match := range matches {
+		if match.Week.Valid && int(match.Week.Int64) == week && match.Team2Id.Valid &&
+			db.CountResults(db.ResultFilter{MatchId: match.Id}) == 0 {
+			return 0, ErrWeekInProgress
+		}
+	}
+	teams := getActiveTeams(season)
+	if len(teams) < 2 {
+		return 0, ErrNotEnoughTeams
+	}
+
+	var ids []int
+	for _, team := range teams {
+		ids = append(ids, team.Id)
+	}
+	meetings := map[int]map[int]int{}
+	home := map[int]int{}
+	for _, match := range matches {
+		if !match.Team2Id.Valid {
+			continue
+		}
+		away := int(match.Team2Id.Int64)
+		for _, pair := range [][2]int{{match.Team1Id, away}, {away, match.Team1Id}} {
+			if meetings[pair[0]] == nil {
+				meetings[pair[0]] = map[int]int{}
+			}
+			meetings[pair[0]][pair[1]]++
+		}
+		home[match.Team1Id]++
+	}
+
+	week++
+	pairs, bye := PairSwiss(GetSwissStandings(ids, getSeasonGames(matches)), meetings, int64(season.Id), week)
+	var scheduled []db.Match
+	for _, pair := range pairs {
+		if home[pair[1]] < home[pair[0]] {
+			pair[0], pair[1] = pair[1], pair[0]
+		}
+		scheduled = append(scheduled, db.Match{
+			LeagueId: season.LeagueId,
+			SeasonId: season.Id,
+			Team1Id:  pair[0],
+			Team2Id:  sql.NullInt64{Int64: int64(pair[1]), Valid: true},
+			Week:     sql.NullInt64{Int64: int64(week), Valid: true},
+			Date:     date,
+		})
+	}
+	if bye > 0 {
+		scheduled = append(scheduled, db.Match{
+			LeagueId: season.LeagueId,
+			SeasonId: season.Id,
+			Team1Id:  bye,
+			Week:     sql.NullInt64{Int64: int64(week), Valid: true},
+			Date:     date,
+		})
+	}
+	if !db.CreateMatches(scheduled) {
+		return 0, ErrWeekNotCreated
+	}
+	return week, nil
+}
@@ -0,0 +1,163 @@
+package tournament
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/mikefero/tpl/db"
+)
+
+// MatchPlayGroupSize is the group size of match play tournaments
+const MatchPlayGroupSize = 4
+
+var ErrNotPickable = errors.New("machines are only picked in match play tournaments")
+var ErrNotPicker = errors.New("only the first player of the game picks its machine")
+var ErrMachineNotAvailable = errors.New("the machine is not available to this game")
+
+// getMatchPlayGroups reseeds the players by their points every round, players
+// on the same points in a different order every round, and fills four player
+// groups from the top of the standings. Players pick machines in turn: within
+// a group the player who picked the fewest times plays first and picks, the
+// higher ranked player first on a tie.
+func getMatchPlayGroups(tournament db.Tournament, standings []Standing, games []db.TournamentGame, round int) [][]int {
+	ordered := Shuffle(standings, tournament.Seed, round)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Score > ordered[j].Score
+	})
+	var order []int
+	for _, standing := range ordered {
+		order = append(order, standing.UserId)
+	}
+	groups := MakeGroups(order, GetGroupSizes(len(order), MatchPlayGroupSize), GetMeetings(games))
+
+	picks := map[int]int{}
+	for _, game := range games {
+		if len(game.Players) > 0 {
+			picks[game.Players[0].UserId]++
+		}
+	}
+	for _, group := range groups {
+		sort.SliceStable(group, func(i, j int) bool {
+			return picks[group[i]] < picks[group[j]]
+		})
+	}
+	return groups
+}
+
+// getMatchPlayStandings ranks the players by their placement points and then
+// by the games they won; players that cannot be told apart share a position.
+func getMatchPlayStandings(tournament db.Tournament, players []db.TournamentPlayer, games []db.TournamentGame) []Standing {
+	rule := GetScoringRule(tournament.Scoring.String)
+	standings := map[int]*Standing{}
+	var order []int
+	for _, player := range players {
+		standings[player.UserId] = &Standing{
+			UserId: player.UserId,
+			Name:   player.Name,
+		}
+		order = append(order, player.UserId)
+	}
+
+	for round := 1; round <= GetRounds(games); round++ {
+		playing := map[int]bool{}
+		for _, game := range games {
+			if game.Round != round {
+				continue
+			}
+			for _, player := range game.Players {
+				playing[player.UserId] = true
+				standing, exists := standings[player.UserId]
+				if !exists || !player.Place.Valid {
+					continue
+				}
+				standing.Games++
+				if player.Place.Int64 == 1 {
+					standing.Wins++
+				} else {
+					standing.Losses++
+				}
+				standing.Score += float64(rule.Award(len(game.Players), int(player.Place.Int64)))
+			}
+		}
+		for _, standing := range standings {
+			if !playing[standing.UserId] {
+				standing.Byes++
+			}
+		}
+	}
+
+	var sorted []Standing
+	for _, id := range order {
+		sorted = append(sorted, *standings[id])
+	}
+	compare := func(a Standing, b Standing) float64 {
+		if a.Score != b.Score {
+			return b.Score - a.Score
+		}
+		return float64(b.Wins - a.Wins)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return compare(sorted[i], sorted[j]) < 0
+	})
+	for i := range sorted {
+		if i > 0 && compare(sorted[i-1], sorted[i]) == 0 {
+			sorted[i].Position = sorted[i-1].Position
+		} else {
+			sorted[i].Position = i + 1
+		}
+	}
+	return sorted
+}
+
+// GetPickableMachines returns the machines the first player of a match play
+// game can pick: the in service machines not assigned to another game of the
+// round, preferring machines none of its players played yet.
+func GetPickableMachines(games []db.TournamentGame, game db.TournamentGame, pool []string) []string {
+	assigned := map[string]bool{}
+	for _, other := range games {
+		if other.Round == game.Round && other.Id != game.Id {
+			assigned[other.OpdbId] = true
+		}
+	}
+	var group []int
+	for _, player := range game.Players {
+		group = append(group, player.UserId)
+	}
+	return getAvailableMachines(group, pool, assigned, getPlayedMachines(games, game.Round))
+}
+
+// PickMachine replaces the drawn machine of a match play game that was not
+// recorded yet with a machine picked by its first player or by staff.
+func PickMachine(tournament db.Tournament, gameId int, user db.User, opdbId string) error {
+	if tournament.Format != db.TournamentFormatMatchPlay {
+		return ErrNotPickable
+	}
+	games := db.GetTournamentGames(tournament.Id)
+	var game *db.TournamentGame
+	for i := range games {
+		if games[i].Id == gameId {
+			game = &games[i]
+		}
+	}
+	if game == nil {
+		return ErrGameNotFound
+	}
+	if game.CompletedAt.Valid {
+		return ErrGameRecorded
+	}
+	if len(game.Players) == 0 || (game.Players[0].UserId != user.Id && !user.HasRole(db.RoleStaff)) {
+		return ErrNotPicker
+	}
+
+	available := false
+	for _, pickable := range GetPickableMachines(games, *game, db.GetSelectableMachines()) {
+		available = available || pickable == opdbId
+	}
+	if !available {
+		return ErrMachineNotAvailable
+	}
+	if !db.PickTournamentGameMachine(game.Id, opdbId, user.Id) {
+		return ErrGameRecorded
+	}
+	return nil
+}
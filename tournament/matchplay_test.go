@@ -0,0 +1,111 @@
+package tournament
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/mikefero/tpl/db"
+)
+
+const addamsFamily = "G4ODR-MDXEy"
+const godzilla = "G5po2-MeP6B"
+
+func TestPlacementPoints(t *testing.T) {
+	for _, test := range []struct {
+		scoring   string
+		groupSize int
+		points    []int
+	}{
+		{ScoringPapa, 2, []int{7, 1}},
+		{ScoringPapa, 3, []int{7, 4, 1}},
+		{ScoringPapa, 4, []int{7, 5, 3, 1}},
+		{ScoringIfpa, 2, []int{4, 0}},
+		{ScoringIfpa, 3, []int{4, 2, 0}},
+		{ScoringIfpa, 4, []int{4, 2, 1, 0}},
+		{"", 4, []int{7, 5, 3, 1}},
+		{"10-0", 2, []int{7, 1}},
+	} {
+		rule := GetScoringRule(test.scoring)
+		var points []int
+		for place := 1; place <= test.groupSize; place++ {
+			points = append(points, rule.Award(test.groupSize, place))
+		}
+		if !reflect.DeepEqual(points, test.points) {
+			t.Errorf("expected %q to award groups of %d with %v, got %v", test.scoring, test.groupSize, test.points, points)
+		}
+	}
+	if !IsScoring(ScoringIfpa) || IsScoring("") {
+		t.Error("expected only the listed scorings to be valid")
+	}
+}
+
+func TestGetMatchPlayStandings(t *testing.T) {
+	tournament := db.Tournament{
+		Format:    db.TournamentFormatMatchPlay,
+		GroupSize: MatchPlayGroupSize,
+		Scoring:   sql.NullString{String: ScoringIfpa, Valid: true},
+	}
+	games := []db.TournamentGame{
+		newTestGame(1, 1, addamsFamily, []int{1, 2, 3, 4}, []int{1, 2, 3, 4}),
+		newTestGame(2, 2, attackFromMars, []int{5, 4, 3}, []int{1, 2, 3}),
+		newTestGame(3, 2, medievalMadness, []int{2, 1}, []int{1, 2}),
+		newTestGame(4, 3, godzilla, []int{1, 2, 3, 5}, nil),
+	}
+	standings := GetStandings(tournament, newTestPlayers(1, 2, 3, 4, 5), games)
+
+	for i, expected := range []Standing{
+		{UserId: 2, Games: 2, Wins: 1, Losses: 1, Score: 6, Position: 1},
+		{UserId: 1, Games: 2, Wins: 1, Losses: 1, Score: 4, Position: 2},
+		{UserId: 5, Games: 1, Wins: 1, Byes: 1, Score: 4, Position: 2},
+		{UserId: 4, Games: 2, Losses: 2, Byes: 1, Score: 2, Position: 4},
+		{UserId: 3, Games: 2, Losses: 2, Score: 1, Position: 5},
+	} {
+		if standings[i] != expected {
+			t.Errorf("expected %+v at position %d, got %+v", expected, i+1, standings[i])
+		}
+	}
+}
+
+func TestGetMatchPlayGroups(t *testing.T) {
+	// Player 1 picked twice and player 2 once, so players 3 and 4 pick first
+	// in the order of the standings
+	games := []db.TournamentGame{
+		newTestGame(1, 1, addamsFamily, []int{1, 2, 3, 4}, []int{1, 2, 3, 4}),
+		newTestGame(2, 2, attackFromMars, []int{2, 1, 4, 3}, []int{1, 2, 3, 4}),
+		newTestGame(3, 3, medievalMadness, []int{1, 3, 2, 4}, []int{1, 2, 3, 4}),
+	}
+	standings := []Standing{
+		{UserId: 4, Score: 3},
+		{UserId: 2, Score: 8},
+		{UserId: 1, Score: 10},
+		{UserId: 3, Score: 5},
+	}
+	groups := getMatchPlayGroups(db.Tournament{Seed: 42}, standings, games, 4)
+	if !reflect.DeepEqual(groups, [][]int{{3, 4, 2, 1}}) {
+		t.Errorf("expected the players who picked the least to play first, got %v", groups)
+	}
+}
+
+func TestGetPickableMachines(t *testing.T) {
+	games := []db.TournamentGame{
+		newTestGame(1, 1, godzilla, []int{1, 2}, []int{1, 2}),
+		newTestGame(2, 1, attackFromMars, []int{3, 4}, []int{1, 2}),
+		newTestGame(3, 2, addamsFamily, []int{1, 3}, nil),
+		newTestGame(4, 2, medievalMadness, []int{2, 4}, nil),
+	}
+	for _, test := range []struct {
+		name     string
+		game     int
+		pool     []string
+		pickable []string
+	}{
+		{"machines nobody played", 2, []string{addamsFamily, attackFromMars, medievalMadness, godzilla}, []string{addamsFamily}},
+		{"machines played when no other is left", 3, []string{godzilla, attackFromMars, addamsFamily}, []string{godzilla, attackFromMars}},
+		{"machines of other games when no other is left", 3, []string{addamsFamily}, []string{addamsFamily}},
+	} {
+		if pickable := GetPickableMachines(games, games[test.game], test.pool); !reflect.DeepEqual(pickable, test.pickable) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.pickable, pickable)
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package tournament
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/mikefero/tpl/db"
+)
+
+var ErrNotQualifying = errors.New("only qualifying tournaments have entries")
+var ErrNoRounds = errors.New("qualifying tournaments have no rounds")
+var ErrNotInLineup = errors.New("the machine is not in the active lineup")
+var ErrInvalidQuantity = errors.New("at least one entry must be bought")
+var ErrTooManyEntries = errors.New("the player cannot buy that many entries on the machine")
+var ErrNoEntryLeft = errors.New("no unused entry is left on the machine")
+var ErrInvalidScore = errors.New("scores must be positive")
+var ErrNotEntryPlayer = errors.New("only staff can submit scores for other players")
+var ErrScoreNotFound = errors.New("no unverified score found in this tournament")
+var ErrScoresPending = errors.New("every submitted score must be verified or rejected first")
+var ErrEntriesNotSold = errors.New("the entries could not be sold")
+var ErrBracketNotCreated = errors.New("the bracket could not be created")
+
+// Entry counts the entries a player bought and used on a machine
+type Entry struct {
+	OpdbId      string
+	MachineName string
+	Bought      int
+	Used        int
+}
+
+// MachineRank is the place of the best verified score of a player on a
+// machine; counted ranks are part of the points of the player.
+type MachineRank struct {
+	OpdbId      string
+	MachineName string
+	UserId      int
+	Name        string
+	Score       int64
+	Rank        int
+	Points      int
+	Counted     bool
+}
+
+type MachineRanking struct {
+	OpdbId      string
+	MachineName string
+	Ranks       []MachineRank
+}
+
+// Qualifier is the standing of a player in a qualifying tournament with their
+// machine ranks ordered from the most points.
+type Qualifier struct {
+	UserId    int
+	Name      string
+	Entries   int
+	Points    int
+	Machines  []MachineRank
+	Position  int
+	Qualified bool
+}
+
+func (entry Entry) Remaining() int {
+	return entry.Bought - entry.Used
+}
+
+// QualifyingPoints converts the rank of a score on a machine into points: the
+// best score earns 100 points, second 90, third 85 and every later rank one
+// point less down to zero.
+func QualifyingPoints(rank int) int {
+	switch {
+	case rank == 1:
+		return 100
+	case rank == 2:
+		return 90
+	case rank < 1 || rank > 87:
+		return 0
+	}
+	return 88 - rank
+}
+
+// GetEntries counts the entries of a player on every machine they bought
+// entries for in the order they were first bought.
+func GetEntries(tickets []db.TournamentTicket, userId int) []Entry {
+	entries := map[string]*Entry{}
+	var opdbIds []string
+	for _, ticket := range tickets {
+		if ticket.UserId != userId {
+			continue
+		}
+		entry, exists := entries[ticket.OpdbId]
+		if !exists {
+			entry = &Entry{
+				OpdbId:      ticket.OpdbId,
+				MachineName: ticket.MachineName,
+			}
+			entries[ticket.OpdbId] = entry
+			opdbIds = append(opdbIds, ticket.OpdbId)
+		}
+		entry.Bought++
+		if ticket.Used {
+			entry.Used++
+		}
+	}
+
+	var sorted []Entry
+	for _, opdbId := range opdbIds {
+		sorted = append(sorted, *entries[opdbId])
+	}
+	return sorted
+}
+
+// RankMachines ranks the best verified score of every player on each machine
+// independently; equal scores share a rank. Machines are ordered by name.
+func RankMachines(scores []db.TournamentScore) []MachineRanking {
+	best := map[string]map[int]db.TournamentScore{}
+	for _, score := range scores {
+		if !score.VerifiedAt.Valid {
+			continue
+		}
+		if best[score.OpdbId] == nil {
+			best[score.OpdbId] = map[int]db.TournamentScore{}
+		}
+		if previous, exists := best[score.OpdbId][score.UserId]; !exists || score.Score > previous.Score {
+			best[score.OpdbId][score.UserId] = score
+		}
+	}
+
+	var rankings []MachineRanking
+	for opdbId, players := range best {
+		ranking := MachineRanking{
+			OpdbId: opdbId,
+		}
+		for _, score := range players {
+			ranking.MachineName = score.MachineName
+			ranking.Ranks = append(ranking.Ranks, MachineRank{
+				OpdbId:      opdbId,
+				MachineName: score.MachineName,
+				UserId:      score.UserId,
+				Name:        score.Name,
+				Score:       score.Score,
+			})
+		}
+		sort.Slice(ranking.Ranks, func(i, j int) bool {
+			if ranking.Ranks[i].Score != ranking.Ranks[j].Score {
+				return ranking.Ranks[i].Score > ranking.Ranks[j].Score
+			}
+			return ranking.Ranks[i].UserId < ranking.Ranks[j].UserId
+		})
+		for i := range ranking.Ranks {
+			if i > 0 && ranking.Ranks[i-1].Score == ranking.Ranks[i].Score {
+				ranking.Ranks[i].Rank = ranking.Ranks[i-1].Rank
+			} else {
+				ranking.Ranks[i].Rank = i + 1
+			}
+			ranking.Ranks[i].Points = QualifyingPoints(ranking.Ranks[i].Rank)
+		}
+		rankings = append(rankings, ranking)
+	}
+	sort.Slice(rankings, func(i, j int) bool {
+		if rankings[i].MachineName != rankings[j].MachineName {
+			return rankings[i].MachineName < rankings[j].MachineName
+		}
+		return rankings[i].OpdbId < rankings[j].OpdbId
+	})
+	return rankings
+}
+
+// GetQualifiers ranks every player who bought an entry by the points of their
+// best machines, counting every machine unless the tournament limits them, and
+// breaks ties by their single best machine. The top qualifiers with points
+// advance to the bracket.
+func GetQualifiers(tournament db.Tournament, tickets []db.TournamentTicket, scores []db.TournamentScore) []Qualifier {
+	qualifiers := map[int]*Qualifier{}
+	var order []int
+	for _, ticket := range tickets {
+		qualifier, exists := qualifiers[ticket.UserId]
+		if !exists {
+			qualifier = &Qualifier{
+				UserId: ticket.UserId,
+				Name:   ticket.Name,
+			}
+			qualifiers[ticket.UserId] = qualifier
+			order = append(order, ticket.UserId)
+		}
+		qualifier.Entries++
+	}
+	for _, ranking := range RankMachines(scores) {
+		for _, rank := range ranking.Ranks {
+			if qualifier, exists := qualifiers[rank.UserId]; exists {
+				qualifier.Machines = append(qualifier.Machines, rank)
+			}
+		}
+	}
+
+	var sorted []Qualifier
+	for _, userId := range order {
+		qualifier := qualifiers[userId]
+		sort.SliceStable(qualifier.Machines, func(i, j int) bool {
+			return qualifier.Machines[i].Points > qualifier.Machines[j].Points
+		})
+		for i := range qualifier.Machines {
+			if tournament.CountedMachines.Valid && int64(i) >= tournament.CountedMachines.Int64 {
+				break
+			}
+			qualifier.Machines[i].Counted = true
+			qualifier.Points += qualifier.Machines[i].Points
+		}
+		sorted = append(sorted, *qualifier)
+	}
+	best := func(qualifier Qualifier) int {
+		if len(qualifier.Machines) == 0 {
+			return 0
+		}
+		return qualifier.Machines[0].Points
+	}
+	compare := func(a Qualifier, b Qualifier) int {
+		if a.Points != b.Points {
+			return b.Points - a.Points
+		}
+		return best(b) - best(a)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return compare(sorted[i], sorted[j]) < 0
+	})
+	for i := range sorted {
+		if i > 0 && compare(sorted[i-1], sorted[i]) == 0 {
+			sorted[i].Position = sorted[i-1].Position
+		} else {
+			sorted[i].Position = i + 1
+		}
+		sorted[i].Qualified = sorted[i].Points > 0 && tournament.Qualifiers.Valid && int64(i) < tournament.Qualifiers.Int64
+	}
+	return sorted
+}
+
+// GetQualifyingStandings computes the qualifying leaderboard of a tournament
+// from its entries and verified scores.
+func GetQualifyingStandings(tournament db.Tournament) []Qualifier {
+	return GetQualifiers(tournament, db.GetTournamentTickets(tournament.Id), db.GetTournamentScores(tournament.Id))
+}
+
+// SellTickets sells a player entries on a machine of the active lineup up to
+// the entries allowed per machine; the first sale opens qualifying.
+func SellTickets(tournament db.Tournament, userId int, opdbId string, quantity int, soldBy int) error {
+	if tournament.Format != db.TournamentFormatQualifying {
+		return ErrNotQualifying
+	}
+	if tournament.State == db.TournamentStateCompleted {
+		return ErrTournamentCompleted
+	}
+	if quantity < 1 {
+		return ErrInvalidQuantity
+	}
+	inLineup := false
+	for _, selectable := range db.GetSelectableMachines() {
+		inLineup = inLineup || selectable == opdbId
+	}
+	if !inLineup {
+		return ErrNotInLineup
+	}
+	bought := 0
+	for _, entry := range GetEntries(db.GetTournamentTickets(tournament.Id), userId) {
+		if entry.OpdbId == opdbId {
+			bought = entry.Bought
+		}
+	}
+	if tournament.Entries.Valid && int64(bought+quantity) > tournament.Entries.Int64 {
+		return ErrTooManyEntries
+	}
+
+	if !db.SellTournamentTickets(tournament.Id, userId, opdbId, quantity, soldBy) {
+		return ErrEntriesNotSold
+	}
+	if tournament.State == db.TournamentStateCheckIn {
+		db.UpdateTournamentState(tournament.Id, db.TournamentStateRunning)
+	}
+	return nil
+}
+
+// SubmitScore uses an unused entry of a player for a score. Players submit
+// their own scores to the queue and scores submitted by staff count right
+// away.
+func SubmitScore(tournament db.Tournament, user db.User, userId int, opdbId string, score int64) error {
+	if tournament.Format != db.TournamentFormatQualifying {
+		return ErrNotQualifying
+	}
+	if tournament.State == db.TournamentStateCompleted {
+		return ErrTournamentCompleted
+	}
+	staff := user.HasRole(db.RoleStaff)
+	if userId != user.Id && !staff {
+		return ErrNotEntryPlayer
+	}
+	if score < 1 {
+		return ErrInvalidScore
+	}
+	if !db.SubmitTournamentScore(tournament.Id, userId, opdbId, score, user.Id, staff) {
+		return ErrNoEntryLeft
+	}
+	return nil
+}
+
+func VerifyScore(tournament db.Tournament, id int, verifiedBy int) error {
+	if !db.VerifyTournamentScore(tournament.Id, id, verifiedBy) {
+		return ErrScoreNotFound
+	}
+	return nil
+}
+
+// RejectScore discards an unverified score and gives the player back the
+// entry it used.
+func RejectScore(tournament db.Tournament, id int) error {
+	if !db.RejectTournamentScore(tournament.Id, id) {
+		return ErrScoreNotFound
+	}
+	return nil
+}
+
+// CloseQualifying completes a qualifying tournament once every score was
+// reviewed and seeds its qualifiers into a single elimination bracket by their
+// qualifying position.
+func CloseQualifying(tournament db.Tournament, createdBy int) (*db.Tournament, error) {
+	if tournament.Format != db.TournamentFormatQualifying {
+		return nil, ErrNotQualifying
+	}
+	if tournament.State == db.TournamentStateCompleted {
+		return nil, ErrTournamentCompleted
+	}
+	scores := db.GetTournamentScores(tournament.Id)
+	for _, score := range scores {
+		if !score.VerifiedAt.Valid {
+			return nil, ErrScoresPending
+		}
+	}
+	var userIds []int
+	for _, qualifier := range GetQualifiers(tournament, db.GetTournamentTickets(tournament.Id), scores) {
+		if qualifier.Qualified {
+			userIds = append(userIds, qualifier.UserId)
+		}
+	}
+	if len(userIds) < 2 {
+		return nil, ErrNotEnoughPlayers
+	}
+
+	bracket := db.CreateBracket(tournament.Id, db.Tournament{
+		Name:        tournament.Name + " Finals",
+		Format:      db.TournamentFormatBracket,
+		GroupSize:   2,
+		StrikeLimit: 1,
+		CreatedBy:   createdBy,
+	}, userIds)
+	if bracket == nil {
+		return nil, ErrBracketNotCreated
+	}
+	return bracket, nil
+}
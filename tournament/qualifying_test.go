@@ -0,0 +1,195 @@
+package tournament
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/mikefero/tpl/db"
+)
+
+const attackFromMars = "G4do5-MDlN7"
+const medievalMadness = "G5pe4-MePZv"
+
+var testMachineNames = map[string]string{
+	attackFromMars:  "Attack from Mars",
+	medievalMadness: "Medieval Madness",
+}
+
+func newTestTicket(userId int, opdbId string, used bool) db.TournamentTicket {
+	return db.TournamentTicket{
+		UserId:      userId,
+		OpdbId:      opdbId,
+		MachineName: testMachineNames[opdbId],
+		Used:        used,
+	}
+}
+
+func newTestScore(userId int, opdbId string, score int64, verified bool) db.TournamentScore {
+	return db.TournamentScore{
+		UserId:      userId,
+		OpdbId:      opdbId,
+		MachineName: testMachineNames[opdbId],
+		Score:       score,
+		VerifiedAt:  sql.NullInt64{Int64: 1, Valid: verified},
+	}
+}
+
+// testQualifyingScores are the scores of a qualifying tournament: Ann has the
+// best score on Attack from Mars while Bob and Cat tie behind her, Bob has the
+// best score on Medieval Madness and Ann's better score there is not verified.
+var testQualifyingScores = []db.TournamentScore{
+	newTestScore(1, attackFromMars, 500, true),
+	newTestScore(2, attackFromMars, 400, true),
+	newTestScore(3, attackFromMars, 400, true),
+	newTestScore(2, attackFromMars, 300, true),
+	newTestScore(2, medievalMadness, 900, true),
+	newTestScore(1, medievalMadness, 100, true),
+	newTestScore(1, medievalMadness, 1000, false),
+	newTestScore(3, medievalMadness, 50, true),
+}
+
+var testQualifyingTickets = []db.TournamentTicket{
+	newTestTicket(1, attackFromMars, true),
+	newTestTicket(2, attackFromMars, true),
+	newTestTicket(3, attackFromMars, true),
+	newTestTicket(4, attackFromMars, false),
+	newTestTicket(1, medievalMadness, true),
+	newTestTicket(2, medievalMadness, true),
+	newTestTicket(1, medievalMadness, true),
+	newTestTicket(3, medievalMadness, true),
+	newTestTicket(2, attackFromMars, true),
+}
+
+func TestQualifyingPoints(t *testing.T) {
+	for _, test := range []struct {
+		rank   int
+		points int
+	}{
+		{-1, 0},
+		{0, 0},
+		{1, 100},
+		{2, 90},
+		{3, 85},
+		{4, 84},
+		{10, 78},
+		{87, 1},
+		{88, 0},
+	} {
+		if points := QualifyingPoints(test.rank); points != test.points {
+			t.Errorf("expected rank %d to earn %d points, got %d", test.rank, test.points, points)
+		}
+	}
+}
+
+func TestGetEntries(t *testing.T) {
+	tickets := []db.TournamentTicket{
+		newTestTicket(1, medievalMadness, true),
+		newTestTicket(2, attackFromMars, true),
+		newTestTicket(1, attackFromMars, false),
+		newTestTicket(1, medievalMadness, false),
+	}
+	expected := []Entry{
+		{OpdbId: medievalMadness, MachineName: "Medieval Madness", Bought: 2, Used: 1},
+		{OpdbId: attackFromMars, MachineName: "Attack from Mars", Bought: 1},
+	}
+	entries := GetEntries(tickets, 1)
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected %+v, got %+v", expected, entries)
+	}
+	if entries[0].Remaining() != 1 {
+		t.Errorf("expected 1 entry left, got %d", entries[0].Remaining())
+	}
+}
+
+func TestRankMachines(t *testing.T) {
+	rankings := RankMachines(testQualifyingScores)
+	if len(rankings) != 2 {
+		t.Fatalf("expected 2 ranked machines, got %d", len(rankings))
+	}
+	for i, test := range []struct {
+		opdbId string
+		ranks  [][4]int64
+	}{
+		{attackFromMars, [][4]int64{{1, 500, 1, 100}, {2, 400, 2, 90}, {3, 400, 2, 90}}},
+		{medievalMadness, [][4]int64{{2, 900, 1, 100}, {1, 100, 2, 90}, {3, 50, 3, 85}}},
+	} {
+		ranking := rankings[i]
+		if ranking.OpdbId != test.opdbId {
+			t.Errorf("expected %s at position %d, got %s", test.opdbId, i+1, ranking.OpdbId)
+			continue
+		}
+		var ranks [][4]int64
+		for _, rank := range ranking.Ranks {
+			ranks = append(ranks, [4]int64{int64(rank.UserId), rank.Score, int64(rank.Rank), int64(rank.Points)})
+		}
+		if !reflect.DeepEqual(ranks, test.ranks) {
+			t.Errorf("expected %s to rank user, score, rank and points %v, got %v", test.opdbId, test.ranks, ranks)
+		}
+	}
+}
+
+func TestGetQualifiers(t *testing.T) {
+	for _, test := range []struct {
+		name            string
+		countedMachines sql.NullInt64
+		qualifiers      []Qualifier
+	}{
+		{
+			name: "every machine counted",
+			qualifiers: []Qualifier{
+				{UserId: 1, Entries: 3, Points: 190, Position: 1, Qualified: true},
+				{UserId: 2, Entries: 3, Points: 190, Position: 1, Qualified: true},
+				{UserId: 3, Entries: 2, Points: 175, Position: 3},
+				{UserId: 4, Entries: 1, Position: 4},
+			},
+		},
+		{
+			name:            "best machine counted",
+			countedMachines: sql.NullInt64{Int64: 1, Valid: true},
+			qualifiers: []Qualifier{
+				{UserId: 1, Entries: 3, Points: 100, Position: 1, Qualified: true},
+				{UserId: 2, Entries: 3, Points: 100, Position: 1, Qualified: true},
+				{UserId: 3, Entries: 2, Points: 90, Position: 3},
+				{UserId: 4, Entries: 1, Position: 4},
+			},
+		},
+	} {
+		tournament := db.Tournament{
+			Format:          db.TournamentFormatQualifying,
+			CountedMachines: test.countedMachines,
+			Qualifiers:      sql.NullInt64{Int64: 2, Valid: true},
+		}
+		qualifiers := GetQualifiers(tournament, testQualifyingTickets, testQualifyingScores)
+		if len(qualifiers) != len(test.qualifiers) {
+			t.Fatalf("%s: expected %d qualifiers, got %d", test.name, len(test.qualifiers), len(qualifiers))
+		}
+		for i, expected := range test.qualifiers {
+			qualifier := qualifiers[i]
+			if qualifier.UserId != expected.UserId || qualifier.Entries != expected.Entries || qualifier.Points != expected.Points ||
+				qualifier.Position != expected.Position || qualifier.Qualified != expected.Qualified {
+				t.Errorf("%s: expected %+v at position %d, got %+v", test.name, expected, i+1, qualifier)
+			}
+			counted := 0
+			for _, machine := range qualifier.Machines {
+				if machine.Counted {
+					counted += machine.Points
+				}
+			}
+			if counted != qualifier.Points {
+				t.Errorf("%s: expected the counted machines of %d to add up to %d points, got %d", test.name, qualifier.UserId, qualifier.Points, counted)
+			}
+		}
+	}
+}
+
+func TestGetQualifiersWithoutPoints(t *testing.T) {
+	tournament := db.Tournament{
+		Format:     db.TournamentFormatQualifying,
+		Qualifiers: sql.NullInt64{Int64: 2, Valid: true},
+	}
+	qualifiers := GetQualifiers(tournament, []db.TournamentTicket{newTestTicket(1, attackFromMars, false)}, nil)
+	if len(qualifiers) != 1 || qualifiers[0].Qualified {
+		t.Errorf("expected players without points not to qualify, got %+v", qualifiers)
+	}
+}
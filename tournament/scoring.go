@@ -0,0 +1,66 @@
+package tournament
+
+// Rule awards strikes or points to the players of a group by their finishing
+// place; each group size lists the award of the first place first.
+type Rule map[int][]int
+
+// FairStrikes gives no strike to the winner of a group and the most strikes to
+// the last place, splitting the middle places of four player groups evenly.
+var FairStrikes = Rule{
+	2: {0, 1},
+	3: {0, 1, 2},
+	4: {0, 1, 1, 2},
+}
+
+// PapaPoints are the 7-5-3-1 placement points of group match play; smaller
+// groups keep the points of the first and last place.
+var PapaPoints = Rule{
+	2: {7, 1},
+	3: {7, 4, 1},
+	4: {7, 5, 3, 1},
+}
+
+// IfpaPoints are the 4-2-1-0 placement points of group match play; smaller
+// groups keep the points of the first and last place.
+var IfpaPoints = Rule{
+	2: {4, 0},
+	3: {4, 2, 0},
+	4: {4, 2, 1, 0},
+}
+
+const ScoringPapa = "7-5-3-1"
+const ScoringIfpa = "4-2-1-0"
+
+// Scorings are the placement points a match play tournament can be played with
+var Scorings = []string{
+	ScoringPapa,
+	ScoringIfpa,
+}
+
+var scoringRules = map[string]Rule{
+	ScoringPapa: PapaPoints,
+	ScoringIfpa: IfpaPoints,
+}
+
+func IsScoring(scoring string) bool {
+	_, exists := scoringRules[scoring]
+	return exists
+}
+
+// GetScoringRule returns the placement points of a match play scoring,
+// defaulting to 7-5-3-1.
+func GetScoringRule(scoring string) Rule {
+	if rule, exists := scoringRules[scoring]; exists {
+		return rule
+	}
+	return PapaPoints
+}
+
+// Award returns the strikes or points of a finishing place in a group.
+func (rule Rule) Award(groupSize int, place int) int {
+	awards := rule[groupSize]
+	if place < 1 || place > len(awards) {
+		return 0
+	}
+	return awards[place-1]
+}
@@ -0,0 +1,225 @@
+package tournament
+
+import (
+	"sort"
+
+	"github.com/mikefero/tpl/db"
+)
+
+// Standing is the record of a player in a tournament; players who are still
+// in have not been eliminated in any round. Strikes apply to strikes
+// tournaments, the score and tiebreaks to Swiss tournaments and the score to
+// the placement points of match play tournaments.
+type Standing struct {
+	UserId             int
+	Name               string
+	Strikes            int
+	Games              int
+	Wins               int
+	Losses             int
+	Byes               int
+	Score              float64
+	Buchholz           float64
+	StrengthOfSchedule float64
+	EliminatedRound    int
+	Position           int
+}
+
+func (standing Standing) Eliminated() bool {
+	return standing.EliminatedRound > 0
+}
+
+// GetRounds returns the number of rounds started in a tournament.
+func GetRounds(games []db.TournamentGame) int {
+	rounds := 0
+	for _, game := range games {
+		if game.Round > rounds {
+			rounds = game.Round
+		}
+	}
+	return rounds
+}
+
+// IsRoundComplete determines if every game of a round was recorded.
+func IsRoundComplete(games []db.TournamentGame, round int) bool {
+	for _, game := range games {
+		if game.Round == round && !game.CompletedAt.Valid {
+			return false
+		}
+	}
+	return true
+}
+
+// GetMeetings counts how often each pair of players played in the same game.
+func GetMeetings(games []db.TournamentGame) map[int]map[int]int {
+	meetings := map[int]map[int]int{}
+	for _, game := range games {
+		for _, player := range game.Players {
+			if meetings[player.UserId] == nil {
+				meetings[player.UserId] = map[int]int{}
+			}
+			for _, opponent := range game.Players {
+				if opponent.UserId != player.UserId {
+					meetings[player.UserId][opponent.UserId]++
+				}
+			}
+		}
+	}
+	return meetings
+}
+
+// GetStandings computes the standings of every checked in player from the
+// recorded games, which must be ordered by round.
+func GetStandings(tournament db.Tournament, players []db.TournamentPlayer, games []db.TournamentGame) []Standing {
+	switch tournament.Format {
+	case db.TournamentFormatSwiss:
+		return getSwissStandings(players, games)
+	case db.TournamentFormatMatchPlay:
+		return getMatchPlayStandings(tournament, players, games)
+	}
+	return getStrikeStandings(tournament, players, games)
+}
+
+// getStrikeStandings ranks the players still in by the fewest strikes and
+// eliminated players by how late they went out; players that cannot be told
+// apart share a position.
+func getStrikeStandings(tournament db.Tournament, players []db.TournamentPlayer, games []db.TournamentGame) []Standing {
+	standings := map[int]*Standing{}
+	var order []int
+	for _, player := range players {
+		standings[player.UserId] = &Standing{
+			UserId: player.UserId,
+			Name:   player.Name,
+		}
+		order = append(order, player.UserId)
+	}
+
+	for round := 1; round <= GetRounds(games); round++ {
+		playing := map[int]bool{}
+		for _, game := range games {
+			if game.Round != round {
+				continue
+			}
+			for _, player := range game.Players {
+				playing[player.UserId] = true
+				standing, exists := standings[player.UserId]
+				if !exists || !player.Place.Valid {
+					continue
+				}
+				standing.Games++
+				if player.Place.Int64 == 1 {
+					standing.Wins++
+				} else {
+					standing.Losses++
+				}
+				standing.Strikes += FairStrikes.Award(len(game.Players), int(player.Place.Int64))
+			}
+		}
+		for _, standing := range standings {
+			if standing.Eliminated() {
+				continue
+			}
+			if !playing[standing.UserId] {
+				standing.Byes++
+			} else if standing.Strikes >= tournament.StrikeLimit {
+				standing.EliminatedRound = round
+			}
+		}
+	}
+
+	var sorted []Standing
+	for _, id := range order {
+		sorted = append(sorted, *standings[id])
+	}
+	compare := func(a Standing, b Standing) int {
+		switch {
+		case a.Eliminated() != b.Eliminated():
+			if b.Eliminated() {
+				return -1
+			}
+			return 1
+		case a.EliminatedRound != b.EliminatedRound:
+			return b.EliminatedRound - a.EliminatedRound
+		}
+		return a.Strikes - b.Strikes
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return compare(sorted[i], sorted[j]) < 0
+	})
+	for i := range sorted {
+		if i > 0 && compare(sorted[i-1], sorted[i]) == 0 {
+			sorted[i].Position = sorted[i-1].Position
+		} else {
+			sorted[i].Position = i + 1
+		}
+	}
+	return sorted
+}
+
+// GetActivePlayers returns the standings of the players still in.
+func GetActivePlayers(standings []Standing) []Standing {
+	var active []Standing
+	for _, standing := range standings {
+		if !standing.Eliminated() {
+			active = append(active, standing)
+		}
+	}
+	return active
+}
+
+// getSwissGames returns the games of a Swiss tournament from the point of view
+// of each player, giving a bye to every player left out of a round.
+func getSwissGames(players []db.TournamentPlayer, games []db.TournamentGame) []SwissGame {
+	var swissGames []SwissGame
+	for round := 1; round <= GetRounds(games); round++ {
+		playing := map[int]bool{}
+		for _, game := range games {
+			if game.Round != round {
+				continue
+			}
+			for _, player := range game.Players {
+				playing[player.UserId] = true
+			}
+			if !game.CompletedAt.Valid || len(game.Players) != 2 {
+				continue
+			}
+			a, b := game.Players[0], game.Players[1]
+			outcome := getOutcome(b.Place.Int64, a.Place.Int64)
+			swissGames = append(swissGames,
+				SwissGame{Id: a.UserId, OpponentId: b.UserId, Outcome: outcome},
+				SwissGame{Id: b.UserId, OpponentId: a.UserId, Outcome: 1 - outcome})
+		}
+		for _, player := range players {
+			if !playing[player.UserId] {
+				swissGames = append(swissGames, SwissGame{Id: player.UserId})
+			}
+		}
+	}
+	return swissGames
+}
+
+func getSwissStandings(players []db.TournamentPlayer, games []db.TournamentGame) []Standing {
+	names := map[int]string{}
+	var ids []int
+	for _, player := range players {
+		names[player.UserId] = player.Name
+		ids = append(ids, player.UserId)
+	}
+
+	var standings []Standing
+	for _, swiss := range GetSwissStandings(ids, getSwissGames(players, games)) {
+		standings = append(standings, Standing{
+			UserId:             swiss.Id,
+			Name:               names[swiss.Id],
+			Games:              swiss.games(),
+			Wins:               swiss.Wins,
+			Losses:             swiss.Losses,
+			Byes:               swiss.Byes,
+			Score:              swiss.Score,
+			Buchholz:           swiss.Buchholz,
+			StrengthOfSchedule: swiss.StrengthOfSchedule,
+			Position:           swiss.Position,
+		})
+	}
+	return standings
+}
@@ -0,0 +1,138 @@
+package tournament
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/mikefero/tpl/db"
+)
+
+// newTestGame returns a game of a round between players in order of play;
+// the game is recorded when the places of its players are given.
+func newTestGame(id int, round int, opdbId string, userIds []int, places []int) db.TournamentGame {
+	game := db.TournamentGame{
+		Id:     id,
+		Round:  round,
+		OpdbId: opdbId,
+	}
+	for i, userId := range userIds {
+		player := db.TournamentGamePlayer{
+			GameId:   id,
+			UserId:   userId,
+			Position: i + 1,
+		}
+		if places != nil {
+			player.Place = sql.NullInt64{Int64: int64(places[i]), Valid: true}
+		}
+		game.Players = append(game.Players, player)
+	}
+	if places != nil {
+		game.CompletedAt = sql.NullInt64{Int64: int64(id), Valid: true}
+	}
+	return game
+}
+
+func newTestPlayers(userIds ...int) []db.TournamentPlayer {
+	var players []db.TournamentPlayer
+	for _, userId := range userIds {
+		players = append(players, db.TournamentPlayer{
+			UserId:      userId,
+			CheckedInAt: int64(userId),
+		})
+	}
+	return players
+}
+
+func TestFairStrikes(t *testing.T) {
+	for _, test := range []struct {
+		groupSize int
+		strikes   []int
+	}{
+		{2, []int{0, 1}},
+		{3, []int{0, 1, 2}},
+		{4, []int{0, 1, 1, 2}},
+	} {
+		var strikes []int
+		for place := 1; place <= test.groupSize; place++ {
+			strikes = append(strikes, FairStrikes.Award(test.groupSize, place))
+		}
+		if !reflect.DeepEqual(strikes, test.strikes) {
+			t.Errorf("expected groups of %d to get %v strikes, got %v", test.groupSize, test.strikes, strikes)
+		}
+	}
+	if strikes := FairStrikes.Award(4, 5); strikes != 0 {
+		t.Errorf("expected no strikes outside the group, got %d", strikes)
+	}
+}
+
+func TestGetMeetings(t *testing.T) {
+	games := []db.TournamentGame{
+		newTestGame(1, 1, "G4ODR-MDXEy", []int{1, 2, 3}, []int{1, 2, 3}),
+		newTestGame(2, 2, "G5pe4-MePZv", []int{1, 2}, nil),
+	}
+	meetings := GetMeetings(games)
+	if meetings[1][2] != 2 || meetings[2][1] != 2 || meetings[1][3] != 1 || meetings[3][2] != 1 {
+		t.Errorf("expected players to have met once per game, got %v", meetings)
+	}
+	if GetRounds(games) != 2 {
+		t.Errorf("expected 2 rounds, got %d", GetRounds(games))
+	}
+	if !IsRoundComplete(games, 1) || IsRoundComplete(games, 2) {
+		t.Error("expected only the first round to be complete")
+	}
+}
+
+func TestGetStrikeStandings(t *testing.T) {
+	tournament := db.Tournament{
+		Format:      db.TournamentFormatStrikes,
+		GroupSize:   2,
+		StrikeLimit: 3,
+	}
+	games := []db.TournamentGame{
+		newTestGame(1, 1, "G4ODR-MDXEy", []int{1, 2}, []int{1, 2}),
+		newTestGame(2, 1, "G5pe4-MePZv", []int{3, 4}, []int{2, 1}),
+		newTestGame(3, 2, "G4ODR-MDXEy", []int{5, 2}, []int{1, 2}),
+		newTestGame(4, 2, "G5pe4-MePZv", []int{1, 3}, []int{2, 1}),
+		newTestGame(5, 3, "G4ODR-MDXEy", []int{2, 4}, []int{2, 1}),
+		newTestGame(6, 3, "G5pe4-MePZv", []int{1, 3}, []int{1, 2}),
+	}
+	standings := GetStandings(tournament, newTestPlayers(1, 2, 3, 4, 5), games)
+
+	for i, expected := range []Standing{
+		{UserId: 4, Games: 2, Wins: 2, Byes: 1, Position: 1},
+		{UserId: 5, Games: 1, Wins: 1, Byes: 2, Position: 1},
+		{UserId: 1, Strikes: 1, Games: 3, Wins: 2, Losses: 1, Position: 3},
+		{UserId: 3, Strikes: 2, Games: 3, Wins: 1, Losses: 2, Position: 4},
+		{UserId: 2, Strikes: 3, Games: 3, Losses: 3, EliminatedRound: 3, Position: 5},
+	} {
+		if standings[i] != expected {
+			t.Errorf("expected %+v at position %d, got %+v", expected, i+1, standings[i])
+		}
+	}
+	if active := GetActivePlayers(standings); len(active) != 4 {
+		t.Errorf("expected 4 players still in, got %d", len(active))
+	}
+}
+
+func TestGetStrikeStandingsEliminationOrder(t *testing.T) {
+	tournament := db.Tournament{
+		Format:      db.TournamentFormatStrikes,
+		GroupSize:   2,
+		StrikeLimit: 1,
+	}
+	games := []db.TournamentGame{
+		newTestGame(1, 1, "G4ODR-MDXEy", []int{1, 2}, []int{1, 2}),
+		newTestGame(2, 1, "G5pe4-MePZv", []int{3, 4}, []int{1, 2}),
+		newTestGame(3, 2, "G4ODR-MDXEy", []int{1, 3}, []int{2, 1}),
+	}
+	var order []int
+	var positions []int
+	for _, standing := range GetStandings(tournament, newTestPlayers(1, 2, 3, 4), games) {
+		order = append(order, standing.UserId)
+		positions = append(positions, standing.Position)
+	}
+	if !reflect.DeepEqual(order, []int{3, 1, 2, 4}) || !reflect.DeepEqual(positions, []int{1, 2, 3, 3}) {
+		t.Errorf("expected players going out later to rank higher, got %v at %v", order, positions)
+	}
+}
@@ -0,0 +1,205 @@
+package tournament
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// SwissGame is a game of a Swiss-system event from the point of view of an
+// entrant; byes have no opponent and count as a win.
+type SwissGame struct {
+	Id         int
+	OpponentId int
+	Outcome    float64
+}
+
+// SwissStanding is the record of a player or team in a Swiss-system event.
+// The Buchholz score is the sum of the scores of every opponent and the
+// strength of schedule is the average share of games the opponents won, not
+// counting their byes.
+type SwissStanding struct {
+	Id                 int
+	Wins               int
+	Losses             int
+	Ties               int
+	Byes               int
+	Score              float64
+	Buchholz           float64
+	StrengthOfSchedule float64
+	Position           int
+}
+
+func (standing SwissStanding) games() int {
+	return standing.Wins + standing.Losses + standing.Ties
+}
+
+func (standing SwissStanding) winRate() float64 {
+	if standing.games() == 0 {
+		return 0
+	}
+	return (float64(standing.Wins) + float64(standing.Ties)/2) / float64(standing.games())
+}
+
+// getOutcome is 1 when a score beats an opposing score, 0 when it loses and
+// 0.5 for a tie.
+func getOutcome(score int64, opponentScore int64) float64 {
+	switch {
+	case score > opponentScore:
+		return 1
+	case score < opponentScore:
+		return 0
+	}
+	return 0.5
+}
+
+// GetSwissStandings ranks entrants by score and breaks ties with the Buchholz
+// score and then the strength of schedule; entrants that cannot be told apart
+// share a position.
+func GetSwissStandings(ids []int, games []SwissGame) []SwissStanding {
+	standings := map[int]*SwissStanding{}
+	for _, id := range ids {
+		standings[id] = &SwissStanding{
+			Id: id,
+		}
+	}
+	opponents := map[int][]int{}
+	for _, game := range games {
+		standing, exists := standings[game.Id]
+		if !exists {
+			continue
+		}
+		switch {
+		case game.OpponentId == 0:
+			standing.Byes++
+			standing.Score++
+			continue
+		case game.Outcome == 1:
+			standing.Wins++
+		case game.Outcome == 0:
+			standing.Losses++
+		default:
+			standing.Ties++
+		}
+		standing.Score += game.Outcome
+		opponents[game.Id] = append(opponents[game.Id], game.OpponentId)
+	}
+
+	var sorted []SwissStanding
+	for _, id := range ids {
+		standing := standings[id]
+		for _, opponentId := range opponents[id] {
+			if opponent, exists := standings[opponentId]; exists {
+				standing.Buchholz += opponent.Score
+				standing.StrengthOfSchedule += opponent.winRate()
+			}
+		}
+		if len(opponents[id]) > 0 {
+			standing.StrengthOfSchedule /= float64(len(opponents[id]))
+		}
+		sorted = append(sorted, *standing)
+	}
+	compare := func(a SwissStanding, b SwissStanding) bool {
+		return a.Score == b.Score && a.Buchholz == b.Buchholz && a.StrengthOfSchedule == b.StrengthOfSchedule
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Buchholz != b.Buchholz {
+			return a.Buchholz > b.Buchholz
+		}
+		return a.StrengthOfSchedule > b.StrengthOfSchedule
+	})
+	for i := range sorted {
+		if i > 0 && compare(sorted[i-1], sorted[i]) {
+			sorted[i].Position = sorted[i-1].Position
+		} else {
+			sorted[i].Position = i + 1
+		}
+	}
+	return sorted
+}
+
+// PairSwiss pairs entrants of the same or closest score for a round, leaving
+// the lowest ranked entrant with the fewest byes out when their number is odd.
+// Entrants on the same score are shuffled by the seed and round so the same
+// round is always paired the same way. Rematches are only allowed when every
+// other pairing fails and then between entrants who met the least.
+func PairSwiss(standings []SwissStanding, meetings map[int]map[int]int, seed int64, round int) ([][2]int, int) {
+	ordered := append([]SwissStanding{}, standings...)
+	random := rand.New(rand.NewSource(seed + int64(round)))
+	random.Shuffle(len(ordered), func(i, j int) {
+		ordered[i], ordered[j] = ordered[j], ordered[i]
+	})
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Score > ordered[j].Score
+	})
+
+	bye := 0
+	if len(ordered)%2 == 1 {
+		last := len(ordered) - 1
+		for i := len(ordered) - 1; i >= 0; i-- {
+			if ordered[i].Byes < ordered[last].Byes {
+				last = i
+			}
+		}
+		bye = ordered[last].Id
+		ordered = append(ordered[:last:last], ordered[last+1:]...)
+	}
+
+	var order []int
+	for _, standing := range ordered {
+		order = append(order, standing.Id)
+	}
+	if pairs, ok := pairWithoutRematches(order, meetings); ok {
+		return pairs, bye
+	}
+	return pairWithFewestRematches(order, meetings), bye
+}
+
+// maximumPairingSteps bounds the search for pairings without rematches, which
+// grows quickly once most entrants have met
+const maximumPairingSteps = 100000
+
+// pairWithoutRematches pairs the first entrant left with the closest entrant
+// they have not met, backtracking when the entrants left cannot be paired.
+func pairWithoutRematches(order []int, meetings map[int]map[int]int) ([][2]int, bool) {
+	steps := 0
+	var pair func(order []int) ([][2]int, bool)
+	pair = func(order []int) ([][2]int, bool) {
+		if len(order) == 0 {
+			return nil, true
+		}
+		first := order[0]
+		for i := 1; i < len(order) && steps < maximumPairingSteps; i++ {
+			steps++
+			if meetings[first][order[i]] > 0 {
+				continue
+			}
+			remaining := append(append([]int{}, order[1:i]...), order[i+1:]...)
+			if pairs, ok := pair(remaining); ok {
+				return append([][2]int{{first, order[i]}}, pairs...), true
+			}
+		}
+		return nil, false
+	}
+	return pair(order)
+}
+
+func pairWithFewestRematches(order []int, meetings map[int]map[int]int) [][2]int {
+	var pairs [][2]int
+	remaining := append([]int{}, order...)
+	for len(remaining) > 1 {
+		first := remaining[0]
+		best := 1
+		for i := 2; i < len(remaining); i++ {
+			if meetings[first][remaining[i]] < meetings[first][remaining[best]] {
+				best = i
+			}
+		}
+		pairs = append(pairs, [2]int{first, remaining[best]})
+		remaining = append(remaining[1:best], remaining[best+1:]...)
+	}
+	return pairs
+}
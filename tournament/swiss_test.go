@@ -0,0 +1,171 @@
+package tournament
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mikefero/tpl/db"
+)
+
+// newSwissStandings returns standings of entrants on the given scores with the
+// given byes, keyed by entrant ID.
+func newSwissStandings(scores map[int]float64, byes map[int]int) []SwissStanding {
+	var standings []SwissStanding
+	for id := 1; id <= len(scores); id++ {
+		standings = append(standings, SwissStanding{
+			Id:    id,
+			Score: scores[id],
+			Byes:  byes[id],
+		})
+	}
+	return standings
+}
+
+func TestGetOutcome(t *testing.T) {
+	for _, test := range []struct {
+		score         int64
+		opponentScore int64
+		outcome       float64
+	}{
+		{200, 100, 1},
+		{100, 200, 0},
+		{100, 100, 0.5},
+	} {
+		if outcome := getOutcome(test.score, test.opponentScore); outcome != test.outcome {
+			t.Errorf("expected %d against %d to be %v, got %v", test.score, test.opponentScore, test.outcome, outcome)
+		}
+	}
+}
+
+func TestGetSwissStandings(t *testing.T) {
+	// Entrant 5 has a bye in both rounds and ties entrant 1 on score but loses
+	// the tiebreak on Buchholz; games of entrant 6, who is not in the event,
+	// are ignored
+	games := []SwissGame{
+		{Id: 1, OpponentId: 2, Outcome: 1},
+		{Id: 2, OpponentId: 1, Outcome: 0},
+		{Id: 3, OpponentId: 4, Outcome: 1},
+		{Id: 4, OpponentId: 3, Outcome: 0},
+		{Id: 5},
+		{Id: 1, OpponentId: 3, Outcome: 1},
+		{Id: 3, OpponentId: 1, Outcome: 0},
+		{Id: 2, OpponentId: 4, Outcome: 0.5},
+		{Id: 4, OpponentId: 2, Outcome: 0.5},
+		{Id: 5},
+		{Id: 6, OpponentId: 1, Outcome: 1},
+	}
+	standings := GetSwissStandings([]int{1, 2, 3, 4, 5}, games)
+
+	for i, expected := range []SwissStanding{
+		{Id: 1, Wins: 2, Score: 2, Buchholz: 1.5, StrengthOfSchedule: 0.375, Position: 1},
+		{Id: 5, Byes: 2, Score: 2, Position: 2},
+		{Id: 3, Wins: 1, Losses: 1, Score: 1, Buchholz: 2.5, StrengthOfSchedule: 0.625, Position: 3},
+		{Id: 2, Losses: 1, Ties: 1, Score: 0.5, Buchholz: 2.5, StrengthOfSchedule: 0.625, Position: 4},
+		{Id: 4, Losses: 1, Ties: 1, Score: 0.5, Buchholz: 1.5, StrengthOfSchedule: 0.375, Position: 5},
+	} {
+		if standings[i] != expected {
+			t.Errorf("expected %+v at position %d, got %+v", expected, i+1, standings[i])
+		}
+	}
+}
+
+func TestGetSwissStandingsSharedPosition(t *testing.T) {
+	standings := GetSwissStandings([]int{1, 2, 3}, []SwissGame{
+		{Id: 1, OpponentId: 2, Outcome: 0.5},
+		{Id: 2, OpponentId: 1, Outcome: 0.5},
+	})
+	var positions []int
+	for _, standing := range standings {
+		positions = append(positions, standing.Position)
+	}
+	if !reflect.DeepEqual(positions, []int{1, 1, 3}) {
+		t.Errorf("expected entrants that cannot be told apart to share a position, got %v", positions)
+	}
+}
+
+func TestPairSwiss(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		scores   map[int]float64
+		byes     map[int]int
+		meetings map[int]map[int]int
+		pairs    [][2]int
+		bye      int
+	}{
+		{
+			name:   "by score",
+			scores: map[int]float64{1: 3, 2: 2, 3: 1, 4: 0},
+			pairs:  [][2]int{{1, 2}, {3, 4}},
+		},
+		{
+			name:     "backtracking to avoid a rematch",
+			scores:   map[int]float64{1: 3, 2: 2, 3: 1, 4: 0},
+			meetings: map[int]map[int]int{3: {4: 1}, 4: {3: 1}},
+			pairs:    [][2]int{{1, 3}, {2, 4}},
+		},
+		{
+			name:   "rematches between entrants who met the least",
+			scores: map[int]float64{1: 3, 2: 2, 3: 1, 4: 0},
+			meetings: map[int]map[int]int{
+				1: {2: 2, 3: 1, 4: 1},
+				2: {1: 2, 3: 1, 4: 1},
+				3: {1: 1, 2: 1, 4: 1},
+				4: {1: 1, 2: 1, 3: 1},
+			},
+			pairs: [][2]int{{1, 3}, {2, 4}},
+		},
+		{
+			name:   "bye to the lowest entrant without one",
+			scores: map[int]float64{1: 4, 2: 3, 3: 2, 4: 1, 5: 0},
+			byes:   map[int]int{5: 1},
+			pairs:  [][2]int{{1, 2}, {3, 5}},
+			bye:    4,
+		},
+		{
+			name:   "bye to the lowest entrant",
+			scores: map[int]float64{1: 2, 2: 1, 3: 0},
+			pairs:  [][2]int{{1, 2}},
+			bye:    3,
+		},
+	} {
+		pairs, bye := PairSwiss(newSwissStandings(test.scores, test.byes), test.meetings, 42, 1)
+		if !reflect.DeepEqual(pairs, test.pairs) || bye != test.bye {
+			t.Errorf("%s: expected %v with a bye for %d, got %v with a bye for %d", test.name, test.pairs, test.bye, pairs, bye)
+		}
+	}
+}
+
+func TestPairSwissSameScore(t *testing.T) {
+	standings := newSwissStandings(map[int]float64{1: 2, 2: 1, 3: 1, 4: 0}, nil)
+	pairs, _ := PairSwiss(standings, nil, 42, 3)
+	if again, _ := PairSwiss(standings, nil, 42, 3); !reflect.DeepEqual(pairs, again) {
+		t.Errorf("expected the same seed and round to pair the same way, got %v and %v", pairs, again)
+	}
+	if len(pairs) != 2 || pairs[0][0] != 1 || pairs[1][1] != 4 || pairs[0][1]+pairs[1][0] != 5 {
+		t.Errorf("expected the entrants on the same score to be paired with their neighbours, got %v", pairs)
+	}
+}
+
+func TestGetSwissTournamentStandings(t *testing.T) {
+	tournament := db.Tournament{
+		Format:    db.TournamentFormatSwiss,
+		GroupSize: 2,
+	}
+	// Player 2 lost to player 1 and has a bye in the second round, whose game
+	// is not recorded yet
+	games := []db.TournamentGame{
+		newTestGame(1, 1, "G4ODR-MDXEy", []int{1, 2}, []int{1, 2}),
+		newTestGame(2, 2, "G4ODR-MDXEy", []int{3, 1}, nil),
+	}
+	standings := GetStandings(tournament, newTestPlayers(1, 2, 3), games)
+
+	for i, expected := range []Standing{
+		{UserId: 2, Games: 1, Losses: 1, Byes: 1, Score: 1, Buchholz: 1, StrengthOfSchedule: 1, Position: 1},
+		{UserId: 1, Games: 1, Wins: 1, Score: 1, Buchholz: 1, Position: 2},
+		{UserId: 3, Byes: 1, Score: 1, Position: 3},
+	} {
+		if standings[i] != expected {
+			t.Errorf("expected %+v at position %d, got %+v", expected, i+1, standings[i])
+		}
+	}
+}
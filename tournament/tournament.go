@@ -0,0 +1,276 @@
+package tournament
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/mikefero/tpl/db"
+)
+
+var ErrCheckInClosed = errors.New("check-in is closed for this tournament")
+var ErrTournamentCompleted = errors.New("the tournament is completed")
+var ErrRoundInProgress = errors.New("the games of the current round are not all recorded")
+var ErrNotEnoughPlayers = errors.New("at least two players are needed to play a round")
+var ErrNoMachines = errors.New("no machines are available to assign")
+var ErrRoundNotCreated = errors.New("the round could not be created")
+var ErrGameNotFound = errors.New("game not found in this tournament")
+var ErrNotGamePlayer = errors.New("only players of the game can record it")
+var ErrInvalidPlaces = errors.New("every player of the game must finish in a different place")
+var ErrGameRecorded = errors.New("the game was already recorded")
+
+// CheckIn adds a player to a tournament that has not started yet.
+func CheckIn(tournament db.Tournament, userId int) error {
+	if tournament.State != db.TournamentStateCheckIn {
+		return ErrCheckInClosed
+	}
+	db.CheckInTournamentPlayer(tournament.Id, userId)
+	return nil
+}
+
+// CheckOut removes a player from a tournament that has not started yet.
+func CheckOut(tournament db.Tournament, userId int) error {
+	if tournament.State != db.TournamentStateCheckIn {
+		return ErrCheckInClosed
+	}
+	db.CheckOutTournamentPlayer(tournament.Id, userId)
+	return nil
+}
+
+// GetTournamentStandings computes the standings of a tournament from its
+// games.
+func GetTournamentStandings(tournament db.Tournament) []Standing {
+	return GetStandings(tournament, db.GetTournamentPlayers(tournament.Id), db.GetTournamentGames(tournament.Id))
+}
+
+// getAvailableMachines returns the machines of the pool a group can play,
+// avoiding machines already assigned in the round and machines any of its
+// players already played in the tournament as long as others are left.
+func getAvailableMachines(group []int, pool []string, assigned map[string]bool, played map[int]map[string]bool) []string {
+	var available []string
+	for _, opdbId := range pool {
+		if !assigned[opdbId] {
+			available = append(available, opdbId)
+		}
+	}
+	if len(available) == 0 {
+		available = pool
+	}
+	var fresh []string
+	for _, opdbId := range available {
+		isFresh := true
+		for _, player := range group {
+			isFresh = isFresh && !played[player][opdbId]
+		}
+		if isFresh {
+			fresh = append(fresh, opdbId)
+		}
+	}
+	if len(fresh) > 0 {
+		return fresh
+	}
+	return available
+}
+
+// getPlayedMachines returns the machines every player played in games before
+// a round.
+func getPlayedMachines(games []db.TournamentGame, round int) map[int]map[string]bool {
+	played := map[int]map[string]bool{}
+	for _, game := range games {
+		if game.Round >= round {
+			continue
+		}
+		for _, player := range game.Players {
+			if played[player.UserId] == nil {
+				played[player.UserId] = map[string]bool{}
+			}
+			played[player.UserId][game.OpdbId] = true
+		}
+	}
+	return played
+}
+
+// AssignMachines draws a machine from the machines available to every group of
+// a round. Draws are numbered across the tournament so that every draw can be
+// audited.
+func AssignMachines(seed int64, draw int, groups [][]int, pool []string, played map[int]map[string]bool) []db.TournamentGame {
+	assigned := map[string]bool{}
+	var games []db.TournamentGame
+	for i, group := range groups {
+		available := getAvailableMachines(group, pool, assigned, played)
+		game := db.TournamentGame{
+			OpdbId: db.DrawMachine(seed, draw+i, available),
+		}
+		game.DrawPool.String = strings.Join(available, ",")
+		game.DrawPool.Valid = true
+		for position, player := range group {
+			game.Players = append(game.Players, db.TournamentGamePlayer{
+				UserId:   player,
+				Position: position + 1,
+			})
+		}
+		assigned[game.OpdbId] = true
+		games = append(games, game)
+	}
+	return games
+}
+
+// StartRound groups the players for the next round and assigns each group a
+// machine from the active lineup. The first round closes check-in and a round
+// can only start once every game of the previous one was recorded.
+func StartRound(tournament db.Tournament) error {
+	if tournament.Format == db.TournamentFormatQualifying {
+		return ErrNoRounds
+	}
+	if tournament.State == db.TournamentStateCompleted {
+		return ErrTournamentCompleted
+	}
+	games := db.GetTournamentGames(tournament.Id)
+	round := GetRounds(games)
+	if !IsRoundComplete(games, round) {
+		return ErrRoundInProgress
+	}
+	if tournament.Rounds.Valid && int64(round) >= tournament.Rounds.Int64 {
+		return ErrTournamentCompleted
+	}
+	players := db.GetTournamentPlayers(tournament.Id)
+	standings := GetStandings(tournament, players, games)
+	if len(GetActivePlayers(standings)) < 2 {
+		return ErrNotEnoughPlayers
+	}
+	pool := db.GetSelectableMachines()
+	if len(pool) == 0 {
+		return ErrNoMachines
+	}
+
+	var groups [][]int
+	switch tournament.Format {
+	case db.TournamentFormatSwiss:
+		groups = getSwissGroups(tournament, standings, games, round+1)
+	case db.TournamentFormatBracket:
+		if round == 0 {
+			var seeded bool
+			if players, seeded = seedBracket(tournament, players); !seeded {
+				return ErrRoundNotCreated
+			}
+		}
+		groups = getBracketGroups(players, games, round+1)
+	case db.TournamentFormatMatchPlay:
+		groups = getMatchPlayGroups(tournament, standings, games, round+1)
+	default:
+		groups = getStrikeGroups(tournament, standings, games, round+1)
+	}
+	played := getPlayedMachines(games, round+1)
+	if !db.CreateTournamentRound(tournament.Id, round+1, AssignMachines(tournament.Seed, len(games)+1, groups, pool, played)) {
+		return ErrRoundNotCreated
+	}
+	if tournament.State == db.TournamentStateCheckIn {
+		db.UpdateTournamentState(tournament.Id, db.TournamentStateRunning)
+	}
+	return nil
+}
+
+// getStrikeGroups groups the players still in with players on the same number
+// of strikes.
+func getStrikeGroups(tournament db.Tournament, standings []Standing, games []db.TournamentGame, round int) [][]int {
+	ordered := Shuffle(GetActivePlayers(standings), tournament.Seed, round)
+	sizes := GetGroupSizes(len(ordered), tournament.GroupSize)
+	players := 0
+	for _, size := range sizes {
+		players += size
+	}
+	if players < len(ordered) {
+		ordered = assignByes(ordered, len(ordered)-players)
+	}
+	var order []int
+	for _, standing := range ordered {
+		order = append(order, standing.UserId)
+	}
+	return MakeGroups(order, sizes, GetMeetings(games))
+}
+
+// getSwissGroups pairs every player with a player on the same score.
+func getSwissGroups(tournament db.Tournament, standings []Standing, games []db.TournamentGame, round int) [][]int {
+	var swiss []SwissStanding
+	for _, standing := range standings {
+		swiss = append(swiss, SwissStanding{
+			Id:    standing.UserId,
+			Byes:  standing.Byes,
+			Score: standing.Score,
+		})
+	}
+	pairs, _ := PairSwiss(swiss, GetMeetings(games), tournament.Seed, round)
+	var groups [][]int
+	for _, pair := range pairs {
+		groups = append(groups, []int{pair[0], pair[1]})
+	}
+	return groups
+}
+
+// assignByes leaves players out of a round, choosing from the players with the
+// fewest byes so far those with the most strikes.
+func assignByes(ordered []Standing, byes int) []Standing {
+	for ; byes > 0; byes-- {
+		bye := len(ordered) - 1
+		for i := len(ordered) - 1; i >= 0; i-- {
+			if ordered[i].Byes < ordered[bye].Byes {
+				bye = i
+			}
+		}
+		ordered = append(ordered[:bye:bye], ordered[bye+1:]...)
+	}
+	return ordered
+}
+
+// RecordGame records a game from the user IDs of its players in finishing
+// order. Players of the game and staff can record it; the tournament is
+// completed once a round ends with a single player left or its last round
+// ends.
+func RecordGame(tournament db.Tournament, gameId int, user db.User, finish []int) error {
+	games := db.GetTournamentGames(tournament.Id)
+	var game *db.TournamentGame
+	for i := range games {
+		if games[i].Id == gameId {
+			game = &games[i]
+		}
+	}
+	if game == nil {
+		return ErrGameNotFound
+	}
+	if game.CompletedAt.Valid {
+		return ErrGameRecorded
+	}
+
+	isPlayer := false
+	inGame := map[int]bool{}
+	for _, player := range game.Players {
+		inGame[player.UserId] = true
+		isPlayer = isPlayer || player.UserId == user.Id
+	}
+	if !isPlayer && !user.HasRole(db.RoleStaff) {
+		return ErrNotGamePlayer
+	}
+	if len(finish) != len(game.Players) {
+		return ErrInvalidPlaces
+	}
+	places := map[int]int{}
+	for i, userId := range finish {
+		if _, exists := places[userId]; exists || !inGame[userId] {
+			return ErrInvalidPlaces
+		}
+		places[userId] = i + 1
+	}
+	if !db.RecordTournamentGame(game.Id, places) {
+		return ErrGameRecorded
+	}
+
+	games = db.GetTournamentGames(tournament.Id)
+	round := GetRounds(games)
+	if !IsRoundComplete(games, round) {
+		return nil
+	}
+	standings := GetStandings(tournament, db.GetTournamentPlayers(tournament.Id), games)
+	if len(GetActivePlayers(standings)) < 2 || (tournament.Rounds.Valid && int64(round) >= tournament.Rounds.Int64) {
+		db.UpdateTournamentState(tournament.Id, db.TournamentStateCompleted)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package utils
+
+// EditDistance returns the Levenshtein distance between two strings,
+// counting insertions, deletions and substitutions of runes.
+func EditDistance(a string, b string) int {
+	source := []rune(a)
+	target := []rune(b)
+	previous := make([]int, len(target)+1)
+	current := make([]int, len(target)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(source); i++ {
+		current[0] = i
+		for j := 1; j <= len(target); j++ {
+			cost := 1
+			if source[i-1] == target[j-1] {
+				cost = 0
+			}
+			current[j] = previous[j] + 1
+			if insertion := current[j-1] + 1; insertion < current[j] {
+				current[j] = insertion
+			}
+			if substitution := previous[j-1] + cost; substitution < current[j] {
+				current[j] = substitution
+			}
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(target)]
+}